@@ -0,0 +1,81 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownAccount is returned by [AccountManager.APIClientFor] if no account
+// has the given label.
+var ErrUnknownAccount = errors.New("butterflymx: unknown account")
+
+// AccountManager holds the API token sources of several ButterflyMX resident
+// accounts, such as those of multiple rental units, keyed by a label chosen
+// by the caller. It hands out one [APIClient] per account, all configured with
+// the same options.
+//
+// An AccountManager is safe for concurrent use.
+type AccountManager struct {
+	opts APIClientOpts
+
+	mu      sync.Mutex
+	clients map[string]*APIClient
+}
+
+// NewAccountManager creates an AccountManager whose clients are created with
+// opts. The logger of each client has an "account" attribute holding the
+// account's label. Options such as [APIClientOpts.RequestBudget] are shared by
+// every client.
+func NewAccountManager(opts *APIClientOpts) *AccountManager {
+	opts = use(opts, &APIClientOpts{})
+	return &AccountManager{
+		opts:    *opts,
+		clients: make(map[string]*APIClient),
+	}
+}
+
+// Add adds the account with the given label, replacing any account with the
+// same label. Clients previously returned for that label keep using the old
+// token source.
+func (m *AccountManager) Add(label string, tokenSource APITokenSource) {
+	opts := m.opts
+	client := NewAPIClient(tokenSource, &opts)
+	client.opts.Logger = client.opts.Logger.With("account", label)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[label] = client
+}
+
+// Remove removes the account with the given label, if any.
+func (m *AccountManager) Remove(label string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, label)
+}
+
+// Labels returns the labels of all accounts in sorted order.
+func (m *AccountManager) Labels() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Sorted(maps.Keys(m.clients))
+}
+
+// APIClientFor returns the client of the account with the given label. The
+// same client is returned every time until the account is replaced. If there
+// is no such account, it returns an error wrapping [ErrUnknownAccount].
+func (m *AccountManager) APIClientFor(label string) (*APIClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client, ok := m.clients[label]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAccount, label)
+	}
+	return client, nil
+}
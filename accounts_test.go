@@ -0,0 +1,48 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestAccountManager(t *testing.T) {
+	expectToken := func(token string) httpmock.RoundTrip {
+		return httpmock.RoundTrip{
+			RequestCheck: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, "Bearer "+token, req.Header.Get("Authorization"))
+			},
+			Response: httpmock.RoundTripResponse{Body: []byte(`{}`)},
+		}
+	}
+	manager := NewAccountManager(&APIClientOpts{
+		HTTPClient: &http.Client{Transport: httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			expectToken("token-a"),
+			expectToken("token-b"),
+		})},
+		Logger: slogt.New(t),
+	})
+	manager.Add("unit-b", APIStaticToken("token-b"))
+	manager.Add("unit-a", APIStaticToken("token-a"))
+
+	assert.Equal(t, []string{"unit-a", "unit-b"}, manager.Labels())
+
+	a, err := manager.APIClientFor("unit-a")
+	assert.NoError(t, err)
+	b, err := manager.APIClientFor("unit-b")
+	assert.NoError(t, err)
+
+	again, err := manager.APIClientFor("unit-a")
+	assert.NoError(t, err)
+	assert.True(t, a == again, "the same client should be returned")
+
+	assert.NoError(t, a.UnlockDoor(t.Context(), 1, 100))
+	assert.NoError(t, b.UnlockDoor(t.Context(), 2, 200))
+
+	manager.Remove("unit-a")
+	_, err = manager.APIClientFor("unit-a")
+	assert.IsError(t, err, ErrUnknownAccount)
+}
@@ -0,0 +1,91 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AccessPointAliases maps user-defined short names, such as "garage", to
+// access point IDs, so that voice assistants and CLIs can keep using the same
+// names even if the building renames its doors. Names are matched
+// case-insensitively; see [AccessPointAliases.Set].
+//
+// Aliases can be kept alongside the account's tokens using
+// [LoadAccessPointAliases] and [AccessPointAliases.Save].
+type AccessPointAliases map[string]TaggedID
+
+// normalizeAlias returns the form of name that aliases are keyed by.
+func normalizeAlias(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Set makes name an alias of the access point with the given ID, replacing
+// any existing alias of the same name.
+func (a AccessPointAliases) Set(name string, accessPointID TaggedID) {
+	a[normalizeAlias(name)] = accessPointID
+}
+
+// Delete removes the alias of the given name, if any.
+func (a AccessPointAliases) Delete(name string) {
+	delete(a, normalizeAlias(name))
+}
+
+// Lookup returns the ID of the access point aliased by name.
+func (a AccessPointAliases) Lookup(name string) (TaggedID, bool) {
+	id, ok := a[normalizeAlias(name)]
+	return id, ok
+}
+
+// Resolve finds the access point called name among accessPoints, such as
+// those returned by [APIClient.TenantAccessPoints]. Aliases take precedence;
+// otherwise, the access point's own name is matched case-insensitively.
+func (a AccessPointAliases) Resolve(name string, accessPoints []AccessPoint) (AccessPoint, bool) {
+	if id, ok := a.Lookup(name); ok {
+		for _, accessPoint := range accessPoints {
+			if accessPoint.ID == id {
+				return accessPoint, true
+			}
+		}
+	}
+	for _, accessPoint := range accessPoints {
+		if normalizeAlias(accessPoint.Name) == normalizeAlias(name) {
+			return accessPoint, true
+		}
+	}
+	return AccessPoint{}, false
+}
+
+// LoadAccessPointAliases loads the aliases saved in store by
+// [AccessPointAliases.Save]. If none were saved, it returns an empty map.
+func LoadAccessPointAliases(ctx context.Context, store TokenStore) (AccessPointAliases, error) {
+	b, err := store.Load(ctx, AccessPointAliasesStoreKey)
+	if err != nil {
+		if errors.Is(err, ErrTokenNotFound) {
+			return AccessPointAliases{}, nil
+		}
+		return nil, err
+	}
+
+	aliases := AccessPointAliases{}
+	if err := json.Unmarshal(b, &aliases); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stored access point aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+// Save stores the aliases in store under [AccessPointAliasesStoreKey].
+func (a AccessPointAliases) Save(ctx context.Context, store TokenStore) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access point aliases: %w", err)
+	}
+	if err := store.Save(ctx, AccessPointAliasesStoreKey, b); err != nil {
+		return fmt.Errorf("failed to save access point aliases: %w", err)
+	}
+	return nil
+}
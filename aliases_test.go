@@ -0,0 +1,46 @@
+package butterflymx
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAccessPointAliases(t *testing.T) {
+	accessPoints := []AccessPoint{
+		{ID: AccessPointTaggedID(400), Name: "Parking Garage Gate"},
+		{ID: AccessPointTaggedID(401), Name: "Front Door"},
+	}
+
+	aliases := AccessPointAliases{}
+	aliases.Set(" Garage ", AccessPointTaggedID(400))
+
+	accessPoint, ok := aliases.Resolve("GARAGE", accessPoints)
+	assert.True(t, ok)
+	assert.Equal(t, AccessPointTaggedID(400), accessPoint.ID)
+
+	accessPoint, ok = aliases.Resolve("front door", accessPoints)
+	assert.True(t, ok, "access points should be found by their own name")
+	assert.Equal(t, AccessPointTaggedID(401), accessPoint.ID)
+
+	_, ok = aliases.Resolve("lobby", accessPoints)
+	assert.False(t, ok)
+
+	store := &MemoryTokenStore{}
+	loaded, err := LoadAccessPointAliases(t.Context(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(loaded))
+
+	assert.NoError(t, aliases.Save(t.Context(), store))
+	loaded, err = LoadAccessPointAliases(t.Context(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, aliases, loaded)
+
+	stored, err := store.Load(t.Context(), AccessPointAliasesStoreKey)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"garage":"prod-access_point-400"}`, string(stored))
+
+	loaded.Delete("garage")
+	_, ok = loaded.Lookup("garage")
+	assert.False(t, ok)
+}
@@ -5,30 +5,42 @@ package butterflymx
 import (
 	"bytes"
 	"context"
+	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
 	"log/slog"
+	"math/rand/v2"
+	"mime"
 	"net/http"
 	"net/url"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v5"
+	"golang.org/x/sync/singleflight"
+	"libdb.so/go-butterflymx/ptr"
 )
 
-// API URL constants.
+// API URL constants. These are the defaults of [APIClientOpts.APIBaseURL].
 const (
 	APIBaseURL             = "https://api.butterflymx.com"
-	DenizenGraphQLEndpoint = APIBaseURL + "/denizen/v1/graphql"
+	DenizenGraphQLEndpoint = APIBaseURL + denizenGraphQLPath
+
+	denizenGraphQLPath = "/denizen/v1/graphql"
 )
 
-// Unlock API URL constants.
+// Unlock API URL constants. These are the defaults of
+// [APIClientOpts.UnlockAPIBaseURL].
 const (
 	UnlockAPIBaseURL          = "https://api.unlock.prod.butterflymx.com"
-	UnlockAccessPointEndpoint = UnlockAPIBaseURL + "/v1/access-point"
+	UnlockAccessPointEndpoint = UnlockAPIBaseURL + unlockAccessPointPath
+
+	unlockAccessPointPath = "/v1/access-point"
 )
 
 // DefaultUserAgent is the User-Agent header value used by the API client. You
@@ -42,6 +54,19 @@ var DefaultRequestRetryOpts = []backoff.RetryOption{
 	backoff.WithMaxTries(5),
 }
 
+// DefaultMaxResponseSize is the default maximum size of an API response body
+// in bytes. Responses larger than this fail instead of being read into memory.
+var DefaultMaxResponseSize int64 = 32 << 20 // 32 MiB
+
+// DefaultMaxPages is the default maximum number of pages fetched by a single
+// paginated call. It is high enough to never be reached by real accounts.
+var DefaultMaxPages = 10000
+
+// DefaultGraphQLBatchSize is the default maximum number of GraphQL operations
+// sent in a single batched request. The server's complexity limit isn't
+// documented, so it is kept conservative.
+var DefaultGraphQLBatchSize = 20
+
 // DefaultRequestBackoff is the default backoff configuration for retrying API
 // requests.
 var DefaultRequestBackoff = func() backoff.BackOff {
@@ -53,19 +78,121 @@ var DefaultRequestBackoff = func() backoff.BackOff {
 	}
 }
 
+// Client is the set of API calls implemented by [APIClient]. Code that only
+// needs to make API calls should depend on Client rather than [APIClient], so
+// that the client can be replaced with a fake or wrapped with decorators such
+// as caching or metrics.
+type Client interface {
+	// Tenants is [APIClient.Tenants].
+	Tenants(ctx context.Context, opts ...CallOption) iter.Seq2[Tenant, error]
+	// TenantAccessPoints is [APIClient.TenantAccessPoints].
+	TenantAccessPoints(ctx context.Context, tenantID TaggedID, opts ...CallOption) iter.Seq2[AccessPoint, error]
+	// AccessPointsOfTenants is [APIClient.AccessPointsOfTenants].
+	AccessPointsOfTenants(ctx context.Context, tenantIDs []TaggedID, opts ...CallOption) (map[TaggedID][]AccessPoint, error)
+	// UnlockDoor is [APIClient.UnlockDoor].
+	UnlockDoor(ctx context.Context, tenantID ID, accessPointID ID, opts ...CallOption) error
+	// Keychains is [APIClient.Keychains].
+	Keychains(ctx context.Context, tenantID ID, status AccessCodeStatus, opts ...CallOption) (*ResultsWithReferences[Keychain], error)
+	// KeychainPages is [APIClient.KeychainPages].
+	KeychainPages(ctx context.Context, tenantID ID, status AccessCodeStatus, opts ...CallOption) iter.Seq2[*ResultsWithReferences[Keychain], error]
+	// Keychain is [APIClient.Keychain].
+	Keychain(ctx context.Context, keychainID ID, opts ...CallOption) (*ResultWithReferences[Keychain], error)
+	// CreateCustomKeychain is [APIClient.CreateCustomKeychain].
+	CreateCustomKeychain(ctx context.Context, tenantID ID, accessPointIDs []ID, args CustomKeychainArgs, opts ...CallOption) (*ResultWithReferences[Keychain], error)
+	// CreateVirtualKeys is [APIClient.CreateVirtualKeys].
+	CreateVirtualKeys(ctx context.Context, keychainID ID, virtualKeyArgs VirtualKeyArgs, opts ...CallOption) (*ResultsWithReferences[VirtualKey], error)
+	// RevokeVirtualKey is [APIClient.RevokeVirtualKey].
+	RevokeVirtualKey(ctx context.Context, keychainID, virtualKeyID ID, opts ...CallOption) error
+	// Ping is [APIClient.Ping].
+	Ping(ctx context.Context, opts ...CallOption) (PingResult, error)
+	// ValidateToken is [APIClient.ValidateToken].
+	ValidateToken(ctx context.Context, opts ...CallOption) error
+	// PingUnlock is [APIClient.PingUnlock].
+	PingUnlock(ctx context.Context, opts ...CallOption) (PingResult, error)
+	// ProbeAccessPoint is [APIClient.ProbeAccessPoint].
+	ProbeAccessPoint(ctx context.Context, tenantID TaggedID, accessPointID ID, opts ...CallOption) (AccessPointProbe, error)
+	// DownloadMedia is [APIClient.DownloadMedia].
+	DownloadMedia(ctx context.Context, rawURL string, w io.Writer, opts ...CallOption) (MediaInfo, error)
+	// ExportKeychains is [APIClient.ExportKeychains].
+	ExportKeychains(ctx context.Context, tenantID ID, w io.Writer, opts ...CallOption) error
+	// ImportKeychains is [APIClient.ImportKeychains].
+	ImportKeychains(ctx context.Context, tenantID ID, r io.Reader, opts ...CallOption) (*KeychainImportResult, error)
+}
+
+var _ Client = (*APIClient)(nil)
+
 // APIClient is a client for interacting with the main ButterflyMX API.
+//
+// An APIClient is safe for concurrent use by multiple goroutines, provided
+// that its [APITokenSource] is. The iterators returned by its methods may also
+// be ranged over concurrently, since each range statement performs its own
+// requests.
+//
+// Identical GET requests made concurrently with the same options, such as
+// multiple goroutines fetching the same keychain, are coalesced into a single
+// HTTP request whose response is shared. See
+// [APIClientOpts.DisableCoalescing].
 type APIClient struct {
 	tokenSource APITokenSource
 	opts        APIClientOpts
+	flight      singleflight.Group
 }
 
 // APIClientOpts holds optional parameters for configuring the API client.
 type APIClientOpts struct {
+	// HTTPClient defaults to [http.DefaultClient]. Use [NewHTTPClient] to
+	// tune its connection pool.
 	HTTPClient       *http.Client
 	Logger           *slog.Logger
 	UserAgent        string
 	RequestRetryOpts []backoff.RetryOption // appends to [DefaultRequestRetryOpts]
 	RequestBackoff   func() backoff.BackOff
+	MaxResponseSize  int64 // defaults to [DefaultMaxResponseSize], negative for no limit
+	// DisableCoalescing disables sharing a single in-flight GET request
+	// between concurrent identical calls.
+	DisableCoalescing bool
+	// RequestLogSampleRate is the fraction of HTTP requests, between 0 and 1,
+	// that are logged at Info level along with their status, sizes and
+	// latency. It defaults to 0, which disables request logging.
+	RequestLogSampleRate float64
+	// Locale is the BCP 47 language tag of the user, e.g. "es" or "fr-CA".
+	// If set, it is sent as the Accept-Language header of every request, so
+	// that error messages and other localized content are in that language.
+	Locale string
+	// GraphQLBatching makes methods that issue several independent GraphQL
+	// operations, such as [APIClient.AccessPointsOfTenants], send them in a
+	// single HTTP request as a JSON array. Not every deployment of the
+	// Denizen endpoint is known to accept batches, so it is disabled by
+	// default, in which case the operations are sent one request at a time.
+	GraphQLBatching bool
+	// GraphQLBatchSize is the maximum number of operations sent in a single
+	// batched request. Larger batches are split into chunks of this size, so
+	// that each request stays below the server's query complexity limit. It
+	// defaults to [DefaultGraphQLBatchSize]; negative values are treated as 1.
+	GraphQLBatchSize int
+	// RequestBudget, if set, counts the requests made by the client per
+	// tenant. See [RequestBudget].
+	RequestBudget *RequestBudget
+	// MaxPages is the maximum number of pages a single paginated call may
+	// fetch before failing with [ErrTooManyPages], as a safety net against
+	// servers that never stop paginating. It defaults to [DefaultMaxPages],
+	// negative for no limit.
+	MaxPages int
+	// DefaultTenant is the numeric ID of the tenant that methods taking a
+	// tenant fall back to if they are given 0 and the context has no tenant.
+	// See [ContextWithTenant].
+	DefaultTenant ID
+	// UnlockLatency, if set, records the latency of every successful
+	// [APIClient.UnlockDoor] call. See [UnlockLatencyTracker].
+	UnlockLatency *UnlockLatencyTracker
+	// APIBaseURL is the base URL of the Rails REST API and the Denizen
+	// GraphQL endpoint, without a trailing slash. It defaults to
+	// [APIBaseURL]. Set it to target another region, a staging environment
+	// or a local mock server.
+	APIBaseURL string
+	// UnlockAPIBaseURL is the base URL of the Unlock API, without a trailing
+	// slash. It defaults to [UnlockAPIBaseURL].
+	UnlockAPIBaseURL string
 }
 
 // NewAPIClient creates a new API client.
@@ -75,6 +202,11 @@ func NewAPIClient(tokenSource APITokenSource, opts *APIClientOpts) *APIClient {
 	opts.HTTPClient = use(opts.HTTPClient, http.DefaultClient)
 	opts.Logger = use(opts.Logger, slog.Default())
 	opts.UserAgent = use(opts.UserAgent, DefaultUserAgent)
+	opts.MaxResponseSize = use(opts.MaxResponseSize, DefaultMaxResponseSize)
+	opts.GraphQLBatchSize = max(use(opts.GraphQLBatchSize, DefaultGraphQLBatchSize), 1)
+	opts.MaxPages = use(opts.MaxPages, DefaultMaxPages)
+	opts.APIBaseURL = strings.TrimSuffix(use(opts.APIBaseURL, APIBaseURL), "/")
+	opts.UnlockAPIBaseURL = strings.TrimSuffix(use(opts.UnlockAPIBaseURL, UnlockAPIBaseURL), "/")
 	opts.RequestRetryOpts = slices.Concat(DefaultRequestRetryOpts, opts.RequestRetryOpts)
 	if opts.RequestBackoff == nil {
 		opts.RequestBackoff = DefaultRequestBackoff
@@ -94,30 +226,26 @@ func use[T comparable](v, otherwise T) T {
 	return otherwise
 }
 
-// CollectResults collects all results from the given iterator into a slice,
-// returning an error if any occurred during iteration.
-func CollectResults[T any](seq iter.Seq2[T, error]) ([]T, error) {
-	var results []T
-	for v, err := range seq {
-		if err != nil {
-			return results, err
-		}
-		results = append(results, v)
-	}
-	return results, nil
-}
-
 // Tenants retrieves a list of tenants associated with the current user.
 // It calls the POST /denizen/v1/graphql endpoint with the "Tenants" operation.
-// This method automatically handles pagination and returns an iterator.
-func (c *APIClient) Tenants(ctx context.Context) iter.Seq2[Tenant, error] {
+// This method automatically handles pagination and returns an iterator. Use
+// [WithCursor] to resume an interrupted enumeration.
+func (c *APIClient) Tenants(ctx context.Context, opts ...CallOption) iter.Seq2[Tenant, error] {
+	call := newCallOptions(opts)
 	return func(yield func(Tenant, error) bool) {
-		var after *string
-		for {
-			variables := map[string]any{"after": after}
+		var fetched int
+		after := call.startCursor()
+		guard := c.newPageGuard(after)
+		for page := 1; ; page++ {
+			if err := guard.checkPage(page); err != nil {
+				yield(Tenant{}, &PaginationError{Page: page, Cursor: ptr.ValueOrZero(after), Fetched: fetched, Err: err})
+				return
+			}
+
+			variables := map[string]any{"after": after, "first": call.first()}
 			var resp tenantsGraphQLResponse
-			if err := c.doDenizenGraphQL(ctx, "Tenants", tenantsQuery, variables, &resp); err != nil {
-				yield(Tenant{}, err)
+			if err := c.doDenizenGraphQL(ctx, call, "Tenants", tenantsQuery, variables, &resp); err != nil {
+				yield(Tenant{}, &PaginationError{Page: page, Cursor: ptr.ValueOrZero(after), Fetched: fetched, Err: err})
 				return
 			}
 
@@ -126,36 +254,66 @@ func (c *APIClient) Tenants(ctx context.Context) iter.Seq2[Tenant, error] {
 					return
 				}
 			}
+			fetched += len(resp.Data.Tenants.Nodes)
+			call.reportProgress(Progress{Pages: page, Items: fetched})
+			call.saveCursor(resp.Data.Tenants.PageInfo.EndCursor)
 
 			if !resp.Data.Tenants.PageInfo.HasNextPage {
 				return
 			}
 			after = &resp.Data.Tenants.PageInfo.EndCursor
+			if err := guard.checkCursor(*after); err != nil {
+				yield(Tenant{}, &PaginationError{Page: page + 1, Cursor: *after, Fetched: fetched, Err: err})
+				return
+			}
 		}
 	}
 }
 
 // TenantAccessPoints retrieves a list of access points (doors) for a given tenant.
 // It calls the POST /denizen/v1/graphql endpoint with the "TenantAccessPoints" operation.
-// This method automatically handles pagination and returns an iterator.
-func (c *APIClient) TenantAccessPoints(ctx context.Context, tenantID TaggedID) iter.Seq2[AccessPoint, error] {
+// This method automatically handles pagination and returns an iterator. Use
+// [WithCursor] to resume an interrupted enumeration. tenantID must be of type
+// [TaggedTypeTenant], e.g. created using [TenantTaggedID].
+func (c *APIClient) TenantAccessPoints(ctx context.Context, tenantID TaggedID, opts ...CallOption) iter.Seq2[AccessPoint, error] {
+	if tenantID == (TaggedID{}) {
+		if id := c.tenantOr(ctx, 0); id != 0 {
+			tenantID = TenantTaggedID(id)
+		}
+	}
+
+	call := newCallOptions(opts)
+	call.tenant = tenantID.Number
 	return func(yield func(AccessPoint, error) bool) {
-		var after *string
-		for {
+		if _, err := tenantID.AsType(TaggedTypeTenant); err != nil {
+			yield(AccessPoint{}, err)
+			return
+		}
+
+		var fetched int
+		after := call.startCursor()
+		guard := c.newPageGuard(after)
+		for page := 1; ; page++ {
+			if err := guard.checkPage(page); err != nil {
+				yield(AccessPoint{}, &PaginationError{Page: page, Cursor: ptr.ValueOrZero(after), Fetched: fetched, Err: err})
+				return
+			}
+
 			variables := map[string]any{
 				"ids":   []TaggedID{tenantID},
 				"after": after,
+				"first": call.first(),
 			}
 			var resp tenantAccessPointsGraphQLResponse
-			if err := c.doDenizenGraphQL(ctx, "TenantAccessPoints", tenantAccessPointsQuery, variables, &resp); err != nil {
-				yield(AccessPoint{}, err)
+			if err := c.doDenizenGraphQL(ctx, call, "TenantAccessPoints", tenantAccessPointsQuery, variables, &resp); err != nil {
+				yield(AccessPoint{}, &PaginationError{Page: page, Cursor: ptr.ValueOrZero(after), Fetched: fetched, Err: err})
 				return
 			}
 			if len(resp.Data.Nodes) == 0 {
 				return
 			}
 			if len(resp.Data.Nodes) > 1 {
-				yield(AccessPoint{}, fmt.Errorf("more than 1 tenant returned"))
+				yield(AccessPoint{}, &PaginationError{Page: page, Cursor: ptr.ValueOrZero(after), Fetched: fetched, Err: errors.New("more than 1 tenant returned")})
 				return
 			}
 
@@ -165,43 +323,164 @@ func (c *APIClient) TenantAccessPoints(ctx context.Context, tenantID TaggedID) i
 					return
 				}
 			}
+			fetched += len(accessPoints.Nodes)
+			call.reportProgress(Progress{Pages: page, Items: fetched})
+			call.saveCursor(accessPoints.PageInfo.EndCursor)
 
 			if !accessPoints.PageInfo.HasNextPage {
 				return
 			}
 			after = &accessPoints.PageInfo.EndCursor
+			if err := guard.checkCursor(*after); err != nil {
+				yield(AccessPoint{}, &PaginationError{Page: page + 1, Cursor: *after, Fetched: fetched, Err: err})
+				return
+			}
 		}
 	}
 }
 
+// AccessPointsOfTenants retrieves the access points of each of the given
+// tenants, like calling [APIClient.TenantAccessPoints] for each of them. The
+// first page of every tenant is fetched using a single batched GraphQL request
+// if [APIClientOpts.GraphQLBatching] is enabled, so warming up a session costs
+// one round trip instead of one per tenant. Further pages, if any, are fetched
+// separately. [WithCursor] is not supported.
+//
+// Large batches are split into chunks of [APIClientOpts.GraphQLBatchSize]
+// tenants. If some chunks fail, the error joins a [*BatchChunkError] for each
+// of them, and with [WithPartialResults], the access points of the tenants in
+// the other chunks are returned along with it. Likewise, with
+// [WithPartialResults], a tenant whose further pages fail is left out and
+// reported as a [*BatchChunkError] of its own instead of failing the call.
+func (c *APIClient) AccessPointsOfTenants(ctx context.Context, tenantIDs []TaggedID, opts ...CallOption) (map[TaggedID][]AccessPoint, error) {
+	call := newCallOptions(opts)
+
+	ops := make([]graphQLOperation, len(tenantIDs))
+	resps := make([]tenantAccessPointsGraphQLResponse, len(tenantIDs))
+	for i, tenantID := range tenantIDs {
+		if _, err := tenantID.AsType(TaggedTypeTenant); err != nil {
+			return nil, err
+		}
+		ops[i] = graphQLOperation{
+			OperationName: "TenantAccessPoints",
+			Query:         tenantAccessPointsQuery,
+			Variables:     map[string]any{"ids": []TaggedID{tenantID}, "after": nil, "first": call.first()},
+			result:        &resps[i],
+		}
+	}
+
+	batchErr := c.doDenizenGraphQLBatch(ctx, call, ops)
+	if batchErr != nil && !call.partial {
+		return nil, batchErr
+	}
+
+	errs := []error{batchErr}
+	accessPoints := make(map[TaggedID][]AccessPoint, len(tenantIDs))
+	for i, tenantID := range tenantIDs {
+		if ops[i].failed {
+			continue
+		}
+		aps, err := c.remainingAccessPoints(ctx, tenantID, resps[i], opts)
+		if err != nil {
+			if !call.partial {
+				return nil, err
+			}
+			errs = append(errs, &BatchChunkError{Start: i, End: i + 1, Err: err})
+			continue
+		}
+		accessPoints[tenantID] = aps
+	}
+
+	return accessPoints, errors.Join(errs...)
+}
+
+// remainingAccessPoints returns the access points of the tenant in resp, the
+// response for its first page, along with those of all following pages.
+func (c *APIClient) remainingAccessPoints(ctx context.Context, tenantID TaggedID, resp tenantAccessPointsGraphQLResponse, opts []CallOption) ([]AccessPoint, error) {
+	if len(resp.Data.Nodes) > 1 {
+		return nil, fmt.Errorf("tenant %s: %w", tenantID, &PaginationError{Page: 1, Err: errors.New("more than 1 tenant returned")})
+	}
+	if len(resp.Data.Nodes) == 0 {
+		return nil, nil
+	}
+
+	page := resp.Data.Nodes[0].AccessPoints
+	accessPoints := page.Nodes
+	if !page.PageInfo.HasNextPage {
+		return accessPoints, nil
+	}
+
+	cursor := page.PageInfo.EndCursor
+	for ap, err := range c.TenantAccessPoints(ctx, tenantID, append(slices.Clip(opts), WithCursor(&cursor))...) {
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenantID, err)
+		}
+		accessPoints = append(accessPoints, ap)
+	}
+	return accessPoints, nil
+}
+
 // UnlockDoor sends a request to unlock a door (access point) for a given
 // tenant.
-func (c *APIClient) UnlockDoor(ctx context.Context, tenantID ID, accessPointID ID) error {
-	tenantTaggedID := NewTaggedID("tenant", tenantID)
-	accessPointTaggedID := NewTaggedID("access_point", accessPointID)
+func (c *APIClient) UnlockDoor(ctx context.Context, tenantID ID, accessPointID ID, opts ...CallOption) error {
+	tenantID = c.tenantOr(ctx, tenantID)
+	tenantTaggedID := TenantTaggedID(tenantID)
+	accessPointTaggedID := AccessPointTaggedID(accessPointID)
+
+	call := newCallOptions(opts)
+	call.tenant = tenantID
+
+	start := time.Now()
 
-	req, err := c.createRequest(ctx, http.MethodPost, UnlockAccessPointEndpoint, map[string]any{
+	var resp struct{}
+	err := c.doRequest(ctx, call, unlockProfile, http.MethodPost, c.opts.UnlockAPIBaseURL+unlockAccessPointPath, map[string]any{
 		"accessPointId": accessPointTaggedID,
 		"source":        "mobile_app",
 		"tenantId":      tenantTaggedID,
-	})
+	}, &resp)
 	if err != nil {
 		return err
 	}
 
-	var resp struct{}
-	if err := c.doJSONRequest(req, &resp); err != nil {
-		return err
+	if c.opts.UnlockLatency != nil {
+		c.opts.UnlockLatency.record(accessPointID, time.Since(start))
 	}
-
 	return nil
 }
 
 // Keychains retrieves a rich list of keychains, with all related entities
 // resolved into a convenient structure. It calls the GET /v3/access_codes REST
 // endpoint. This method automatically handles pagination and accumulates all
-// results before resolving relationships.
-func (c *APIClient) Keychains(ctx context.Context, tenantID ID, status AccessCodeStatus) (*ResultsWithReferences[Keychain], error) {
+// results before returning. Related entities shared across pages are only kept
+// once.
+//
+// To avoid holding all pages in memory at once, use [APIClient.KeychainPages]
+// instead.
+func (c *APIClient) Keychains(ctx context.Context, tenantID ID, status AccessCodeStatus, opts ...CallOption) (*ResultsWithReferences[Keychain], error) {
+	results := &ResultsWithReferences[Keychain]{
+		Data: []Keychain{},
+		Refs: map[ID]RawReference{},
+	}
+
+	for page, err := range c.KeychainPages(ctx, tenantID, status, opts...) {
+		if err != nil {
+			if newCallOptions(opts).partial {
+				return results, err
+			}
+			return nil, err
+		}
+		results.merge(page)
+	}
+
+	return results, nil
+}
+
+// KeychainPages is like [APIClient.Keychains], but yields the keychains one
+// page at a time. The references of each page only contain the entities
+// related to the keychains in that page, so they can be resolved and discarded
+// before the next page is fetched.
+func (c *APIClient) KeychainPages(ctx context.Context, tenantID ID, status AccessCodeStatus, opts ...CallOption) iter.Seq2[*ResultsWithReferences[Keychain], error] {
+	tenantID = c.tenantOr(ctx, tenantID)
 	type accessCodesResponse struct {
 		Data     []RawReference `json:"data"`
 		Included []RawReference `json:"included"`
@@ -210,31 +489,53 @@ func (c *APIClient) Keychains(ctx context.Context, tenantID ID, status AccessCod
 		} `json:"links"`
 	}
 
-	var allData []RawReference
-	var allIncluded []RawReference
+	call := newCallOptions(opts)
+	call.tenant = tenantID
+	return func(yield func(*ResultsWithReferences[Keychain], error) bool) {
+		var fetched int
+		guard := c.newPageGuard(nil)
+		hasNext := true
+		for page := 1; hasNext; page++ {
+			if err := guard.checkPage(page); err != nil {
+				yield(nil, &PaginationError{Page: page, Fetched: fetched, Err: err})
+				return
+			}
 
-	hasNext := true
-	for page := 1; hasNext; page++ {
-		path := "/v3/access_codes?" + url.Values{
-			"include":        {"virtual_keys.door_releases.panel,devices"},
-			"filter[tenant]": {fmt.Sprintf("%d", tenantID)},
-			"filter[status]": {string(status)},
-			"page[size]":     {"100"},
-			"page[number]":   {strconv.Itoa(page)},
-		}.Encode()
+			path := "/v3/access_codes?" + url.Values{
+				"include":        {call.includeOr("virtual_keys.door_releases.panel,devices")},
+				"filter[tenant]": {fmt.Sprintf("%d", tenantID)},
+				"filter[status]": {string(status)},
+				"page[size]":     {call.pageSizeOr(100)},
+				"page[number]":   {strconv.Itoa(page)},
+			}.Encode()
+
+			var resp accessCodesResponse
+			if err := c.getAPI(ctx, call, path, &resp); err != nil {
+				yield(nil, &PaginationError{Page: page, Fetched: fetched, Err: err})
+				return
+			}
 
-		var resp accessCodesResponse
-		if err := c.getAPI(ctx, path, &resp); err != nil {
-			return nil, err
-		}
+			results, err := unmarshalResultsWithReferences[Keychain](resp.Data, resp.Included)
+			if err != nil {
+				yield(nil, &PaginationError{Page: page, Fetched: fetched, Err: err})
+				return
+			}
 
-		allData = append(allData, resp.Data...)
-		allIncluded = append(allIncluded, resp.Included...)
+			if !yield(results, nil) {
+				return
+			}
+			fetched += len(results.Data)
+			call.reportProgress(Progress{Pages: page, Items: fetched})
 
-		hasNext = resp.Links.Next != nil
+			hasNext = resp.Links.Next != nil
+			if hasNext {
+				if err := guard.checkCursor(*resp.Links.Next); err != nil {
+					yield(nil, &PaginationError{Page: page + 1, Fetched: fetched, Err: err})
+					return
+				}
+			}
+		}
 	}
-
-	return unmarshalResultsWithReferences[Keychain](allData, allIncluded)
 }
 
 // Keychain retrieves a single keychain by its ID, along with all related
@@ -242,13 +543,16 @@ func (c *APIClient) Keychains(ctx context.Context, tenantID ID, status AccessCod
 // [VirtualKey]s associated with the keychain, so the Devices will be missing.
 //
 // It calls the GET /v3/keychains/{id} REST endpoint.
-func (c *APIClient) Keychain(ctx context.Context, keychainID ID) (*ResultWithReferences[Keychain], error) {
-	path := fmt.Sprintf("/v3/keychains/%d?include=virtual_keys.door_releases.panel", keychainID)
+func (c *APIClient) Keychain(ctx context.Context, keychainID ID, opts ...CallOption) (*ResultWithReferences[Keychain], error) {
+	call := newCallOptions(opts)
+	path := fmt.Sprintf("/v3/keychains/%d?", keychainID) + url.Values{
+		"include": {call.includeOr("virtual_keys.door_releases.panel")},
+	}.Encode()
 	var resp struct {
 		Data     RawReference   `json:"data"`
 		Included []RawReference `json:"included"`
 	}
-	if err := c.getAPI(ctx, path, &resp); err != nil {
+	if err := c.getAPI(ctx, call, path, &resp); err != nil {
 		return nil, err
 	}
 	return unmarshalResultWithReferences[Keychain](resp.Data, resp.Included)
@@ -259,9 +563,9 @@ type CustomKeychainArgs struct {
 	// Name is the name of the keychain.
 	Name string `json:"name"`
 	// StartsAt is the start time of the keychain.
-	StartsAt time.Time `json:"starts_at,format:'2006-01-02T15:04:05-0700'"`
+	StartsAt time.Time `json:"starts_at"`
 	// EndsAt is the end time of the keychain.
-	EndsAt time.Time `json:"ends_at,format:'2006-01-02T15:04:05-0700'"`
+	EndsAt time.Time `json:"ends_at"`
 	// AllowUnitAccess indicates whether unit access is allowed.
 	AllowUnitAccess bool `json:"allow_unit_access"`
 }
@@ -274,6 +578,7 @@ type CustomKeychainArgs struct {
 func (c *APIClient) CreateCustomKeychain(
 	ctx context.Context,
 	tenantID ID, accessPointIDs []ID, args CustomKeychainArgs,
+	opts ...CallOption,
 ) (*ResultWithReferences[Keychain], error) {
 	type RequestBody struct {
 		Data struct {
@@ -296,6 +601,8 @@ func (c *APIClient) CreateCustomKeychain(
 		} `json:"data"`
 	}
 
+	tenantID = c.tenantOr(ctx, tenantID)
+
 	var body RequestBody
 	body.Data.Type = "keychains"
 	body.Data.Attributes.Kind = "custom"
@@ -319,7 +626,13 @@ func (c *APIClient) CreateCustomKeychain(
 		Included []RawReference `json:"included"`
 	}
 
-	if err := c.doAPIWithBody(ctx, http.MethodPost, "/v3/keychains/custom", body, &resp); err != nil {
+	// The relationship IDs are sent as strings, as in the recorded request at
+	// testdata/api-post-v3-keychains-custom.json.
+	profile := railsProfile.withIDs(idAsString)
+
+	call := newCallOptions(opts)
+	call.tenant = tenantID
+	if err := c.doAPIWithBody(ctx, call, profile, http.MethodPost, "/v3/keychains/custom", body, &resp); err != nil {
 		return nil, err
 	}
 
@@ -358,6 +671,7 @@ func (c *APIClient) CreateVirtualKeys(
 	ctx context.Context,
 	keychainID ID,
 	virtualKeyArgs VirtualKeyArgs,
+	opts ...CallOption,
 ) (*ResultsWithReferences[VirtualKey], error) {
 	type RequestBody struct {
 		Data struct {
@@ -375,7 +689,7 @@ func (c *APIClient) CreateVirtualKeys(
 		Data     []RawReference `json:"data"`
 		Included []RawReference `json:"included"`
 	}
-	if err := c.doAPIWithBody(ctx, http.MethodPost, path, body, &resp); err != nil {
+	if err := c.doAPIWithBody(ctx, newCallOptions(opts), railsProfile, http.MethodPost, path, body, &resp); err != nil {
 		return nil, err
 	}
 
@@ -383,54 +697,399 @@ func (c *APIClient) CreateVirtualKeys(
 }
 
 // RevokeVirtualKey revokes a virtual key.
-func (c *APIClient) RevokeVirtualKey(ctx context.Context, keychainID, virtualKeyID ID) error {
+func (c *APIClient) RevokeVirtualKey(ctx context.Context, keychainID, virtualKeyID ID, opts ...CallOption) error {
 	path := fmt.Sprintf("/v3/keychains/%d/virtual_keys/%d", keychainID, virtualKeyID)
-	return c.doAPI(ctx, http.MethodDelete, path, nil)
+	return c.doAPI(ctx, newCallOptions(opts), http.MethodDelete, path, nil)
+}
+
+// PingResult is the result of [APIClient.Ping] and [APIClient.PingUnlock].
+type PingResult struct {
+	// Latency is the round-trip time of the last ping request.
+	Latency time.Duration
+	// Reachable is true if the API responded to the ping request.
+	Reachable bool
+	// TokenOK is true if the API accepted the API token. If the token was
+	// rejected, a renewed token is tried once before giving up.
+	TokenOK bool
+}
+
+// Ping performs the cheapest possible authenticated call against the API and
+// reports whether the API is reachable and the API token is accepted. Unlike
+// other methods, Ping does not retry on failure.
+//
+// A nil error is returned as long as the API responded, even if the token was
+// rejected; check [PingResult.TokenOK] for that.
+func (c *APIClient) Ping(ctx context.Context, opts ...CallOption) (PingResult, error) {
+	body := map[string]any{
+		"operationName": "Ping",
+		"variables":     map[string]any{},
+		"query":         pingQuery,
+	}
+	return c.ping(ctx, newCallOptions(opts), denizenProfile, http.MethodPost, c.opts.APIBaseURL+denizenGraphQLPath, body, func(status int) (bool, error) {
+		switch {
+		// Like other requests, expired tokens may be rejected with a 403
+		// rather than a 401.
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return false, nil
+		case status >= 200 && status < 300:
+			return true, nil
+		default:
+			return false, fmt.Errorf("unexpected ping response: status %d", status)
+		}
+	})
+}
+
+// ValidateToken checks that the API accepts the API token using
+// [APIClient.Ping], for health checks and setup wizards. If the token is
+// rejected, even after renewing it, it returns an [*AuthError] matching
+// [ErrTokenExpired] or [ErrTokenInvalid]. Other errors mean that the token
+// couldn't be checked.
+func (c *APIClient) ValidateToken(ctx context.Context, opts ...CallOption) error {
+	result, err := c.Ping(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	if result.TokenOK {
+		return nil
+	}
+
+	// The token source has the renewed token that was rejected last.
+	token, err := c.tokenSource.APIToken(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to get API token: %w", err)
+	}
+	return &AuthError{Failure: rejectedTokenFailure(token)}
+}
+
+// PingUnlock is like [APIClient.Ping], but checks the API token against the
+// Unlock API at [APIClientOpts.UnlockAPIBaseURL] instead. The Unlock API is a
+// different host with its own authentication, so a token that works for
+// everything else may still fail to unlock doors. Daemons can use PingUnlock
+// to detect that before someone is waiting at the door.
+//
+// The Unlock API has no endpoint for checking a token, and any POST to the
+// unlock endpoint risks releasing a door, so PingUnlock sends a GET to it
+// instead, which can't unlock anything. The token is considered accepted if
+// the request succeeds or is rejected with 405 Method Not Allowed after
+// authenticating it, and rejected on 401 Unauthorized. This has not been
+// verified against a recorded response, so every other status, including 403
+// Forbidden, is returned as an unexpected error rather than guessed at.
+func (c *APIClient) PingUnlock(ctx context.Context, opts ...CallOption) (PingResult, error) {
+	return c.ping(ctx, newCallOptions(opts), unlockProfile, http.MethodGet, c.opts.UnlockAPIBaseURL+unlockAccessPointPath, nil, func(status int) (bool, error) {
+		switch {
+		case status == http.StatusUnauthorized:
+			return false, nil
+		case status == http.StatusMethodNotAllowed:
+			return true, nil
+		case status >= 200 && status < 300:
+			return true, nil
+		default:
+			return false, fmt.Errorf("unexpected ping response: status %d", status)
+		}
+	})
+}
+
+// AccessPointProbe is the result of [APIClient.ProbeAccessPoint].
+type AccessPointProbe struct {
+	// Found is true if the access point is one of the tenant's.
+	Found bool
+	// AccessPoint is the access point as listed for the tenant, if found.
+	AccessPoint AccessPoint
+	// Unlock is the result of checking the API token against the Unlock API
+	// using [APIClient.PingUnlock].
+	Unlock PingResult
+}
+
+// Ready reports whether unlocking the access point is expected to work: the
+// tenant has access to it, it is online, and the Unlock API accepts the API
+// token.
+func (p AccessPointProbe) Ready() bool {
+	return p.Found && p.AccessPoint.Online && p.Unlock.TokenOK
+}
+
+// ProbeAccessPoint checks whether the access point with the given numeric ID
+// can be unlocked by the tenant, without releasing the door, so that
+// monitoring can verify unlock readiness safely. tenantID must be of type
+// [TaggedTypeTenant].
+//
+// The Unlock API has no way to validate an unlock without performing it, so
+// the probe combines the access point's online status, as listed by
+// [APIClient.TenantAccessPoints], with [APIClient.PingUnlock]. Neither sends a
+// POST to the unlock endpoint. Check [AccessPointProbe.Ready] for the outcome;
+// a nil error only means that both checks completed, and an unexpected
+// response from the Unlock API, such as 403 Forbidden, is returned as an
+// error.
+func (c *APIClient) ProbeAccessPoint(ctx context.Context, tenantID TaggedID, accessPointID ID, opts ...CallOption) (AccessPointProbe, error) {
+	return ProbeAccessPoint(ctx, c, tenantID, accessPointID, opts...)
+}
+
+// ProbeAccessPoint is like [APIClient.ProbeAccessPoint], but works with any
+// [Client].
+func ProbeAccessPoint(ctx context.Context, client Client, tenantID TaggedID, accessPointID ID, opts ...CallOption) (AccessPointProbe, error) {
+	var probe AccessPointProbe
+
+	for ap, err := range client.TenantAccessPoints(ctx, tenantID, opts...) {
+		if err != nil {
+			return probe, fmt.Errorf("failed to list access points: %w", err)
+		}
+		if ap.ID.Number == accessPointID {
+			probe.Found = true
+			probe.AccessPoint = ap
+			break
+		}
+	}
+
+	unlock, err := client.PingUnlock(ctx, opts...)
+	if err != nil {
+		return probe, fmt.Errorf("failed to check Unlock API: %w", err)
+	}
+	probe.Unlock = unlock
+
+	return probe, nil
+}
+
+// ping sends a request with the given method and body to rawURL once, or twice
+// with a renewed token if the first response is rejected by tokenOK. tokenOK
+// reports whether the token was accepted given the response status, or an
+// error if the status is unexpected.
+func (c *APIClient) ping(ctx context.Context, call callOptions, profile encodingProfile, method, rawURL string, body any, tokenOK func(status int) (bool, error)) (PingResult, error) {
+	ctx, cancel := call.context(ctx)
+	defer cancel()
+
+	var result PingResult
+
+	for _, renew := range []bool{false, true} {
+		token, err := c.tokenSource.APIToken(ctx, renew)
+		if err != nil {
+			return result, fmt.Errorf("failed to get API token: %w", err)
+		}
+
+		req, err := c.createRequest(ctx, profile, method, rawURL, body)
+		if err != nil {
+			return result, err
+		}
+		call.applyHeader(req)
+		req.Header.Set("Authorization", "Bearer "+string(token))
+
+		start := time.Now()
+		resp, err := c.opts.HTTPClient.Do(req)
+		result.Latency = time.Since(start)
+		c.recordResponse(call, resp, result.Latency)
+		if err != nil {
+			return result, fmt.Errorf("HTTP request failed: %w", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		result.Reachable = true
+
+		ok, err := tokenOK(resp.StatusCode)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			result.TokenOK = true
+			return result, nil
+		}
+	}
+
+	return result, nil
 }
 
-func (c *APIClient) doDenizenGraphQL(ctx context.Context, operationName, query string, variables map[string]any, v any) error {
-	req, err := c.createRequest(ctx, http.MethodPost, DenizenGraphQLEndpoint, map[string]any{
+func (c *APIClient) doDenizenGraphQL(ctx context.Context, call callOptions, operationName, query string, variables map[string]any, v any) error {
+	ctx = context.WithValue(ctx, graphQLOperationKey{}, operationName)
+	return c.doRequest(ctx, call, denizenProfile, http.MethodPost, c.opts.APIBaseURL+denizenGraphQLPath, map[string]any{
 		"operationName": operationName,
 		"variables":     variables,
 		"query":         query,
-	})
-	if err != nil {
+	}, v)
+}
+
+type graphQLOperationKey struct{}
+
+// GraphQLOperationName returns the operationName of the GraphQL request made
+// with ctx, such as "Tenants" or "TenantAccessPoints", or "" if it isn't a
+// GraphQL request. Since all GraphQL requests share a single endpoint,
+// [http.RoundTripper]s that record metrics or traces can use it on the
+// request's context to tell the operations apart. Batched requests have the
+// distinct names of their operations joined by commas.
+func GraphQLOperationName(ctx context.Context) string {
+	name, _ := ctx.Value(graphQLOperationKey{}).(string)
+	return name
+}
+
+// graphQLOperation is a single operation of a batch sent by
+// doDenizenGraphQLBatch.
+type graphQLOperation struct {
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Query         string         `json:"query"`
+
+	// result is where the response of the operation is decoded into.
+	result any
+	// failed is set by doDenizenGraphQLBatch if the chunk of the operation
+	// failed, in which case result must not be used.
+	failed bool
+}
+
+// doDenizenGraphQLBatch performs all operations, decoding the response of
+// each into its result. If [APIClientOpts.GraphQLBatching] is enabled, the
+// operations are sent in chunks of [APIClientOpts.GraphQLBatchSize] per
+// request; otherwise, each operation is its own chunk. A failing chunk doesn't
+// stop the others: the operations of failed chunks are marked as such, and
+// the returned error joins a [*BatchChunkError] for each failed chunk.
+func (c *APIClient) doDenizenGraphQLBatch(ctx context.Context, call callOptions, ops []graphQLOperation) error {
+	size := 1
+	if c.opts.GraphQLBatching {
+		size = c.opts.GraphQLBatchSize
+	}
+
+	var errs []error
+	for start := 0; start < len(ops); start += size {
+		chunk := ops[start:min(start+size, len(ops))]
+		if err := c.doDenizenGraphQLChunk(ctx, call, chunk); err != nil {
+			for i := range chunk {
+				chunk[i].failed = true
+			}
+			errs = append(errs, &BatchChunkError{Start: start, End: start + len(chunk), Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// doDenizenGraphQLChunk performs the operations of a single chunk, sending
+// them in a single request if there is more than one.
+func (c *APIClient) doDenizenGraphQLChunk(ctx context.Context, call callOptions, ops []graphQLOperation) error {
+	if len(ops) == 1 {
+		op := ops[0]
+		if err := c.doDenizenGraphQL(ctx, call, op.OperationName, op.Query, op.Variables, op.result); err != nil {
+			return fmt.Errorf("%s: %w", op.OperationName, err)
+		}
+		return nil
+	}
+
+	var names []string
+	for _, op := range ops {
+		if !slices.Contains(names, op.OperationName) {
+			names = append(names, op.OperationName)
+		}
+	}
+	ctx = context.WithValue(ctx, graphQLOperationKey{}, strings.Join(names, ","))
+
+	var results []jsontext.Value
+	if err := c.doRequest(ctx, call, denizenProfile, http.MethodPost, c.opts.APIBaseURL+denizenGraphQLPath, ops, &results); err != nil {
 		return err
 	}
-	return c.doJSONRequest(req, v)
+	if len(results) != len(ops) {
+		return &InvariantError{Msg: fmt.Sprintf("sent %d batched GraphQL operations, got %d results", len(ops), len(results))}
+	}
+
+	for i, op := range ops {
+		if err := json.Unmarshal(results[i], op.result, denizenProfile.Unmarshal); err != nil {
+			return fmt.Errorf("%s: failed to unmarshal JSON response: %w", op.OperationName, err)
+		}
+	}
+	return nil
+}
+
+func (c *APIClient) getAPI(ctx context.Context, call callOptions, path string, v any) error {
+	return c.doAPIWithBody(ctx, call, railsProfile, http.MethodGet, path, nil, v)
+}
+
+func (c *APIClient) doAPI(ctx context.Context, call callOptions, method, path string, v any) error {
+	return c.doAPIWithBody(ctx, call, railsProfile, method, path, nil, v)
+}
+
+// doAPIWithBody sends a request to the REST API at [APIClientOpts.APIBaseURL].
+// profile should be railsProfile, possibly with the [idEncoding] that the
+// endpoint expects.
+func (c *APIClient) doAPIWithBody(ctx context.Context, call callOptions, profile encodingProfile, method, path string, body any, v any) error {
+	return c.doRequest(ctx, call, profile, method, c.opts.APIBaseURL+path, body, v)
+}
+
+func (c *APIClient) doRequest(ctx context.Context, call callOptions, profile encodingProfile, method, rawURL string, body any, v any) error {
+	if method == http.MethodGet && body == nil && v != nil && call.header == nil && call.meta == nil && !c.opts.DisableCoalescing {
+		return c.doCoalescedGET(ctx, call, profile, rawURL, v)
+	}
+	return c.doUncoalescedRequest(ctx, call, profile, method, rawURL, body, v)
 }
 
-func (c *APIClient) getAPI(ctx context.Context, path string, v any) error {
-	return c.doAPIWithBody(ctx, http.MethodGet, path, nil, v)
+// doCoalescedGET performs a GET request, sharing the response with any other
+// goroutine that requests the same URL at the same time with the same
+// options. The response body is decoded separately for each caller.
+func (c *APIClient) doCoalescedGET(ctx context.Context, call callOptions, profile encodingProfile, rawURL string, v any) error {
+	ch := c.flight.DoChan(coalescingKey(call, rawURL), func() (any, error) {
+		var raw jsontext.Value
+		err := c.doUncoalescedRequest(ctx, call, profile, http.MethodGet, rawURL, nil, &raw)
+		return raw, err
+	})
+
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res = <-ch:
+	}
+
+	if res.Err != nil {
+		// The request is bound to the context of whoever started it. If that
+		// context ended but ours didn't, try again on our own.
+		if res.Shared && ctx.Err() == nil &&
+			(errors.Is(res.Err, context.Canceled) || errors.Is(res.Err, context.DeadlineExceeded)) {
+			return c.doUncoalescedRequest(ctx, call, profile, http.MethodGet, rawURL, nil, v)
+		}
+		return res.Err
+	}
+
+	if err := json.Unmarshal(res.Val.(jsontext.Value), v, profile.Unmarshal); err != nil {
+		return fmt.Errorf("failed to unmarshal JSON response: %w", err)
+	}
+	return nil
 }
 
-func (c *APIClient) doAPI(ctx context.Context, method, path string, v any) error {
-	return c.doAPIWithBody(ctx, method, path, nil, v)
+// coalescingKey returns the key under which a GET request to rawURL made with
+// call is coalesced. The shared request is made with the options of whoever
+// started it, so calls are only coalesced if the options that affect the
+// request match: the timeout and the tenant that [APIClientOpts.RequestBudget]
+// counts it against. Options that end up in the URL, such as [WithPageSize],
+// are already part of rawURL, and calls with headers or a [ResponseMeta] are
+// never coalesced.
+func coalescingKey(call callOptions, rawURL string) string {
+	return fmt.Sprintf("%s %v %d", rawURL, call.timeout, call.tenant)
 }
 
-func (c *APIClient) doAPIWithBody(ctx context.Context, method, path string, body any, v any) error {
-	req, err := c.createRequest(ctx, method, APIBaseURL+path, body)
+func (c *APIClient) doUncoalescedRequest(ctx context.Context, call callOptions, profile encodingProfile, method, rawURL string, body any, v any) error {
+	ctx, cancel := call.context(ctx)
+	defer cancel()
+
+	req, err := c.createRequest(ctx, profile, method, rawURL, body)
 	if err != nil {
 		return err
 	}
-	return c.doJSONRequest(req, v)
+	call.applyHeader(req)
+	return c.doJSONRequest(req, profile, v, call)
 }
 
-func (c *APIClient) createRequest(ctx context.Context, method, rawURL string, jsonBody any) (*http.Request, error) {
+func (c *APIClient) createRequest(ctx context.Context, profile encodingProfile, method, rawURL string, jsonBody any) (*http.Request, error) {
 	var body io.Reader
 	if jsonBody != nil {
-		b, err := json.Marshal(jsonBody)
+		b, err := json.Marshal(jsonBody, profile.marshalOptions())
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
 		body = bytes.NewReader(b)
 	}
 
+	// For a *bytes.Reader body, this also sets req.GetBody, which allows the
+	// body to be resent on retries and redirects.
 	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 	req.Header.Set("User-Agent", c.opts.UserAgent)
+	if c.opts.Locale != "" {
+		req.Header.Set("Accept-Language", c.opts.Locale)
+	}
 	if jsonBody != nil {
 		req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	}
@@ -438,7 +1097,169 @@ func (c *APIClient) createRequest(ctx context.Context, method, rawURL string, js
 	return req, nil
 }
 
-func (c *APIClient) doJSONRequest(req *http.Request, dst any) error {
+// logRequest logs a completed HTTP request at Info level if it is sampled
+// according to [APIClientOpts.RequestLogSampleRate]. resp is nil if the
+// request failed without a response.
+func (c *APIClient) logRequest(req *http.Request, resp *http.Response, respBytes int64, start time.Time) {
+	rate := c.opts.RequestLogSampleRate
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("req.method", req.Method),
+		slog.String("req.path", req.URL.Path),
+		slog.Int64("req.bytes", max(req.ContentLength, 0)),
+		slog.Duration("latency", time.Since(start)),
+	}
+	if operation := GraphQLOperationName(req.Context()); operation != "" {
+		attrs = append(attrs, slog.String("req.operation", operation))
+	}
+	if resp != nil {
+		attrs = append(attrs,
+			slog.Int("resp.status", resp.StatusCode),
+			slog.Int64("resp.bytes", respBytes))
+	}
+
+	c.opts.Logger.LogAttrs(req.Context(), slog.LevelInfo, "API request", attrs...)
+}
+
+// countingReadCloser counts the bytes read through it.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// tenantOr returns tenantID, or the tenant of ctx or
+// [APIClientOpts.DefaultTenant] if it is 0.
+func (c *APIClient) tenantOr(ctx context.Context, tenantID ID) ID {
+	if tenantID != 0 {
+		return tenantID
+	}
+	if id, ok := TenantFromContext(ctx); ok {
+		return id
+	}
+	return c.opts.DefaultTenant
+}
+
+// rejectedTokenFailure classifies why the API rejected token even after it
+// was renewed. A token that hasn't expired according to its own expiry must
+// have been rejected for another reason.
+func rejectedTokenFailure(token APIStaticToken) AuthFailure {
+	if expiry, ok := token.Expiry(); ok && time.Now().Before(expiry) {
+		return AuthFailureAPITokenInvalid
+	}
+	return AuthFailureAPITokenExpired
+}
+
+// recordResponse records an HTTP attempt made for call, whose response is
+// resp, or nil if the request failed without one.
+func (c *APIClient) recordResponse(call callOptions, resp *http.Response, d time.Duration) {
+	if call.meta != nil {
+		call.meta.record(resp, d)
+	}
+	if c.opts.RequestBudget != nil {
+		c.opts.RequestBudget.record(call.tenant)
+	}
+}
+
+// maintenanceSniffSize is how much of a response body [checkMaintenance]
+// looks at.
+const maintenanceSniffSize = 4096
+
+// checkMaintenance returns a [*MaintenanceError] if resp is a maintenance
+// page, which is either a 503 or an HTML response that mentions maintenance.
+// The part of the body that was inspected is put back, so resp can still be
+// decoded otherwise.
+func checkMaintenance(resp *http.Response) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusServiceUnavailable && mediaType != "text/html" {
+		return nil
+	}
+
+	sniffed, _ := io.ReadAll(io.LimitReader(resp.Body, maintenanceSniffSize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(sniffed), resp.Body), resp.Body}
+
+	if !bytes.Contains(bytes.ToLower(sniffed), []byte("maintenance")) {
+		return nil
+	}
+
+	apiErr := newAPIError(resp)
+	return &MaintenanceError{
+		RetryAfter: apiErr.RetryAfter(),
+		Err:        apiErr,
+	}
+}
+
+// contentTypeSnippetSize is how much of a non-JSON response body is included
+// in the error returned by [checkJSONContentType].
+const contentTypeSnippetSize = 256
+
+// checkJSONContentType returns an error if resp declares a content type other
+// than JSON, which happens when a captive portal or proxy answers instead of
+// the API. The error includes the start of the body to help tell what
+// answered. Responses without a content type are assumed to be JSON.
+func checkJSONContentType(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, contentTypeSnippetSize))
+	return fmt.Errorf("expected JSON response but got %q (status %d): %q",
+		contentType, resp.StatusCode, strings.Join(strings.Fields(string(snippet)), " "))
+}
+
+// doJSONRequest performs req, retrying as needed, and decodes the response
+// body into dst.
+func (c *APIClient) doJSONRequest(req *http.Request, profile encodingProfile, dst any, call callOptions) error {
+	return c.doRetriedRequest(req, call, true, func(resp *http.Response) error {
+		if c.opts.MaxResponseSize > 0 {
+			resp.Body = http.MaxBytesReader(nil, resp.Body, c.opts.MaxResponseSize)
+		}
+
+		if resp.StatusCode == http.StatusNoContent {
+			if dst != nil {
+				return fmt.Errorf("expected response body but got 204 No Content")
+			}
+			return nil
+		}
+
+		if err := checkJSONContentType(resp); err != nil {
+			return err
+		}
+
+		if err := json.UnmarshalRead(resp.Body, dst, profile.Unmarshal); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return fmt.Errorf("response body exceeds %d bytes", c.opts.MaxResponseSize)
+			}
+			return fmt.Errorf("failed to unmarshal JSON response: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// doRetriedRequest performs req, retrying as needed, and passes the first
+// successful response to handle. The API token is only sent if sendToken is
+// true. Errors returned by handle aren't retried. Every attempt is recorded
+// using [APIClient.recordResponse].
+func (c *APIClient) doRetriedRequest(req *http.Request, call callOptions, sendToken bool, handle func(*http.Response) error) error {
 	var renewToken bool
 
 	retryOpts := slices.Concat(c.opts.RequestRetryOpts, []backoff.RetryOption{
@@ -455,58 +1276,72 @@ func (c *APIClient) doJSONRequest(req *http.Request, dst any) error {
 		}),
 	})
 
+	var token APIStaticToken
+
 	_, err := backoff.Retry(req.Context(), func() (*struct{}, error) {
-		token, err := c.tokenSource.APIToken(req.Context(), renewToken)
-		if err != nil {
-			return nil, backoff.Permanent(fmt.Errorf("failed to get API token: %w", err))
+		if sendToken {
+			var err error
+			token, err = c.tokenSource.APIToken(req.Context(), renewToken)
+			if err != nil {
+				return nil, backoff.Permanent(fmt.Errorf("failed to get API token: %w", err))
+			}
+			req.Header.Set("Authorization", "Bearer "+string(token))
 		}
 
-		req.Header.Set("Authorization", "Bearer "+string(token))
+		// The body was consumed by the previous attempt, so get a fresh one.
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, backoff.Permanent(fmt.Errorf("failed to rewind request body: %w", err))
+			}
+			req.Body = body
+		}
 
+		start := time.Now()
 		resp, err := c.opts.HTTPClient.Do(req)
+		c.recordResponse(call, resp, time.Since(start))
 		if err != nil {
+			c.logRequest(req, nil, 0, start)
 			return nil, fmt.Errorf("HTTP request failed: %w", err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode == http.StatusUnauthorized {
+		body := &countingReadCloser{ReadCloser: resp.Body}
+		resp.Body = body
+		defer func() { c.logRequest(req, resp, body.n, start) }()
+
+		// Expired tokens may be rejected with a 403 rather than a 401, so
+		// renew the token and retry once on both, unless no token was sent.
+		sentToken := req.Header.Get("Authorization") != ""
+		if sentToken && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
 			if !renewToken {
 				renewToken = true
-				return nil, fmt.Errorf("API request unauthorized, renewing token and retrying")
+				return nil, fmt.Errorf("API request rejected with status %d, renewing token and retrying", resp.StatusCode)
 			}
-			// Even after renewing the token, we got a 401. Give up.
-			return nil, backoff.Permanent(fmt.Errorf("API request unauthorized even after renewing token"))
-		}
-
-		if resp.StatusCode >= 500 {
-			return nil, fmt.Errorf("server error: status %d", resp.StatusCode)
+			if resp.StatusCode == http.StatusUnauthorized {
+				// Even after renewing the token, we got a 401. Give up.
+				return nil, backoff.Permanent(fmt.Errorf("API request unauthorized even after renewing token: %w",
+					&AuthError{Failure: rejectedTokenFailure(token), Err: newAPIError(resp)}))
+			}
+			// A 403 with a fresh token means the account really isn't
+			// allowed to do this.
+			return nil, backoff.Permanent(fmt.Errorf("API request failed: %w: %w", ErrForbidden, newAPIError(resp)))
 		}
 
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			return nil, backoff.Permanent(fmt.Errorf("API request failed on non-server error: status %d", resp.StatusCode))
+		if err := checkMaintenance(resp); err != nil {
+			return nil, backoff.Permanent(err)
 		}
 
-		if resp.StatusCode == http.StatusNoContent {
-			if dst != nil {
-				return nil, backoff.Permanent(fmt.Errorf("expected response body but got 204 No Content"))
-			}
-			return nil, nil
+		if resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("server error: %w", newAPIError(resp))
 		}
 
-		if err := json.UnmarshalRead(resp.Body, dst); err != nil {
-			return nil, backoff.Permanent(fmt.Errorf("failed to unmarshal JSON response: %w", err))
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, backoff.Permanent(fmt.Errorf("API request failed on non-server error: %w", newAPIError(resp)))
 		}
 
-		return nil, nil
+		return nil, backoff.Permanent(handle(resp))
 	}, retryOpts...)
 
 	return err
 }
-
-func mustParseURL(rawURL string) *url.URL {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		panic(fmt.Sprintf("BUG: failed to parse URL %q: %v", rawURL, err))
-	}
-	return u
-}
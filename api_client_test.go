@@ -5,14 +5,18 @@ import (
 	"encoding/json/jsontext"
 	"encoding/json/v2"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/alecthomas/assert/v2"
+	"github.com/cenkalti/backoff/v5"
 	"github.com/neilotoole/slogt"
-	"libdb.so/go-butterflymx/internal/httpmock"
+	"libdb.so/go-butterflymx/httpmock"
 )
 
 var mockToken APIStaticToken = "meowmeow"
@@ -165,6 +169,7 @@ func TestAPIClient_UnlockDoor(t *testing.T) {
 					// base URL and not the normal API base URL.
 					assert.Contains(t, req.URL.String(), UnlockAPIBaseURL)
 				},
+				httpmock.MatchGoldenJSON("testdata/golden/unlock-door-request.json"),
 				httpmock.RoundTripRequestCheckJSON(func(t *testing.T, data map[string]any) {
 					assert.Equal(t, "prod-access_point-12345", data["accessPointId"])
 					assert.Equal(t, "prod-tenant-67890", data["tenantId"])
@@ -184,19 +189,13 @@ func TestAPIClient_UnlockDoor(t *testing.T) {
 }
 
 func TestAPIClient_CreateCustomKeychain(t *testing.T) {
-	customKeychainRequest, customKeychainResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-custom.json")
-	assert.NoError(t, customKeychainRequest.Canonicalize())
-	assert.NoError(t, customKeychainResponse.Canonicalize())
+	_, customKeychainResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-custom.json")
 
 	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
 		{
 			RequestCheck: httpmock.ChainRoundTripRequestChecks(
 				requestCheckAuthorizationBearer,
-				httpmock.RoundTripRequestCheckJSON(func(t *testing.T, data jsontext.Value) {
-					assert.NoError(t, data.Canonicalize())
-					// Ensure request body matches expected exactly.
-					assert.Equal(t, string(customKeychainRequest), string(data))
-				}),
+				httpmock.MatchGoldenJSON("testdata/golden/create-custom-keychain-request.json"),
 			),
 			Response: httpmock.RoundTripResponse{
 				Status: http.StatusOK,
@@ -239,22 +238,20 @@ func TestAPIClient_CreateCustomKeychain(t *testing.T) {
 }
 
 func TestAPIClient_CreateVirtualKeys(t *testing.T) {
-	virtualKeyRequest, virtualKeyResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-id.json")
-	assert.NoError(t, virtualKeyRequest.Canonicalize())
-	assert.NoError(t, virtualKeyResponse.Canonicalize())
+	_, virtualKeyResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-id.json")
 
 	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
 		{
 			RequestCheck: httpmock.ChainRoundTripRequestChecks(
-				requestCheckAuthorizationBearer,
-				func(t *testing.T, req *http.Request) {
-					assert.Contains(t, req.URL.Path, "/v3/keychains/10001/virtual_keys")
-				},
-				httpmock.RoundTripRequestCheckJSON(func(t *testing.T, data jsontext.Value) {
-					assert.NoError(t, data.Canonicalize())
-					// Ensure request body matches expected exactly.
-					assert.Equal(t, string(virtualKeyRequest), string(data))
-				}),
+				httpmock.Expect().
+					Post("/v3/keychains/10001/virtual_keys").
+					Header("Authorization", "Bearer meowmeow").
+					JSONPath("data.type", "virtual_keys").
+					JSONPath("data.attributes.recipients", []map[string]string{
+						{"name": "john.doe@example.com", "deliver_to": "john.doe@example.com"},
+					}).
+					Check,
+				httpmock.MatchGoldenJSON("testdata/golden/create-virtual-keys-request.json"),
 			),
 			Response: httpmock.RoundTripResponse{
 				Status: http.StatusOK,
@@ -283,6 +280,741 @@ func TestAPIClient_CreateVirtualKeys(t *testing.T) {
 	assert.True(t, virtualKeys[0].Attributes.SentAt.IsZero())
 }
 
+func TestAPIClient_retryResendsBody(t *testing.T) {
+	_, customKeychainResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-custom.json")
+
+	// Every attempt, including retries after a server error or an
+	// unauthorized response, must carry the full request body.
+	checkBody := httpmock.MatchGoldenJSON("testdata/golden/create-custom-keychain-request.json")
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{RequestCheck: checkBody, Response: httpmock.RoundTripResponse{Status: http.StatusBadGateway}},
+		{RequestCheck: checkBody, Response: httpmock.RoundTripResponse{Status: http.StatusUnauthorized}},
+		{RequestCheck: checkBody, Response: httpmock.RoundTripResponse{Body: customKeychainResponse}},
+	})
+
+	apiClient := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient:     &http.Client{Transport: bodyConsumingTransport{mockrt}},
+		Logger:         slogt.New(t),
+		RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+	})
+
+	_, err := apiClient.CreateCustomKeychain(t.Context(), 10001, []ID{50001}, CustomKeychainArgs{
+		Name:     "Jane Doe",
+		StartsAt: mustRFC3339(t, "2023-01-01T00:00:00-0800"),
+		EndsAt:   mustRFC3339(t, "2023-01-02T00:00:00-0800"),
+	})
+	assert.NoError(t, err)
+}
+
+// bodyConsumingTransport drains and closes request bodies after each round
+// trip, like a real transport does.
+type bodyConsumingTransport struct {
+	http.RoundTripper
+}
+
+func (t bodyConsumingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+	}
+	return resp, err
+}
+
+func TestAPIClient_Ping(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.ChainRoundTripRequestChecks(
+					requestCheckAuthorizationBearer,
+					httpmock.RoundTripRequestCheckJSON(func(t *testing.T, data map[string]any) {
+						assert.Equal(t, "Ping", data["operationName"])
+					}),
+				),
+				Response: httpmock.RoundTripResponse{
+					Status: http.StatusOK,
+					Body:   []byte(`{"data": {"__typename": "Query"}}`),
+				},
+			},
+		})
+
+		result, err := newTestAPIClient(t, mockrt).Ping(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, result.Reachable)
+		assert.True(t, result.TokenOK)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		unauthorized := httpmock.RoundTripResponse{Status: http.StatusUnauthorized}
+		mockrt := httpmock.NewSequence(t, unauthorized, unauthorized)
+
+		result, err := newTestAPIClient(t, mockrt).Ping(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, result.Reachable)
+		assert.False(t, result.TokenOK)
+	})
+}
+
+func TestAPIClient_ValidateToken(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		mockrt := httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: []byte(`{"data": {"__typename": "Query"}}`)})
+		assert.NoError(t, newTestAPIClient(t, mockrt).ValidateToken(t.Context()))
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		unauthorized := httpmock.RoundTripResponse{Status: http.StatusUnauthorized}
+		mockrt := httpmock.NewSequence(t, unauthorized, unauthorized)
+
+		err := newTestAPIClient(t, mockrt).ValidateToken(t.Context())
+		var authErr *AuthError
+		assert.True(t, errors.As(err, &authErr), "error should be an AuthError: %v", err)
+		assert.IsError(t, err, ErrTokenExpired)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		forbidden := httpmock.RoundTripResponse{Status: http.StatusForbidden}
+		mockrt := httpmock.NewSequence(t, forbidden, forbidden)
+
+		err := newTestAPIClient(t, mockrt).ValidateToken(t.Context())
+		var authErr *AuthError
+		assert.True(t, errors.As(err, &authErr), "error should be an AuthError: %v", err)
+		assert.IsError(t, err, ErrTokenExpired)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		mockrt := httpmock.NewSequence(t, httpmock.RoundTripResponse{Status: http.StatusBadGateway})
+
+		err := newTestAPIClient(t, mockrt).ValidateToken(t.Context())
+		assert.Error(t, err)
+		var authErr *AuthError
+		assert.False(t, errors.As(err, &authErr), "a server error says nothing about the token")
+	})
+}
+
+func TestAPIClient_PingUnlock(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.ChainRoundTripRequestChecks(
+					requestCheckAuthorizationBearer,
+					func(t *testing.T, req *http.Request) {
+						assert.Equal(t, http.MethodGet, req.Method, "probe must not POST to the unlock endpoint")
+						assert.Equal(t, UnlockAccessPointEndpoint, req.URL.String())
+						assert.Zero(t, req.Body)
+					},
+				),
+				Response: httpmock.RoundTripResponse{Status: http.StatusMethodNotAllowed},
+			},
+		})
+
+		result, err := newTestAPIClient(t, mockrt).PingUnlock(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, result.Reachable)
+		assert.True(t, result.TokenOK)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		unauthorized := httpmock.RoundTripResponse{Status: http.StatusUnauthorized}
+		mockrt := httpmock.NewSequence(t, unauthorized, unauthorized)
+
+		result, err := newTestAPIClient(t, mockrt).PingUnlock(t.Context())
+		assert.NoError(t, err)
+		assert.True(t, result.Reachable)
+		assert.False(t, result.TokenOK)
+	})
+
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusBadGateway} {
+		t.Run(fmt.Sprintf("unexpected %d", status), func(t *testing.T) {
+			mockrt := httpmock.NewSequence(t, httpmock.RoundTripResponse{Status: status})
+
+			result, err := newTestAPIClient(t, mockrt).PingUnlock(t.Context())
+			assert.Error(t, err)
+			assert.False(t, result.TokenOK)
+		})
+	}
+}
+
+func TestAPIClient_defaultTenant(t *testing.T) {
+	expectTenant := func(tenantID string) httpmock.RoundTrip {
+		return httpmock.RoundTrip{
+			RequestCheck: httpmock.Expect().JSONPath("tenantId", tenantID).Check,
+			Response:     httpmock.RoundTripResponse{Body: []byte(`{}`)},
+		}
+	}
+
+	client := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient: &http.Client{Transport: httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			expectTenant("prod-tenant-5"),
+			expectTenant("prod-tenant-7"),
+			expectTenant("prod-tenant-9"),
+		})},
+		Logger:        slogt.New(t),
+		DefaultTenant: 5,
+	})
+
+	assert.NoError(t, client.UnlockDoor(t.Context(), 0, 400))
+	ctx := ContextWithTenant(t.Context(), 7)
+	assert.NoError(t, client.UnlockDoor(ctx, 0, 400))
+	assert.NoError(t, client.UnlockDoor(ctx, 9, 400), "an explicit tenant should take precedence")
+}
+
+func TestAPIClient_baseURLs(t *testing.T) {
+	expectURL := func(url string) httpmock.RoundTrip {
+		return httpmock.RoundTrip{
+			RequestCheck: func(t *testing.T, req *http.Request) {
+				assert.Equal(t, url, req.URL.String())
+			},
+			Response: httpmock.RoundTripResponse{Body: []byte(`{}`)},
+		}
+	}
+
+	client := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient: &http.Client{Transport: httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			expectURL("http://localhost:8080/denizen/v1/graphql"),
+			expectURL("http://localhost:8081/v1/access-point"),
+		})},
+		Logger:           slogt.New(t),
+		APIBaseURL:       "http://localhost:8080/",
+		UnlockAPIBaseURL: "http://localhost:8081",
+	})
+
+	_, err := client.Ping(t.Context())
+	assert.NoError(t, err)
+	assert.NoError(t, client.UnlockDoor(t.Context(), 100, 400))
+}
+
+func TestAPIClient_ProbeAccessPoint(t *testing.T) {
+	accessPoints := []byte(`{"data":{"nodes":[{"accessPoints":{
+		"nodes":[
+			{"id":"prod-access_point-400","name":"Front Door","online":true},
+			{"id":"prod-access_point-401","name":"Garage","online":false}
+		],
+		"pageInfo":{"hasNextPage":false}
+	}}]}}`)
+	unlockRejected := httpmock.RoundTripResponse{Status: http.StatusMethodNotAllowed}
+
+	tests := []struct {
+		accessPointID ID
+		found, ready  bool
+	}{
+		{400, true, true},
+		{401, true, false},
+		{402, false, false},
+	}
+	for _, test := range tests {
+		mockrt := httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: accessPoints}, unlockRejected)
+
+		probe, err := newTestAPIClient(t, mockrt).ProbeAccessPoint(t.Context(), TenantTaggedID(100), test.accessPointID)
+		assert.NoError(t, err)
+		assert.Equal(t, test.found, probe.Found, "access point %d", test.accessPointID)
+		assert.Equal(t, test.ready, probe.Ready(), "access point %d", test.accessPointID)
+		assert.True(t, probe.Unlock.TokenOK)
+	}
+
+	t.Run("never unlocks", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{Response: httpmock.RoundTripResponse{Body: accessPoints}},
+			{
+				RequestCheck: func(t *testing.T, req *http.Request) {
+					assert.NotEqual(t, http.MethodPost, req.Method, "probe must not POST to the unlock endpoint")
+				},
+				Response: httpmock.RoundTripResponse{Status: http.StatusForbidden},
+			},
+		})
+
+		_, err := newTestAPIClient(t, mockrt).ProbeAccessPoint(t.Context(), TenantTaggedID(100), 400)
+		assert.Error(t, err, "a 403 from the Unlock API is unexpected")
+	})
+}
+
+func TestAPIClient_faults(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	newFaultyAPIClient := func(t *testing.T, faults httpmock.Faults, resps ...httpmock.RoundTripResponse) (*APIClient, *httpmock.FaultTransport) {
+		faulty := httpmock.NewFaultTransport(httpmock.NewSequence(t, resps...), faults)
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:     &http.Client{Transport: faulty},
+			Logger:         slogt.New(t),
+			RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+		}), faulty
+	}
+
+	t.Run("transient faults are retried", func(t *testing.T) {
+		for _, faults := range []httpmock.Faults{
+			{ResetRate: 1, MaxFaults: 4},
+			{TimeoutRate: 1, MaxFaults: 4},
+			{StatusRate: 1, MaxFaults: 4},
+		} {
+			client, faulty := newFaultyAPIClient(t, faults, httpmock.RoundTripResponse{Body: keychainResponse})
+
+			_, err := client.Keychain(t.Context(), 10001)
+			assert.NoError(t, err)
+			assert.Equal(t, 4, faulty.Injected())
+		}
+	})
+
+	t.Run("too many faults", func(t *testing.T) {
+		client, faulty := newFaultyAPIClient(t, httpmock.Faults{ResetRate: 1})
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.Error(t, err)
+		assert.Equal(t, 5, faulty.Injected())
+	})
+
+	t.Run("malformed body is not retried", func(t *testing.T) {
+		client, _ := newFaultyAPIClient(t, httpmock.Faults{MalformedRate: 1},
+			httpmock.RoundTripResponse{Body: keychainResponse})
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.Error(t, err)
+	})
+}
+
+func TestAPIClient_maintenance(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	newClient := func(t *testing.T, resps ...httpmock.RoundTripResponse) *APIClient {
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:     &http.Client{Transport: httpmock.NewSequence(t, resps...)},
+			Logger:         slogt.New(t),
+			RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+		})
+	}
+
+	t.Run("503 page", func(t *testing.T) {
+		client := newClient(t, httpmock.RoundTripResponse{
+			Status:  http.StatusServiceUnavailable,
+			Headers: map[string]string{"Content-Type": "text/html; charset=utf-8", "Retry-After": "120"},
+			Body:    []byte(`<html><body><h1>We'll be back soon!</h1><p>Scheduled Maintenance in progress.</p></body></html>`),
+		})
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.IsError(t, err, ErrMaintenance)
+
+		var maintenanceErr *MaintenanceError
+		assert.True(t, errors.As(err, &maintenanceErr))
+		assert.Equal(t, 2*time.Minute, maintenanceErr.RetryAfter)
+		assert.Equal(t, http.StatusServiceUnavailable, maintenanceErr.Err.StatusCode)
+	})
+
+	t.Run("HTML with OK status", func(t *testing.T) {
+		client := newClient(t, httpmock.RoundTripResponse{
+			Headers: map[string]string{"Content-Type": "text/html"},
+			Body:    []byte(`<html><title>Down for maintenance</title></html>`),
+		})
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.IsError(t, err, ErrMaintenance)
+	})
+
+	t.Run("plain 503 is retried", func(t *testing.T) {
+		client := newClient(t,
+			httpmock.RoundTripResponse{Status: http.StatusServiceUnavailable, Body: []byte(`{"error":"overloaded"}`)},
+			httpmock.RoundTripResponse{Body: keychainResponse},
+		)
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAPIClient_contentType(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	newClient := func(t *testing.T, resp httpmock.RoundTripResponse) *APIClient {
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient: &http.Client{Transport: httpmock.NewSequence(t, resp)},
+			Logger:     slogt.New(t),
+		})
+	}
+
+	for _, contentType := range []string{"", "application/json", "application/json; charset=utf-8", "application/vnd.api+json"} {
+		resp := httpmock.RoundTripResponse{
+			Headers: map[string]string{"Content-Type": contentType},
+			Body:    keychainResponse,
+		}
+		_, err := newClient(t, resp).Keychain(t.Context(), 10001)
+		assert.NoError(t, err, "content type %q", contentType)
+	}
+
+	_, err := newClient(t, httpmock.RoundTripResponse{
+		Headers: map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		Body:    []byte("<html>\n  <title>Sign in to Airport Wi-Fi</title>\n</html>"),
+	}).Keychain(t.Context(), 10001)
+	assert.EqualError(t, err, `expected JSON response but got "text/html; charset=utf-8" (status 200): "<html> <title>Sign in to Airport Wi-Fi</title> </html>"`)
+}
+
+func TestAPIClient_MaxResponseSize(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	newLimitedAPIClient := func(t *testing.T, limit int64) *APIClient {
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:      &http.Client{Transport: httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: keychainResponse})},
+			Logger:          slogt.New(t),
+			MaxResponseSize: limit,
+		})
+	}
+
+	t.Run("within limit", func(t *testing.T) {
+		_, err := newLimitedAPIClient(t, int64(len(keychainResponse))).Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+	})
+
+	t.Run("exceeds limit", func(t *testing.T) {
+		_, err := newLimitedAPIClient(t, 64).Keychain(t.Context(), 10001)
+		assert.EqualError(t, err, "response body exceeds 64 bytes")
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		_, err := newLimitedAPIClient(t, -1).Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+	})
+}
+
+func TestAPIClient_callOptions(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	t.Run("defaults", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.Expect().
+					Get("/v3/access_codes").
+					Query("page[size]", "100").
+					Query("include", "virtual_keys.door_releases.panel,devices").
+					Header("X-Request-Id", "").
+					Check,
+				Response: httpmock.RoundTripResponse{Body: accessCodesResponse},
+			},
+		})
+
+		_, err := newTestAPIClient(t, mockrt).Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.NoError(t, err)
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.Expect().
+					Get("/v3/access_codes").
+					Query("page[size]", "10").
+					Query("include", "devices").
+					Header("X-Request-Id", "abc").
+					Header("Authorization", "Bearer meowmeow").
+					Check,
+				Response: httpmock.RoundTripResponse{Body: accessCodesResponse},
+			},
+		})
+
+		_, err := newTestAPIClient(t, mockrt).Keychains(t.Context(), 10001, ActiveAccessCode,
+			WithPageSize(10),
+			WithInclude("devices"),
+			WithHeader("X-Request-Id", "abc"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: func(t *testing.T, req *http.Request) {
+					deadline, ok := req.Context().Deadline()
+					assert.True(t, ok, "request should have a deadline")
+					assert.True(t, time.Until(deadline) <= time.Minute)
+				},
+				Response: httpmock.RoundTripResponse{Body: keychainResponse},
+			},
+		})
+
+		_, err := newTestAPIClient(t, mockrt).Keychain(t.Context(), 10001, WithTimeout(time.Minute))
+		assert.NoError(t, err)
+	})
+}
+
+func TestAPIClient_responseMeta(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	newClient := func(t *testing.T, resps ...httpmock.RoundTripResponse) *APIClient {
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:     &http.Client{Transport: httpmock.NewSequence(t, resps...)},
+			Logger:         slogt.New(t),
+			RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+		})
+	}
+
+	t.Run("success after retry", func(t *testing.T) {
+		client := newClient(t,
+			httpmock.RoundTripResponse{Status: http.StatusServiceUnavailable},
+			httpmock.RoundTripResponse{
+				Headers: map[string]string{"X-Request-Id": "req-123", "X-RateLimit-Remaining": "42"},
+				Body:    keychainResponse,
+			},
+		)
+
+		var meta ResponseMeta
+		_, err := client.Keychain(t.Context(), 10001, WithResponseMeta(&meta))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, meta.StatusCode)
+		assert.Equal(t, 2, meta.Attempts)
+		assert.Equal(t, "req-123", meta.RequestID())
+		assert.Equal(t, "42", meta.Header.Get("X-RateLimit-Remaining"))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		client := newClient(t, httpmock.RoundTripResponse{Status: http.StatusNotFound})
+
+		var meta ResponseMeta
+		_, err := client.Keychain(t.Context(), 10001, WithResponseMeta(&meta))
+		assert.Error(t, err)
+		assert.Equal(t, http.StatusNotFound, meta.StatusCode)
+		assert.Equal(t, 1, meta.Attempts)
+	})
+}
+
+func TestAPIClient_requestLogging(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	logRequests := func(t *testing.T, rate float64) []map[string]any {
+		var buf bytes.Buffer
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:           &http.Client{Transport: httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: keychainResponse})},
+			Logger:               slog.New(slog.NewJSONHandler(&buf, nil)),
+			RequestLogSampleRate: rate,
+		})
+
+		_, err := client.Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+
+		var records []map[string]any
+		for line := range bytes.Lines(buf.Bytes()) {
+			var record map[string]any
+			assert.NoError(t, json.Unmarshal(line, &record))
+			records = append(records, record)
+		}
+		return records
+	}
+
+	t.Run("sampled", func(t *testing.T) {
+		records := logRequests(t, 1)
+		assert.Equal(t, 1, len(records))
+		assert.Equal[any](t, "INFO", records[0]["level"])
+		assert.Equal[any](t, "GET", records[0]["req.method"])
+		assert.Equal[any](t, "/v3/keychains/10001", records[0]["req.path"])
+		assert.Equal[any](t, float64(http.StatusOK), records[0]["resp.status"])
+		assert.Equal[any](t, float64(len(keychainResponse)), records[0]["resp.bytes"])
+		assert.NotZero(t, records[0]["latency"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, 0, len(logRequests(t, 0)))
+	})
+}
+
+func TestAPIClient_locale(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{
+			RequestCheck: httpmock.Expect().Header("Accept-Language", "es-MX").Check,
+			Response:     httpmock.RoundTripResponse{Body: keychainResponse},
+		},
+	})
+
+	client := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient: &http.Client{Transport: mockrt},
+		Logger:     slogt.New(t),
+		Locale:     "es-MX",
+	})
+	_, err := client.Keychain(t.Context(), 10001)
+	assert.NoError(t, err)
+
+	assert.Equal(t, APIDeviceInfo, deviceInfo(""))
+	assert.Equal[any](t, []string{"es-MX"}, deviceInfo("es-MX")["locales"])
+	assert.Equal[any](t, []string{"en"}, APIDeviceInfo["locales"], "APIDeviceInfo must not be modified")
+}
+
+func TestAPIClient_AccessPointsOfTenants(t *testing.T) {
+	tenantIDs := []TaggedID{TenantTaggedID(100), TenantTaggedID(101)}
+	frontDoor := AccessPoint{ID: AccessPointTaggedID(400), Name: "Front Door", OpenDuration: 5, Online: true}
+	garage := AccessPoint{ID: AccessPointTaggedID(401), Name: "Garage", OpenDuration: 10, Online: true}
+
+	page := func(accessPoints []AccessPoint, endCursor string) map[string]any {
+		return map[string]any{
+			"data": map[string]any{
+				"nodes": []any{map[string]any{
+					"accessPoints": map[string]any{
+						"nodes":    accessPoints,
+						"pageInfo": PageInfo{HasNextPage: endCursor != "", EndCursor: endCursor},
+					},
+				}},
+			},
+		}
+	}
+	mustMarshal := func(v any) []byte {
+		b, err := json.Marshal(v)
+		assert.NoError(t, err)
+		return b
+	}
+
+	type operation struct {
+		OperationName string `json:"operationName"`
+		Variables     struct {
+			IDs   []TaggedID `json:"ids"`
+			After *string    `json:"after"`
+		} `json:"variables"`
+	}
+
+	want := map[TaggedID][]AccessPoint{
+		tenantIDs[0]: {frontDoor},
+		tenantIDs[1]: {frontDoor, garage},
+	}
+
+	t.Run("batched", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.RoundTripRequestCheckJSON(func(t *testing.T, ops []operation) {
+					assert.Equal(t, 2, len(ops))
+					for i, op := range ops {
+						assert.Equal(t, "TenantAccessPoints", op.OperationName)
+						assert.Equal(t, []TaggedID{tenantIDs[i]}, op.Variables.IDs)
+					}
+				}),
+				Response: httpmock.RoundTripResponse{Body: mustMarshal([]any{
+					page([]AccessPoint{frontDoor}, ""),
+					page([]AccessPoint{frontDoor}, "page-2"),
+				})},
+			},
+			{
+				// The rest of the second tenant's access points are fetched
+				// without batching.
+				RequestCheck: httpmock.RoundTripRequestCheckJSON(func(t *testing.T, op operation) {
+					assert.Equal(t, []TaggedID{tenantIDs[1]}, op.Variables.IDs)
+					assert.Equal(t, "page-2", *op.Variables.After)
+				}),
+				Response: httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{garage}, ""))},
+			},
+		})
+
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:      &http.Client{Transport: mockrt},
+			Logger:          slogt.New(t),
+			GraphQLBatching: true,
+		})
+		got, err := client.AccessPointsOfTenants(t.Context(), tenantIDs)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("unbatched", func(t *testing.T) {
+		mockrt := httpmock.NewSequence(t,
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{frontDoor}, ""))},
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{frontDoor}, "page-2"))},
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{garage}, ""))},
+		)
+
+		got, err := newTestAPIClient(t, mockrt).AccessPointsOfTenants(t.Context(), tenantIDs)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("chunked", func(t *testing.T) {
+		tenantIDs := []TaggedID{TenantTaggedID(100), TenantTaggedID(101), TenantTaggedID(102)}
+
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: httpmock.RoundTripRequestCheckJSON(func(t *testing.T, ops []operation) {
+					assert.Equal(t, 2, len(ops))
+				}),
+				Response: httpmock.RoundTripResponse{Status: http.StatusBadRequest},
+			},
+			{
+				RequestCheck: httpmock.RoundTripRequestCheckJSON(func(t *testing.T, op operation) {
+					assert.Equal(t, []TaggedID{tenantIDs[2]}, op.Variables.IDs)
+				}),
+				Response: httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{garage}, ""))},
+			},
+		})
+
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:       &http.Client{Transport: mockrt},
+			Logger:           slogt.New(t),
+			GraphQLBatching:  true,
+			GraphQLBatchSize: 2,
+		})
+		got, err := client.AccessPointsOfTenants(t.Context(), tenantIDs, WithPartialResults())
+
+		var chunkErr *BatchChunkError
+		assert.True(t, errors.As(err, &chunkErr), "error should be a BatchChunkError: %v", err)
+		assert.Equal(t, 0, chunkErr.Start)
+		assert.Equal(t, 2, chunkErr.End)
+		assert.Equal(t, map[TaggedID][]AccessPoint{tenantIDs[2]: {garage}}, got)
+	})
+
+	t.Run("negative batch size", func(t *testing.T) {
+		mockrt := httpmock.NewSequence(t,
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{frontDoor}, ""))},
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{frontDoor}, "page-2"))},
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{garage}, ""))},
+		)
+
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:       &http.Client{Transport: mockrt},
+			Logger:           slogt.New(t),
+			GraphQLBatching:  true,
+			GraphQLBatchSize: -1,
+		})
+		got, err := client.AccessPointsOfTenants(t.Context(), tenantIDs)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("partial results with a failing page", func(t *testing.T) {
+		mockrt := httpmock.NewSequence(t,
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{frontDoor}, "page-2"))},
+			httpmock.RoundTripResponse{Body: mustMarshal(page([]AccessPoint{garage}, ""))},
+			httpmock.RoundTripResponse{Status: http.StatusBadRequest},
+		)
+
+		got, err := newTestAPIClient(t, mockrt).AccessPointsOfTenants(t.Context(), tenantIDs, WithPartialResults())
+
+		var chunkErr *BatchChunkError
+		assert.True(t, errors.As(err, &chunkErr), "error should be a BatchChunkError: %v", err)
+		assert.Equal(t, 0, chunkErr.Start)
+		assert.Equal(t, 1, chunkErr.End)
+		assert.Equal(t, map[TaggedID][]AccessPoint{tenantIDs[1]: {garage}}, got)
+	})
+
+	t.Run("operation names", func(t *testing.T) {
+		expectOperation := func(name string) httpmock.RoundTripRequestCheck {
+			return func(t *testing.T, req *http.Request) {
+				assert.Equal(t, name, GraphQLOperationName(req.Context()))
+			}
+		}
+
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: expectOperation("TenantAccessPoints"),
+				Response: httpmock.RoundTripResponse{Body: mustMarshal([]any{
+					page([]AccessPoint{frontDoor}, ""),
+					page([]AccessPoint{garage}, ""),
+				})},
+			},
+			{
+				RequestCheck: expectOperation(""),
+				Response:     httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			},
+		})
+
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:      &http.Client{Transport: mockrt},
+			Logger:          slogt.New(t),
+			GraphQLBatching: true,
+		})
+		_, err := client.AccessPointsOfTenants(t.Context(), tenantIDs)
+		assert.NoError(t, err)
+		assert.NoError(t, client.UnlockDoor(t.Context(), 100, 400))
+	})
+}
+
 func newTestAPIClient(t *testing.T, mockrt http.RoundTripper) *APIClient {
 	return NewAPIClient(mockToken, &APIClientOpts{
 		HTTPClient: &http.Client{Transport: mockrt},
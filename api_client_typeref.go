@@ -3,10 +3,12 @@
 package butterflymx
 
 import (
+	"cmp"
 	"encoding/json/jsontext"
 	"encoding/json/v2"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/danielgtaylor/huma/v2"
 )
@@ -27,6 +29,126 @@ const (
 type ResultsWithReferences[T any] struct {
 	Data []T                 `json:"data"`
 	Refs map[ID]RawReference `json:"refs"`
+
+	// duplicates holds the IDs of objects that replaced another object with
+	// the same ID and type in Refs while unmarshaling.
+	duplicates []ID
+	// collisions holds the ID and type of objects that were replaced in Refs
+	// by an object with the same ID but a different type.
+	collisions []RawReference
+}
+
+// ReferenceDiagnostics describes the references of a [ResultsWithReferences].
+// It is meant for debugging relationships that won't resolve, as the objects
+// included by the API aren't always consistent.
+type ReferenceDiagnostics struct {
+	// Counts is the number of objects in the references per type.
+	Counts map[ObjectType]int
+	// Unresolved holds the relationships of the objects in the references
+	// that point to an object that isn't in the references, or whose type
+	// doesn't match, sorted by ID. Only the ID and type of each relationship
+	// are set.
+	Unresolved []RawReference
+	// Duplicates holds the IDs of objects that appeared more than once in the
+	// response, sorted. Since the references are keyed by ID, only the last
+	// object with each ID is kept.
+	Duplicates []ID
+	// Collisions holds the objects that were dropped from the references
+	// because an object of a different type had the same ID, sorted by ID.
+	// Relationships to them can't be resolved. Only the ID and type of each
+	// object are set.
+	Collisions []RawReference
+}
+
+// Diagnostics inspects the references of r. Relationships are read from the
+// raw JSON of every object in [ResultsWithReferences.Refs], so the results
+// themselves must also be in there, as they are for results returned by
+// [APIClient].
+func (r *ResultsWithReferences[T]) Diagnostics() ReferenceDiagnostics {
+	diag := ReferenceDiagnostics{
+		Counts:     make(map[ObjectType]int),
+		Duplicates: slices.Compact(slices.Sorted(slices.Values(r.duplicates))),
+		Collisions: sortedReferences(slices.Clone(r.collisions)),
+	}
+
+	for _, raw := range r.Refs {
+		diag.Counts[raw.Type]++
+		for _, ref := range rawRelationships(raw) {
+			if dst, ok := r.Refs[ref.ID]; !ok || dst.Type != ref.Type {
+				diag.Unresolved = append(diag.Unresolved, ref)
+			}
+		}
+	}
+
+	diag.Unresolved = sortedReferences(diag.Unresolved)
+	return diag
+}
+
+// sortedReferences sorts refs by ID and type and removes duplicates.
+func sortedReferences(refs []RawReference) []RawReference {
+	slices.SortFunc(refs, func(a, b RawReference) int {
+		return cmp.Or(cmp.Compare(a.ID, b.ID), cmp.Compare(a.Type, b.Type))
+	})
+	return slices.CompactFunc(refs, func(a, b RawReference) bool {
+		return a.ID == b.ID && a.Type == b.Type
+	})
+}
+
+// Validate returns an [*InvariantError] if the references of r have
+// unresolved relationships, duplicate IDs or IDs shared by objects of
+// different types. Use [ResultsWithReferences.Diagnostics] for the details.
+func (r *ResultsWithReferences[T]) Validate() error {
+	diag := r.Diagnostics()
+	if len(diag.Unresolved) == 0 && len(diag.Duplicates) == 0 && len(diag.Collisions) == 0 {
+		return nil
+	}
+
+	return &InvariantError{Msg: fmt.Sprintf(
+		"inconsistent references: unresolved %v, duplicate IDs %v, colliding IDs %v",
+		formatReferences(diag.Unresolved), diag.Duplicates, formatReferences(diag.Collisions))}
+}
+
+// formatReferences formats the ID and type of each reference as "type/ID".
+func formatReferences(refs []RawReference) []string {
+	formatted := make([]string, len(refs))
+	for i, ref := range refs {
+		formatted[i] = fmt.Sprintf("%s/%d", ref.Type, ref.ID)
+	}
+	return formatted
+}
+
+// rawRelationships returns the ID and type of every relationship of raw,
+// following the JSON:API format, where each relationship has a data member
+// holding either a single resource identifier or a list of them. Malformed
+// relationships are skipped.
+func rawRelationships(raw RawReference) []RawReference {
+	var object struct {
+		Relationships map[string]struct {
+			Data jsontext.Value `json:"data"`
+		} `json:"relationships"`
+	}
+	if json.Unmarshal(raw.Data, &object) != nil {
+		return nil
+	}
+
+	var refs []RawReference
+	for _, relationship := range object.Relationships {
+		switch relationship.Data.Kind() {
+		case '{':
+			var ref RawReference
+			if json.Unmarshal(relationship.Data, &ref) == nil {
+				refs = append(refs, RawReference{ID: ref.ID, Type: ref.Type})
+			}
+		case '[':
+			var list []RawReference
+			if json.Unmarshal(relationship.Data, &list) == nil {
+				for _, ref := range list {
+					refs = append(refs, RawReference{ID: ref.ID, Type: ref.Type})
+				}
+			}
+		}
+	}
+	return refs
 }
 
 // ResultWithReferences holds a single result of type T along with
@@ -52,6 +174,11 @@ func (ref *TypedReference[T]) Resolve(refs map[ID]RawReference) (*T, error) {
 	if !ok {
 		return nil, fmt.Errorf("reference ID %v not found", ref.ID)
 	}
+	if ref.Type != "" && refDest.Type != ref.Type {
+		// Refs is keyed by ID only, so another object with the same ID
+		// replaced the referenced one.
+		return nil, fmt.Errorf("reference ID %v: expected type %q, found %q", ref.ID, ref.Type, refDest.Type)
+	}
 
 	refData, err := unmarshalReference[T](refDest)
 	if err != nil {
@@ -120,26 +247,53 @@ func unmarshalResultsWithReferences[DataT any](data, included []RawReference) (*
 	}
 
 	for _, raw := range data {
-		results.Refs[raw.ID] = raw
+		results.addRef(raw)
 	}
 
 	for _, raw := range included {
 		if raw.Data == nil {
 			return nil, fmt.Errorf("included object %q: missing data field", raw.ID)
 		}
-		results.Refs[raw.ID] = raw
+		results.addRef(raw)
 	}
 
 	return &results, nil
 }
 
+func (r *ResultsWithReferences[T]) addRef(raw RawReference) {
+	if old, ok := r.Refs[raw.ID]; ok {
+		if old.Type == raw.Type {
+			r.duplicates = append(r.duplicates, raw.ID)
+		} else {
+			r.collisions = append(r.collisions, RawReference{ID: old.ID, Type: old.Type})
+		}
+	}
+	r.Refs[raw.ID] = raw
+}
+
+// merge adds the results and references of other to r. Objects included in
+// both aren't duplicates, since related objects are commonly included in
+// every page that refers to them, but objects of different types sharing an
+// ID are still recorded as collisions.
+func (r *ResultsWithReferences[T]) merge(other *ResultsWithReferences[T]) {
+	r.Data = append(r.Data, other.Data...)
+	r.duplicates = append(r.duplicates, other.duplicates...)
+	r.collisions = append(r.collisions, other.collisions...)
+	for id, raw := range other.Refs {
+		if old, ok := r.Refs[id]; ok && old.Type != raw.Type {
+			r.collisions = append(r.collisions, RawReference{ID: old.ID, Type: old.Type})
+		}
+		r.Refs[id] = raw
+	}
+}
+
 func unmarshalResultWithReferences[DataT any](data RawReference, included []RawReference) (*ResultWithReferences[DataT], error) {
 	results, err := unmarshalResultsWithReferences[DataT]([]RawReference{data}, included)
 	if err != nil {
 		return nil, err
 	}
 	if len(results.Data) != 1 {
-		panic("BUG: expected exactly one data object")
+		return nil, &InvariantError{Msg: fmt.Sprintf("expected exactly one data object, got %d", len(results.Data))}
 	}
 	return &ResultWithReferences[DataT]{
 		Data: results.Data[0],
@@ -0,0 +1,87 @@
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestResultsWithReferences_Diagnostics(t *testing.T) {
+	var response struct {
+		Data     []RawReference `json:"data"`
+		Included []RawReference `json:"included"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"data": [{
+			"id": "1000",
+			"type": "keychains",
+			"attributes": {"name": "Family"},
+			"relationships": {
+				"virtual_keys": {"data": [
+					{"id": "2000", "type": "virtual_keys"},
+					{"id": "2001", "type": "virtual_keys"}
+				]},
+				"devices": {"data": [{"id": "3000", "type": "panels"}]}
+			}
+		}],
+		"included": [
+			{
+				"id": "2000",
+				"type": "virtual_keys",
+				"attributes": {"name": "Jane"},
+				"relationships": {"door_releases": {"data": []}}
+			},
+			{"id": "3000", "type": "buildings", "attributes": {}},
+			{"id": "3000", "type": "buildings", "attributes": {}}
+		]
+	}`), &response))
+
+	results, err := unmarshalResultsWithReferences[Keychain](response.Data, response.Included)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ReferenceDiagnostics{
+		Counts: map[ObjectType]int{
+			TypeKeychain:   1,
+			TypeVirtualKey: 1,
+			TypeBuilding:   1,
+		},
+		Unresolved: []RawReference{
+			{ID: 2001, Type: TypeVirtualKey},
+			{ID: 3000, Type: TypePanel},
+		},
+		Duplicates: []ID{3000},
+	}, results.Diagnostics())
+
+	err = results.Validate()
+	assert.EqualError(t, err, "butterflymx: invariant violated: inconsistent references: "+
+		"unresolved [virtual_keys/2001 panels/3000], duplicate IDs [3000], colliding IDs []")
+
+	t.Run("collisions", func(t *testing.T) {
+		panel := RawReference{ID: 2000, Type: TypePanel, Data: []byte(`{"attributes":{"name":"Front Door"}}`)}
+
+		results, err := unmarshalResultsWithReferences[Keychain](response.Data, append(response.Included[:1:1], panel))
+		assert.NoError(t, err)
+		assert.Equal(t, []RawReference{{ID: 2000, Type: TypeVirtualKey}}, results.Diagnostics().Collisions)
+
+		_, err = results.Data[0].Relationships.VirtualKeys[0].Resolve(results.Refs)
+		assert.EqualError(t, err, `reference ID 2000: expected type "virtual_keys", found "panels"`)
+
+		// Collisions across pages are detected when merging them.
+		page, err := unmarshalResultsWithReferences[Keychain](response.Data, response.Included[:1])
+		assert.NoError(t, err)
+		merged, err := unmarshalResultsWithReferences[Keychain](nil, []RawReference{panel})
+		assert.NoError(t, err)
+		merged.merge(page)
+		merged.merge(page)
+		assert.Equal(t, 2, len(merged.Data))
+		assert.Equal(t, []RawReference{{ID: 2000, Type: TypePanel}}, merged.Diagnostics().Collisions)
+		assert.Equal(t, 0, len(merged.Diagnostics().Duplicates), "objects included in every page aren't duplicates")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		results, err := unmarshalResultsWithReferences[Keychain](response.Data[:0], response.Included[:2])
+		assert.NoError(t, err)
+		assert.NoError(t, results.Validate())
+	})
+}
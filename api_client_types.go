@@ -6,6 +6,8 @@ import (
 	"encoding/json/v2"
 	"fmt"
 	"iter"
+	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -45,6 +47,12 @@ func (p PINCode) Validate() error {
 	return nil
 }
 
+// LogValue implements [slog.LogValuer]. The PIN code is masked so that it
+// never ends up in logs.
+func (p PINCode) LogValue() slog.Value {
+	return slog.StringValue(strings.Repeat("*", len(p)))
+}
+
 // UnmarshalText implements [encoding.TextUnmarshaler].
 func (p *PINCode) UnmarshalText(text []byte) error {
 	newPIN := PINCode(text)
@@ -104,6 +112,22 @@ type Tenant struct {
 	Building  Building `json:"building"`
 }
 
+// String returns the tenant's name along with their ID and unit, e.g.
+// "Jane Doe (prod-tenant-12345, Apt 4B, Hunter Capital)".
+func (t Tenant) String() string {
+	return fmt.Sprintf("%s (%s, %s, %s)", t.Name, t.ID, t.Unit.Label, t.Building.Name)
+}
+
+// LogValue implements [slog.LogValuer]. The tenant's PIN code is omitted.
+func (t Tenant) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", t.ID.String()),
+		slog.String("name", t.Name),
+		slog.String("unit", t.Unit.Label),
+		slog.String("building", t.Building.Name),
+	)
+}
+
 // Unit represents a specific unit within a building.
 type Unit struct {
 	ID          TaggedID `json:"id" example:"prod-unit-40001"`
@@ -126,6 +150,21 @@ type AccessPoint struct {
 	Online       bool     `json:"online" example:"true"`
 }
 
+// String returns the access point's name along with its ID, e.g.
+// "Front Door (prod-access_point-50001)".
+func (a AccessPoint) String() string {
+	return fmt.Sprintf("%s (%s)", a.Name, a.ID)
+}
+
+// LogValue implements [slog.LogValuer].
+func (a AccessPoint) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", a.ID.String()),
+		slog.String("name", a.Name),
+		slog.Bool("online", a.Online),
+	)
+}
+
 // Keychain represents a virtual keychain, containing virtual keys and their associated entities.
 type Keychain struct {
 	ID         ID `json:"id" example:"10001"`
@@ -159,6 +198,23 @@ type Keychain struct {
 	} `json:"relationships"`
 }
 
+// String returns the keychain's ID and name, e.g. `keychain 10001 "Amazon
+// Delivery"`.
+func (k Keychain) String() string {
+	return fmt.Sprintf("keychain %d %q", k.ID, k.Attributes.Name)
+}
+
+// LogValue implements [slog.LogValuer].
+func (k Keychain) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", int(k.ID)),
+		slog.String("name", k.Attributes.Name),
+		slog.String("kind", string(k.Attributes.Kind)),
+		slog.Time("starts_at", k.Attributes.StartsAt),
+		slog.Time("ends_at", k.Attributes.EndsAt),
+	)
+}
+
 // VirtualKey represents an allocated door PIN code for a contact (not
 // necessarily a user but usually is).
 type VirtualKey struct {
@@ -176,6 +232,22 @@ type VirtualKey struct {
 	} `json:"relationships"`
 }
 
+// String returns the virtual key's ID and recipient, e.g. `virtual key 10002
+// "john.doe@example.com"`. The PIN code is not included.
+func (v VirtualKey) String() string {
+	return fmt.Sprintf("virtual key %d %q", v.ID, v.Attributes.Name)
+}
+
+// LogValue implements [slog.LogValuer]. The PIN code is masked, and the QR
+// code and instructions URLs, which grant access on their own, are omitted.
+func (v VirtualKey) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", int(v.ID)),
+		slog.String("name", v.Attributes.Name),
+		slog.Any("pin", v.Attributes.PINCode),
+	)
+}
+
 // DoorRelease represents an event of a door being released.
 type DoorRelease struct {
 	ID         ID `json:"id" example:"30001"`
@@ -241,7 +313,7 @@ const (
 // --- GraphQL Specific Types (can be moved if file is split) ---
 
 const tenantsQuery = `
-	query Tenants($after: String) { tenants(after: $after) { pageInfo { ...PageInfoFragment } nodes { ...TenantFragment } } }
+	query Tenants($after: String, $first: Int) { tenants(after: $after, first: $first) { pageInfo { ...PageInfoFragment } nodes { ...TenantFragment } } }
 	fragment PageInfoFragment on PageInfo { hasNextPage endCursor }
 	fragment UnitFragment on Unit { id label floorNumber }
 	fragment BuildingFragment on Building { id guid name }
@@ -258,7 +330,7 @@ type tenantsGraphQLResponse struct {
 }
 
 const tenantAccessPointsQuery = `
-	query TenantAccessPoints($ids: [ID!]!, $after: String) { nodes(ids: $ids) { __typename id ... on Tenant { accessPoints(after: $after) { pageInfo { ...PageInfoFragment } nodes { ...AccessPointFragment } } } } }
+	query TenantAccessPoints($ids: [ID!]!, $after: String, $first: Int) { nodes(ids: $ids) { __typename id ... on Tenant { accessPoints(after: $after, first: $first) { pageInfo { ...PageInfoFragment } nodes { ...AccessPointFragment } } } } }
 	fragment PageInfoFragment on PageInfo { hasNextPage endCursor }
 	fragment AccessPointFragment on AccessPoint { id name openDuration online }
 `
@@ -274,6 +346,8 @@ type tenantAccessPointsGraphQLResponse struct {
 	} `json:"data"`
 }
 
+const pingQuery = `query Ping { __typename }`
+
 type PageInfo struct {
 	HasNextPage bool   `json:"hasNextPage" example:"true"`
 	EndCursor   string `json:"endCursor" example:"eyJpZCI6IjEwMDAxIn0"`
@@ -0,0 +1,69 @@
+package butterflymx
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestTypes_logging(t *testing.T) {
+	tenant := Tenant{
+		ID:       NewTaggedID("tenant", 12345),
+		Name:     "Jane Doe",
+		PINCode:  "012345",
+		Unit:     Unit{ID: NewTaggedID("unit", 40001), Label: "Apt 4B"},
+		Building: Building{ID: NewTaggedID("building", 40003), Name: "Hunter Capital"},
+	}
+	accessPoint := AccessPoint{ID: NewTaggedID("access_point", 50001), Name: "Front Door", Online: true}
+
+	var keychain Keychain
+	keychain.ID = 10001
+	keychain.Attributes.Name = "Amazon Delivery"
+	keychain.Attributes.Kind = CustomKeychain
+
+	var virtualKey VirtualKey
+	virtualKey.ID = 10002
+	virtualKey.Attributes.Name = "john.doe@example.com"
+	virtualKey.Attributes.PINCode = "987654"
+	virtualKey.Attributes.InstructionsURL = "https://butterflymx.com/instructions/secret-uuid"
+
+	assert.Equal(t, "Jane Doe (prod-tenant-12345, Apt 4B, Hunter Capital)", fmt.Sprint(tenant))
+	assert.Equal(t, "Front Door (prod-access_point-50001)", fmt.Sprint(accessPoint))
+	assert.Equal(t, `keychain 10001 "Amazon Delivery"`, fmt.Sprint(keychain))
+	assert.Equal(t, `virtual key 10002 "john.doe@example.com"`, fmt.Sprint(virtualKey))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("test",
+		"tenant", tenant,
+		"access_point", accessPoint,
+		"keychain", keychain,
+		"virtual_key", virtualKey)
+
+	out := buf.String()
+	for _, want := range []string{
+		"tenant.id=prod-tenant-12345",
+		`tenant.name="Jane Doe"`,
+		"access_point.online=true",
+		"keychain.id=10001",
+		"keychain.kind=custom",
+		"virtual_key.id=10002",
+		"virtual_key.pin=******",
+	} {
+		assert.True(t, strings.Contains(out, want), "log output %q should contain %q", out, want)
+	}
+	for _, secret := range []string{"012345", "987654", "secret-uuid"} {
+		assert.False(t, strings.Contains(out, secret), "log output %q should not contain %q", out, secret)
+	}
+}
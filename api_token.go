@@ -2,7 +2,10 @@ package butterflymx
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
+	"time"
 )
 
 // APIStaticToken represents a static ButterflyMX API token.
@@ -26,30 +29,118 @@ type APITokenSource interface {
 	APIToken(ctx context.Context, renew bool) (APIStaticToken, error)
 }
 
+// DefaultAPITokenEarlyRefresh is the default
+// [ReuseAPITokenSourceOpts.EarlyRefresh].
+var DefaultAPITokenEarlyRefresh = 30 * time.Second
+
+// ReuseAPITokenSourceOpts holds optional parameters for
+// [ReuseAPITokenSourceWithOpts].
+type ReuseAPITokenSourceOpts struct {
+	// Store, if set, keeps the token across restarts. See
+	// [ReuseStoredAPITokenSource].
+	Store TokenStore
+	// TTL is how long a token is assumed to be valid for after it is obtained
+	// if its expiry can't be read from the token itself, see
+	// [APIStaticToken.Expiry]. Tokens loaded from Store are assumed to have
+	// just been obtained. Zero means that such tokens are used until a caller
+	// asks to renew them.
+	TTL time.Duration
+	// EarlyRefresh is how long before a token expires it is renewed, so that
+	// requests in flight don't fail because the token expired on the way. It
+	// defaults to [DefaultAPITokenEarlyRefresh], negative for none.
+	EarlyRefresh time.Duration
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+	// OnTokenRenewed, if set, is called whenever a new token is obtained
+	// from the wrapped token source, e.g. to persist it, emit metrics or
+	// update other clients. It isn't called for tokens loaded from Store. It
+	// is called while the token source is locked, so it must not use the
+	// token source itself.
+	OnTokenRenewed func(token APIStaticToken)
+}
+
 // ReuseAPITokenSource returns a new [APITokenSource] that obeys the [renew]
 // parameter. If [src] is already a reused token source, it is returned as-is.
+//
+// If the expiry of a token is known, see [APIStaticToken.Expiry], it is
+// renewed shortly before it expires even if [renew] is false. Use
+// [ReuseAPITokenSourceWithOpts] to configure this.
+//
+// The returned token source is safe for concurrent use, and [src] is never
+// called concurrently.
 func ReuseAPITokenSource(src APITokenSource) APITokenSource {
 	if reused, ok := src.(*reusedAPITokenSource); ok {
 		return reused
 	}
-	return &reusedAPITokenSource{
-		new: src,
+	return ReuseAPITokenSourceWithOpts(src, nil)
+}
+
+// ReuseStoredAPITokenSource is like [ReuseAPITokenSource], but it also keeps
+// the token in store under [APITokenStoreKey]. The stored token is used until
+// it needs to be renewed, so that restarting the process doesn't require a new
+// token exchange. If renewing fails with an [*AuthError], the stored token is
+// deleted.
+func ReuseStoredAPITokenSource(src APITokenSource, store TokenStore) APITokenSource {
+	return ReuseAPITokenSourceWithOpts(src, &ReuseAPITokenSourceOpts{Store: store})
+}
+
+// ReuseAPITokenSourceWithOpts is like [ReuseAPITokenSource], but it always
+// wraps [src] and lets the expiry of tokens be configured. If opts is nil,
+// the defaults are used.
+func ReuseAPITokenSourceWithOpts(src APITokenSource, opts *ReuseAPITokenSourceOpts) APITokenSource {
+	opts = use(opts, &ReuseAPITokenSourceOpts{})
+	s := &reusedAPITokenSource{
+		new:          src,
+		store:        opts.Store,
+		ttl:          opts.TTL,
+		earlyRefresh: use(opts.EarlyRefresh, DefaultAPITokenEarlyRefresh),
+		now:          time.Now,
+		onRenewed:    opts.OnTokenRenewed,
+	}
+	if opts.Now != nil {
+		s.now = opts.Now
+	}
+	return s
+}
+
+// ForgetAPIToken drops the API token cached in memory by src, if it was
+// returned by [ReuseAPITokenSource] or a variant, so that the next call
+// obtains a new token instead, e.g. after logging out. A token kept in its
+// store isn't deleted. Other token sources are left alone.
+func ForgetAPIToken(src APITokenSource) {
+	reused, ok := src.(*reusedAPITokenSource)
+	if !ok {
+		return
 	}
+	reused.mu.Lock()
+	defer reused.mu.Unlock()
+	reused.old = ""
+	reused.expiry = time.Time{}
 }
 
 type reusedAPITokenSource struct {
-	mu  sync.RWMutex
-	new APITokenSource
-	old APIStaticToken
+	mu    sync.RWMutex
+	new   APITokenSource
+	old   APIStaticToken
+	store TokenStore // optional
+
+	ttl          time.Duration
+	earlyRefresh time.Duration
+	now          func() time.Time
+	onRenewed    func(APIStaticToken) // optional
+
+	// expiry is when old expires, or zero if unknown, in which case old is
+	// used until a caller asks to renew it.
+	expiry time.Time
 }
 
 func (s *reusedAPITokenSource) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
 	if !renew {
 		s.mu.RLock()
-		token := s.old
+		token, valid := s.old, s.valid()
 		s.mu.RUnlock()
 
-		if token != "" {
+		if valid {
 			return token, nil
 		}
 	}
@@ -57,11 +148,85 @@ func (s *reusedAPITokenSource) APIToken(ctx context.Context, renew bool) (APISta
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	var err error
-	s.old, err = s.new.APIToken(ctx, renew)
+	// Another caller may have renewed the token while we waited for the lock.
+	if !renew && s.valid() {
+		return s.old, nil
+	}
+
+	if !renew && s.old == "" && s.store != nil {
+		stored, err := s.store.Load(ctx, APITokenStoreKey)
+		if err == nil && len(stored) > 0 {
+			s.setToken(APIStaticToken(stored))
+			if s.valid() {
+				return s.old, nil
+			}
+		}
+		if err != nil && !errors.Is(err, ErrTokenNotFound) {
+			return "", fmt.Errorf("failed to load API token: %w", err)
+		}
+	}
+
+	// An expired token must be renewed even if the caller didn't ask to.
+	renew = renew || s.old != ""
+
+	token, err := s.new.APIToken(ctx, renew)
 	if err != nil {
+		var authErr *AuthError
+		if s.store != nil && errors.As(err, &authErr) {
+			if err := s.store.Delete(ctx, APITokenStoreKey); err != nil {
+				return "", fmt.Errorf("failed to delete API token: %w", err)
+			}
+		}
 		return "", err
 	}
 
+	if err := s.renewed(ctx, token); err != nil {
+		return "", err
+	}
 	return s.old, nil
 }
+
+// seed caches token, which was obtained elsewhere, as if it had just been
+// renewed.
+func (s *reusedAPITokenSource) seed(ctx context.Context, token APIStaticToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.renewed(ctx, token)
+}
+
+// renewed saves and caches a newly obtained token. s.mu must be held.
+func (s *reusedAPITokenSource) renewed(ctx context.Context, token APIStaticToken) error {
+	if s.store != nil {
+		if err := s.store.Save(ctx, APITokenStoreKey, []byte(token)); err != nil {
+			return fmt.Errorf("failed to save API token: %w", err)
+		}
+	}
+
+	s.setToken(token)
+	if s.onRenewed != nil {
+		s.onRenewed(token)
+	}
+	return nil
+}
+
+// setToken caches token along with its expiry, if known. s.mu must be held.
+func (s *reusedAPITokenSource) setToken(token APIStaticToken) {
+	s.old = token
+	s.expiry, _ = token.Expiry()
+	if s.expiry.IsZero() && s.ttl > 0 {
+		s.expiry = s.now().Add(s.ttl)
+	}
+}
+
+// valid reports whether the cached token can be returned without renewing
+// it: it must exist and not be about to expire, if its expiry is known. s.mu
+// must be held.
+func (s *reusedAPITokenSource) valid() bool {
+	if s.old == "" {
+		return false
+	}
+	if s.expiry.IsZero() {
+		return true
+	}
+	return s.now().Before(s.expiry.Add(-max(s.earlyRefresh, 0)))
+}
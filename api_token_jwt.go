@@ -0,0 +1,36 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"encoding/base64"
+	"encoding/json/v2"
+	"strings"
+	"time"
+)
+
+// Expiry returns when the token expires, read from the "exp" claim of the
+// token if it is a JWT. The signature isn't verified, since the token is only
+// meaningful to the server. It returns false if the token isn't a JWT or
+// doesn't have an expiry.
+func (t APIStaticToken) Expiry() (time.Time, bool) {
+	parts := strings.Split(string(t), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+
+	sec := int64(*claims.Exp)
+	return time.Unix(sec, 0), true
+}
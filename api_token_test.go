@@ -0,0 +1,512 @@
+package butterflymx
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/cenkalti/backoff/v5"
+	"github.com/neilotoole/slogt"
+	"golang.org/x/oauth2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+// TokenSourceStep is a single programmed result of a [TokenSourceScript].
+type TokenSourceStep struct {
+	Token APIStaticToken
+	Err   error
+}
+
+// TokenSourceScript is an [APITokenSource] that returns a programmed sequence
+// of tokens and errors, recording the renew flag of every call. The test fails
+// if the script is called more times than it has steps, or if not all steps
+// were consumed by the end of the test.
+type TokenSourceScript struct {
+	t      *testing.T
+	mu     sync.Mutex
+	steps  []TokenSourceStep
+	renews []bool
+}
+
+var _ APITokenSource = (*TokenSourceScript)(nil)
+
+// NewTokenSourceScript creates a new [TokenSourceScript].
+func NewTokenSourceScript(t *testing.T, steps ...TokenSourceStep) *TokenSourceScript {
+	s := &TokenSourceScript{t: t, steps: steps}
+	t.Cleanup(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if len(s.renews) < len(s.steps) {
+			t.Errorf("TokenSourceScript: only %d of %d steps were used", len(s.renews), len(s.steps))
+		}
+	})
+	return s
+}
+
+// APIToken implements [APITokenSource].
+func (s *TokenSourceScript) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := len(s.renews)
+	s.renews = append(s.renews, renew)
+
+	if i >= len(s.steps) {
+		s.t.Errorf("TokenSourceScript: unexpected call %d (renew=%v), only %d steps programmed", i+1, renew, len(s.steps))
+		return "", fmt.Errorf("token source script exhausted")
+	}
+
+	step := s.steps[i]
+	return step.Token, step.Err
+}
+
+// Renews returns the renew flag of every call made so far.
+func (s *TokenSourceScript) Renews() []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]bool(nil), s.renews...)
+}
+
+func TestReuseAPITokenSource(t *testing.T) {
+	t.Run("reuse", func(t *testing.T) {
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "first"},
+			TokenSourceStep{Token: "second"},
+		)
+		src := ReuseAPITokenSource(script)
+
+		for _, want := range []APIStaticToken{"first", "first"} {
+			token, err := src.APIToken(t.Context(), false)
+			assert.NoError(t, err)
+			assert.Equal(t, want, token)
+		}
+
+		token, err := src.APIToken(t.Context(), true)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", token)
+
+		token, err = src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", token)
+
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("error is not cached", func(t *testing.T) {
+		errExpired := errors.New("refresh token expired")
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Err: errExpired},
+			TokenSourceStep{Token: "first"},
+		)
+		src := ReuseAPITokenSource(script)
+
+		_, err := src.APIToken(t.Context(), false)
+		assert.IsError(t, err, errExpired)
+
+		token, err := src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", token)
+
+		assert.Equal(t, []bool{false, false}, script.Renews())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expired := testJWT(t, time.Now().Add(-time.Minute))
+		valid := testJWT(t, time.Now().Add(time.Hour))
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: expired},
+			TokenSourceStep{Token: valid},
+		)
+		src := ReuseAPITokenSource(script)
+
+		for _, want := range []APIStaticToken{expired, valid, valid} {
+			token, err := src.APIToken(t.Context(), false)
+			assert.NoError(t, err)
+			assert.Equal(t, want, token)
+		}
+
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("already reused", func(t *testing.T) {
+		src := ReuseAPITokenSource(APIStaticToken("static"))
+		assert.Equal(t, src, ReuseAPITokenSource(src))
+	})
+}
+
+func TestReuseAPITokenSourceWithOpts(t *testing.T) {
+	t.Run("early refresh", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		first := testJWT(t, now.Add(time.Hour))
+		second := testJWT(t, now.Add(2*time.Hour))
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: first},
+			TokenSourceStep{Token: second},
+		)
+		src := ReuseAPITokenSourceWithOpts(script, &ReuseAPITokenSourceOpts{
+			EarlyRefresh: time.Minute,
+			Now:          func() time.Time { return now },
+		})
+
+		token, err := src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, first, token)
+
+		now = now.Add(58 * time.Minute)
+		token, err = src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, first, token, "token should be reused before the margin")
+
+		now = now.Add(time.Minute)
+		token, err = src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, second, token, "token should be renewed within the margin")
+
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "first"},
+			TokenSourceStep{Token: "second"},
+		)
+		src := ReuseAPITokenSourceWithOpts(script, &ReuseAPITokenSourceOpts{
+			TTL:          10 * time.Minute,
+			EarlyRefresh: -1,
+			Now:          func() time.Time { return now },
+		})
+
+		for _, step := range []struct {
+			elapsed time.Duration
+			want    APIStaticToken
+		}{
+			{0, "first"},
+			{10*time.Minute - time.Second, "first"},
+			{time.Second, "second"},
+			{time.Minute, "second"},
+		} {
+			now = now.Add(step.elapsed)
+			token, err := src.APIToken(t.Context(), false)
+			assert.NoError(t, err)
+			assert.Equal(t, step.want, token)
+		}
+
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("on token renewed", func(t *testing.T) {
+		store := &MemoryTokenStore{}
+		assert.NoError(t, store.Save(t.Context(), APITokenStoreKey, []byte("stored")))
+
+		script := NewTokenSourceScript(t, TokenSourceStep{Token: "renewed"})
+		var renewed []APIStaticToken
+		src := ReuseAPITokenSourceWithOpts(script, &ReuseAPITokenSourceOpts{
+			Store:          store,
+			OnTokenRenewed: func(token APIStaticToken) { renewed = append(renewed, token) },
+		})
+
+		for _, renew := range []bool{false, true, false} {
+			_, err := src.APIToken(t.Context(), renew)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []APIStaticToken{"renewed"}, renewed, "stored tokens should not be reported")
+	})
+}
+
+func TestAPIStaticToken_Expiry(t *testing.T) {
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiry, ok := testJWT(t, exp).Expiry()
+	assert.True(t, ok)
+	assert.True(t, exp.Equal(expiry), "got %v", expiry)
+
+	for _, token := range []APIStaticToken{"meowmeow", "a.b.c", "a.e30.c"} {
+		_, ok := token.Expiry()
+		assert.False(t, ok, "token %q", token)
+	}
+}
+
+// testJWT returns an unsigned JWT that expires at exp.
+func testJWT(t *testing.T, exp time.Time) APIStaticToken {
+	payload, err := json.Marshal(map[string]any{"sub": "1", "exp": exp.Unix()})
+	assert.NoError(t, err)
+	return APIStaticToken("eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".sig")
+}
+
+func TestAPIClient_renewTokenOnUnauthorized(t *testing.T) {
+	requestCheckBearer := func(token string) httpmock.RoundTripRequestCheck {
+		return func(t *testing.T, req *http.Request) {
+			assert.Equal(t, "Bearer "+token, req.Header.Get("Authorization"))
+		}
+	}
+
+	unauthorized := httpmock.RoundTripResponse{Status: http.StatusUnauthorized}
+
+	t.Run("renewed", func(t *testing.T) {
+		keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "stale"},
+			TokenSourceStep{Token: "fresh"},
+		)
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{RequestCheck: requestCheckBearer("stale"), Response: unauthorized},
+			{RequestCheck: requestCheckBearer("fresh"), Response: httpmock.RoundTripResponse{Body: keychainResponse}},
+		})
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("still unauthorized", func(t *testing.T) {
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "stale"},
+			TokenSourceStep{Token: "stale"},
+		)
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{RequestCheck: requestCheckBearer("stale"), Response: unauthorized},
+			{RequestCheck: requestCheckBearer("stale"), Response: unauthorized},
+		})
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		var authErr *AuthError
+		assert.True(t, errors.As(err, &authErr), "error should be an AuthError: %v", err)
+		assert.Equal(t, AuthFailureAPITokenExpired, authErr.Failure)
+		assert.False(t, authErr.NeedsLogin())
+		assert.IsError(t, err, ErrTokenExpired)
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		token := testJWT(t, time.Now().Add(time.Hour))
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: token},
+			TokenSourceStep{Token: token},
+		)
+		mockrt := httpmock.NewSequence(t, unauthorized, unauthorized)
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		var authErr *AuthError
+		assert.True(t, errors.As(err, &authErr), "error should be an AuthError: %v", err)
+		assert.Equal(t, AuthFailureAPITokenInvalid, authErr.Failure)
+		assert.True(t, authErr.NeedsLogin())
+		assert.IsError(t, err, ErrTokenInvalid)
+		assert.NotIsError(t, err, ErrTokenExpired)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+		forbidden := httpmock.RoundTripResponse{Status: http.StatusForbidden}
+
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "stale"},
+			TokenSourceStep{Token: "fresh"},
+		)
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{RequestCheck: requestCheckBearer("stale"), Response: forbidden},
+			{RequestCheck: requestCheckBearer("fresh"), Response: httpmock.RoundTripResponse{Body: keychainResponse}},
+		})
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+
+	t.Run("still forbidden", func(t *testing.T) {
+		forbidden := httpmock.RoundTripResponse{Status: http.StatusForbidden}
+
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "stale"},
+			TokenSourceStep{Token: "fresh"},
+		)
+		mockrt := httpmock.NewSequence(t, forbidden, forbidden)
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr), "error should be an APIError: %v", err)
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+		var authErr *AuthError
+		assert.False(t, errors.As(err, &authErr), "a persistent 403 is not an auth failure")
+		assert.IsError(t, err, ErrForbidden)
+	})
+
+	t.Run("renew fails", func(t *testing.T) {
+		errRevoked := errors.New("refresh token revoked")
+		script := NewTokenSourceScript(t,
+			TokenSourceStep{Token: "stale"},
+			TokenSourceStep{Err: errRevoked},
+		)
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{RequestCheck: requestCheckBearer("stale"), Response: unauthorized},
+		})
+
+		_, err := newScriptedAPIClient(t, script, mockrt).Keychain(t.Context(), 10001)
+		assert.IsError(t, err, errRevoked)
+		assert.Equal(t, []bool{false, true}, script.Renews())
+	})
+}
+
+func TestAuthFailureClassification(t *testing.T) {
+	t.Run("oauth2", func(t *testing.T) {
+		invalidGrant := &oauth2.RetrieveError{ErrorCode: "invalid_grant"}
+		var authErr *AuthError
+		assert.True(t, errors.As(classifyOAuth2Error(fmt.Errorf("refresh: %w", invalidGrant)), &authErr))
+		assert.Equal(t, AuthFailureRefreshTokenExpired, authErr.Failure)
+		assert.True(t, authErr.NeedsLogin())
+		assert.IsError(t, authErr, invalidGrant)
+
+		// Other errors may be temporary, so they must not ask for a login.
+		errNetwork := errors.New("connection reset")
+		assert.Equal(t, errNetwork, classifyOAuth2Error(errNetwork))
+		serverErr := &oauth2.RetrieveError{ErrorCode: "server_error"}
+		assert.Equal[error](t, serverErr, classifyOAuth2Error(serverErr))
+	})
+
+	t.Run("login", func(t *testing.T) {
+		tests := []struct {
+			status   int
+			failure  AuthFailure
+			sentinel error
+		}{
+			{http.StatusUnauthorized, AuthFailureRefreshTokenExpired, ErrTokenExpired},
+			{http.StatusForbidden, AuthFailureAccountDisabled, ErrForbidden},
+		}
+		for _, test := range tests {
+			err := checkLoginResponse(&http.Response{StatusCode: test.status})
+			var authErr *AuthError
+			assert.True(t, errors.As(err, &authErr), "status %d", test.status)
+			assert.Equal(t, test.failure, authErr.Failure)
+			assert.True(t, authErr.NeedsLogin())
+			assert.IsError(t, err, test.sentinel)
+		}
+
+		err := checkLoginResponse(&http.Response{StatusCode: http.StatusBadGateway})
+		var authErr *AuthError
+		assert.False(t, errors.As(err, &authErr))
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+
+		assert.NoError(t, checkLoginResponse(&http.Response{StatusCode: http.StatusOK}))
+	})
+}
+
+func TestDenizenLoginClientOpts(t *testing.T) {
+	client := NewDenizenLoginClientWithOpts(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth2-token"}),
+		&DenizenLoginClientOpts{
+			HTTPClient: &http.Client{Transport: httpmock.NewRoundTripper(t, []httpmock.RoundTrip{{
+				RequestCheck: httpmock.Expect().
+					Post("/denizen/v1/login").
+					Header("User-Agent", "test-agent").
+					JSONPath("access_token", "oauth2-token").
+					Check,
+				Response: httpmock.RoundTripResponse{Body: []byte(`{"token":"api-token"}`)},
+			}})},
+			Logger:    slogt.New(t),
+			UserAgent: "test-agent",
+		},
+	)
+
+	token, err := client.APIToken(t.Context(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, APIStaticToken("api-token"), token)
+}
+
+func TestDenizenLoginClient_Login(t *testing.T) {
+	store := &MemoryTokenStore{}
+	client := NewDenizenLoginClientWithOpts(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth2-token"}),
+		&DenizenLoginClientOpts{
+			HTTPClient: &http.Client{Transport: httpmock.NewSequence(t, httpmock.RoundTripResponse{
+				Body: []byte(`{
+					"token": "api-token",
+					"user": {
+						"id": "prod-user-1",
+						"tenantIds": ["prod-tenant-100", "prod-tenant-101"],
+						"features": ["virtual_keys"],
+						"email": "jane@example.com"
+					},
+					"expiresIn": 300
+				}`),
+			})},
+			Logger: slogt.New(t),
+		},
+	)
+	client.TokenStore = store
+
+	session, err := client.Login(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, APIStaticToken("api-token"), session.Token)
+	assert.Equal(t, NewTaggedID("user", 1), session.User.ID)
+	assert.Equal(t, []TaggedID{TenantTaggedID(100), TenantTaggedID(101)}, session.User.TenantIDs)
+	assert.Equal(t, []string{"virtual_keys"}, session.User.Features)
+
+	var extra map[string]any
+	assert.NoError(t, json.Unmarshal(session.Extra, &extra))
+	assert.Equal(t, map[string]any{"expiresIn": 300.0}, extra)
+	var userExtra map[string]any
+	assert.NoError(t, json.Unmarshal(session.User.Extra, &userExtra))
+	assert.Equal(t, map[string]any{"email": "jane@example.com"}, userExtra)
+
+	// The token source reuses the token of the login instead of exchanging
+	// again, which the sequence above would reject.
+	token, err := client.APITokenSource().APIToken(t.Context(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, APIStaticToken("api-token"), token)
+
+	stored, err := store.Load(t.Context(), APITokenStoreKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "api-token", string(stored))
+}
+
+func newScriptedAPIClient(t *testing.T, script *TokenSourceScript, mockrt http.RoundTripper) *APIClient {
+	return NewAPIClient(script, &APIClientOpts{
+		HTTPClient:     &http.Client{Transport: mockrt},
+		Logger:         slogt.New(t),
+		RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+	})
+}
+
+func TestNewDenizenLoginClientFromRefreshToken(t *testing.T) {
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{
+			RequestCheck: func(t *testing.T, req *http.Request) {
+				assert.NoError(t, req.ParseForm())
+				assert.Equal(t, AccountAuthConfig.Endpoint.TokenURL, req.URL.String())
+				assert.Equal(t, "refresh_token", req.PostForm.Get("grant_type"))
+				assert.Equal(t, "refresh", req.PostForm.Get("refresh_token"))
+				assert.Equal(t, "client", req.PostForm.Get("client_id"))
+			},
+			Response: httpmock.RoundTripResponse{
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    []byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`),
+			},
+		},
+		{
+			RequestCheck: httpmock.Expect().
+				Post("/denizen/v1/login").
+				JSONPath("access_token", "access").
+				Check,
+			Response: httpmock.RoundTripResponse{Body: []byte(`{"token":"api-token"}`)},
+		},
+	})
+	// The OAuth2 token is refreshed using the HTTP client in the context.
+	ctx := context.WithValue(t.Context(), oauth2.HTTPClient, &http.Client{Transport: mockrt})
+
+	client := NewDenizenLoginClientFromRefreshToken(ctx, "refresh", "client")
+	client.opts.HTTPClient = &http.Client{Transport: mockrt}
+
+	token, err := client.APIToken(t.Context(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, APIStaticToken("api-token"), token)
+}
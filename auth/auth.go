@@ -0,0 +1,45 @@
+// Package auth describes the OAuth2 identity provider of ButterflyMX, the
+// accounts service, as used by the official mobile app. None of it is
+// documented by ButterflyMX; the values were captured from the app, so they
+// may change without notice.
+//
+// The package only depends on [golang.org/x/oauth2], so that it can be used
+// without the API client in package butterflymx, which builds on it.
+package auth
+
+import "golang.org/x/oauth2"
+
+// Endpoints of the accounts service. The app hasn't been captured logging out,
+// so the token revocation endpoint, if there is one, isn't known.
+const (
+	AuthURL  = "https://accounts.butterflymx.com/oauth/authorize"
+	TokenURL = "https://accounts.butterflymx.com/oauth/token"
+)
+
+// DefaultClientID is the OAuth2 client ID of the official Android app.
+const DefaultClientID = "0e3aeeb7cec2782b9fb21352a4349a44405ed5d7674072416b6481d51abfd6b6"
+
+// RedirectURL is the redirect URL that the official app uses to finish the
+// authorization flow. The client ID is only registered with this URL, so it
+// must be given to the server even though it can't be opened in a browser.
+const RedirectURL = "com.butterflymx.oauth://oauth"
+
+// Endpoint is the [oauth2.Endpoint] of the accounts service.
+var Endpoint = oauth2.Endpoint{
+	AuthURL:   AuthURL,
+	TokenURL:  TokenURL,
+	AuthStyle: oauth2.AuthStyleInParams,
+}
+
+// Config returns a new [oauth2.Config] for the accounts service using the
+// given client ID, or [DefaultClientID] if it is empty.
+func Config(clientID string) *oauth2.Config {
+	if clientID == "" {
+		clientID = DefaultClientID
+	}
+	return &oauth2.Config{
+		ClientID:    clientID,
+		Endpoint:    Endpoint,
+		RedirectURL: RedirectURL,
+	}
+}
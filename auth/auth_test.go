@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestConfig(t *testing.T) {
+	config := Config("")
+	assert.Equal(t, DefaultClientID, config.ClientID)
+	assert.Equal(t, TokenURL, config.Endpoint.TokenURL)
+	assert.Equal(t, RedirectURL, config.RedirectURL)
+
+	config.Endpoint.TokenURL = "http://localhost/token"
+	assert.Equal(t, TokenURL, Config("").Endpoint.TokenURL, "configs should not share state")
+	assert.Equal(t, "client", Config("client").ClientID)
+}
@@ -3,27 +3,29 @@ package butterflymx
 import (
 	"context"
 	"crypto/rand"
-	"encoding/base64"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strings"
 
 	"golang.org/x/oauth2"
+	"libdb.so/go-butterflymx/auth"
 )
 
 // AccountAuthConfig is an [oauth2.Config] for the ButterflyMX accounts service
-// with the appropriate configuration.
-var AccountAuthConfig = &oauth2.Config{
-	ClientID: "0e3aeeb7cec2782b9fb21352a4349a44405ed5d7674072416b6481d51abfd6b6",
-	Endpoint: oauth2.Endpoint{
-		AuthURL:   "https://accounts.butterflymx.com/oauth/authorize",
-		TokenURL:  "https://accounts.butterflymx.com/oauth/token",
-		AuthStyle: oauth2.AuthStyleInParams,
-	},
-	// RedirectURI is the redirect URI that is used by the ButterflyMX app to
-	// finish the OAuth2 flow. We're not using this URL for anything, but we
-	// give it to the server to satisfy its requirements.
-	RedirectURL: "com.butterflymx.oauth://oauth",
-}
+// with the appropriate configuration. See package auth for its endpoints.
+var AccountAuthConfig = auth.Config("")
+
+// AccountRevokeURL is the OAuth2 token revocation endpoint (RFC 7009) of the
+// ButterflyMX accounts service, used by [AuthFlowClient.RevokeToken]. The
+// official app hasn't been captured revoking tokens, so it is empty by default
+// and tokens can't be revoked until it is set.
+var AccountRevokeURL = ""
+
+// ErrRevokeUnsupported is returned by [AuthFlowClient.RevokeToken] if no token
+// revocation endpoint is known, see [AccountRevokeURL].
+var ErrRevokeUnsupported = errors.New("butterflymx: no token revocation endpoint is known")
 
 // AuthFlowClient handles the flow of exchanging user credentials for an OAuth2
 // token. It is built with the assumption that the user manually visits the
@@ -35,11 +37,16 @@ var AccountAuthConfig = &oauth2.Config{
 // manually feed back the redirected URL, we can extract the authorization code
 // and state from it without needing to handle the redirection ourselves.
 //
+// The flow uses PKCE, like the official app. A localhost redirect listener
+// isn't offered, since the client ID is only registered with the app's redirect
+// URL.
+//
 // TODO: write a light browser wrapper that interjects the redirection request
 // with this URL and finishes the handshake automatically. We can't use a normal
 // browser because the server will likely flag all HTTP redirect URLs.
 type AuthFlowClient struct {
-	config *oauth2.Config
+	config    *oauth2.Config
+	revokeURL string // defaults to [AccountRevokeURL]
 }
 
 // NewAuthFlowClient creates a new [AuthFlowClient] with the default configuration.
@@ -118,10 +125,121 @@ func (f *AuthFlowClient) Finish(ctx context.Context, start AuthFlowStart, redire
 	return token, nil
 }
 
-func generateState() string {
-	var r [16]byte
-	if _, err := rand.Read(r[:]); err != nil {
-		panic("failed to generate random state: " + err.Error())
+// PasswordLogin logs in with the username and password of a resident using
+// the OAuth2 resource owner password credentials grant and returns a token
+// source that refreshes the token as needed, like [AuthFlowClient.TokenSource].
+//
+// The official app doesn't log in this way, and the accounts service may
+// reject the grant or require a second factor, in which case the interactive
+// flow of [AuthFlowClient.Start] must be used instead. Prefer that flow when
+// possible, since it avoids storing passwords.
+func (f *AuthFlowClient) PasswordLogin(ctx context.Context, username, password string) (oauth2.TokenSource, error) {
+	token, err := f.config.PasswordCredentialsToken(ctx, username, password)
+	if err != nil {
+		return nil, fmt.Errorf("password login failed: %w", err)
+	}
+	return f.TokenSource(ctx, token), nil
+}
+
+// TokenSource returns a token source that starts with token, such as the one
+// returned by [AuthFlowClient.Finish], and refreshes it as needed. The token
+// source can be given to [NewDenizenLoginClient]. To resume a session, only
+// the RefreshToken of token has to be set.
+//
+// ctx is used to refresh the token, so it should outlive the returned token
+// source.
+func (f *AuthFlowClient) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return f.config.TokenSource(ctx, token)
+}
+
+// RevokeToken revokes token at the accounts service, so that it can no longer
+// be used or refreshed, e.g. because it was leaked. The refresh token is
+// revoked if token has one, which also revokes its access tokens; otherwise,
+// the access token is revoked. Revoking a token that is already invalid is not
+// an error.
+//
+// The revocation endpoint of the accounts service isn't known, so RevokeToken
+// returns [ErrRevokeUnsupported] unless [AccountRevokeURL] is set.
+//
+// API tokens obtained using the revoked token keep working until they expire,
+// since the Denizen API's logout endpoint hasn't been captured yet. They can't
+// be renewed afterwards, though. See [AuthFlowClient.Logout] to also forget
+// stored tokens.
+//
+// Like [oauth2.Config], the HTTP client can be set in ctx using
+// [oauth2.HTTPClient].
+func (f *AuthFlowClient) RevokeToken(ctx context.Context, token *oauth2.Token) error {
+	revokeURL := f.revokeURL
+	if revokeURL == "" {
+		revokeURL = AccountRevokeURL
+	}
+	if revokeURL == "" {
+		return ErrRevokeUnsupported
 	}
-	return base64.URLEncoding.EncodeToString(r[:])
+
+	form := url.Values{"client_id": {f.config.ClientID}}
+	switch {
+	case token.RefreshToken != "":
+		form.Set("token", token.RefreshToken)
+		form.Set("token_type_hint", "refresh_token")
+	case token.AccessToken != "":
+		form.Set("token", token.AccessToken)
+		form.Set("token_type_hint", "access_token")
+	default:
+		return errors.New("token is empty")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to revoke token: %w", newAPIError(resp))
+	}
+	return nil
+}
+
+// Logout logs out of the session of token, as far as it can be done without
+// the server: it revokes token using [AuthFlowClient.RevokeToken], deletes the
+// API and OAuth2 tokens kept in store, if it isn't nil, and forgets the API
+// token cached by apiTokens, if it isn't nil, using [ForgetAPIToken]. The
+// tokens are forgotten even if revoking fails, in which case the error is
+// returned. If no revocation endpoint is known, token is only forgotten.
+//
+// The API token itself is never invalidated, since the Denizen API's logout
+// endpoint hasn't been captured yet, so copies of it elsewhere keep working
+// until it expires.
+func (f *AuthFlowClient) Logout(ctx context.Context, token *oauth2.Token, store TokenStore, apiTokens APITokenSource) error {
+	err := f.RevokeToken(ctx, token)
+	if errors.Is(err, ErrRevokeUnsupported) {
+		err = nil
+	}
+	if store != nil {
+		for _, key := range []string{APITokenStoreKey, OAuth2TokenStoreKey} {
+			if deleteErr := store.Delete(ctx, key); deleteErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to delete stored %s: %w", key, deleteErr))
+			}
+		}
+	}
+	if apiTokens != nil {
+		ForgetAPIToken(apiTokens)
+	}
+	return err
+}
+
+func generateState() string {
+	return rand.Text()
 }
@@ -0,0 +1,148 @@
+package butterflymx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"golang.org/x/oauth2"
+)
+
+func TestAuthFlowClient_Finish(t *testing.T) {
+	var challenge string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			assert.Equal(t, "code123", r.PostForm.Get("code"))
+			assert.Equal(t, challenge, oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"access1","refresh_token":"refresh","token_type":"Bearer","expires_in":1}`))
+		case "refresh_token":
+			assert.Equal(t, "refresh", r.PostForm.Get("refresh_token"))
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"access2","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+		default:
+			t.Errorf("unexpected grant type %q", r.PostForm.Get("grant_type"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := *AccountAuthConfig
+	config.Endpoint.TokenURL = server.URL
+	flow := &AuthFlowClient{config: &config}
+
+	start := flow.Start()
+	startURL, err := url.Parse(start.URL())
+	assert.NoError(t, err)
+	assert.Equal(t, "S256", startURL.Query().Get("code_challenge_method"))
+	challenge = startURL.Query().Get("code_challenge")
+
+	token, err := flow.Finish(t.Context(), start, "com.butterflymx.oauth://oauth?code=code123&state="+url.QueryEscape(start.state))
+	assert.NoError(t, err)
+	assert.Equal(t, "access1", token.AccessToken)
+
+	// The token expires within the expiry delta, so it is refreshed right
+	// away.
+	token, err = flow.TokenSource(t.Context(), token).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "access2", token.AccessToken)
+
+	_, err = flow.Finish(t.Context(), start, "com.butterflymx.oauth://oauth?code=code123&state=forged")
+	assert.Error(t, err)
+}
+
+func TestAuthFlowClient_PasswordLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "password", r.PostForm.Get("grant_type"))
+		if r.PostForm.Get("username") != "jane@example.com" || r.PostForm.Get("password") != "hunter2" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := *AccountAuthConfig
+	config.Endpoint.TokenURL = server.URL
+	flow := &AuthFlowClient{config: &config}
+
+	tokenSource, err := flow.PasswordLogin(t.Context(), "jane@example.com", "hunter2")
+	assert.NoError(t, err)
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+
+	_, err = flow.PasswordLogin(t.Context(), "jane@example.com", "wrong")
+	var retrieveErr *oauth2.RetrieveError
+	assert.True(t, errors.As(err, &retrieveErr), "error should be a RetrieveError: %v", err)
+	assert.Equal(t, "invalid_grant", retrieveErr.ErrorCode)
+}
+
+func TestAuthFlowClient_Logout(t *testing.T) {
+	var revoked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, AccountAuthConfig.ClientID, r.PostForm.Get("client_id"))
+		if r.PostForm.Get("token") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		revoked = append(revoked, r.PostForm.Get("token_type_hint")+":"+r.PostForm.Get("token"))
+	}))
+	t.Cleanup(server.Close)
+
+	flow := &AuthFlowClient{config: AccountAuthConfig, revokeURL: server.URL}
+
+	store := &MemoryTokenStore{}
+	assert.NoError(t, store.Save(t.Context(), APITokenStoreKey, []byte("api")))
+	assert.NoError(t, store.Save(t.Context(), OAuth2TokenStoreKey, []byte("{}")))
+
+	apiTokens := ReuseStoredAPITokenSource(&countingTokenSource{}, store)
+	token, err := apiTokens.APIToken(t.Context(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "api", token)
+
+	assert.NoError(t, flow.Logout(t.Context(), &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}, store, apiTokens))
+	assert.NoError(t, flow.RevokeToken(t.Context(), &oauth2.Token{AccessToken: "access"}))
+	assert.Equal(t, []string{"refresh_token:refresh", "access_token:access"}, revoked)
+
+	for _, key := range []string{APITokenStoreKey, OAuth2TokenStoreKey} {
+		_, err := store.Load(t.Context(), key)
+		assert.IsError(t, err, ErrTokenNotFound)
+	}
+
+	token, err = apiTokens.APIToken(t.Context(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token, "the cached API token should be forgotten")
+
+	assert.NoError(t, store.Save(t.Context(), APITokenStoreKey, []byte("api")))
+	err = flow.Logout(t.Context(), &oauth2.Token{RefreshToken: "broken"}, store, nil)
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr), "error should be an APIError: %v", err)
+	_, err = store.Load(t.Context(), APITokenStoreKey)
+	assert.IsError(t, err, ErrTokenNotFound, "stored tokens should be deleted even if revoking fails")
+
+	assert.Error(t, flow.RevokeToken(t.Context(), &oauth2.Token{}))
+}
+
+func TestAuthFlowClient_Logout_noRevokeURL(t *testing.T) {
+	flow := NewAuthFlowClient()
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+
+	assert.IsError(t, flow.RevokeToken(t.Context(), token), ErrRevokeUnsupported)
+
+	store := &MemoryTokenStore{}
+	assert.NoError(t, store.Save(t.Context(), OAuth2TokenStoreKey, []byte("{}")))
+	assert.NoError(t, flow.Logout(t.Context(), token, store, nil), "tokens should still be forgotten")
+	_, err := store.Load(t.Context(), OAuth2TokenStoreKey)
+	assert.IsError(t, err, ErrTokenNotFound)
+}
@@ -0,0 +1,263 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KeychainBackupVersion is the current version of the [KeychainBackup] format.
+const KeychainBackupVersion = 1
+
+// KeychainBackup is a portable snapshot of a tenant's keychains and their
+// virtual keys. It only contains the fields needed to recreate them, so
+// server-generated fields such as IDs and PIN codes are not included.
+type KeychainBackup struct {
+	Version   int                   `json:"version"`
+	TenantID  ID                    `json:"tenant_id"`
+	CreatedAt time.Time             `json:"created_at"`
+	Keychains []KeychainBackupEntry `json:"keychains"`
+}
+
+// KeychainBackupEntry is a single keychain within a [KeychainBackup].
+type KeychainBackupEntry struct {
+	Name            string                `json:"name"`
+	Kind            KeychainKind          `json:"kind"`
+	StartsAt        time.Time             `json:"starts_at"`
+	EndsAt          time.Time             `json:"ends_at"`
+	AllowUnitAccess bool                  `json:"allow_unit_access"`
+	Panels          []KeychainBackupPanel `json:"panels"`
+	Recipients      []VirtualKeyRecipient `json:"recipients"`
+}
+
+// KeychainBackupPanel is a panel that a backed up keychain had access to.
+// The name is kept so that the panel can be matched against an access point
+// during import, since keychains only reference panels and not access points.
+type KeychainBackupPanel struct {
+	ID   ID     `json:"id"`
+	Name string `json:"name"`
+}
+
+// ExportKeychains writes a [KeychainBackup] of all active keychains of the
+// given tenant to w as JSON. The options are passed to every API call made.
+func (c *APIClient) ExportKeychains(ctx context.Context, tenantID ID, w io.Writer, opts ...CallOption) error {
+	return ExportKeychains(ctx, c, tenantID, w, opts...)
+}
+
+// ExportKeychains is like [APIClient.ExportKeychains], but works with any
+// [Client].
+func ExportKeychains(ctx context.Context, client Client, tenantID ID, w io.Writer, opts ...CallOption) error {
+	keychains, err := client.Keychains(ctx, tenantID, ActiveAccessCode, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch keychains: %w", err)
+	}
+
+	backup := KeychainBackup{
+		Version:   KeychainBackupVersion,
+		TenantID:  tenantID,
+		CreatedAt: time.Now(),
+		Keychains: make([]KeychainBackupEntry, 0, len(keychains.Data)),
+	}
+
+	for _, keychain := range keychains.Data {
+		entry := KeychainBackupEntry{
+			Name:            keychain.Attributes.Name,
+			Kind:            keychain.Attributes.Kind,
+			StartsAt:        keychain.Attributes.StartsAt,
+			EndsAt:          keychain.Attributes.EndsAt,
+			AllowUnitAccess: keychain.Attributes.AllowUnitAccess,
+		}
+
+		for panel, err := range keychain.Relationships.Devices.Resolve(keychains.Refs) {
+			if err != nil {
+				return fmt.Errorf("keychain %v: failed to resolve panel: %w", keychain.ID, err)
+			}
+			entry.Panels = append(entry.Panels, KeychainBackupPanel{
+				ID:   panel.ID,
+				Name: panel.Attributes.Name,
+			})
+		}
+
+		for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(keychains.Refs) {
+			if err != nil {
+				return fmt.Errorf("keychain %v: failed to resolve virtual key: %w", keychain.ID, err)
+			}
+			entry.Recipients = append(entry.Recipients, VirtualKeyRecipient{
+				Name:      virtualKey.Attributes.Name,
+				DeliverTo: virtualKey.Attributes.Email,
+			})
+		}
+
+		backup.Keychains = append(backup.Keychains, entry)
+	}
+
+	if err := json.MarshalWrite(w, backup, jsontext.WithIndent("  ")); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+// KeychainImportResult describes the outcome of [APIClient.ImportKeychains].
+type KeychainImportResult struct {
+	// Created lists the IDs of the keychains that were recreated.
+	Created []ID
+	// Skipped lists the backup entries that could not be recreated along
+	// with the reason.
+	Skipped []KeychainImportSkip
+}
+
+// KeychainImportSkip is a backup entry that was not imported.
+type KeychainImportSkip struct {
+	Entry  KeychainBackupEntry
+	Reason error
+}
+
+// ImportKeychains reads a [KeychainBackup] from r and recreates its keychains
+// and virtual keys for the given tenant.
+//
+// Only custom keychains can be recreated, since the API does not support
+// creating other kinds. Panels are matched to the tenant's access points by
+// name, since their IDs differ: an access point matches a panel if their
+// names are equal or, failing that, if the panel name ends with the access
+// point name. A panel matching several access points is ambiguous and isn't
+// matched at all. Entries that cannot be matched are reported in
+// [KeychainImportResult.Skipped] rather than failing the whole import. New
+// PIN codes are generated by the server for every virtual key.
+//
+// If the virtual keys of a keychain can't be created, the import stops with a
+// [*PartialKeychainError] identifying the keychain, which is left in place
+// without them since there is no API call to delete it.
+//
+// The options are passed to every API call made, except for [WithProgress],
+// which reports the number of backup entries processed instead.
+func (c *APIClient) ImportKeychains(ctx context.Context, tenantID ID, r io.Reader, opts ...CallOption) (*KeychainImportResult, error) {
+	return ImportKeychains(ctx, c, tenantID, r, opts...)
+}
+
+// ImportKeychains is like [APIClient.ImportKeychains], but works with any
+// [Client].
+func ImportKeychains(ctx context.Context, client Client, tenantID ID, r io.Reader, opts ...CallOption) (*KeychainImportResult, error) {
+	call := newCallOptions(opts)
+	opts = append(slices.Clip(opts), WithProgress(nil))
+
+	var backup KeychainBackup
+	if err := json.UnmarshalRead(r, &backup); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	if backup.Version != KeychainBackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+
+	accessPoints, err := CollectResults(client.TenantAccessPoints(ctx, TenantTaggedID(tenantID), opts...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch access points: %w", err)
+	}
+
+	var result KeychainImportResult
+
+	for i, entry := range backup.Keychains {
+		// Entries are skipped using continue, so the previous entry is
+		// reported here rather than at the end of the loop.
+		if i > 0 {
+			call.reportProgress(Progress{Items: i, Total: len(backup.Keychains)})
+		}
+
+		if entry.Kind != CustomKeychain {
+			result.Skipped = append(result.Skipped, KeychainImportSkip{
+				Entry:  entry,
+				Reason: fmt.Errorf("unsupported keychain kind %q", entry.Kind),
+			})
+			continue
+		}
+
+		accessPointIDs, err := matchBackupPanels(entry.Panels, accessPoints)
+		if err != nil {
+			result.Skipped = append(result.Skipped, KeychainImportSkip{Entry: entry, Reason: err})
+			continue
+		}
+
+		keychain, err := client.CreateCustomKeychain(ctx, tenantID, accessPointIDs, CustomKeychainArgs{
+			Name:            entry.Name,
+			StartsAt:        entry.StartsAt,
+			EndsAt:          entry.EndsAt,
+			AllowUnitAccess: entry.AllowUnitAccess,
+		}, opts...)
+		if err != nil {
+			return &result, fmt.Errorf("failed to create keychain %q: %w", entry.Name, err)
+		}
+		result.Created = append(result.Created, keychain.Data.ID)
+
+		if len(entry.Recipients) == 0 {
+			continue
+		}
+
+		if _, err := client.CreateVirtualKeys(ctx, keychain.Data.ID, VirtualKeyArgs{
+			Recipients: entry.Recipients,
+		}, opts...); err != nil {
+			return &result, &PartialKeychainError{KeychainID: keychain.Data.ID, Name: entry.Name, Err: err}
+		}
+	}
+
+	call.reportProgress(Progress{Items: len(backup.Keychains), Total: len(backup.Keychains)})
+	return &result, nil
+}
+
+// matchBackupPanels returns the numeric IDs of the access points matching
+// panels. See [APIClient.ImportKeychains] for how they are matched.
+func matchBackupPanels(panels []KeychainBackupPanel, accessPoints []AccessPoint) ([]ID, error) {
+	var errs []error
+	ids := make([]ID, 0, len(panels))
+
+	for _, panel := range panels {
+		id, err := matchBackupPanel(panel, accessPoints)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 && len(errs) == 0 {
+		errs = append(errs, errors.New("keychain has no panels"))
+	}
+
+	return ids, errors.Join(errs...)
+}
+
+func matchBackupPanel(panel KeychainBackupPanel, accessPoints []AccessPoint) (ID, error) {
+	matches := matchingAccessPoints(accessPoints, func(ap AccessPoint) bool {
+		return panel.Name == ap.Name
+	})
+	if len(matches) == 0 {
+		matches = matchingAccessPoints(accessPoints, func(ap AccessPoint) bool {
+			return strings.HasSuffix(panel.Name, " "+ap.Name)
+		})
+	}
+
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("no access point matches panel %q", panel.Name)
+	case 1:
+		return matches[0].ID.Number, nil
+	default:
+		return 0, fmt.Errorf("panel %q matches several access points: %v", panel.Name, matches)
+	}
+}
+
+func matchingAccessPoints(accessPoints []AccessPoint, match func(AccessPoint) bool) []AccessPoint {
+	var matches []AccessPoint
+	for _, ap := range accessPoints {
+		if match(ap) {
+			matches = append(matches, ap)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,179 @@
+package butterflymx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestAPIClient_ExportImportKeychains(t *testing.T) {
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+	_, customKeychainResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-custom.json")
+	_, virtualKeyResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-id.json")
+
+	accessPointsResponse := []byte(`{"data": {"nodes": [{"accessPoints": {
+		"nodes": [{"id": "prod-access_point-50001", "name": "Front Door", "openDuration": 5, "online": true}],
+		"pageInfo": {"hasNextPage": false, "endCursor": ""}
+	}}]}}`)
+
+	roundTrips := []httpmock.RoundTrip{
+		{
+			Response: httpmock.RoundTripResponse{Body: accessCodesResponse},
+		},
+		{
+			Response: httpmock.RoundTripResponse{Body: accessPointsResponse},
+		},
+	}
+
+	// The recurring keychain in the fixture is skipped, so only the three
+	// custom keychains are recreated along with their virtual keys.
+	for range 3 {
+		roundTrips = append(roundTrips,
+			httpmock.RoundTrip{
+				RequestCheck: httpmock.RoundTripRequestCheckJSON(func(t *testing.T, data map[string]any) {
+					relationships := data["data"].(map[string]any)["relationships"].(map[string]any)
+					accessPoints := relationships["access_points"].(map[string]any)["data"].([]any)
+					assert.Equal(t, 1, len(accessPoints))
+					assert.Equal(t, "50001", accessPoints[0].(map[string]any)["id"])
+				}),
+				Response: httpmock.RoundTripResponse{Body: customKeychainResponse},
+			},
+			httpmock.RoundTrip{
+				RequestCheck: func(t *testing.T, req *http.Request) {
+					assert.Equal(t, "/v3/keychains/10001/virtual_keys", req.URL.Path)
+				},
+				Response: httpmock.RoundTripResponse{Body: virtualKeyResponse},
+			},
+		)
+	}
+
+	apiClient := newTestAPIClient(t, httpmock.NewRoundTripper(t, roundTrips))
+
+	var exportProgress []Progress
+	var buf bytes.Buffer
+	err := apiClient.ExportKeychains(t.Context(), 10001, &buf, WithProgress(func(p Progress) {
+		exportProgress = append(exportProgress, p)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []Progress{{Pages: 1, Items: 4}}, exportProgress)
+
+	var importProgress []Progress
+	result, err := apiClient.ImportKeychains(t.Context(), 10001, &buf, WithProgress(func(p Progress) {
+		importProgress = append(importProgress, p)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []Progress{
+		{Items: 1, Total: 4},
+		{Items: 2, Total: 4},
+		{Items: 3, Total: 4},
+		{Items: 4, Total: 4},
+	}, importProgress, "only backup entries should be reported")
+
+	assert.Equal(t, []ID{10001, 10001, 10001}, result.Created)
+	assert.Equal(t, 1, len(result.Skipped))
+	assert.Equal(t, "Amazon Delivery", result.Skipped[0].Entry.Name)
+	assert.Equal(t, RecurringKeychain, result.Skipped[0].Entry.Kind)
+	assert.Equal(t, []KeychainBackupPanel{{ID: 10003, Name: "Hunter Capital Front Door"}}, result.Skipped[0].Entry.Panels)
+}
+
+func TestMatchBackupPanels(t *testing.T) {
+	accessPoints := []AccessPoint{
+		{ID: AccessPointTaggedID(50001), Name: "Front Door"},
+		{ID: AccessPointTaggedID(50002), Name: "Door"},
+		{ID: AccessPointTaggedID(50003), Name: "Garage"},
+		{ID: AccessPointTaggedID(50004), Name: "Garage"},
+		{ID: AccessPointTaggedID(50005), Name: "Pool"},
+	}
+
+	tests := []struct {
+		name   string
+		panels []KeychainBackupPanel
+		want   []ID
+		err    string
+	}{
+		{
+			name:   "by name",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Front Door"}},
+			want:   []ID{50001},
+		},
+		{
+			name:   "panel ID colliding with access point ID",
+			panels: []KeychainBackupPanel{{ID: 50003, Name: "Front Door"}},
+			want:   []ID{50001},
+		},
+		{
+			name:   "exact name over suffix",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Door"}},
+			want:   []ID{50002},
+		},
+		{
+			name:   "by name suffix",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Hunter Capital Pool"}},
+			want:   []ID{50005},
+		},
+		{
+			name:   "ambiguous name suffix",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Hunter Capital Front Door"}},
+			err:    `panel "Hunter Capital Front Door" matches several access points`,
+		},
+		{
+			name:   "ambiguous name",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Garage"}},
+			err:    `panel "Garage" matches several access points`,
+		},
+		{
+			name:   "no match",
+			panels: []KeychainBackupPanel{{ID: 10003, Name: "Gym"}},
+			err:    `no access point matches panel "Gym"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ids, err := matchBackupPanels(test.panels, accessPoints)
+			if test.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, ids)
+		})
+	}
+}
+
+func TestImportKeychains_partialKeychain(t *testing.T) {
+	_, customKeychainResponse := readFileAsRequestAndResponseBodies(t, "testdata/api-post-v3-keychains-custom.json")
+
+	accessPointsResponse := []byte(`{"data": {"nodes": [{"accessPoints": {
+		"nodes": [{"id": "prod-access_point-50001", "name": "Front Door", "openDuration": 5, "online": true}],
+		"pageInfo": {"hasNextPage": false, "endCursor": ""}
+	}}]}}`)
+
+	apiClient := newTestAPIClient(t, httpmock.NewSequence(t,
+		httpmock.RoundTripResponse{Body: accessPointsResponse},
+		httpmock.RoundTripResponse{Body: customKeychainResponse},
+		httpmock.RoundTripResponse{Status: http.StatusUnprocessableEntity},
+	))
+
+	backup := []byte(`{"version": 1, "tenant_id": "10001", "keychains": [{
+		"name": "Jane Doe",
+		"kind": "custom",
+		"starts_at": "2023-01-01T00:00:00Z",
+		"ends_at": "2023-01-02T00:00:00Z",
+		"panels": [{"id": "50001", "name": "Front Door"}],
+		"recipients": [{"name": "jane@example.com", "deliver_to": "jane@example.com"}]
+	}]}`)
+
+	result, err := apiClient.ImportKeychains(t.Context(), 10001, bytes.NewReader(backup))
+
+	var partialErr *PartialKeychainError
+	assert.True(t, errors.As(err, &partialErr), "error should be a PartialKeychainError: %v", err)
+	assert.Equal(t, ID(10001), partialErr.KeychainID)
+	assert.Equal(t, "Jane Doe", partialErr.Name)
+	assert.Equal(t, []ID{10001}, result.Created)
+}
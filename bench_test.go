@@ -0,0 +1,179 @@
+package butterflymx
+
+import (
+	"bytes"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// benchAccessCodesResponse builds a GET /v3/access_codes response with the
+// given number of keychains, each with virtualKeys virtual keys that have
+// releases door releases each. All door releases share a handful of panels.
+func benchAccessCodesResponse(b *testing.B, keychains, virtualKeys, releases int) []byte {
+	const panels = 4
+
+	ref := func(typ ObjectType, id int) map[string]any {
+		return map[string]any{"id": strconv.Itoa(id), "type": string(typ)}
+	}
+
+	var data, included []any
+	nextID := 1000
+
+	for p := range panels {
+		panel := ref(TypePanel, p+1)
+		panel["attributes"] = map[string]any{"name": fmt.Sprintf("Panel %d", p)}
+		panel["relationships"] = map[string]any{"building": map[string]any{"data": ref(TypeBuilding, 100)}}
+		included = append(included, panel)
+	}
+
+	for range keychains {
+		keychainID := nextID
+		nextID++
+
+		var virtualKeyRefs []any
+		for range virtualKeys {
+			virtualKeyID := nextID
+			nextID++
+
+			var releaseRefs []any
+			for r := range releases {
+				releaseID := nextID
+				nextID++
+
+				release := ref(TypeDoorRelease, releaseID)
+				release["attributes"] = map[string]any{
+					"release_method": "virtual_key_pin",
+					"name":           "Jane Doe",
+					"logged_at":      "2023-01-01T00:00:00Z",
+					"created_at":     "2023-01-01T00:00:00Z",
+				}
+				release["relationships"] = map[string]any{
+					"panel": map[string]any{"data": ref(TypePanel, r%panels+1)},
+				}
+				included = append(included, release)
+				releaseRefs = append(releaseRefs, ref(TypeDoorRelease, releaseID))
+			}
+
+			virtualKey := ref(TypeVirtualKey, virtualKeyID)
+			virtualKey["attributes"] = map[string]any{
+				"name":    "guest@example.com",
+				"email":   "guest@example.com",
+				"pin":     fmt.Sprintf("%06d", virtualKeyID),
+				"sent_at": "2023-01-01T00:00:00Z",
+			}
+			virtualKey["relationships"] = map[string]any{
+				"door_releases": map[string]any{"data": releaseRefs},
+			}
+			included = append(included, virtualKey)
+			virtualKeyRefs = append(virtualKeyRefs, ref(TypeVirtualKey, virtualKeyID))
+		}
+
+		keychain := ref(TypeKeychain, keychainID)
+		keychain["attributes"] = map[string]any{
+			"name":       fmt.Sprintf("Keychain %d", keychainID),
+			"kind":       "custom",
+			"weekdays":   []string{},
+			"starts_at":  "2023-01-01T00:00:00Z",
+			"ends_at":    "2023-01-02T00:00:00Z",
+			"time_from":  "00:00",
+			"time_to":    "00:00",
+			"start_date": "2023-01-01",
+			"end_date":   "2023-01-02",
+		}
+		keychain["relationships"] = map[string]any{
+			"virtual_keys": map[string]any{"data": virtualKeyRefs},
+			"devices":      map[string]any{"data": []any{ref(TypePanel, 1)}},
+		}
+		data = append(data, keychain)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"data":     data,
+		"included": included,
+		"links":    map[string]any{"next": nil},
+	})
+	if err != nil {
+		b.Fatalf("failed to marshal benchmark response: %v", err)
+	}
+	return body
+}
+
+// staticRoundTripper responds to every request with the same body.
+type staticRoundTripper []byte
+
+func (rt staticRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(rt)),
+		Request:    req,
+	}, nil
+}
+
+var benchSizes = []struct {
+	keychains, virtualKeys, releases int
+}{
+	{10, 2, 5},
+	{100, 2, 5},
+	{500, 4, 10},
+}
+
+func BenchmarkAPIClient_Keychains(b *testing.B) {
+	for _, size := range benchSizes {
+		body := benchAccessCodesResponse(b, size.keychains, size.virtualKeys, size.releases)
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient: &http.Client{Transport: staticRoundTripper(body)},
+		})
+
+		name := fmt.Sprintf("keychains=%d/virtual_keys=%d/releases=%d", size.keychains, size.virtualKeys, size.releases)
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := client.Keychains(b.Context(), 10001, ActiveAccessCode); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReferenceList_Resolve(b *testing.B) {
+	for _, size := range benchSizes {
+		body := benchAccessCodesResponse(b, size.keychains, size.virtualKeys, size.releases)
+		client := NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient: &http.Client{Transport: staticRoundTripper(body)},
+		})
+
+		results, err := client.Keychains(b.Context(), 10001, ActiveAccessCode)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		name := fmt.Sprintf("keychains=%d/virtual_keys=%d/releases=%d", size.keychains, size.virtualKeys, size.releases)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				for _, keychain := range results.Data {
+					for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(results.Refs) {
+						if err != nil {
+							b.Fatal(err)
+						}
+						for release, err := range virtualKey.Relationships.DoorReleases.Resolve(results.Refs) {
+							if err != nil {
+								b.Fatal(err)
+							}
+							if _, err := release.Relationships.Panel.Data.Resolve(results.Refs); err != nil {
+								b.Fatal(err)
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
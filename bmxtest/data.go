@@ -0,0 +1,119 @@
+//go:build goexperiment.jsonv2
+
+package bmxtest
+
+import (
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+// Data is the in-memory data model served by a [Server]. It can be loaded
+// from and saved as JSON, which allows seeding the server from a file.
+type Data struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Tenant is a tenant along with everything it has access to.
+type Tenant struct {
+	butterflymx.Tenant `json:",inline"`
+	// AccessPoints are the doors this tenant may unlock. Each access point is
+	// also served as a panel with the same ID and name.
+	AccessPoints []butterflymx.AccessPoint `json:"accessPoints"`
+	Keychains    []Keychain                `json:"keychains"`
+}
+
+// Keychain is a keychain owned by a tenant.
+type Keychain struct {
+	ID              butterflymx.ID           `json:"id"`
+	Name            string                   `json:"name"`
+	Kind            butterflymx.KeychainKind `json:"kind"`
+	StartsAt        time.Time                `json:"startsAt"`
+	EndsAt          time.Time                `json:"endsAt"`
+	AllowUnitAccess bool                     `json:"allowUnitAccess"`
+	AccessPointIDs  []butterflymx.ID         `json:"accessPointIds"`
+	VirtualKeys     []VirtualKey             `json:"virtualKeys"`
+}
+
+// IsActive returns true if the keychain has not expired at the given time.
+func (k *Keychain) IsActive(now time.Time) bool {
+	return k.EndsAt.IsZero() || k.EndsAt.After(now)
+}
+
+// VirtualKey is a virtual key within a keychain.
+type VirtualKey struct {
+	ID           butterflymx.ID      `json:"id"`
+	Name         string              `json:"name"`
+	Email        string              `json:"email"`
+	PINCode      butterflymx.PINCode `json:"pin"`
+	SentAt       time.Time           `json:"sentAt,omitzero"`
+	DoorReleases []DoorRelease       `json:"doorReleases"`
+}
+
+// DoorRelease is a door release made using a virtual key.
+type DoorRelease struct {
+	ID            butterflymx.ID `json:"id"`
+	AccessPointID butterflymx.ID `json:"accessPointId"`
+	Name          string         `json:"name"`
+	ReleaseMethod string         `json:"releaseMethod"`
+	LoggedAt      time.Time      `json:"loggedAt"`
+}
+
+// Unlock is a door unlock made through the Unlock API.
+type Unlock struct {
+	TenantID      butterflymx.TaggedID
+	AccessPointID butterflymx.TaggedID
+	Source        string
+	At            time.Time
+}
+
+func (d *Data) tenant(id butterflymx.ID) *Tenant {
+	for i := range d.Tenants {
+		if d.Tenants[i].ID.Number == id {
+			return &d.Tenants[i]
+		}
+	}
+	return nil
+}
+
+func (d *Data) keychain(id butterflymx.ID) (*Tenant, *Keychain) {
+	for i := range d.Tenants {
+		tenant := &d.Tenants[i]
+		for j := range tenant.Keychains {
+			if tenant.Keychains[j].ID == id {
+				return tenant, &tenant.Keychains[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (t *Tenant) accessPoint(id butterflymx.ID) *butterflymx.AccessPoint {
+	for i := range t.AccessPoints {
+		if t.AccessPoints[i].ID.Number == id {
+			return &t.AccessPoints[i]
+		}
+	}
+	return nil
+}
+
+// maxID returns the largest numeric ID used anywhere in the data.
+func (d *Data) maxID() butterflymx.ID {
+	var maxID butterflymx.ID
+	for _, tenant := range d.Tenants {
+		maxID = max(maxID, tenant.ID.Number, tenant.Unit.ID.Number, tenant.Building.ID.Number)
+		for _, ap := range tenant.AccessPoints {
+			maxID = max(maxID, ap.ID.Number)
+		}
+		for _, keychain := range tenant.Keychains {
+			maxID = max(maxID, keychain.ID)
+			for _, vk := range keychain.VirtualKeys {
+				maxID = max(maxID, vk.ID)
+				for _, release := range vk.DoorReleases {
+					maxID = max(maxID, release.ID)
+				}
+			}
+		}
+	}
+	return maxID
+}
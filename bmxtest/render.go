@@ -0,0 +1,176 @@
+//go:build goexperiment.jsonv2
+
+package bmxtest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+// jsonObject is a JSON:API resource object.
+type jsonObject = map[string]any
+
+func resourceID(typ butterflymx.ObjectType, id butterflymx.ID) jsonObject {
+	return jsonObject{"id": fmt.Sprint(int(id)), "type": string(typ)}
+}
+
+func relationship(data any) jsonObject {
+	return jsonObject{"data": data}
+}
+
+// includes tracks the "include" query parameter and the included objects that
+// have been rendered so far, deduplicated by type and ID.
+type includes struct {
+	paths   []string
+	seen    map[string]bool
+	objects []jsonObject
+}
+
+func newIncludes(param string) *includes {
+	inc := &includes{seen: make(map[string]bool)}
+	for path := range strings.SplitSeq(param, ",") {
+		if path != "" {
+			inc.paths = append(inc.paths, path)
+		}
+	}
+	return inc
+}
+
+// has returns true if the given relationship path was requested, either
+// directly or as part of a longer path.
+func (inc *includes) has(path string) bool {
+	for _, p := range inc.paths {
+		if p == path || strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (inc *includes) add(obj jsonObject) {
+	key := fmt.Sprint(obj["type"], "/", obj["id"])
+	if inc.seen[key] {
+		return
+	}
+	inc.seen[key] = true
+	inc.objects = append(inc.objects, obj)
+}
+
+func (inc *includes) list() []jsonObject {
+	if inc.objects == nil {
+		return []jsonObject{}
+	}
+	return inc.objects
+}
+
+func renderKeychain(tenant *Tenant, k *Keychain, inc *includes) jsonObject {
+	virtualKeys := make([]jsonObject, len(k.VirtualKeys))
+	for i := range k.VirtualKeys {
+		vk := &k.VirtualKeys[i]
+		virtualKeys[i] = resourceID(butterflymx.TypeVirtualKey, vk.ID)
+		if inc.has("virtual_keys") {
+			inc.add(renderVirtualKey(tenant, vk, inc))
+		}
+	}
+
+	devices := make([]jsonObject, 0, len(k.AccessPointIDs))
+	for _, apID := range k.AccessPointIDs {
+		devices = append(devices, resourceID(butterflymx.TypePanel, apID))
+		if inc.has("devices") {
+			if ap := tenant.accessPoint(apID); ap != nil {
+				inc.add(renderPanel(tenant, ap))
+			}
+		}
+	}
+
+	obj := resourceID(butterflymx.TypeKeychain, k.ID)
+	obj["attributes"] = jsonObject{
+		"name":              k.Name,
+		"kind":              string(k.Kind),
+		"weekdays":          []string{},
+		"starts_at":         k.StartsAt.UTC().Format(time.RFC3339),
+		"ends_at":           k.EndsAt.UTC().Format(time.RFC3339),
+		"time_from":         k.StartsAt.UTC().Format(butterflymx.TimestampLayout),
+		"time_to":           k.EndsAt.UTC().Format(butterflymx.TimestampLayout),
+		"start_date":        k.StartsAt.UTC().Format(butterflymx.DatestampLayout),
+		"end_date":          k.EndsAt.UTC().Format(butterflymx.DatestampLayout),
+		"allow_unit_access": k.AllowUnitAccess,
+	}
+	obj["relationships"] = jsonObject{
+		"virtual_keys": relationship(virtualKeys),
+		"devices":      relationship(devices),
+	}
+	return obj
+}
+
+func renderVirtualKey(tenant *Tenant, vk *VirtualKey, inc *includes) jsonObject {
+	releases := make([]jsonObject, len(vk.DoorReleases))
+	for i := range vk.DoorReleases {
+		release := &vk.DoorReleases[i]
+		releases[i] = resourceID(butterflymx.TypeDoorRelease, release.ID)
+		if inc.has("virtual_keys.door_releases") {
+			inc.add(renderDoorRelease(release))
+		}
+		if inc.has("virtual_keys.door_releases.panel") {
+			if ap := tenant.accessPoint(release.AccessPointID); ap != nil {
+				inc.add(renderPanel(tenant, ap))
+			}
+		}
+	}
+
+	var sentAt any
+	if !vk.SentAt.IsZero() {
+		sentAt = vk.SentAt.UTC().Format(time.RFC3339)
+	}
+
+	obj := resourceID(butterflymx.TypeVirtualKey, vk.ID)
+	obj["attributes"] = jsonObject{
+		"name":              vk.Name,
+		"email":             vk.Email,
+		"pin":               vk.PINCode.String(),
+		"qr_code_image_url": fmt.Sprintf("https://bmxtest.invalid/qr_codes/%d.png", int(vk.ID)),
+		"instructions_url":  fmt.Sprintf("https://bmxtest.invalid/instructions/%d", int(vk.ID)),
+		"sent_at":           sentAt,
+	}
+	obj["relationships"] = jsonObject{
+		"door_releases": relationship(releases),
+	}
+	return obj
+}
+
+func renderDoorRelease(release *DoorRelease) jsonObject {
+	panel := resourceID(butterflymx.TypePanel, release.AccessPointID)
+
+	obj := resourceID(butterflymx.TypeDoorRelease, release.ID)
+	obj["attributes"] = jsonObject{
+		"release_method":    release.ReleaseMethod,
+		"door_release_type": "visitor",
+		"panel_user_type":   "default",
+		"name":              release.Name,
+		"created_at":        release.LoggedAt.UTC().Format(time.RFC3339),
+		"logged_at":         release.LoggedAt.UTC().Format(time.RFC3339),
+		"thumb_url":         fmt.Sprintf("https://bmxtest.invalid/door_releases/%d/thumb.jpg", int(release.ID)),
+		"medium_url":        fmt.Sprintf("https://bmxtest.invalid/door_releases/%d/medium.jpg", int(release.ID)),
+	}
+	obj["relationships"] = jsonObject{
+		"unit":   relationship(nil),
+		"user":   relationship(nil),
+		"panel":  relationship(panel),
+		"device": relationship(panel),
+	}
+	return obj
+}
+
+func renderPanel(tenant *Tenant, ap *butterflymx.AccessPoint) jsonObject {
+	obj := resourceID(butterflymx.TypePanel, ap.ID.Number)
+	obj["attributes"] = jsonObject{
+		"name": ap.Name,
+	}
+	obj["relationships"] = jsonObject{
+		"building": relationship(resourceID(butterflymx.TypeBuilding, tenant.Building.ID.Number)),
+	}
+	return obj
+}
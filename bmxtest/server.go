@@ -0,0 +1,565 @@
+//go:build goexperiment.jsonv2
+
+// Package bmxtest provides a fake ButterflyMX server for integration tests.
+//
+// The server implements the denizen GraphQL operations, the /v3 keychain
+// endpoints, the login exchange and the Unlock API on top of an in-memory
+// [Data] model, so that automations built on [butterflymx.APIClient] can be
+// tested end-to-end without network access.
+package bmxtest
+
+import (
+	"cmp"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+// DefaultToken is the API token accepted by a [Server] unless changed.
+const DefaultToken butterflymx.APIStaticToken = "bmxtest-token"
+
+// graphQLPageSize is the page size used for GraphQL connections when the
+// request doesn't specify one.
+const graphQLPageSize = 10
+
+// Server is a fake ButterflyMX API server.
+type Server struct {
+	*httptest.Server
+
+	// Token is the API token that requests must carry. It is also the token
+	// handed out by the login endpoint.
+	Token butterflymx.APIStaticToken
+	// Now returns the current time. It is used to decide which keychains are
+	// active and to timestamp unlocks.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	data    Data
+	nextID  butterflymx.ID
+	unlocks []Unlock
+}
+
+// NewServer starts a new fake server serving the given data. The server
+// takes ownership of data. It must be closed when no longer needed.
+func NewServer(data *Data) *Server {
+	s := NewUnstartedServer(data)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer is like [NewServer] but doesn't start the server, which
+// allows the caller to change its configuration first.
+func NewUnstartedServer(data *Data) *Server {
+	if data == nil {
+		data = &Data{}
+	}
+
+	s := &Server{
+		Token:  DefaultToken,
+		Now:    time.Now,
+		data:   *data,
+		nextID: data.maxID() + 1,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /denizen/v1/login", s.handleLogin)
+	mux.Handle("POST /denizen/v1/graphql", s.authenticated(s.handleGraphQL))
+	mux.Handle("GET /v3/access_codes", s.authenticated(s.handleAccessCodes))
+	mux.Handle("GET /v3/keychains/{id}", s.authenticated(s.handleKeychain))
+	mux.Handle("POST /v3/keychains/custom", s.authenticated(s.handleCreateCustomKeychain))
+	mux.Handle("POST /v3/keychains/{id}/virtual_keys", s.authenticated(s.handleCreateVirtualKeys))
+	mux.Handle("DELETE /v3/keychains/{id}/virtual_keys/{virtualKeyID}", s.authenticated(s.handleRevokeVirtualKey))
+	mux.Handle("POST /v1/access-point", s.authenticated(s.handleUnlock))
+
+	s.Server = httptest.NewUnstartedServer(mux)
+	return s
+}
+
+// HTTPClient returns an HTTP client that sends all requests to this server
+// regardless of their original host. This allows using the server with the
+// default ButterflyMX API URLs.
+func (s *Server) HTTPClient() *http.Client {
+	serverURL, _ := url.Parse(s.URL)
+	return &http.Client{
+		Transport: &redirectTransport{
+			target:    serverURL,
+			transport: s.Client().Transport,
+		},
+	}
+}
+
+// APIClient returns a new API client that talks to this server. If opts is
+// not nil, its HTTPClient is overridden.
+func (s *Server) APIClient(opts *butterflymx.APIClientOpts) *butterflymx.APIClient {
+	var o butterflymx.APIClientOpts
+	if opts != nil {
+		o = *opts
+	}
+	o.HTTPClient = s.HTTPClient()
+	return butterflymx.NewAPIClient(s.Token, &o)
+}
+
+// Update calls fn with the server's data while holding its lock, allowing
+// tests to change the data while the server is running.
+func (s *Server) Update(fn func(*Data)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(&s.data)
+	s.nextID = max(s.nextID, s.data.maxID()+1)
+}
+
+// Data returns a snapshot of the server's data.
+func (s *Server) Data() Data {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Round-trip through JSON to deep copy the data.
+	var data Data
+	b, _ := json.Marshal(s.data)
+	json.Unmarshal(b, &data)
+	return data
+}
+
+// Unlocks returns all unlocks made through the Unlock API so far.
+func (s *Server) Unlocks() []Unlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.unlocks)
+}
+
+func (s *Server) newID() butterflymx.ID {
+	id := s.nextID
+	s.nextID++
+	return id
+}
+
+type redirectTransport struct {
+	target    *url.URL
+	transport http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.Host = ""
+	return t.transport.RoundTrip(req)
+}
+
+func (s *Server) authenticated(h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+string(s.Token) {
+			writeError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		h(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.MarshalWrite(w, v)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]any{
+		"errors": []map[string]any{{
+			"status": strconv.Itoa(status),
+			"title":  http.StatusText(status),
+			"detail": detail,
+		}},
+	})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.UnmarshalRead(r.Body, v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.AccessToken == "" {
+		writeError(w, http.StatusUnauthorized, "missing access token")
+		return
+	}
+
+	s.mu.Lock()
+	tenantIDs := make([]butterflymx.TaggedID, len(s.data.Tenants))
+	for i, tenant := range s.data.Tenants {
+		tenantIDs[i] = tenant.ID
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"token": s.Token,
+		"user": map[string]any{
+			"id":        butterflymx.NewTaggedID("user", 1),
+			"tenantIds": tenantIDs,
+			"features":  []string{},
+		},
+	})
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		OperationName string                    `json:"operationName"`
+		Variables     map[string]jsontext.Value `json:"variables"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	var vars struct {
+		IDs   []butterflymx.TaggedID `json:"ids"`
+		After *string                `json:"after"`
+		First *int                   `json:"first"`
+	}
+	varsJSON, _ := json.Marshal(body.Variables)
+	if err := json.Unmarshal(varsJSON, &vars); err != nil {
+		writeGraphQLError(w, fmt.Sprintf("invalid variables: %v", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch body.OperationName {
+	case "Ping":
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"__typename": "Query"}})
+
+	case "Tenants":
+		tenants := make([]butterflymx.Tenant, len(s.data.Tenants))
+		for i, tenant := range s.data.Tenants {
+			tenants[i] = tenant.Tenant
+		}
+		page, err := paginate(tenants, vars.After, vars.First)
+		if err != nil {
+			writeGraphQLError(w, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data": map[string]any{"tenants": page},
+		})
+
+	case "TenantAccessPoints":
+		nodes := make([]map[string]any, 0, len(vars.IDs))
+		for _, id := range vars.IDs {
+			tenant := s.data.tenant(id.Number)
+			if tenant == nil {
+				continue
+			}
+			page, err := paginate(tenant.AccessPoints, vars.After, vars.First)
+			if err != nil {
+				writeGraphQLError(w, err.Error())
+				return
+			}
+			nodes = append(nodes, map[string]any{
+				"__typename":   "Tenant",
+				"id":           tenant.ID,
+				"accessPoints": page,
+			})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"data": map[string]any{"nodes": nodes},
+		})
+
+	default:
+		writeGraphQLError(w, fmt.Sprintf("unknown operation %q", body.OperationName))
+	}
+}
+
+func writeGraphQLError(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":   nil,
+		"errors": []map[string]any{{"message": message}},
+	})
+}
+
+// paginate returns a GraphQL connection for the page of items after the
+// given cursor. Cursors are the index of the last item on a page.
+func paginate[T any](items []T, after *string, first *int) (map[string]any, error) {
+	start := 0
+	if after != nil {
+		i, err := strconv.Atoi(*after)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %q", *after)
+		}
+		start = i + 1
+	}
+
+	size := graphQLPageSize
+	if first != nil && *first > 0 {
+		size = *first
+	}
+
+	start = min(start, len(items))
+	end := min(start+size, len(items))
+
+	return map[string]any{
+		"nodes": slices.Clone(items[start:end]),
+		"pageInfo": butterflymx.PageInfo{
+			HasNextPage: end < len(items),
+			EndCursor:   strconv.Itoa(end - 1),
+		},
+	}, nil
+}
+
+func (s *Server) handleAccessCodes(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	tenantID, err := strconv.Atoi(query.Get("filter[tenant]"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid filter[tenant]")
+		return
+	}
+	pageNumber := cmp.Or(atoi(query.Get("page[number]")), 1)
+	pageSize := cmp.Or(atoi(query.Get("page[size]")), 20)
+	wantActive := query.Get("filter[status]") == string(butterflymx.ActiveAccessCode)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := s.data.tenant(butterflymx.ID(tenantID))
+	if tenant == nil {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	now := s.Now()
+	var keychains []*Keychain
+	for i := range tenant.Keychains {
+		if tenant.Keychains[i].IsActive(now) == wantActive {
+			keychains = append(keychains, &tenant.Keychains[i])
+		}
+	}
+
+	start := min((pageNumber-1)*pageSize, len(keychains))
+	end := min(start+pageSize, len(keychains))
+
+	inc := newIncludes(query.Get("include"))
+	data := make([]jsonObject, 0, end-start)
+	for _, k := range keychains[start:end] {
+		data = append(data, renderKeychain(tenant, k, inc))
+	}
+
+	var next any
+	if end < len(keychains) {
+		nextQuery := url.Values{}
+		for k, v := range query {
+			nextQuery[k] = v
+		}
+		nextQuery.Set("page[number]", strconv.Itoa(pageNumber+1))
+		next = "/v3/access_codes?" + nextQuery.Encode()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":     data,
+		"included": inc.list(),
+		"links":    map[string]any{"next": next},
+	})
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func (s *Server) handleKeychain(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant, keychain := s.data.keychain(butterflymx.ID(atoi(r.PathValue("id"))))
+	if keychain == nil {
+		writeError(w, http.StatusNotFound, "keychain not found")
+		return
+	}
+
+	inc := newIncludes(r.URL.Query().Get("include"))
+	data := renderKeychain(tenant, keychain, inc)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"data":     data,
+		"included": inc.list(),
+	})
+}
+
+func (s *Server) handleCreateCustomKeychain(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Data struct {
+			Attributes struct {
+				Name            string    `json:"name"`
+				Kind            string    `json:"kind"`
+				StartsAt        time.Time `json:"starts_at,format:'2006-01-02T15:04:05-0700'"`
+				EndsAt          time.Time `json:"ends_at,format:'2006-01-02T15:04:05-0700'"`
+				AllowUnitAccess bool      `json:"allow_unit_access"`
+			} `json:"attributes"`
+			Relationships struct {
+				AccessPoints struct {
+					Data []butterflymx.RawReference `json:"data"`
+				} `json:"access_points"`
+				Tenant struct {
+					Data butterflymx.RawReference `json:"data"`
+				} `json:"tenant"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	attrs := body.Data.Attributes
+	if attrs.Kind != string(butterflymx.CustomKeychain) {
+		writeError(w, http.StatusUnprocessableEntity, "kind must be custom")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := s.data.tenant(body.Data.Relationships.Tenant.Data.ID)
+	if tenant == nil {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+
+	keychain := Keychain{
+		ID:              s.newID(),
+		Name:            attrs.Name,
+		Kind:            butterflymx.CustomKeychain,
+		StartsAt:        attrs.StartsAt,
+		EndsAt:          attrs.EndsAt,
+		AllowUnitAccess: attrs.AllowUnitAccess,
+	}
+	for _, ref := range body.Data.Relationships.AccessPoints.Data {
+		if tenant.accessPoint(ref.ID) == nil {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("access point %v not found", ref.ID))
+			return
+		}
+		keychain.AccessPointIDs = append(keychain.AccessPointIDs, ref.ID)
+	}
+
+	tenant.Keychains = append(tenant.Keychains, keychain)
+
+	inc := newIncludes("devices")
+	data := renderKeychain(tenant, &keychain, inc)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"data":     data,
+		"included": inc.list(),
+	})
+}
+
+func (s *Server) handleCreateVirtualKeys(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Data struct {
+			Attributes butterflymx.VirtualKeyArgs `json:"attributes"`
+		} `json:"data"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant, keychain := s.data.keychain(butterflymx.ID(atoi(r.PathValue("id"))))
+	if keychain == nil {
+		writeError(w, http.StatusNotFound, "keychain not found")
+		return
+	}
+
+	inc := newIncludes("")
+	data := make([]jsonObject, 0, len(body.Data.Attributes.Recipients))
+
+	for _, recipient := range body.Data.Attributes.Recipients {
+		id := s.newID()
+		keychain.VirtualKeys = append(keychain.VirtualKeys, VirtualKey{
+			ID:      id,
+			Name:    recipient.Name,
+			Email:   recipient.DeliverTo,
+			PINCode: butterflymx.PINCode(fmt.Sprintf("%06d", int(id)%1000000)),
+			SentAt:  s.Now(),
+		})
+		vk := &keychain.VirtualKeys[len(keychain.VirtualKeys)-1]
+		data = append(data, renderVirtualKey(tenant, vk, inc))
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"data":     data,
+		"included": inc.list(),
+	})
+}
+
+func (s *Server) handleRevokeVirtualKey(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, keychain := s.data.keychain(butterflymx.ID(atoi(r.PathValue("id"))))
+	if keychain == nil {
+		writeError(w, http.StatusNotFound, "keychain not found")
+		return
+	}
+
+	virtualKeyID := butterflymx.ID(atoi(r.PathValue("virtualKeyID")))
+	i := slices.IndexFunc(keychain.VirtualKeys, func(vk VirtualKey) bool { return vk.ID == virtualKeyID })
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "virtual key not found")
+		return
+	}
+
+	keychain.VirtualKeys = slices.Delete(keychain.VirtualKeys, i, i+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUnlock(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		AccessPointID butterflymx.TaggedID `json:"accessPointId"`
+		TenantID      butterflymx.TaggedID `json:"tenantId"`
+		Source        string               `json:"source"`
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenant := s.data.tenant(body.TenantID.Number)
+	if tenant == nil {
+		writeError(w, http.StatusNotFound, "tenant not found")
+		return
+	}
+	if tenant.accessPoint(body.AccessPointID.Number) == nil {
+		writeError(w, http.StatusForbidden, "tenant may not unlock this access point")
+		return
+	}
+
+	s.unlocks = append(s.unlocks, Unlock{
+		TenantID:      body.TenantID,
+		AccessPointID: body.AccessPointID,
+		Source:        body.Source,
+		At:            s.Now(),
+	})
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"requestId": strconv.Itoa(len(s.unlocks)),
+	})
+}
@@ -0,0 +1,139 @@
+package bmxtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/neilotoole/slogt"
+	"golang.org/x/oauth2"
+	"libdb.so/go-butterflymx"
+)
+
+func testData() *Data {
+	data := &Data{}
+	for i := range 12 {
+		tenant := Tenant{
+			Tenant: butterflymx.Tenant{
+				ID:       butterflymx.NewTaggedID("tenant", butterflymx.ID(100+i)),
+				Name:     fmt.Sprintf("Tenant %d", i),
+				PINCode:  "1234",
+				Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+				Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+			},
+		}
+		if i == 0 {
+			tenant.AccessPoints = []butterflymx.AccessPoint{
+				{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door", OpenDuration: 5, Online: true},
+				{ID: butterflymx.NewTaggedID("access_point", 401), Name: "Garage", OpenDuration: 10, Online: true},
+			}
+		}
+		data.Tenants = append(data.Tenants, tenant)
+	}
+	return data
+}
+
+func TestServer(t *testing.T) {
+	server := NewServer(testData())
+	defer server.Close()
+
+	client := server.APIClient(&butterflymx.APIClientOpts{Logger: slogt.New(t)})
+	ctx := t.Context()
+
+	tenants, err := butterflymx.CollectResults(client.Tenants(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, 12, len(tenants), "tenants should be paginated through")
+	assert.Equal(t, "Tenant 11", tenants[11].Name)
+
+	var meta butterflymx.ResponseMeta
+	tenants, err = butterflymx.CollectResults(client.Tenants(ctx, butterflymx.WithPageSize(5), butterflymx.WithResponseMeta(&meta)))
+	assert.NoError(t, err)
+	assert.Equal(t, 12, len(tenants))
+	assert.Equal(t, 3, meta.Attempts, "page size should be sent to the server")
+
+	tenantID := tenants[0].ID
+	accessPoints, err := butterflymx.CollectResults(client.TenantAccessPoints(ctx, tenantID))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(accessPoints))
+	assert.Equal(t, "Garage", accessPoints[1].Name)
+
+	now := time.Now().Truncate(time.Second)
+	keychain, err := client.CreateCustomKeychain(ctx, tenantID.Number, []butterflymx.ID{400}, butterflymx.CustomKeychainArgs{
+		Name:     "Guest",
+		StartsAt: now,
+		EndsAt:   now.Add(24 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Guest", keychain.Data.Attributes.Name)
+
+	panel, err := keychain.Data.Relationships.Devices[0].Resolve(keychain.Refs)
+	assert.NoError(t, err)
+	assert.Equal(t, "Front Door", panel.Attributes.Name)
+
+	virtualKeys, err := client.CreateVirtualKeys(ctx, keychain.Data.ID, butterflymx.VirtualKeyArgs{
+		Recipients: []butterflymx.VirtualKeyRecipient{{Name: "guest", DeliverTo: "guest@example.com"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(virtualKeys.Data))
+	assert.NoError(t, virtualKeys.Data[0].Attributes.PINCode.Validate())
+
+	keychains, err := client.Keychains(ctx, tenantID.Number, butterflymx.ActiveAccessCode)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(keychains.Data))
+
+	virtualKey, err := keychains.Data[0].Relationships.VirtualKeys[0].Resolve(keychains.Refs)
+	assert.NoError(t, err)
+	assert.Equal(t, "guest@example.com", virtualKey.Attributes.Email)
+
+	err = client.RevokeVirtualKey(ctx, keychain.Data.ID, virtualKey.ID)
+	assert.NoError(t, err)
+
+	single, err := client.Keychain(ctx, keychain.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(single.Data.Relationships.VirtualKeys))
+
+	err = client.UnlockDoor(ctx, tenantID.Number, 401)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(server.Unlocks()))
+	assert.Equal(t, butterflymx.NewTaggedID("access_point", 401), server.Unlocks()[0].AccessPointID)
+
+	err = client.UnlockDoor(ctx, tenantID.Number, 999)
+	assert.Error(t, err)
+	assert.Equal(t, 1, len(server.Unlocks()))
+}
+
+func TestServer_unauthorized(t *testing.T) {
+	server := NewServer(testData())
+	defer server.Close()
+
+	client := butterflymx.NewAPIClient(butterflymx.APIStaticToken("wrong"), &butterflymx.APIClientOpts{
+		HTTPClient: server.HTTPClient(),
+		Logger:     slogt.New(t),
+	})
+
+	result, err := client.Ping(t.Context())
+	assert.NoError(t, err)
+	assert.True(t, result.Reachable)
+	assert.False(t, result.TokenOK)
+}
+
+func TestServer_login(t *testing.T) {
+	server := NewServer(testData())
+	defer server.Close()
+
+	client := butterflymx.NewDenizenLoginClientWithOpts(
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "oauth2-token"}),
+		&butterflymx.DenizenLoginClientOpts{
+			HTTPClient: server.Client(),
+			Logger:     slogt.New(t),
+			APIBaseURL: server.URL,
+		},
+	)
+
+	session, err := client.Login(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultToken, session.Token)
+	assert.Equal(t, 12, len(session.User.TenantIDs))
+	assert.Equal(t, butterflymx.TenantTaggedID(100), session.User.TenantIDs[0])
+}
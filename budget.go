@@ -0,0 +1,109 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// DefaultRequestBudgetWindow is the default [RequestBudget.Window].
+const DefaultRequestBudgetWindow = time.Hour
+
+// RequestBudget counts the HTTP requests made by an [APIClient] per tenant, so
+// that deployments managing several buildings can tell which property's
+// automation is consuming the API budget. Set it as
+// [APIClientOpts.RequestBudget]; the same RequestBudget may be shared by
+// several clients.
+//
+// Requests are attributed to the tenant that a method was called for, e.g. the
+// tenant given to [APIClient.UnlockDoor] or [APIClient.Keychains]. Requests
+// that aren't made for a particular tenant, such as those of
+// [APIClient.Tenants], are counted under tenant ID 0. Every HTTP request is
+// counted, including retries and requests for further pages.
+//
+// The zero value counts requests in windows of [DefaultRequestBudgetWindow]
+// without a soft limit. A RequestBudget is safe for concurrent use, but its
+// fields must not be modified once it is in use.
+type RequestBudget struct {
+	// Window is how long requests are counted for before the counts are
+	// reset. It defaults to [DefaultRequestBudgetWindow].
+	Window time.Duration
+	// SoftLimit is the number of requests per tenant per window after which
+	// OnSoftLimit is called. Requests over the limit are still made. Zero
+	// disables the limit.
+	SoftLimit int
+	// OnSoftLimit is called once per window for each tenant whose request
+	// count reaches SoftLimit. It is called synchronously from the goroutine
+	// making the request, so it should return quickly.
+	OnSoftLimit func(tenantID ID, count int)
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[ID]int
+}
+
+// record counts a request made for the tenant with the given numeric ID.
+func (b *RequestBudget) record(tenantID ID) {
+	b.mu.Lock()
+	b.resetIfExpired()
+	if b.counts == nil {
+		b.counts = make(map[ID]int)
+	}
+	b.counts[tenantID]++
+	count := b.counts[tenantID]
+	b.mu.Unlock()
+
+	// Skip unattributed requests, since they don't belong to any property.
+	if tenantID != 0 && b.SoftLimit > 0 && count == b.SoftLimit && b.OnSoftLimit != nil {
+		b.OnSoftLimit(tenantID, count)
+	}
+}
+
+// resetIfExpired clears the counts if the current window has ended. b.mu must
+// be held.
+func (b *RequestBudget) resetIfExpired() {
+	var now time.Time
+	if b.Now != nil {
+		now = b.Now()
+	} else {
+		now = time.Now()
+	}
+	window := use(b.Window, DefaultRequestBudgetWindow)
+	if b.windowStart.IsZero() || now.Sub(b.windowStart) >= window {
+		b.windowStart = now
+		clear(b.counts)
+	}
+}
+
+// Usage returns the number of requests made for each tenant, keyed by the
+// tenant's numeric ID, in the current window. Unattributed requests are keyed
+// by 0.
+func (b *RequestBudget) Usage() map[ID]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetIfExpired()
+	return maps.Clone(b.counts)
+}
+
+// UsageByBuilding returns the number of requests made in the current window
+// for each building, keyed by the building's numeric ID. tenants is used to
+// find the building of each tenant, e.g. the result of [APIClient.Tenants].
+// Requests for tenants that aren't in tenants, including unattributed ones,
+// are keyed by 0.
+func (b *RequestBudget) UsageByBuilding(tenants []Tenant) map[ID]int {
+	buildingOf := make(map[ID]ID, len(tenants))
+	for _, tenant := range tenants {
+		buildingOf[tenant.ID.Number] = tenant.Building.ID.Number
+	}
+
+	usage := make(map[ID]int)
+	for tenantID, count := range b.Usage() {
+		usage[buildingOf[tenantID]] += count
+	}
+	return usage
+}
@@ -0,0 +1,62 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/cenkalti/backoff/v5"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestRequestBudget(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var exceeded []ID
+	budget := &RequestBudget{
+		SoftLimit:   2,
+		OnSoftLimit: func(tenantID ID, count int) { exceeded = append(exceeded, tenantID) },
+		Now:         func() time.Time { return now },
+	}
+
+	client := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient: &http.Client{Transport: httpmock.NewSequence(t,
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			httpmock.RoundTripResponse{Status: http.StatusServiceUnavailable},
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			httpmock.RoundTripResponse{Body: keychainResponse},
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+		)},
+		Logger:         slogt.New(t),
+		RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+		RequestBudget:  budget,
+	})
+
+	for range 3 {
+		assert.NoError(t, client.UnlockDoor(t.Context(), 1, 100))
+	}
+	// The retry counts as a separate request.
+	assert.NoError(t, client.UnlockDoor(t.Context(), 2, 200))
+	_, err := client.Keychain(t.Context(), 10001)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[ID]int{0: 1, 1: 3, 2: 2}, budget.Usage())
+	assert.Equal(t, []ID{1, 2}, exceeded, "soft limit should be reported once per tenant")
+
+	tenants := []Tenant{
+		{ID: TenantTaggedID(1), Building: Building{ID: BuildingTaggedID(10)}},
+		{ID: TenantTaggedID(2), Building: Building{ID: BuildingTaggedID(10)}},
+	}
+	assert.Equal(t, map[ID]int{0: 1, 10: 5}, budget.UsageByBuilding(tenants))
+
+	now = now.Add(DefaultRequestBudgetWindow)
+	assert.Equal(t, 0, len(budget.Usage()), "counts should reset after the window")
+
+	assert.NoError(t, client.UnlockDoor(t.Context(), 1, 100))
+	assert.Equal(t, map[ID]int{1: 1}, budget.Usage())
+}
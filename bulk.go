@@ -0,0 +1,152 @@
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BulkOp is a single operation executed by [RunBulk], usually a call to an
+// [APIClient] mutation such as [APIClient.CreateCustomKeychain].
+type BulkOp func(ctx context.Context) error
+
+// BulkOpts holds optional parameters for [RunBulk].
+type BulkOpts struct {
+	// Concurrency is the number of operations running at once.
+	// Defaults to 2; negative values are treated as 1.
+	Concurrency int
+	// Interval is the minimum delay between starting two operations.
+	// Defaults to 250ms.
+	Interval time.Duration
+	// MaxRateLimitRetries is the number of times an operation rejected with
+	// 429 Too Many Requests is retried. Defaults to 5.
+	MaxRateLimitRetries int
+	// RateLimitDelay is how long all operations are paused after a 429
+	// response that does not include a Retry-After header. Defaults to 10s.
+	RateLimitDelay time.Duration
+	// Progress, if not nil, is called after each operation finishes. Calls
+	// are serialized.
+	Progress func(BulkProgress)
+}
+
+// BulkProgress reports the progress of [RunBulk].
+type BulkProgress struct {
+	// Total is the total number of operations.
+	Total int
+	// Done is the number of finished operations, including failed ones.
+	Done int
+	// Failed is the number of operations that returned an error.
+	Failed int
+}
+
+// RunBulk executes the given operations while pacing them to avoid being rate
+// limited by the API. Operations that are rejected with 429 Too Many Requests
+// are retried after the delay requested by the server, during which no other
+// operation is started.
+//
+// The returned slice holds the error of each operation at the same index, or
+// nil if it succeeded. Operations that were not started because ctx was
+// cancelled report the context error.
+func RunBulk(ctx context.Context, ops []BulkOp, opts *BulkOpts) []error {
+	opts = use(opts, &BulkOpts{})
+	opts.Concurrency = max(use(opts.Concurrency, 2), 1)
+	opts.Interval = use(opts.Interval, 250*time.Millisecond)
+	opts.MaxRateLimitRetries = use(opts.MaxRateLimitRetries, 5)
+	opts.RateLimitDelay = use(opts.RateLimitDelay, 10*time.Second)
+
+	errs := make([]error, len(ops))
+	pacer := &bulkPacer{interval: opts.Interval}
+
+	var progressMu sync.Mutex
+	progress := BulkProgress{Total: len(ops)}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(opts.Concurrency, len(ops)) {
+		wg.Go(func() {
+			for i := range jobs {
+				errs[i] = runBulkOp(ctx, ops[i], pacer, opts)
+
+				progressMu.Lock()
+				progress.Done++
+				if errs[i] != nil {
+					progress.Failed++
+				}
+				if opts.Progress != nil {
+					opts.Progress(progress)
+				}
+				progressMu.Unlock()
+			}
+		})
+	}
+
+	for i := range ops {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
+
+func runBulkOp(ctx context.Context, op BulkOp, pacer *bulkPacer, opts *BulkOpts) error {
+	for retries := 0; ; retries++ {
+		if err := pacer.wait(ctx); err != nil {
+			return err
+		}
+
+		err := op(ctx)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+		if retries >= opts.MaxRateLimitRetries {
+			return err
+		}
+
+		pacer.pause(use(apiErr.RetryAfter(), opts.RateLimitDelay))
+	}
+}
+
+// bulkPacer spaces out operations by a fixed interval and allows pausing all
+// of them at once.
+type bulkPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next operation may start.
+func (p *bulkPacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	at := time.Now()
+	if p.next.After(at) {
+		at = p.next
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pause delays all operations that haven't started yet by at least d.
+func (p *bulkPacer) pause(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(p.next) {
+		p.next = until
+	}
+}
@@ -0,0 +1,69 @@
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRunBulk(t *testing.T) {
+	var calls atomic.Int32
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	failure := errors.New("permanent failure")
+
+	ops := []BulkOp{
+		func(ctx context.Context) error {
+			calls.Add(1)
+			return nil
+		},
+		func() BulkOp {
+			var limited atomic.Bool
+			return func(ctx context.Context) error {
+				calls.Add(1)
+				if limited.CompareAndSwap(false, true) {
+					return rateLimited
+				}
+				return nil
+			}
+		}(),
+		func(ctx context.Context) error {
+			calls.Add(1)
+			return failure
+		},
+	}
+
+	var progress []BulkProgress
+	errs := RunBulk(t.Context(), ops, &BulkOpts{
+		Interval:       time.Millisecond,
+		RateLimitDelay: time.Millisecond,
+		Progress: func(p BulkProgress) {
+			progress = append(progress, p)
+		},
+	})
+
+	assert.Equal(t, []error{nil, nil, failure}, errs)
+	assert.Equal(t, int32(4), calls.Load())
+	assert.Equal(t, 3, len(progress))
+	assert.Equal(t, BulkProgress{Total: 3, Done: 3, Failed: 1}, progress[2])
+}
+
+func TestRunBulk_negativeConcurrency(t *testing.T) {
+	var calls atomic.Int32
+	op := func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}
+
+	errs := RunBulk(t.Context(), []BulkOp{op, op}, &BulkOpts{
+		Concurrency: -1,
+		Interval:    time.Millisecond,
+	})
+
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, int32(2), calls.Load())
+}
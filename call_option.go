@@ -0,0 +1,241 @@
+package butterflymx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CallOption tweaks a single call to an [APIClient] method without affecting
+// the rest of the client. Options that don't apply to a method are ignored.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	timeout  time.Duration
+	pageSize int
+	include  []string
+	header   http.Header
+	cursor   *string
+	meta     *ResponseMeta
+	partial  bool
+	progress func(Progress)
+	// tenant is the numeric ID of the tenant that the call is made for, or 0.
+	// It is set by methods rather than by an option.
+	tenant ID
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTimeout limits how long each HTTP request made by the call may take,
+// including retries. For methods that paginate, the timeout applies to each
+// page rather than the whole call.
+func WithTimeout(d time.Duration) CallOption {
+	return func(o *callOptions) { o.timeout = d }
+}
+
+// WithPageSize sets the number of items requested per page by methods that
+// paginate, such as [APIClient.Keychains] and [APIClient.Tenants]. Larger pages
+// take fewer requests but make each response bigger. By default, the REST
+// methods request 100 items per page, while the GraphQL methods use the
+// server's default page size.
+func WithPageSize(n int) CallOption {
+	return func(o *callOptions) { o.pageSize = n }
+}
+
+// WithInclude replaces the related entities requested through the JSON:API
+// include parameter, such as "virtual_keys.door_releases.panel". Entities that
+// aren't included can't be resolved from the result's references.
+func WithInclude(paths ...string) CallOption {
+	return func(o *callOptions) { o.include = paths }
+}
+
+// WithHeader sets an additional HTTP header on every request made by the call.
+// It can be given multiple times.
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// WithCursor makes paginated GraphQL methods, such as [APIClient.Tenants] and
+// [APIClient.TenantAccessPoints], resume from the cursor stored in *cursor and
+// keep it updated as pages are consumed. An empty cursor starts from the
+// beginning.
+//
+// The cursor only moves past a page once all of its items have been yielded,
+// so saving it after the iteration stops (for example because of an error or
+// a break) and passing it to a later call resumes without skipping items,
+// although items of a partially consumed page are yielded again.
+func WithCursor(cursor *string) CallOption {
+	return func(o *callOptions) { o.cursor = cursor }
+}
+
+// WithPartialResults makes methods that accumulate every page before returning,
+// such as [APIClient.Keychains], return the results of the pages fetched so far
+// along with the error if a later page fails, instead of discarding them. The
+// error is then a [*PaginationError]. [APIClient.AccessPointsOfTenants]
+// similarly returns the results of the chunks that succeeded.
+func WithPartialResults() CallOption {
+	return func(o *callOptions) { o.partial = true }
+}
+
+// Progress reports how far a long-running call has come. See [WithProgress].
+type Progress struct {
+	// Pages is the number of pages fetched so far.
+	Pages int
+	// Items is the number of items fetched or processed so far.
+	Items int
+	// Total is the total number of items, or 0 if it isn't known in advance,
+	// which is the case for paginated endpoints.
+	Total int
+}
+
+// WithProgress makes the call report its progress to fn, so that CLIs and UIs
+// can render progress bars for large accounts. Paginated methods, such as
+// [APIClient.Keychains] and [APIClient.Tenants], call fn after every page,
+// while [APIClient.ImportKeychains] calls it after every backup entry. fn is
+// called from the goroutine running the call. For bulk operations, see
+// [BulkOpts.Progress].
+func WithProgress(fn func(Progress)) CallOption {
+	return func(o *callOptions) { o.progress = fn }
+}
+
+// ResponseMeta describes the last HTTP response received by a call. Use
+// [WithResponseMeta] to capture it.
+type ResponseMeta struct {
+	// StatusCode is the HTTP status code of the last response.
+	StatusCode int
+	// Header is the header of the last response. It includes rate limiting
+	// information and request IDs, if the server sent any.
+	Header http.Header
+	// Duration is how long the last attempt took until the response header
+	// was received.
+	Duration time.Duration
+	// Attempts is the number of HTTP requests made, including retries and
+	// requests for other pages.
+	Attempts int
+}
+
+// RequestID returns the request ID assigned to the last response by the
+// server, if any.
+func (m *ResponseMeta) RequestID() string {
+	return m.Header.Get("X-Request-Id")
+}
+
+func (m *ResponseMeta) record(resp *http.Response, d time.Duration) {
+	m.Attempts++
+	m.Duration = d
+	if resp != nil {
+		m.StatusCode = resp.StatusCode
+		m.Header = resp.Header
+	} else {
+		m.StatusCode = 0
+		m.Header = nil
+	}
+}
+
+// WithResponseMeta fills *meta with the metadata of the last HTTP response
+// received by the call, even if the call fails. For paginated methods, it
+// describes the response of the last page fetched so far. The call is never
+// coalesced with other calls.
+//
+// meta must not be read until the call returns or, for iterators, until the
+// iteration is done.
+func WithResponseMeta(meta *ResponseMeta) CallOption {
+	return func(o *callOptions) { o.meta = meta }
+}
+
+// reportProgress calls the call's progress function, if any.
+func (o callOptions) reportProgress(p Progress) {
+	if o.progress != nil {
+		o.progress(p)
+	}
+}
+
+// context returns ctx with the call's timeout applied, if any.
+func (o callOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(ctx, o.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// applyHeader sets the call's additional headers on req.
+func (o callOptions) applyHeader(req *http.Request) {
+	for key, values := range o.header {
+		req.Header[key] = values
+	}
+}
+
+// startCursor returns the cursor to start paginating after, or nil to start
+// from the beginning.
+func (o callOptions) startCursor() *string {
+	if o.cursor == nil || *o.cursor == "" {
+		return nil
+	}
+	after := *o.cursor
+	return &after
+}
+
+// saveCursor records that all items up to and including cursor have been
+// consumed. Empty cursors, which the server returns for the last page, are
+// ignored so that resuming doesn't start over.
+func (o callOptions) saveCursor(cursor string) {
+	if o.cursor != nil && cursor != "" {
+		*o.cursor = cursor
+	}
+}
+
+func (o callOptions) pageSizeOr(def int) string {
+	if o.pageSize > 0 {
+		return strconv.Itoa(o.pageSize)
+	}
+	return strconv.Itoa(def)
+}
+
+// first returns the page size to request from GraphQL connections, or nil to
+// use the server's default.
+func (o callOptions) first() *int {
+	if o.pageSize > 0 {
+		return &o.pageSize
+	}
+	return nil
+}
+
+func (o callOptions) includeOr(def string) string {
+	if o.include != nil {
+		return strings.Join(o.include, ",")
+	}
+	return def
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx that makes [APIClient] methods
+// taking a tenant, such as [APIClient.UnlockDoor] and [APIClient.Keychains],
+// use the tenant with the given numeric ID if they are given 0 (or a zero
+// [TaggedID]). It takes precedence over [APIClientOpts.DefaultTenant]. This is
+// convenient for apps that only deal with a single residence.
+//
+// Unlike the With* functions, it isn't a [CallOption], since it applies to
+// every call made with the context.
+func ContextWithTenant(ctx context.Context, tenantID ID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set in ctx using [ContextWithTenant].
+func TenantFromContext(ctx context.Context) (ID, bool) {
+	id, ok := ctx.Value(tenantKey{}).(ID)
+	return id, ok && id != 0
+}
@@ -13,6 +13,7 @@ import (
 	"charm.land/lipgloss/v2"
 	"charm.land/lipgloss/v2/table"
 	butterflymx "libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/errfmt"
 )
 
 func main() {
@@ -28,7 +29,7 @@ func main() {
 
 	tenants, err := butterflymx.CollectResults(client.Tenants(ctx))
 	if err != nil {
-		log.Fatalf("failed to fetch tenants: %v", err)
+		log.Fatalf("failed to fetch tenants: %s", errfmt.Format(err))
 	}
 	if len(tenants) == 0 {
 		log.Fatal("no tenants found for this account")
@@ -34,6 +34,11 @@ func main() {
 	log.Println()
 	log.Println("Successfully obtained OAuth2 token:")
 	fmt.Println("oauth2_token:", token.AccessToken)
+	if token.RefreshToken != "" {
+		// The refresh token outlives the access token, so it is what should be
+		// persisted, e.g. as BUTTERFLYMX_REFRESH_TOKEN for the exporter.
+		fmt.Println("oauth2_refresh_token:", token.RefreshToken)
+	}
 
 	loginClient := butterflymx.NewDenizenLoginClient(oauth2.StaticTokenSource(token))
 
@@ -0,0 +1,84 @@
+//go:build goexperiment.jsonv2
+
+// Command bmx-mockserver runs the fake ButterflyMX server from package bmxtest
+// as a standalone binary, so that tests written in other languages can run
+// against a local ButterflyMX stand-in.
+//
+// The server can be seeded with a JSON file in the format of [bmxtest.Data].
+// Clients must send all API requests to the server's address instead of the
+// real API hosts, authenticating with the token given by -token.
+package main
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+)
+
+var (
+	listenAddr = "127.0.0.1:8080"
+	seedFile   = ""
+	dumpFile   = ""
+	apiToken   = string(bmxtest.DefaultToken)
+)
+
+func init() {
+	flag.StringVar(&listenAddr, "listen", listenAddr, "address to listen on")
+	flag.StringVar(&seedFile, "data", seedFile, "JSON file to seed the server with")
+	flag.StringVar(&dumpFile, "dump", dumpFile, "JSON file to write the server's data to on exit")
+	flag.StringVar(&apiToken, "token", apiToken, "API token that clients must use")
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var data bmxtest.Data
+	if seedFile != "" {
+		b, err := os.ReadFile(seedFile)
+		if err != nil {
+			log.Fatalf("failed to read seed data: %v", err)
+		}
+		if err := json.Unmarshal(b, &data); err != nil {
+			log.Fatalf("failed to parse seed data: %v", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+
+	server := bmxtest.NewUnstartedServer(&data)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Token = butterflymx.APIStaticToken(apiToken)
+	server.Start()
+
+	log.Printf("serving fake ButterflyMX API on %s with %d tenants", server.URL, len(data.Tenants))
+
+	<-ctx.Done()
+	server.Close()
+
+	if dumpFile != "" {
+		b, err := json.Marshal(server.Data(), jsontext.WithIndent("  "))
+		if err != nil {
+			log.Fatalf("failed to marshal data: %v", err)
+		}
+		if err := os.WriteFile(dumpFile, append(b, '\n'), 0644); err != nil {
+			log.Fatalf("failed to write data: %v", err)
+		}
+		log.Printf("wrote data to %s", dumpFile)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/errfmt"
 )
 
 var (
@@ -36,7 +37,7 @@ func main() {
 
 	tenants, err := butterflymx.CollectResults(client.Tenants(ctx))
 	if err != nil {
-		log.Fatalf("failed to fetch tenants: %v", err)
+		log.Fatalf("failed to fetch tenants: %s", errfmt.Format(err))
 	}
 	if len(tenants) == 0 {
 		log.Fatal("no tenants found for this account")
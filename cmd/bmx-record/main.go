@@ -0,0 +1,268 @@
+//go:build goexperiment.jsonv2
+
+// Command bmx-record records sanitized JSON fixtures from the live ButterflyMX
+// API for use in tests.
+//
+// Only read-only endpoints are recorded. Every request and response is passed
+// through the same sanitizer used by the VCR cassettes, so tokens, PIN codes,
+// signed URLs and personal information are replaced deterministically before
+// anything is written to disk. As a last line of defense, no fixtures are
+// written if any of them still contain the details of the recorded tenant.
+//
+// The fixtures are canonicalized and named after the endpoint, e.g.
+// testdata/api-get-v3-access-codes.json. If the request has a JSON body, the
+// fixture contains the request followed by the response, otherwise just the
+// response.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	butterflymx "libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/internal/vcr"
+)
+
+var (
+	outDir     = "testdata"
+	keychainID = 0
+	overwrite  = false
+)
+
+func init() {
+	flag.StringVar(&outDir, "out", outDir, "directory to write fixtures to")
+	flag.IntVar(&keychainID, "keychain", keychainID, "keychain ID for the keychain endpoint (default: first active keychain)")
+	flag.BoolVar(&overwrite, "overwrite", overwrite, "overwrite existing fixtures")
+}
+
+// endpoints are the endpoints that can be recorded, in recording order.
+var endpoints = []string{
+	"tenants",
+	"access-points",
+	"access-codes",
+	"keychain",
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [endpoints...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Endpoints: %s (default: all)\n", strings.Join(endpoints, ", "))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	log.SetFlags(0)
+	ctx := context.Background()
+
+	selected := flag.Args()
+	if len(selected) == 0 {
+		selected = endpoints
+	}
+	for _, name := range selected {
+		if !slices.Contains(endpoints, name) {
+			log.Fatalf("unknown endpoint %q", name)
+		}
+	}
+
+	apiToken := os.Getenv("BUTTERFLYMX_API_TOKEN")
+	if apiToken == "" {
+		log.Fatal("BUTTERFLYMX_API_TOKEN environment variable is required")
+	}
+
+	recorder := vcr.NewRecorder(http.DefaultTransport)
+	client := butterflymx.NewAPIClient(butterflymx.APIStaticToken(apiToken), &butterflymx.APIClientOpts{
+		HTTPClient: &http.Client{Transport: recorder},
+	})
+
+	tenant, err := record(ctx, client, selected)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cassette, err := recorder.Cassette()
+	if err != nil {
+		log.Fatalf("failed to sanitize recording: %v", err)
+	}
+
+	fixtures := make(map[string][]byte)
+	var names []string
+	for _, in := range cassette.Interactions {
+		name, err := fixtureName(in.Request)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Only keep the first page of paginated endpoints.
+		if _, ok := fixtures[name]; ok {
+			continue
+		}
+
+		fixture, err := formatFixture(in)
+		if err != nil {
+			log.Fatalf("failed to format %s: %v", name, err)
+		}
+		if leaked := leakedDetail(fixture, tenant); leaked != "" {
+			log.Fatalf("refusing to write fixtures: %s still contains the tenant's %s", name, leaked)
+		}
+
+		fixtures[name] = fixture
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		path := filepath.Join(outDir, name)
+		if !overwrite {
+			if _, err := os.Stat(path); err == nil {
+				log.Printf("skipping %s: already exists", path)
+				continue
+			}
+		}
+
+		if err := writeFixture(path, fixtures[name]); err != nil {
+			log.Fatalf("failed to write %s: %v", path, err)
+		}
+		log.Printf("wrote %s", path)
+	}
+}
+
+// leakedDetail returns the name of the first personal detail of the tenant
+// that is still present as a string in the fixture, or an empty string if
+// there is none.
+func leakedDetail(fixture []byte, tenant butterflymx.Tenant) string {
+	details := []struct {
+		name  string
+		value string
+	}{
+		{"name", tenant.Name},
+		{"first name", tenant.FirstName},
+		{"last name", tenant.LastName},
+		{"unit label", tenant.Unit.Label},
+		{"building name", tenant.Building.Name},
+	}
+	for _, detail := range details {
+		if detail.value == "" {
+			continue
+		}
+		quoted, err := jsontext.AppendQuote(nil, detail.value)
+		if err == nil && bytes.Contains(fixture, quoted) {
+			return detail.name
+		}
+	}
+	return ""
+}
+
+// record makes the requests for the selected endpoints and returns the tenant
+// they were made for.
+func record(ctx context.Context, client *butterflymx.APIClient, selected []string) (butterflymx.Tenant, error) {
+	tenants, err := butterflymx.CollectResults(client.Tenants(ctx))
+	if err != nil {
+		return butterflymx.Tenant{}, fmt.Errorf("failed to fetch tenants: %w", err)
+	}
+	if len(tenants) == 0 {
+		return butterflymx.Tenant{}, fmt.Errorf("no tenants found for this account")
+	}
+	tenant := tenants[0]
+
+	for _, name := range selected {
+		switch name {
+		case "tenants":
+			// Already recorded above.
+		case "access-points":
+			if _, err := butterflymx.CollectResults(client.TenantAccessPoints(ctx, tenant.ID)); err != nil {
+				return butterflymx.Tenant{}, fmt.Errorf("failed to fetch access points: %w", err)
+			}
+		case "access-codes":
+			if _, err := client.Keychains(ctx, tenant.ID.Number, butterflymx.ActiveAccessCode); err != nil {
+				return butterflymx.Tenant{}, fmt.Errorf("failed to fetch keychains: %w", err)
+			}
+		case "keychain":
+			id := butterflymx.ID(keychainID)
+			if id == 0 {
+				keychains, err := client.Keychains(ctx, tenant.ID.Number, butterflymx.ActiveAccessCode)
+				if err != nil {
+					return butterflymx.Tenant{}, fmt.Errorf("failed to fetch keychains: %w", err)
+				}
+				if len(keychains.Data) == 0 {
+					return butterflymx.Tenant{}, fmt.Errorf("no active keychains found, use -keychain")
+				}
+				id = keychains.Data[0].ID
+			}
+			if _, err := client.Keychain(ctx, id); err != nil {
+				return butterflymx.Tenant{}, fmt.Errorf("failed to fetch keychain %v: %w", id, err)
+			}
+		}
+	}
+
+	return tenant, nil
+}
+
+// fixtureName returns the fixture file name for the given request. Numeric
+// path segments are replaced with "id", and GraphQL requests are further
+// distinguished by their operation name.
+func fixtureName(req vcr.Request) (string, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid request URL %q: %w", req.URL, err)
+	}
+
+	parts := []string{"api", strings.ToLower(req.Method)}
+	for segment := range strings.SplitSeq(strings.Trim(u.Path, "/"), "/") {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segment = "id"
+		}
+		parts = append(parts, strings.ReplaceAll(segment, "_", "-"))
+	}
+
+	if strings.HasSuffix(u.Path, "/graphql") && req.Body.JSON != nil {
+		var body struct {
+			OperationName string `json:"operationName"`
+		}
+		if err := json.Unmarshal(req.Body.JSON, &body); err == nil && body.OperationName != "" {
+			parts = append(parts, strings.ToLower(body.OperationName))
+		}
+	}
+
+	return strings.Join(parts, "-") + ".json", nil
+}
+
+// formatFixture formats the JSON bodies of the interaction as a fixture.
+func formatFixture(in vcr.Interaction) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, body := range []vcr.Body{in.Request.Body, in.Response.Body} {
+		if body.JSON == nil {
+			continue
+		}
+		v := body.JSON.Clone()
+		if err := v.Canonicalize(); err != nil {
+			return nil, err
+		}
+		if err := v.Indent(jsontext.WithIndentPrefix(""), jsontext.WithIndent("  ")); err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return nil, fmt.Errorf("response is not JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+func writeFixture(path string, fixture []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, fixture, 0644)
+}
@@ -0,0 +1,118 @@
+//go:build goexperiment.jsonv2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	butterflymx "libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/internal/sdnotify"
+)
+
+const readinessTimeout = 10 * time.Second
+
+// healthChecker serves the /healthz and /readyz endpoints.
+//
+// /healthz reports whether the scrape loop is still making progress, which
+// is what the systemd watchdog is tied to. /readyz additionally verifies that
+// the API token is valid and that the API is reachable.
+type healthChecker struct {
+	client  *butterflymx.APIClient
+	scraper *scraper
+}
+
+func (h *healthChecker) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.serveHealthz)
+	mux.HandleFunc("GET /readyz", h.serveReadyz)
+}
+
+// alive returns an error if the scrape loop appears to be wedged.
+func (h *healthChecker) alive() error {
+	lastAttempt := h.scraper.lastAttemptTime()
+	if lastAttempt.IsZero() {
+		// Still starting up.
+		return nil
+	}
+	if since := time.Since(lastAttempt); since > 3*scrapeInterval {
+		return fmt.Errorf("no scrape attempted in %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+// ready returns an error if the API cannot currently be used.
+func (h *healthChecker) ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	result, err := h.client.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	if !result.TokenOK {
+		return errors.New("API token was rejected")
+	}
+	return nil
+}
+
+func (h *healthChecker) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, h.alive())
+}
+
+func (h *healthChecker) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := h.alive(); err != nil {
+		writeHealth(w, err)
+		return
+	}
+	writeHealth(w, h.ready(r.Context()))
+}
+
+func writeHealth(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// runWatchdog notifies systemd that the exporter is ready and keeps pinging
+// the watchdog for as long as the scrape loop is alive. It does nothing if the
+// exporter isn't running under systemd.
+func (h *healthChecker) runWatchdog(ctx context.Context) {
+	if ok, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		log.Printf("failed to notify systemd: %v", err)
+	} else if !ok {
+		return
+	}
+
+	interval := sdnotify.WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			sdnotify.Notify(sdnotify.Stopping)
+			return
+		case <-ticker.C:
+		}
+
+		if err := h.alive(); err != nil {
+			log.Printf("not pinging systemd watchdog: %v", err)
+			continue
+		}
+		if _, err := sdnotify.Notify(sdnotify.Watchdog); err != nil {
+			log.Printf("failed to ping systemd watchdog: %v", err)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+//go:build goexperiment.jsonv2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	butterflymx "libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/errfmt"
+)
+
+var (
+	listenAddr     = ":9877"
+	scrapeInterval = time.Minute
+)
+
+func init() {
+	flag.StringVar(&listenAddr, "listen", listenAddr, "address to serve metrics on")
+	flag.DurationVar(&scrapeInterval, "interval", scrapeInterval, "interval between API scrapes")
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	metrics := newMetrics(prometheus.DefaultRegisterer)
+
+	tokenSource, err := tokenSourceFromEnv(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := butterflymx.NewAPIClient(countingTokenSource{tokenSource, metrics}, nil)
+	scraper := &scraper{client: client, metrics: metrics}
+
+	go func() {
+		ticker := time.NewTicker(scrapeInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := scraper.scrape(ctx); err != nil {
+				log.Printf("scrape failed: %s", errfmt.Format(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	health := &healthChecker{client: client, scraper: scraper}
+	go health.runWatchdog(ctx)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	health.register(mux)
+
+	server := &http.Server{Addr: listenAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Printf("serving metrics on %s/metrics", listenAddr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
+
+// tokenSourceFromEnv returns a token source based on the environment. A
+// refresh token is preferred, since static API tokens expire and the exporter
+// is meant to run for a long time.
+func tokenSourceFromEnv(ctx context.Context) (butterflymx.APITokenSource, error) {
+	if refreshToken := os.Getenv("BUTTERFLYMX_REFRESH_TOKEN"); refreshToken != "" {
+		return butterflymx.NewDenizenLoginClientFromRefreshToken(ctx, refreshToken, "").APITokenSource(), nil
+	}
+
+	if apiToken := os.Getenv("BUTTERFLYMX_API_TOKEN"); apiToken != "" {
+		return butterflymx.APIStaticToken(apiToken), nil
+	}
+
+	return nil, errors.New("BUTTERFLYMX_REFRESH_TOKEN or BUTTERFLYMX_API_TOKEN environment variable is required")
+}
+
+type metrics struct {
+	tenants                *tenantCollector
+	tokenRefreshFailures   prometheus.Counter
+	scrapeFailures         prometheus.Counter
+	lastScrapeSuccessSecs  prometheus.Gauge
+	lastScrapeDurationSecs prometheus.Gauge
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		tenants: newTenantCollector(),
+		tokenRefreshFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "butterflymx",
+			Name:      "token_refresh_failures_total",
+			Help:      "Number of times an API token could not be obtained.",
+		}),
+		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "butterflymx",
+			Name:      "scrape_failures_total",
+			Help:      "Number of failed API scrapes.",
+		}),
+		lastScrapeSuccessSecs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "butterflymx",
+			Name:      "last_scrape_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful API scrape.",
+		}),
+		lastScrapeDurationSecs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "butterflymx",
+			Name:      "last_scrape_duration_seconds",
+			Help:      "Duration of the last API scrape.",
+		}),
+	}
+	reg.MustRegister(
+		m.tenants,
+		m.tokenRefreshFailures,
+		m.scrapeFailures,
+		m.lastScrapeSuccessSecs,
+		m.lastScrapeDurationSecs,
+	)
+	return m
+}
+
+// countingTokenSource counts failures to obtain an API token.
+type countingTokenSource struct {
+	src     butterflymx.APITokenSource
+	metrics *metrics
+}
+
+func (s countingTokenSource) APIToken(ctx context.Context, renew bool) (butterflymx.APIStaticToken, error) {
+	token, err := s.src.APIToken(ctx, renew)
+	if err != nil {
+		s.metrics.tokenRefreshFailures.Inc()
+	}
+	return token, err
+}
+
+type scraper struct {
+	client      *butterflymx.APIClient
+	metrics     *metrics
+	lastAttempt atomic.Int64 // unix nanoseconds
+}
+
+// lastAttemptTime returns the time the last scrape was started, or the zero
+// time if no scrape has been started yet.
+func (s *scraper) lastAttemptTime() time.Time {
+	nanos := s.lastAttempt.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+func (s *scraper) scrape(ctx context.Context) error {
+	start := time.Now()
+	s.lastAttempt.Store(start.UnixNano())
+	defer func() {
+		s.metrics.lastScrapeDurationSecs.Set(time.Since(start).Seconds())
+	}()
+
+	if err := s.scrapeTenants(ctx, start); err != nil {
+		s.metrics.scrapeFailures.Inc()
+		return err
+	}
+
+	s.metrics.lastScrapeSuccessSecs.Set(float64(start.Unix()))
+	return nil
+}
+
+func (s *scraper) scrapeTenants(ctx context.Context, now time.Time) error {
+	tenants, err := butterflymx.CollectResults(s.client.Tenants(ctx))
+	if err != nil {
+		return err
+	}
+
+	// The values are only swapped in once every tenant has been scraped, so
+	// a failed scrape keeps serving the previous values rather than partial
+	// ones, and removed tenants and access points don't linger.
+	var snapshot tenantSnapshot
+
+	for _, tenant := range tenants {
+		tenantLabel := tenant.ID.String()
+
+		for ap, err := range s.client.TenantAccessPoints(ctx, tenant.ID) {
+			if err != nil {
+				return err
+			}
+			snapshot.accessPoints = append(snapshot.accessPoints, accessPointSample{
+				tenant: tenantLabel,
+				ap:     ap,
+			})
+		}
+
+		keychains, err := s.client.Keychains(ctx, tenant.ID.Number, butterflymx.ActiveAccessCode)
+		if err != nil {
+			return err
+		}
+
+		releases, err := countDoorReleasesSince(keychains, now.Add(-time.Hour))
+		if err != nil {
+			return err
+		}
+
+		snapshot.tenants = append(snapshot.tenants, tenantSample{
+			tenant:               tenantLabel,
+			activeKeychains:      len(keychains.Data),
+			doorReleasesLastHour: releases,
+		})
+	}
+
+	s.metrics.tenants.snapshot.Store(&snapshot)
+	return nil
+}
+
+// countDoorReleasesSince counts the door releases of the virtual keys of the
+// given keychains that were logged at or after since.
+func countDoorReleasesSince(keychains *butterflymx.ResultsWithReferences[butterflymx.Keychain], since time.Time) (int, error) {
+	var n int
+	for _, keychain := range keychains.Data {
+		for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(keychains.Refs) {
+			if err != nil {
+				return 0, err
+			}
+			for release, err := range virtualKey.Relationships.DoorReleases.Resolve(keychains.Refs) {
+				if err != nil {
+					return 0, err
+				}
+				if !release.Attributes.LoggedAt.Before(since) {
+					n++
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+// tenantSnapshot holds the per-tenant values of a single successful scrape.
+type tenantSnapshot struct {
+	accessPoints []accessPointSample
+	tenants      []tenantSample
+}
+
+type accessPointSample struct {
+	tenant string
+	ap     butterflymx.AccessPoint
+}
+
+type tenantSample struct {
+	tenant               string
+	activeKeychains      int
+	doorReleasesLastHour int
+}
+
+// tenantCollector exports the values of the latest [tenantSnapshot]. Unlike
+// gauges, which would have to be reset and then set one by one, a snapshot
+// is swapped in all at once, so Prometheus never sees a half-updated scrape.
+type tenantCollector struct {
+	snapshot atomic.Pointer[tenantSnapshot]
+
+	accessPointOnline    *prometheus.Desc
+	doorReleasesLastHour *prometheus.Desc
+	activeKeychains      *prometheus.Desc
+}
+
+func newTenantCollector() *tenantCollector {
+	return &tenantCollector{
+		accessPointOnline: prometheus.NewDesc(
+			"butterflymx_access_point_online",
+			"Whether the access point is online (1) or offline (0).",
+			[]string{"tenant", "access_point", "name"}, nil),
+		doorReleasesLastHour: prometheus.NewDesc(
+			"butterflymx_door_releases_last_hour",
+			"Number of door releases by virtual keys logged within the last hour.",
+			[]string{"tenant"}, nil),
+		activeKeychains: prometheus.NewDesc(
+			"butterflymx_active_keychains",
+			"Number of active keychains.",
+			[]string{"tenant"}, nil),
+	}
+}
+
+// Describe implements [prometheus.Collector].
+func (c *tenantCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.accessPointOnline
+	ch <- c.doorReleasesLastHour
+	ch <- c.activeKeychains
+}
+
+// Collect implements [prometheus.Collector].
+func (c *tenantCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.snapshot.Load()
+	if snapshot == nil {
+		return
+	}
+
+	for _, sample := range snapshot.accessPoints {
+		var online float64
+		if sample.ap.Online {
+			online = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.accessPointOnline, prometheus.GaugeValue, online,
+			sample.tenant, sample.ap.ID.String(), sample.ap.Name)
+	}
+
+	for _, sample := range snapshot.tenants {
+		ch <- prometheus.MustNewConstMetric(c.doorReleasesLastHour, prometheus.GaugeValue,
+			float64(sample.doorReleasesLastHour), sample.tenant)
+		ch <- prometheus.MustNewConstMetric(c.activeKeychains, prometheus.GaugeValue,
+			float64(sample.activeKeychains), sample.tenant)
+	}
+}
@@ -3,8 +3,15 @@ package butterflymx
 import (
 	"bytes"
 	"context"
+	"encoding/json/jsontext"
 	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
 	"net/http"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,10 +21,14 @@ import (
 // AssumedAPITokenValidity is the assumed validity duration for ButterflyMX API
 // tokens obtained via OAuth2 exchange, as the actual validity period is
 // unknown.
+//
+// Deprecated: The expiry of API tokens is read from the tokens themselves
+// instead. See [APIStaticToken.Expiry].
 const AssumedAPITokenValidity = 5 * time.Minute
 
 // APIDeviceInfo represents the device information sent during the OAuth2 to
-// API token exchange.
+// API token exchange. Its locales are replaced by [DenizenLoginClient.Locale]
+// if that is set.
 var APIDeviceInfo = map[string]any{
 	"locales":  []string{"en"},
 	"platform": "android",
@@ -29,10 +40,47 @@ var APIDeviceInfo = map[string]any{
 // [oauth2.TokenSource] to obtain an [APITokenSource] that provides API tokens
 // for authenticating with the ButterflyMX API.
 //
-// It implements the [APITokenSource] interface.
+// It implements the [APITokenSource] interface. Errors caused by rejected
+// credentials or a disabled account are [*AuthError]s.
 type DenizenLoginClient struct {
+	// Locale is the BCP 47 language tag of the user, e.g. "es" or "fr-CA",
+	// sent as the device locale during the token exchange. It should match
+	// [APIClientOpts.Locale]. If empty, the locales in [APIDeviceInfo] are
+	// used. It must not be changed after the client is first used.
+	Locale string
+	// TokenStore, if set, keeps the API token obtained by
+	// [DenizenLoginClient.APITokenSource] across restarts. See
+	// [ReuseStoredAPITokenSource]. To also keep the OAuth2 token, wrap the
+	// OAuth2 token source using [StoreOAuth2TokenSource].
+	TokenStore TokenStore
+
 	tokenSource oauth2.TokenSource
+	opts        DenizenLoginClientOpts
 	lastToken   atomic.Pointer[APIStaticToken]
+
+	reusedOnce sync.Once
+	reused     APITokenSource
+}
+
+// DenizenLoginClientOpts holds optional parameters for configuring a
+// [DenizenLoginClient].
+type DenizenLoginClientOpts struct {
+	// HTTPClient is used for the token exchange. It defaults to
+	// [http.DefaultClient]. It isn't used to refresh OAuth2 tokens, which is
+	// done by the [oauth2.TokenSource] given to the client.
+	HTTPClient *http.Client
+	// Logger logs every token exchange at Debug level. It defaults to
+	// [slog.Default].
+	Logger    *slog.Logger
+	UserAgent string // defaults to [DefaultUserAgent]
+	// OnTokenRenewed, if set, is called whenever a new API token is obtained
+	// by [DenizenLoginClient.APITokenSource]. See
+	// [ReuseAPITokenSourceOpts.OnTokenRenewed].
+	OnTokenRenewed func(token APIStaticToken)
+	// APIBaseURL is the base URL of the API that tokens are exchanged with.
+	// It defaults to [APIBaseURL] and should match
+	// [APIClientOpts.APIBaseURL].
+	APIBaseURL string
 }
 
 var _ APITokenSource = (*DenizenLoginClient)(nil)
@@ -42,8 +90,39 @@ var _ APITokenSource = (*DenizenLoginClient)(nil)
 // configured and capable of providing valid OAuth2 access tokens for the
 // ButterflyMX service.
 func NewDenizenLoginClient(tokenSource oauth2.TokenSource) *DenizenLoginClient {
+	return NewDenizenLoginClientWithOpts(tokenSource, nil)
+}
+
+// NewDenizenLoginClientFromRefreshToken creates a client from just an OAuth2
+// refresh token, such as one extracted from the mobile app or printed by
+// cmd/bmx-auth. The OAuth2 token source is built using [AccountAuthConfig],
+// with its client ID replaced by clientID if that isn't empty.
+//
+// ctx is used to refresh the OAuth2 token, so it should outlive the client.
+// Refresh tokens may be rotated on every refresh; to keep the latest one, build
+// the token source using [AuthFlowClient.TokenSource] instead and wrap it using
+// [StoreOAuth2TokenSource].
+func NewDenizenLoginClientFromRefreshToken(ctx context.Context, refreshToken, clientID string) *DenizenLoginClient {
+	config := *AccountAuthConfig
+	if clientID != "" {
+		config.ClientID = clientID
+	}
+	return NewDenizenLoginClient(config.TokenSource(ctx, &oauth2.Token{RefreshToken: refreshToken}))
+}
+
+// NewDenizenLoginClientWithOpts is like [NewDenizenLoginClient], but it lets
+// the HTTP client, logger, User-Agent and base URL of the token exchange be
+// configured. If opts is nil, the defaults are used.
+func NewDenizenLoginClientWithOpts(tokenSource oauth2.TokenSource, opts *DenizenLoginClientOpts) *DenizenLoginClient {
+	opts = use(opts, &DenizenLoginClientOpts{})
+	opts.HTTPClient = use(opts.HTTPClient, http.DefaultClient)
+	opts.Logger = use(opts.Logger, slog.Default())
+	opts.UserAgent = use(opts.UserAgent, DefaultUserAgent)
+	opts.APIBaseURL = strings.TrimSuffix(use(opts.APIBaseURL, APIBaseURL), "/")
+
 	return &DenizenLoginClient{
 		tokenSource: tokenSource,
+		opts:        *opts,
 	}
 }
 
@@ -53,55 +132,173 @@ func NewDenizenLoginClient(tokenSource oauth2.TokenSource) *DenizenLoginClient {
 // It first retrieves an OAuth2 access token from the client's token source,
 // then sends it to the /denizen/v1/login endpoint. The ButterflyMX API
 // validates the OAuth2 token and returns a Rails session token, which is
-// required for all subsequent API interactions.
+// required for all subsequent API interactions. The new token is also handed
+// out by [DenizenLoginClient.APITokenSource] afterwards.
 func (c *DenizenLoginClient) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
-	return c.APITokenSource().APIToken(ctx, renew)
+	return c.APITokenSource().APIToken(ctx, true)
+}
+
+// LoginSession is the response of the /denizen/v1/login endpoint.
+type LoginSession struct {
+	// Token is the API token obtained by the login.
+	Token APIStaticToken `json:"token"`
+	// User is the user that logged in.
+	User LoginUser `json:"user"`
+	// Extra holds the other members of the response as a JSON object.
+	Extra jsontext.Value `json:",inline"`
+}
+
+// LoginUser describes the user of a [LoginSession]. Like the rest of the
+// Denizen API, IDs are tagged and member names are in camel case.
+type LoginUser struct {
+	// ID is the ID of the user, e.g. "prod-user-12345".
+	ID TaggedID `json:"id"`
+	// TenantIDs are the IDs of the tenants of the user, of type
+	// [TaggedTypeTenant]. They can be listed using [APIClient.Tenants].
+	TenantIDs []TaggedID `json:"tenantIds"`
+	// Features are the names of the app features enabled for the user.
+	Features []string `json:"features"`
+	// Extra holds the other members of the user as a JSON object.
+	Extra jsontext.Value `json:",inline"`
+}
+
+// Login performs the token exchange like [DenizenLoginClient.APIToken], but
+// returns the whole response instead of just the API token. The token is
+// handed out by [DenizenLoginClient.APITokenSource] afterwards, and saved to
+// [DenizenLoginClient.TokenStore] if it is set, so that it isn't exchanged
+// again.
+func (c *DenizenLoginClient) Login(ctx context.Context) (*LoginSession, error) {
+	session, err := c.exchanger().login(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.APITokenSource().(*reusedAPITokenSource).seed(ctx, session.Token); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
 // APITokenSource returns an [APITokenSource] that provides an API token until it
-// needs to be renewed (once [renew] is true).
+// needs to be renewed (once [renew] is true). The same token source is
+// returned on every call.
 func (c *DenizenLoginClient) APITokenSource() APITokenSource {
-	return ReuseAPITokenSource(oauth2APITokenSource{
-		oauth2TokenSource: c.tokenSource,
+	c.reusedOnce.Do(func() {
+		c.reused = ReuseAPITokenSourceWithOpts(c.exchanger(), &ReuseAPITokenSourceOpts{
+			Store:          c.TokenStore,
+			OnTokenRenewed: c.opts.OnTokenRenewed,
+		})
 	})
+	return c.reused
+}
+
+// exchanger returns the token source that performs the token exchange without
+// reusing tokens.
+func (c *DenizenLoginClient) exchanger() oauth2APITokenSource {
+	return oauth2APITokenSource{
+		oauth2TokenSource: c.tokenSource,
+		locale:            c.Locale,
+		opts:              c.opts,
+	}
 }
 
 type oauth2APITokenSource struct {
 	oauth2TokenSource oauth2.TokenSource
+	locale            string
+	opts              DenizenLoginClientOpts
+}
+
+// deviceInfo returns [APIDeviceInfo] with its locales replaced by locale, if
+// it is not empty.
+func deviceInfo(locale string) map[string]any {
+	if locale == "" {
+		return APIDeviceInfo
+	}
+	info := maps.Clone(APIDeviceInfo)
+	info["locales"] = []string{locale}
+	return info
 }
 
 func (s oauth2APITokenSource) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
-	token, err := s.oauth2TokenSource.Token()
+	session, err := s.login(ctx, renew)
 	if err != nil {
 		return "", err
 	}
+	return session.Token, nil
+}
+
+func (s oauth2APITokenSource) login(ctx context.Context, renew bool) (*LoginSession, error) {
+	token, err := s.oauth2TokenSource.Token()
+	if err != nil {
+		return nil, classifyOAuth2Error(err)
+	}
 
 	requestBody, err := json.Marshal(map[string]any{
 		"access_token": token.AccessToken,
-		"device":       APIDeviceInfo,
+		"device":       deviceInfo(s.locale),
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, APIBaseURL+"/denizen/v1/login", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.opts.APIBaseURL+"/denizen/v1/login", bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("User-Agent", s.opts.UserAgent)
+	if s.locale != "" {
+		req.Header.Set("Accept-Language", s.locale)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := s.opts.HTTPClient.Do(req)
 	if err != nil {
-		return "", err
+		s.opts.Logger.DebugContext(ctx, "API token exchange failed", "renew", renew, "err", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var responseBody struct {
-		Token string `json:"token"`
+	s.opts.Logger.DebugContext(ctx, "API token exchange",
+		"renew", renew,
+		"status", resp.StatusCode,
+		"latency", time.Since(start))
+
+	if err := checkLoginResponse(resp); err != nil {
+		return nil, err
 	}
-	if err := json.UnmarshalRead(resp.Body, &responseBody); err != nil {
-		return "", err
+
+	var session LoginSession
+	if err := json.UnmarshalRead(resp.Body, &session); err != nil {
+		return nil, err
 	}
 
-	return APIStaticToken(responseBody.Token), nil
+	return &session, nil
+}
+
+// classifyOAuth2Error wraps err in an [*AuthError] if the OAuth2 server
+// rejected the refresh token. Other errors, such as network errors, are
+// returned as-is, since retrying may fix them.
+func classifyOAuth2Error(err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+		return &AuthError{Failure: AuthFailureRefreshTokenExpired, Err: err}
+	}
+	return err
+}
+
+// checkLoginResponse returns an error if the /denizen/v1/login endpoint
+// didn't accept the OAuth2 access token. A 401 means the access token was
+// rejected, while a 403 means the token is valid but the account isn't
+// allowed to log in.
+func checkLoginResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return &AuthError{Failure: AuthFailureRefreshTokenExpired, Err: newAPIError(resp)}
+	case resp.StatusCode == http.StatusForbidden:
+		return &AuthError{Failure: AuthFailureAccountDisabled, Err: newAPIError(resp)}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("login failed: %w", newAPIError(resp))
+	default:
+		return nil
+	}
 }
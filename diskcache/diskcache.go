@@ -0,0 +1,196 @@
+//go:build goexperiment.jsonv2
+
+// Package diskcache caches slow-changing ButterflyMX reference data, such as
+// tenants (including their units and buildings) and access points, on disk.
+//
+// Unlike the API client itself, which always hits the network, a disk cache
+// survives restarts, so short-lived CLI invocations and daemons that start
+// often don't have to refetch data that rarely changes.
+package diskcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+// DefaultTTL is the default time after which cached entries are refetched.
+const DefaultTTL = 24 * time.Hour
+
+// Opts holds optional parameters for configuring a [Client].
+type Opts struct {
+	// TTL is how long cached entries are used before being refetched. It
+	// defaults to [DefaultTTL].
+	TTL time.Duration
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+}
+
+// Client is a [butterflymx.Client] that caches the results of
+// [butterflymx.Client.Tenants] and [butterflymx.Client.TenantAccessPoints] on
+// disk. All other methods are passed through to the wrapped client.
+//
+// Calls made with [butterflymx.CallOption]s bypass the cache, since the
+// options may change what is fetched, as do calls for a zero tenant that
+// falls back to the wrapped client's default tenant. Failed fetches are
+// never cached.
+//
+// A Client is safe for concurrent use, and multiple processes may share the
+// same cache directory, since entries are replaced atomically.
+type Client struct {
+	butterflymx.Client
+	dir  string
+	opts Opts
+}
+
+var _ butterflymx.Client = (*Client)(nil)
+
+// New creates a new [Client] wrapping client. Entries are stored in a
+// subdirectory of dir specific to account, which can be any string that
+// identifies the account client is authenticated as, such as its email
+// address. This keeps the data of different accounts apart when they share a
+// cache directory.
+func New(client butterflymx.Client, dir, account string, opts *Opts) *Client {
+	if opts == nil {
+		opts = &Opts{}
+	}
+	o := *opts
+	if o.TTL == 0 {
+		o.TTL = DefaultTTL
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+
+	accountHash := sha256.Sum256([]byte(account))
+	return &Client{
+		Client: client,
+		dir:    filepath.Join(dir, hex.EncodeToString(accountHash[:8])),
+		opts:   o,
+	}
+}
+
+// Clear removes all cached entries of the account.
+func (c *Client) Clear() error {
+	return os.RemoveAll(c.dir)
+}
+
+// Tenants implements [butterflymx.Client].
+func (c *Client) Tenants(ctx context.Context, opts ...butterflymx.CallOption) iter.Seq2[butterflymx.Tenant, error] {
+	if len(opts) > 0 {
+		return c.Client.Tenants(ctx, opts...)
+	}
+	return cachedSeq(c, "tenants.json", func() iter.Seq2[butterflymx.Tenant, error] {
+		return c.Client.Tenants(ctx)
+	})
+}
+
+// TenantAccessPoints implements [butterflymx.Client].
+func (c *Client) TenantAccessPoints(ctx context.Context, tenantID butterflymx.TaggedID, opts ...butterflymx.CallOption) iter.Seq2[butterflymx.AccessPoint, error] {
+	// A zero tenant means the tenant of the context, then the wrapped
+	// client's default tenant, which the cache doesn't know about.
+	if tenantID == (butterflymx.TaggedID{}) {
+		if id, ok := butterflymx.TenantFromContext(ctx); ok {
+			tenantID = butterflymx.TenantTaggedID(id)
+		}
+	}
+	if len(opts) > 0 || tenantID == (butterflymx.TaggedID{}) {
+		return c.Client.TenantAccessPoints(ctx, tenantID, opts...)
+	}
+	name := fmt.Sprintf("access-points-%d.json", tenantID.Number)
+	return cachedSeq(c, name, func() iter.Seq2[butterflymx.AccessPoint, error] {
+		return c.Client.TenantAccessPoints(ctx, tenantID)
+	})
+}
+
+type entry[T any] struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Items     []T       `json:"items"`
+}
+
+// cachedSeq yields the items cached under name if they are fresh. Otherwise,
+// it collects all items from fetch, caches them and then yields them.
+func cachedSeq[T any](c *Client, name string, fetch func() iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		path := filepath.Join(c.dir, name)
+
+		items, ok := readEntry[T](path, c.opts.Now().Add(-c.opts.TTL))
+		if !ok {
+			var err error
+			items, err = butterflymx.CollectResults(fetch())
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			if err := writeEntry(path, entry[T]{FetchedAt: c.opts.Now(), Items: items}); err != nil {
+				var zero T
+				yield(zero, fmt.Errorf("diskcache: %w", err))
+				return
+			}
+		}
+
+		for _, item := range items {
+			if !yield(item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// readEntry reads the entry at path. It returns false if the entry doesn't
+// exist, can't be read or was fetched before notBefore.
+func readEntry[T any](path string, notBefore time.Time) ([]T, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var e entry[T]
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, false
+	}
+	if e.FetchedAt.Before(notBefore) {
+		return nil, false
+	}
+
+	return e.Items, true
+}
+
+// writeEntry atomically replaces the entry at path.
+func writeEntry[T any](path string, e entry[T]) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package diskcache
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+var tenantID = butterflymx.NewTaggedID("tenant", 100)
+
+func newFakeClient() *fakebmx.Client {
+	return fakebmx.New(&bmxtest.Data{
+		Tenants: []bmxtest.Tenant{{
+			Tenant: butterflymx.Tenant{
+				ID:       tenantID,
+				Name:     "Tenant",
+				Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+				Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+			},
+			AccessPoints: []butterflymx.AccessPoint{
+				{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door", OpenDuration: 5, Online: true},
+			},
+		}},
+	})
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	opts := &Opts{TTL: time.Hour, Now: func() time.Time { return now }}
+
+	fake := newFakeClient()
+	want, err := butterflymx.CollectResults(fake.Tenants(t.Context()))
+	assert.NoError(t, err)
+	wantAccessPoints, err := butterflymx.CollectResults(fake.TenantAccessPoints(t.Context(), tenantID))
+	assert.NoError(t, err)
+
+	fetch := func(t *testing.T, fake *fakebmx.Client, account string) {
+		t.Helper()
+		client := New(fake, dir, account, opts)
+
+		got, err := butterflymx.CollectResults(client.Tenants(t.Context()))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		gotAccessPoints, err := butterflymx.CollectResults(client.TenantAccessPoints(t.Context(), tenantID))
+		assert.NoError(t, err)
+		assert.Equal(t, wantAccessPoints, gotAccessPoints)
+	}
+
+	t.Run("cold", func(t *testing.T) {
+		fake := newFakeClient()
+		fetch(t, fake, "alice@example.com")
+		assert.Equal(t, 1, len(fake.CallsTo("Tenants")))
+		assert.Equal(t, 1, len(fake.CallsTo("TenantAccessPoints")))
+	})
+
+	t.Run("warm", func(t *testing.T) {
+		// A new client, like a restarted process, should be served from disk.
+		fake := newFakeClient()
+		fetch(t, fake, "alice@example.com")
+		assert.Equal(t, 0, len(fake.Calls()))
+	})
+
+	t.Run("other account", func(t *testing.T) {
+		fake := newFakeClient()
+		fetch(t, fake, "bob@example.com")
+		assert.Equal(t, 2, len(fake.Calls()))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		fake := newFakeClient()
+		fetch(t, fake, "alice@example.com")
+		assert.Equal(t, 2, len(fake.Calls()))
+	})
+
+	t.Run("call options bypass the cache", func(t *testing.T) {
+		fake := newFakeClient()
+		client := New(fake, dir, "alice@example.com", opts)
+
+		var cursor string
+		_, err := butterflymx.CollectResults(client.Tenants(t.Context(), butterflymx.WithCursor(&cursor)))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(fake.CallsTo("Tenants")))
+	})
+}
+
+func TestClient_fetchError(t *testing.T) {
+	dir := t.TempDir()
+	errFailed := &butterflymx.APIError{StatusCode: http.StatusInternalServerError}
+
+	fake := newFakeClient()
+	fake.SetError("Tenants", errFailed)
+
+	client := New(fake, dir, "alice@example.com", nil)
+	_, err := butterflymx.CollectResults(client.Tenants(t.Context()))
+	assert.True(t, errors.Is(err, errFailed))
+
+	// The failure must not have been cached.
+	fake.SetError("Tenants", nil)
+	got, err := butterflymx.CollectResults(client.Tenants(t.Context()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(got))
+
+	assert.NoError(t, client.Clear())
+	assert.Equal(t, 2, len(fake.CallsTo("Tenants")))
+}
+
+func TestClient_contextTenant(t *testing.T) {
+	otherTenantID := butterflymx.NewTaggedID("tenant", 101)
+	garage := butterflymx.AccessPoint{ID: butterflymx.NewTaggedID("access_point", 401), Name: "Garage", OpenDuration: 5, Online: true}
+
+	fake := newFakeClient()
+	fake.Update(func(d *bmxtest.Data) {
+		other := d.Tenants[0]
+		other.ID = otherTenantID
+		other.AccessPoints = []butterflymx.AccessPoint{garage}
+		d.Tenants = append(d.Tenants, other)
+	})
+	fake.DefaultTenant = tenantID.Number
+
+	client := New(fake, t.TempDir(), "alice@example.com", nil)
+
+	ctx := butterflymx.ContextWithTenant(t.Context(), tenantID.Number)
+	got, err := butterflymx.CollectResults(client.TenantAccessPoints(ctx, butterflymx.TaggedID{}))
+	assert.NoError(t, err)
+	assert.Equal(t, "Front Door", got[0].Name)
+
+	ctx = butterflymx.ContextWithTenant(t.Context(), otherTenantID.Number)
+	got, err = butterflymx.CollectResults(client.TenantAccessPoints(ctx, butterflymx.TaggedID{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []butterflymx.AccessPoint{garage}, got, "each tenant of the context should be cached separately")
+
+	// The default tenant of the wrapped client isn't known, so it is never
+	// cached.
+	for range 2 {
+		got, err = butterflymx.CollectResults(client.TenantAccessPoints(t.Context(), butterflymx.TaggedID{}))
+		assert.NoError(t, err)
+		assert.Equal(t, "Front Door", got[0].Name)
+	}
+	assert.Equal(t, 4, len(fake.CallsTo("TenantAccessPoints")))
+}
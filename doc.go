@@ -1,2 +1,12 @@
 // Package butterflymx provides a Go client for the ButterflyMX API.
+//
+// # Compatibility
+//
+// Breaking changes to the API of this package are avoided where possible.
+// When a name or signature has to change, the old one is kept as a type alias
+// or wrapper marked "Deprecated:" for at least one minor release, so that
+// users can migrate gradually instead of all at once. Changes that can't be
+// expressed this way, such as changing how [ResultsWithReferences.Refs] is
+// keyed, are collected for a future v2 module at libdb.so/go-butterflymx/v2,
+// rather than being made in place.
 package butterflymx
@@ -0,0 +1,115 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"fmt"
+	"time"
+)
+
+// ResolvedDoorRelease is a flat view of a [DoorRelease] with its panel,
+// building, unit and user already resolved. See [DoorRelease.Resolved].
+type ResolvedDoorRelease struct {
+	ID              ID
+	ReleaseMethod   string
+	DoorReleaseType string
+	LoggedAt        time.Time
+
+	PanelID   ID
+	PanelName string
+
+	BuildingID   ID
+	BuildingName string
+
+	UnitID    ID
+	UnitLabel string
+
+	UserID ID
+	// UserName is the name of the user who released the door. If the user
+	// isn't included, it is the account name recorded on the door release.
+	UserName string
+}
+
+// Resolved resolves the relationships of the door release using refs, which
+// is usually the Refs of the result that the door release was resolved from.
+//
+// Related objects that weren't included in the response only have their IDs
+// filled in, since the API doesn't always include buildings, units and users.
+// An error is only returned if an included object can't be decoded.
+func (r *DoorRelease) Resolved(refs map[ID]RawReference) (ResolvedDoorRelease, error) {
+	resolved := ResolvedDoorRelease{
+		ID:              r.ID,
+		ReleaseMethod:   r.Attributes.ReleaseMethod,
+		DoorReleaseType: r.Attributes.DoorReleaseType,
+		LoggedAt:        r.Attributes.LoggedAt,
+		UserName:        r.Attributes.Name,
+	}
+
+	panelRef := r.Relationships.Panel.Data
+	if panelRef == nil {
+		panelRef = r.Relationships.Device.Data
+	}
+	if panelRef != nil {
+		resolved.PanelID = panelRef.ID
+		if _, ok := refs[panelRef.ID]; ok {
+			panel, err := panelRef.Resolve(refs)
+			if err != nil {
+				return resolved, fmt.Errorf("door release %d: failed to resolve panel: %w", r.ID, err)
+			}
+			resolved.PanelName = panel.Attributes.Name
+
+			if buildingRef := panel.Relationships.Building.Data; buildingRef != nil {
+				resolved.BuildingID = buildingRef.ID
+				attrs, err := resolveNamedAttributes(refs, buildingRef)
+				if err != nil {
+					return resolved, fmt.Errorf("door release %d: failed to resolve building: %w", r.ID, err)
+				}
+				resolved.BuildingName = attrs.Name
+			}
+		}
+	}
+
+	if unitRef := r.Relationships.Unit.Data; unitRef != nil {
+		resolved.UnitID = unitRef.ID
+		attrs, err := resolveNamedAttributes(refs, unitRef)
+		if err != nil {
+			return resolved, fmt.Errorf("door release %d: failed to resolve unit: %w", r.ID, err)
+		}
+		resolved.UnitLabel = attrs.Label
+	}
+
+	if userRef := r.Relationships.User.Data; userRef != nil {
+		resolved.UserID = userRef.ID
+		attrs, err := resolveNamedAttributes(refs, userRef)
+		if err != nil {
+			return resolved, fmt.Errorf("door release %d: failed to resolve user: %w", r.ID, err)
+		}
+		if attrs.Name != "" {
+			resolved.UserName = attrs.Name
+		}
+	}
+
+	return resolved, nil
+}
+
+// namedAttributes holds the human-readable attributes shared by the objects
+// that don't have their own types yet, such as buildings, units and users.
+type namedAttributes struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+}
+
+// resolveNamedAttributes returns the attributes of the object referenced by
+// ref, or zero attributes if the object isn't in refs.
+func resolveNamedAttributes(refs map[ID]RawReference, ref *RawReference) (namedAttributes, error) {
+	if included, ok := refs[ref.ID]; !ok || included.Type != ref.Type {
+		return namedAttributes{}, nil
+	}
+	object, err := (*TypedReference[struct {
+		Attributes namedAttributes `json:"attributes"`
+	}])(ref).Resolve(refs)
+	if err != nil {
+		return namedAttributes{}, err
+	}
+	return object.Attributes, nil
+}
@@ -0,0 +1,63 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestDoorRelease_Resolved(t *testing.T) {
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{Response: httpmock.RoundTripResponse{Status: http.StatusOK, Body: accessCodesResponse}},
+	})
+
+	results, err := newTestAPIClient(t, mockrt).Keychains(t.Context(), 10001, ActiveAccessCode)
+	assert.NoError(t, err)
+
+	virtualKey, err := results.Data[0].Relationships.VirtualKeys[0].Resolve(results.Refs)
+	assert.NoError(t, err)
+	doorRelease, err := virtualKey.Relationships.DoorReleases[0].Resolve(results.Refs)
+	assert.NoError(t, err)
+
+	t.Run("partially included", func(t *testing.T) {
+		resolved, err := doorRelease.Resolved(results.Refs)
+		assert.NoError(t, err)
+		assert.Equal(t, ResolvedDoorRelease{
+			ID:              30001,
+			ReleaseMethod:   "virtual_key_pin",
+			DoorReleaseType: "visitor",
+			LoggedAt:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			PanelID:         10003,
+			PanelName:       "Hunter Capital Front Door",
+			BuildingID:      40003,
+			UnitID:          40001,
+			UserID:          40002,
+			UserName:        "Jane Doe",
+		}, resolved)
+	})
+
+	t.Run("fully included", func(t *testing.T) {
+		refs := map[ID]RawReference{
+			40001: {ID: 40001, Type: "units", Data: []byte(`{"attributes":{"label":"Apt 1"}}`)},
+			40002: {ID: 40002, Type: "users", Data: []byte(`{"attributes":{"name":"Jane Q. Doe"}}`)},
+			40003: {ID: 40003, Type: TypeBuilding, Data: []byte(`{"attributes":{"name":"Hunter Capital"}}`)},
+		}
+		for id, ref := range results.Refs {
+			if _, ok := refs[id]; !ok {
+				refs[id] = ref
+			}
+		}
+
+		resolved, err := doorRelease.Resolved(refs)
+		assert.NoError(t, err)
+		assert.Equal(t, "Hunter Capital Front Door", resolved.PanelName)
+		assert.Equal(t, "Hunter Capital", resolved.BuildingName)
+		assert.Equal(t, "Apt 1", resolved.UnitLabel)
+		assert.Equal(t, "Jane Q. Doe", resolved.UserName)
+	})
+}
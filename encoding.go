@@ -0,0 +1,90 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"strconv"
+	"time"
+)
+
+// RailsTimeLayout is the layout of timestamps sent to the Rails REST API at
+// [APIBaseURL]. The API rejects RFC 3339 timestamps with a colon in the zone
+// offset.
+const RailsTimeLayout = "2006-01-02T15:04:05-0700"
+
+// encodingProfile holds the JSON options for the request and response bodies
+// of one family of endpoints. Each family has its own conventions, so types
+// used in request bodies should not hardcode formats in their struct tags;
+// the profile of the endpoint takes care of that instead.
+type encodingProfile struct {
+	// Marshalers are the custom marshalers used to marshal request bodies.
+	// It may be nil.
+	Marshalers *json.Marshalers
+	// IDs is how [ID]s are encoded in request bodies. Endpoints aren't
+	// consistent about this even within a family, so wrappers of new
+	// endpoints should check a recorded request and use
+	// [encodingProfile.withIDs] if needed.
+	IDs idEncoding
+	// Unmarshal is used to unmarshal response bodies.
+	Unmarshal json.Options
+}
+
+// idEncoding is how [ID]s are encoded in request bodies.
+type idEncoding int
+
+const (
+	// idAsString encodes IDs as JSON strings, e.g. "10001", like
+	// [ID.MarshalJSON] does.
+	idAsString idEncoding = iota
+	// idAsNumber encodes IDs as JSON numbers, e.g. 10001.
+	idAsNumber
+)
+
+// numericIDMarshalers marshals [ID]s as JSON numbers, overriding
+// [ID.MarshalJSON].
+var numericIDMarshalers = json.MarshalFunc(func(id ID) ([]byte, error) {
+	return strconv.AppendInt(nil, int64(id), 10), nil
+})
+
+// withIDs returns a copy of the profile that encodes IDs using ids.
+func (p encodingProfile) withIDs(ids idEncoding) encodingProfile {
+	p.IDs = ids
+	return p
+}
+
+// marshalOptions returns the options used to marshal request bodies.
+func (p encodingProfile) marshalOptions() json.Options {
+	var marshalers []*json.Marshalers
+	if p.IDs == idAsNumber {
+		marshalers = append(marshalers, numericIDMarshalers)
+	}
+	if p.Marshalers != nil {
+		marshalers = append(marshalers, p.Marshalers)
+	}
+	if len(marshalers) == 0 {
+		return json.JoinOptions()
+	}
+	return json.WithMarshalers(json.JoinMarshalers(marshalers...))
+}
+
+var (
+	// railsProfile is for the Rails REST API at [APIBaseURL], which uses
+	// JSON:API documents with snake_case members and string IDs. Timestamps
+	// are sent in [RailsTimeLayout] and received as RFC 3339.
+	railsProfile = encodingProfile{
+		Marshalers: json.MarshalFunc(func(t time.Time) ([]byte, error) {
+			return json.Marshal(t.Format(RailsTimeLayout))
+		}),
+	}
+
+	// denizenProfile is for the Denizen GraphQL API at
+	// [DenizenGraphQLEndpoint], which uses camelCase members and RFC 3339
+	// timestamps. IDs are sent as [TaggedID]s.
+	denizenProfile = encodingProfile{}
+
+	// unlockProfile is for the Unlock API at [UnlockAPIBaseURL], which uses
+	// camelCase members and RFC 3339 timestamps. IDs are sent as
+	// [TaggedID]s.
+	unlockProfile = encodingProfile{}
+)
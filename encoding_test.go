@@ -0,0 +1,49 @@
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEncodingProfile_IDs(t *testing.T) {
+	body := map[string]any{
+		"id":        ID(10001),
+		"ids":       []ID{50001, 50002},
+		"tenant":    RawReference{ID: 10001, Type: "tenants"},
+		"tenant_id": TenantTaggedID(10001),
+		"starts_at": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name    string
+		profile encodingProfile
+		want    string
+	}{
+		{
+			name:    "rails",
+			profile: railsProfile,
+			want:    `{"id":"10001","ids":["50001","50002"],"starts_at":"2023-01-01T00:00:00+0000","tenant":{"id":"10001","type":"tenants"},"tenant_id":"prod-tenant-10001"}`,
+		},
+		{
+			name:    "rails with numeric IDs",
+			profile: railsProfile.withIDs(idAsNumber),
+			want:    `{"id":10001,"ids":[50001,50002],"starts_at":"2023-01-01T00:00:00+0000","tenant":{"id":10001,"type":"tenants"},"tenant_id":"prod-tenant-10001"}`,
+		},
+		{
+			name:    "denizen",
+			profile: denizenProfile,
+			want:    `{"id":"10001","ids":["50001","50002"],"starts_at":"2023-01-01T00:00:00Z","tenant":{"id":"10001","type":"tenants"},"tenant_id":"prod-tenant-10001"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := json.Marshal(body, test.profile.marshalOptions(), json.Deterministic(true))
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, string(b))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"fmt"
+	"iter"
+)
+
+// DoorReleaseEvent is a door release with every related entity resolved into
+// a human-readable name, ready to be passed on to sinks such as MQTT or Home
+// Assistant. Use an [Enricher] to create them.
+type DoorReleaseEvent struct {
+	ResolvedDoorRelease
+	KeychainID     ID
+	KeychainName   string
+	VirtualKeyID   ID
+	VirtualKeyName string
+}
+
+// Enricher turns door releases into [DoorReleaseEvent]s. Names that the
+// keychain responses don't include, such as those of buildings, are filled in
+// from Topology.
+//
+// To avoid fetching the topology from the API every time, build it using a
+// caching [Client], such as the one from package diskcache.
+type Enricher struct {
+	// Topology is used to fill in names missing from responses. It may be
+	// nil.
+	Topology *Topology
+}
+
+// DoorReleaseEvents yields an event for every door release of every virtual
+// key of the given keychains, in the order they appear.
+func (e *Enricher) DoorReleaseEvents(keychains *ResultsWithReferences[Keychain]) iter.Seq2[DoorReleaseEvent, error] {
+	return func(yield func(DoorReleaseEvent, error) bool) {
+		for _, keychain := range keychains.Data {
+			for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(keychains.Refs) {
+				if err != nil {
+					yield(DoorReleaseEvent{}, fmt.Errorf("keychain %d: %w", keychain.ID, err))
+					return
+				}
+				for release, err := range virtualKey.Relationships.DoorReleases.Resolve(keychains.Refs) {
+					if err != nil {
+						yield(DoorReleaseEvent{}, fmt.Errorf("virtual key %d: %w", virtualKey.ID, err))
+						return
+					}
+
+					event, err := e.DoorReleaseEvent(release, keychains.Refs)
+					if err != nil {
+						yield(DoorReleaseEvent{}, err)
+						return
+					}
+					event.KeychainID = keychain.ID
+					event.KeychainName = keychain.Attributes.Name
+					event.VirtualKeyID = virtualKey.ID
+					event.VirtualKeyName = virtualKey.Attributes.Name
+
+					if !yield(event, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// DoorReleaseEvent enriches a single door release. The keychain and virtual
+// key of the event are left empty, since a door release doesn't reference
+// them.
+func (e *Enricher) DoorReleaseEvent(release *DoorRelease, refs map[ID]RawReference) (DoorReleaseEvent, error) {
+	resolved, err := release.Resolved(refs)
+	if err != nil {
+		return DoorReleaseEvent{}, err
+	}
+
+	if e.Topology != nil {
+		if resolved.PanelName == "" {
+			if panel, ok := e.Topology.Panel(resolved.PanelID); ok {
+				resolved.PanelName = panel.Name
+				resolved.BuildingID = panel.BuildingID
+			}
+		}
+		if resolved.BuildingName == "" {
+			if building, ok := e.Topology.Building(resolved.BuildingID); ok {
+				resolved.BuildingName = building.Building.Name
+			}
+		}
+	}
+
+	return DoorReleaseEvent{ResolvedDoorRelease: resolved}, nil
+}
@@ -0,0 +1,43 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestEnricher_DoorReleaseEvents(t *testing.T) {
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{Response: httpmock.RoundTripResponse{Status: http.StatusOK, Body: accessCodesResponse}},
+	})
+
+	results, err := newTestAPIClient(t, mockrt).Keychains(t.Context(), 10001, ActiveAccessCode)
+	assert.NoError(t, err)
+
+	// The access codes response doesn't include buildings, so their names
+	// come from the topology.
+	topo := NewTopology()
+	topo.AddTenant(Tenant{
+		ID:       TenantTaggedID(10001),
+		Building: Building{ID: BuildingTaggedID(40003), Name: "Hunter Capital"},
+	}, nil)
+	assert.NoError(t, topo.AddKeychains(results))
+
+	enricher := Enricher{Topology: topo}
+	events, err := CollectResults(enricher.DoorReleaseEvents(results))
+	assert.NoError(t, err)
+	assert.Equal(t, 22, len(events))
+
+	event := events[0]
+	assert.Equal(t, ID(30001), event.ID)
+	assert.Equal(t, ID(20001), event.KeychainID)
+	assert.Equal(t, results.Data[0].Attributes.Name, event.KeychainName)
+	assert.Equal(t, "user+delivery@example.com", event.VirtualKeyName)
+	assert.Equal(t, "Hunter Capital Front Door", event.PanelName)
+	assert.Equal(t, "Hunter Capital", event.BuildingName)
+	assert.Equal(t, "Jane Doe", event.UserName)
+}
@@ -0,0 +1,129 @@
+//go:build goexperiment.jsonv2
+
+// Package errfmt renders errors returned by package butterflymx into
+// human-friendly messages for CLIs and daemon logs. It digs the typed errors,
+// such as [*butterflymx.APIError] and [*butterflymx.AuthError], out of the
+// error chain and adds the details that matter when debugging, along with a
+// hint on how to recover.
+package errfmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+// LoginHint is the hint given when the user has to log in again.
+var LoginHint = "log in again, e.g. using bmx-auth, and update the stored token"
+
+// Details are the details of an error that are useful to a human.
+type Details struct {
+	// Status is the HTTP status code of the failed response, or 0.
+	Status int
+	// RequestID is the request ID assigned by the server, if any.
+	RequestID string
+	// RetryAfter is how long the server asked to wait before retrying, or 0.
+	RetryAfter time.Duration
+	// Hint suggests how to recover from the error, if known.
+	Hint string
+}
+
+// DetailsOf returns the details of err.
+func DetailsOf(err error) Details {
+	var d Details
+
+	var apiErr *butterflymx.APIError
+	if errors.As(err, &apiErr) {
+		d.Status = apiErr.StatusCode
+		d.RequestID = apiErr.Header.Get("X-Request-Id")
+		d.RetryAfter = apiErr.RetryAfter()
+	}
+
+	var authErr *butterflymx.AuthError
+	var maintenanceErr *butterflymx.MaintenanceError
+	switch {
+	case errors.As(err, &authErr):
+		switch authErr.Failure {
+		case butterflymx.AuthFailureAccountDisabled:
+			d.Hint = "the account can't log in; contact the building's property manager"
+		case butterflymx.AuthFailureAPITokenExpired:
+			d.Hint = "the API token has expired; renew it, e.g. by using a refresh token instead of a static API token, or " + LoginHint
+		case butterflymx.AuthFailureAPITokenInvalid:
+			d.Hint = "the API token was revoked or is malformed; " + LoginHint
+		default:
+			d.Hint = "the login has expired; " + LoginHint
+		}
+	case errors.As(err, &maintenanceErr):
+		d.RetryAfter = maintenanceErr.RetryAfter
+		d.Hint = "ButterflyMX is down for maintenance; try again later"
+	case errors.Is(err, butterflymx.ErrForbidden):
+		d.Hint = "the account isn't allowed to do this; check its access with the property manager"
+	case d.Status == http.StatusTooManyRequests:
+		d.Hint = "too many requests; slow down and try again later"
+	case d.Status >= 500:
+		d.Hint = "ButterflyMX is having problems; try again later"
+	case errors.Is(err, butterflymx.ErrPaginationLoop), errors.Is(err, butterflymx.ErrTooManyPages):
+		d.Hint = "the server kept returning more pages; this is likely a server bug"
+	case errors.Is(err, context.DeadlineExceeded):
+		d.Hint = "the request timed out; check the network connection"
+	}
+
+	return d
+}
+
+// Format renders err as a message of one or more lines: the error itself,
+// followed by its details, if any, each indented on its own line.
+func Format(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+
+	d := DetailsOf(err)
+	if d.Status != 0 {
+		fmt.Fprintf(&b, "\n  status: %d %s", d.Status, http.StatusText(d.Status))
+	}
+	if d.RequestID != "" {
+		fmt.Fprintf(&b, "\n  request ID: %s", d.RequestID)
+	}
+	if d.RetryAfter > 0 {
+		fmt.Fprintf(&b, "\n  retry after: %v", d.RetryAfter)
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(&b, "\n  hint: %s", d.Hint)
+	}
+	return b.String()
+}
+
+// Attr returns err as a [slog.Attr] with the given key, grouping the error
+// message with its details, for daemons that log using package slog. A nil
+// err returns an empty Attr, which slog handlers ignore.
+func Attr(key string, err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+
+	d := DetailsOf(err)
+	attrs := []any{slog.String("msg", err.Error())}
+	if d.Status != 0 {
+		attrs = append(attrs, slog.Int("status", d.Status))
+	}
+	if d.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", d.RequestID))
+	}
+	if d.RetryAfter > 0 {
+		attrs = append(attrs, slog.Duration("retry_after", d.RetryAfter))
+	}
+	if d.Hint != "" {
+		attrs = append(attrs, slog.String("hint", d.Hint))
+	}
+	return slog.Group(key, attrs...)
+}
@@ -0,0 +1,95 @@
+package errfmt
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+)
+
+func TestFormat(t *testing.T) {
+	rateLimited := &butterflymx.APIError{
+		StatusCode: http.StatusTooManyRequests,
+		Header: http.Header{
+			"X-Request-Id": {"req-123"},
+			"Retry-After":  {"30"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "nil",
+			err:  nil,
+			want: "",
+		},
+		{
+			name: "plain",
+			err:  errors.New("boom"),
+			want: "boom",
+		},
+		{
+			name: "rate limited",
+			err:  fmt.Errorf("failed to fetch tenants: %w", rateLimited),
+			want: "failed to fetch tenants: status 429\n" +
+				"  status: 429 Too Many Requests\n" +
+				"  request ID: req-123\n" +
+				"  retry after: 30s\n" +
+				"  hint: too many requests; slow down and try again later",
+		},
+		{
+			name: "login expired",
+			err: &butterflymx.AuthError{
+				Failure: butterflymx.AuthFailureRefreshTokenExpired,
+				Err:     &butterflymx.APIError{StatusCode: http.StatusUnauthorized, Header: http.Header{}},
+			},
+			want: "OAuth2 refresh token expired: status 401\n" +
+				"  status: 401 Unauthorized\n" +
+				"  hint: the login has expired; " + LoginHint,
+		},
+		{
+			name: "API token expired",
+			err: &butterflymx.AuthError{
+				Failure: butterflymx.AuthFailureAPITokenExpired,
+				Err:     &butterflymx.APIError{StatusCode: http.StatusUnauthorized, Header: http.Header{}},
+			},
+			want: "API token expired: status 401\n" +
+				"  status: 401 Unauthorized\n" +
+				"  hint: the API token has expired; renew it, e.g. by using a refresh token instead of a static API token, or " + LoginHint,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Format(test.err))
+		})
+	}
+}
+
+func TestDetailsOf(t *testing.T) {
+	d := DetailsOf(&butterflymx.MaintenanceError{
+		RetryAfter: time.Minute,
+		Err:        &butterflymx.APIError{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}},
+	})
+	assert.Equal(t, Details{
+		Status:     http.StatusServiceUnavailable,
+		RetryAfter: time.Minute,
+		Hint:       "ButterflyMX is down for maintenance; try again later",
+	}, d)
+}
+
+func TestAttr(t *testing.T) {
+	assert.True(t, Attr("err", nil).Equal(slog.Attr{}))
+
+	attr := Attr("err", errors.New("boom"))
+	assert.Equal(t, "err", attr.Key)
+	assert.Equal(t, "[msg=boom]", attr.Value.String())
+}
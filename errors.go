@@ -0,0 +1,280 @@
+package butterflymx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is returned when the API responds with an unsuccessful status
+// code. Use [errors.As] to retrieve it from errors returned by [APIClient].
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Header is the header of the response.
+	Header http.Header
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+// RetryAfter returns the delay requested by the server using the Retry-After
+// header. It returns 0 if the header is missing or invalid.
+func (e *APIError) RetryAfter() time.Duration {
+	v := e.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// ErrMaintenance is matched by errors returned while the ButterflyMX API is
+// down for maintenance. Use [errors.Is] to check for it, and [errors.As] with
+// a [*MaintenanceError] to find out when to try again.
+var ErrMaintenance = errors.New("butterflymx: API is under maintenance")
+
+// MaintenanceError is returned when the API responds with a maintenance page
+// instead of a regular response. Such responses are not retried, since
+// maintenance usually outlasts the retry policy.
+type MaintenanceError struct {
+	// RetryAfter is how long the server asked to wait before trying again,
+	// or 0 if it didn't say.
+	RetryAfter time.Duration
+	// Err is the [*APIError] of the response.
+	Err *APIError
+}
+
+// Error implements the error interface.
+func (e *MaintenanceError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v (%v, retry after %v)", ErrMaintenance, e.Err, e.RetryAfter)
+	}
+	return fmt.Sprintf("%v (%v)", ErrMaintenance, e.Err)
+}
+
+// Is reports whether target is [ErrMaintenance].
+func (e *MaintenanceError) Is(target error) bool {
+	return target == ErrMaintenance
+}
+
+// Unwrap returns the [*APIError] of the response.
+func (e *MaintenanceError) Unwrap() error {
+	return e.Err
+}
+
+// AuthFailure classifies why authentication failed. See [AuthError].
+type AuthFailure int
+
+const (
+	// AuthFailureUnknown is an authentication failure that couldn't be
+	// classified further.
+	AuthFailureUnknown AuthFailure = iota
+	// AuthFailureRefreshTokenExpired means that the OAuth2 refresh token, or
+	// the OAuth2 access token obtained from it, was rejected because it
+	// expired or was revoked. The user has to log in again.
+	AuthFailureRefreshTokenExpired
+	// AuthFailureAPITokenExpired means that the API (Rails) token was
+	// rejected, even after asking the [APITokenSource] to renew it. This is
+	// usually because the token source can't renew tokens, such as an
+	// [APIStaticToken]. Obtaining a new API token, e.g. through
+	// [DenizenLoginClient], is enough to recover.
+	AuthFailureAPITokenExpired
+	// AuthFailureAccountDisabled means that the account was disabled or
+	// locked by ButterflyMX or the property manager. Logging in again won't
+	// help until the account is reactivated.
+	AuthFailureAccountDisabled
+	// AuthFailureAPITokenInvalid means that the API token was rejected even
+	// after renewing it, although it hasn't expired according to its own
+	// expiry. The token was likely revoked or is malformed, so the user has
+	// to log in again.
+	AuthFailureAPITokenInvalid
+)
+
+// Sentinel authentication errors, matched using [errors.Is] by errors from
+// [APIClient] and the API token sources in this package. They tell errors
+// that a new login fixes apart from errors that it doesn't. Use [errors.As]
+// with an [*AuthError] for the details.
+var (
+	// ErrTokenExpired is matched by an [*AuthError] whose OAuth2 or API token
+	// expired.
+	ErrTokenExpired = errors.New("butterflymx: token expired")
+	// ErrTokenInvalid is matched by an [*AuthError] whose token was rejected
+	// for a reason other than expiry.
+	ErrTokenInvalid = errors.New("butterflymx: token invalid")
+	// ErrForbidden is matched by errors of requests that were denied although
+	// the token was accepted, such as unlocking a door the tenant has no
+	// access to, and by an [*AuthError] whose account is disabled.
+	ErrForbidden = errors.New("butterflymx: forbidden")
+)
+
+// String returns a human-readable description of the failure.
+func (f AuthFailure) String() string {
+	switch f {
+	case AuthFailureRefreshTokenExpired:
+		return "OAuth2 refresh token expired"
+	case AuthFailureAPITokenExpired:
+		return "API token expired"
+	case AuthFailureAccountDisabled:
+		return "account disabled"
+	case AuthFailureAPITokenInvalid:
+		return "API token invalid"
+	default:
+		return "authentication failed"
+	}
+}
+
+// AuthError is returned when authentication fails, either while obtaining an
+// API token or when the API rejects one. Use [errors.As] to retrieve it from
+// errors returned by [APIClient] and [DenizenLoginClient] and check Failure to
+// decide whether to refresh silently or prompt the user to log in again.
+type AuthError struct {
+	// Failure is why authentication failed.
+	Failure AuthFailure
+	// Err is the underlying error, such as an [*APIError] or an
+	// *oauth2.RetrieveError. It may be nil.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *AuthError) Error() string {
+	if e.Err != nil {
+		return e.Failure.String() + ": " + e.Err.Error()
+	}
+	return e.Failure.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error matching the failure:
+// [ErrTokenExpired], [ErrTokenInvalid] or [ErrForbidden].
+func (e *AuthError) Is(target error) bool {
+	switch e.Failure {
+	case AuthFailureRefreshTokenExpired, AuthFailureAPITokenExpired:
+		return target == ErrTokenExpired
+	case AuthFailureAccountDisabled:
+		return target == ErrForbidden
+	default:
+		return target == ErrTokenInvalid
+	}
+}
+
+// NeedsLogin reports whether the user has to log in again, or be told that
+// they can't, to recover from the failure.
+func (e *AuthError) NeedsLogin() bool {
+	return e.Failure != AuthFailureAPITokenExpired
+}
+
+// ErrPaginationLoop is wrapped by the [*PaginationError] of a paginated call
+// if the server returns the same cursor or next page link twice, which would
+// otherwise make the call fetch the same pages forever.
+var ErrPaginationLoop = errors.New("butterflymx: pagination loop detected")
+
+// ErrTooManyPages is wrapped by the [*PaginationError] of a paginated call if
+// it needs more than [APIClientOpts.MaxPages] pages.
+var ErrTooManyPages = errors.New("butterflymx: too many pages")
+
+// PaginationError is returned when fetching a page fails partway through a
+// paginated call. It wraps the error of the failing page.
+type PaginationError struct {
+	// Page is the 1-based number of the page that failed.
+	Page int
+	// Cursor is the GraphQL cursor that the page was requested after. It is
+	// empty for the first page and for REST endpoints, which paginate using
+	// page numbers instead.
+	Cursor string
+	// Fetched is the number of items received before the page failed.
+	Fetched int
+	// Err is the error of the failing page.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PaginationError) Error() string {
+	return fmt.Sprintf("page %d (after %d items): %v", e.Page, e.Fetched, e.Err)
+}
+
+// Unwrap returns the error of the failing page.
+func (e *PaginationError) Unwrap() error {
+	return e.Err
+}
+
+// BatchChunkError is returned for each chunk of a batched GraphQL call that
+// failed, such as in [APIClient.AccessPointsOfTenants]. The errors of all
+// failed chunks are joined; the operations of other chunks succeeded.
+type BatchChunkError struct {
+	// Start and End are the indices of the first and one past the last
+	// operation in the chunk, which correspond to the indices of the inputs
+	// of the call, e.g. the tenant IDs.
+	Start, End int
+	// Err is the error of the chunk.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *BatchChunkError) Error() string {
+	if e.End-e.Start == 1 {
+		return fmt.Sprintf("operation %d: %v", e.Start, e.Err)
+	}
+	return fmt.Sprintf("operations %d to %d: %v", e.Start, e.End-1, e.Err)
+}
+
+// Unwrap returns the error of the chunk.
+func (e *BatchChunkError) Unwrap() error {
+	return e.Err
+}
+
+// InvariantError is returned when the client runs into a state that should be
+// impossible, such as a response that contradicts itself. It indicates a bug
+// in this package or an unexpected change in the API rather than a problem
+// with the caller's request.
+type InvariantError struct {
+	Msg string
+}
+
+// Error implements the error interface.
+func (e *InvariantError) Error() string {
+	return "butterflymx: invariant violated: " + e.Msg
+}
+
+// PartialKeychainError is returned by [APIClient.ImportKeychains] if a
+// keychain was created but its virtual keys couldn't be. The keychain is
+// listed in [KeychainImportResult.Created] and has to be cleaned up or
+// completed by the caller.
+type PartialKeychainError struct {
+	// KeychainID is the ID of the keychain that was created.
+	KeychainID ID
+	// Name is the name of the keychain.
+	Name string
+	// Err is the error of creating the virtual keys.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PartialKeychainError) Error() string {
+	return fmt.Sprintf("keychain %q (%v) was created, but its virtual keys weren't: %v", e.Name, e.KeychainID, e.Err)
+}
+
+// Unwrap returns the error of creating the virtual keys.
+func (e *PartialKeychainError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,188 @@
+//go:build goexperiment.jsonv2
+
+package fakebmx
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"time"
+
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+)
+
+func findTenant(d *bmxtest.Data, id butterflymx.ID) *bmxtest.Tenant {
+	for i := range d.Tenants {
+		if d.Tenants[i].ID.Number == id {
+			return &d.Tenants[i]
+		}
+	}
+	return nil
+}
+
+func findKeychain(d *bmxtest.Data, id butterflymx.ID) (*bmxtest.Tenant, *bmxtest.Keychain) {
+	for i := range d.Tenants {
+		tenant := &d.Tenants[i]
+		for j := range tenant.Keychains {
+			if tenant.Keychains[j].ID == id {
+				return tenant, &tenant.Keychains[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func findAccessPoint(t *bmxtest.Tenant, id butterflymx.ID) *butterflymx.AccessPoint {
+	for i := range t.AccessPoints {
+		if t.AccessPoints[i].ID.Number == id {
+			return &t.AccessPoints[i]
+		}
+	}
+	return nil
+}
+
+// maxID returns the largest numeric ID used anywhere in the data.
+func maxID(d *bmxtest.Data) butterflymx.ID {
+	var maxID butterflymx.ID
+	for _, tenant := range d.Tenants {
+		maxID = max(maxID, tenant.ID.Number, tenant.Unit.ID.Number, tenant.Building.ID.Number)
+		for _, ap := range tenant.AccessPoints {
+			maxID = max(maxID, ap.ID.Number)
+		}
+		for _, keychain := range tenant.Keychains {
+			maxID = max(maxID, keychain.ID)
+			for _, vk := range keychain.VirtualKeys {
+				maxID = max(maxID, vk.ID)
+				for _, release := range vk.DoorReleases {
+					maxID = max(maxID, release.ID)
+				}
+			}
+		}
+	}
+	return maxID
+}
+
+func typedRef[T any](typ butterflymx.ObjectType, id butterflymx.ID) *butterflymx.TypedReference[T] {
+	return &butterflymx.TypedReference[T]{ID: id, Type: typ}
+}
+
+// addRef adds v to refs the same way the API client does for included
+// objects, so that references to it can be resolved.
+func addRef(refs map[butterflymx.ID]butterflymx.RawReference, typ butterflymx.ObjectType, id butterflymx.ID, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("fakebmx: failed to marshal %s %v: %v", typ, id, err))
+	}
+
+	var ref butterflymx.RawReference
+	if err := json.Unmarshal(b, &ref); err != nil {
+		panic(fmt.Sprintf("fakebmx: failed to unmarshal %s %v: %v", typ, id, err))
+	}
+	ref.Type = typ
+
+	refs[id] = ref
+}
+
+// buildKeychain converts k into a [butterflymx.Keychain] and adds all of its
+// related objects to refs. Devices are only added if withDevices is true.
+func buildKeychain(tenant *bmxtest.Tenant, k *bmxtest.Keychain, refs map[butterflymx.ID]butterflymx.RawReference, withDevices bool) butterflymx.Keychain {
+	var keychain butterflymx.Keychain
+	keychain.ID = k.ID
+	keychain.Attributes.Name = k.Name
+	keychain.Attributes.Kind = k.Kind
+	keychain.Attributes.StartsAt = k.StartsAt.UTC()
+	keychain.Attributes.EndsAt = k.EndsAt.UTC()
+	keychain.Attributes.TimeFrom = timestamp(k.StartsAt.UTC())
+	keychain.Attributes.TimeTo = timestamp(k.EndsAt.UTC())
+	keychain.Attributes.StartDate = datestamp(k.StartsAt.UTC())
+	keychain.Attributes.EndDate = datestamp(k.EndsAt.UTC())
+	keychain.Attributes.Weekdays = []butterflymx.Weekday{}
+	keychain.Attributes.AllowUnitAccess = k.AllowUnitAccess
+
+	keychain.Relationships.VirtualKeys = butterflymx.ReferenceList[butterflymx.VirtualKey]{}
+	for i := range k.VirtualKeys {
+		vk := buildVirtualKey(tenant, &k.VirtualKeys[i], refs)
+		addRef(refs, butterflymx.TypeVirtualKey, vk.ID, vk)
+		keychain.Relationships.VirtualKeys = append(keychain.Relationships.VirtualKeys,
+			typedRef[butterflymx.VirtualKey](butterflymx.TypeVirtualKey, vk.ID))
+	}
+
+	keychain.Relationships.Devices = butterflymx.ReferenceList[butterflymx.Panel]{}
+	for _, apID := range k.AccessPointIDs {
+		keychain.Relationships.Devices = append(keychain.Relationships.Devices,
+			typedRef[butterflymx.Panel](butterflymx.TypePanel, apID))
+		if withDevices {
+			addPanel(tenant, apID, refs)
+		}
+	}
+
+	return keychain
+}
+
+// buildVirtualKey converts vk into a [butterflymx.VirtualKey]. If refs is not
+// nil, its door releases and their panels are added to refs.
+func buildVirtualKey(tenant *bmxtest.Tenant, vk *bmxtest.VirtualKey, refs map[butterflymx.ID]butterflymx.RawReference) butterflymx.VirtualKey {
+	var virtualKey butterflymx.VirtualKey
+	virtualKey.ID = vk.ID
+	virtualKey.Attributes.Name = vk.Name
+	virtualKey.Attributes.Email = vk.Email
+	virtualKey.Attributes.PINCode = vk.PINCode
+	virtualKey.Attributes.QRCodeImageURL = fmt.Sprintf("https://fakebmx.invalid/qr_codes/%d.png", int(vk.ID))
+	virtualKey.Attributes.InstructionsURL = fmt.Sprintf("https://fakebmx.invalid/instructions/%d", int(vk.ID))
+	virtualKey.Attributes.SentAt = vk.SentAt.UTC()
+
+	virtualKey.Relationships.DoorReleases = butterflymx.ReferenceList[butterflymx.DoorRelease]{}
+	for i := range vk.DoorReleases {
+		release := buildDoorRelease(&vk.DoorReleases[i])
+		virtualKey.Relationships.DoorReleases = append(virtualKey.Relationships.DoorReleases,
+			typedRef[butterflymx.DoorRelease](butterflymx.TypeDoorRelease, release.ID))
+		if refs != nil {
+			addRef(refs, butterflymx.TypeDoorRelease, release.ID, release)
+			addPanel(tenant, vk.DoorReleases[i].AccessPointID, refs)
+		}
+	}
+
+	return virtualKey
+}
+
+func buildDoorRelease(r *bmxtest.DoorRelease) butterflymx.DoorRelease {
+	var release butterflymx.DoorRelease
+	release.ID = r.ID
+	release.Attributes.ReleaseMethod = r.ReleaseMethod
+	release.Attributes.DoorReleaseType = "visitor"
+	release.Attributes.PanelUserType = "default"
+	release.Attributes.Name = r.Name
+	release.Attributes.CreatedAt = r.LoggedAt.UTC()
+	release.Attributes.LoggedAt = r.LoggedAt.UTC()
+	release.Attributes.ThumbURL = fmt.Sprintf("https://fakebmx.invalid/door_releases/%d/thumb.jpg", int(r.ID))
+	release.Attributes.MediumURL = fmt.Sprintf("https://fakebmx.invalid/door_releases/%d/medium.jpg", int(r.ID))
+	release.Relationships.Panel.Data = typedRef[butterflymx.Panel](butterflymx.TypePanel, r.AccessPointID)
+	release.Relationships.Device.Data = typedRef[butterflymx.Panel](butterflymx.TypePanel, r.AccessPointID)
+	return release
+}
+
+// addPanel adds the panel for the given access point to refs, if the tenant
+// has such an access point.
+func addPanel(tenant *bmxtest.Tenant, apID butterflymx.ID, refs map[butterflymx.ID]butterflymx.RawReference) {
+	ap := findAccessPoint(tenant, apID)
+	if ap == nil {
+		return
+	}
+
+	var panel butterflymx.Panel
+	panel.ID = ap.ID.Number
+	panel.Attributes.Name = ap.Name
+	panel.Relationships.Building.Data = &butterflymx.RawReference{
+		ID:   tenant.Building.ID.Number,
+		Type: butterflymx.TypeBuilding,
+	}
+	addRef(refs, butterflymx.TypePanel, panel.ID, panel)
+}
+
+func timestamp(t time.Time) butterflymx.Timestamp {
+	return butterflymx.Timestamp{Hour: t.Hour(), Minute: t.Minute()}
+}
+
+func datestamp(t time.Time) butterflymx.Datestamp {
+	return butterflymx.Datestamp{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+}
@@ -0,0 +1,548 @@
+//go:build goexperiment.jsonv2
+
+// Package fakebmx provides an in-memory implementation of
+// [butterflymx.Client] for application tests.
+//
+// Unlike [bmxtest], which fakes the ButterflyMX API at the HTTP level, this
+// package implements the client interface directly, so tests don't need a
+// server or any HTTP mocking at all. Both packages share the same [bmxtest.Data]
+// model, so the same seed data can be used with either.
+package fakebmx
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+)
+
+// Call is a single call made to a [Client].
+type Call struct {
+	// Method is the name of the called method, e.g. "UnlockDoor".
+	Method string
+	// Args are the arguments of the call, excluding the context.
+	Args []any
+}
+
+// Client is an in-memory [butterflymx.Client]. It is safe for concurrent use.
+// [butterflymx.CallOption]s are accepted but ignored.
+//
+// Like [butterflymx.APIClient], methods taking a tenant fall back to the
+// tenant set using [butterflymx.ContextWithTenant], then to DefaultTenant, if
+// they are given 0.
+type Client struct {
+	// Now returns the current time. It is used to decide which keychains are
+	// active and to timestamp virtual keys.
+	Now func() time.Time
+	// DefaultTenant is the numeric ID of the tenant that methods fall back to.
+	// See [butterflymx.APIClientOpts.DefaultTenant].
+	DefaultTenant butterflymx.ID
+
+	mu      sync.Mutex
+	data    bmxtest.Data
+	nextID  butterflymx.ID
+	calls   []Call
+	errs    map[string]error
+	unlocks []bmxtest.Unlock
+	media   map[string]media
+}
+
+// media is the media served at a URL by [Client.DownloadMedia].
+type media struct {
+	contentType string
+	data        []byte
+}
+
+var _ butterflymx.Client = (*Client)(nil)
+
+// New creates a new [Client] serving the given data. The client takes
+// ownership of data.
+func New(data *bmxtest.Data) *Client {
+	if data == nil {
+		data = &bmxtest.Data{}
+	}
+
+	c := &Client{
+		Now:   time.Now,
+		data:  *data,
+		errs:  make(map[string]error),
+		media: make(map[string]media),
+	}
+	c.nextID = maxID(&c.data) + 1
+	return c
+}
+
+// Update calls fn with the client's data while holding its lock, allowing
+// tests to change the data between calls.
+func (c *Client) Update(fn func(*bmxtest.Data)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fn(&c.data)
+	c.nextID = max(c.nextID, maxID(&c.data)+1)
+}
+
+// Data returns a snapshot of the client's data.
+func (c *Client) Data() bmxtest.Data {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Round-trip through JSON to deep copy the data.
+	var data bmxtest.Data
+	b, _ := json.Marshal(c.data)
+	json.Unmarshal(b, &data)
+	return data
+}
+
+// SetError makes all future calls to the given method fail with err. A nil
+// err makes the method succeed again.
+func (c *Client) SetError(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		delete(c.errs, method)
+	} else {
+		c.errs[method] = err
+	}
+}
+
+// SetMedia makes [Client.DownloadMedia] serve data with the given content type
+// at rawURL, such as the ThumbURL of a door release.
+func (c *Client) SetMedia(rawURL, contentType string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.media[rawURL] = media{contentType: contentType, data: slices.Clone(data)}
+}
+
+// Calls returns all calls made to the client so far.
+func (c *Client) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.calls)
+}
+
+// CallsTo returns all calls made to the given method so far.
+func (c *Client) CallsTo(method string) []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var calls []Call
+	for _, call := range c.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// record records a call and returns the error set for the method, if any.
+// It must be called with c.mu held.
+func (c *Client) record(method string, args ...any) error {
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+	return c.errs[method]
+}
+
+func (c *Client) newID() butterflymx.ID {
+	id := c.nextID
+	c.nextID++
+	return id
+}
+
+// tenantOr returns tenantID, or the tenant that the real client would fall
+// back to if it is 0.
+func (c *Client) tenantOr(ctx context.Context, tenantID butterflymx.ID) butterflymx.ID {
+	if tenantID != 0 {
+		return tenantID
+	}
+	if id, ok := butterflymx.TenantFromContext(ctx); ok {
+		return id
+	}
+	return c.DefaultTenant
+}
+
+func notFound(what string, id any) error {
+	return fmt.Errorf("%s %v not found: %w", what, id, &butterflymx.APIError{StatusCode: http.StatusNotFound})
+}
+
+// Tenants implements [butterflymx.Client].
+func (c *Client) Tenants(ctx context.Context, opts ...butterflymx.CallOption) iter.Seq2[butterflymx.Tenant, error] {
+	return func(yield func(butterflymx.Tenant, error) bool) {
+		c.mu.Lock()
+		err := c.record("Tenants")
+		tenants := make([]butterflymx.Tenant, len(c.data.Tenants))
+		for i, tenant := range c.data.Tenants {
+			tenants[i] = tenant.Tenant
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			yield(butterflymx.Tenant{}, err)
+			return
+		}
+
+		for _, tenant := range tenants {
+			if !yield(tenant, nil) {
+				return
+			}
+		}
+	}
+}
+
+// TenantAccessPoints implements [butterflymx.Client].
+func (c *Client) TenantAccessPoints(ctx context.Context, tenantID butterflymx.TaggedID, opts ...butterflymx.CallOption) iter.Seq2[butterflymx.AccessPoint, error] {
+	if tenantID == (butterflymx.TaggedID{}) {
+		if id := c.tenantOr(ctx, 0); id != 0 {
+			tenantID = butterflymx.TenantTaggedID(id)
+		}
+	}
+
+	return func(yield func(butterflymx.AccessPoint, error) bool) {
+		c.mu.Lock()
+		err := c.record("TenantAccessPoints", tenantID)
+		var accessPoints []butterflymx.AccessPoint
+		if tenant := findTenant(&c.data, tenantID.Number); tenant != nil {
+			accessPoints = slices.Clone(tenant.AccessPoints)
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			yield(butterflymx.AccessPoint{}, err)
+			return
+		}
+
+		for _, ap := range accessPoints {
+			if !yield(ap, nil) {
+				return
+			}
+		}
+	}
+}
+
+// AccessPointsOfTenants implements [butterflymx.Client]. Unknown tenants have
+// no access points.
+func (c *Client) AccessPointsOfTenants(ctx context.Context, tenantIDs []butterflymx.TaggedID, opts ...butterflymx.CallOption) (map[butterflymx.TaggedID][]butterflymx.AccessPoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("AccessPointsOfTenants", slices.Clone(tenantIDs)); err != nil {
+		return nil, err
+	}
+
+	accessPoints := make(map[butterflymx.TaggedID][]butterflymx.AccessPoint, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		var tenantAccessPoints []butterflymx.AccessPoint
+		if tenant := findTenant(&c.data, tenantID.Number); tenant != nil {
+			tenantAccessPoints = slices.Clone(tenant.AccessPoints)
+		}
+		accessPoints[tenantID] = tenantAccessPoints
+	}
+	return accessPoints, nil
+}
+
+// UnlockDoor implements [butterflymx.Client]. Like the real client, it fails
+// with an error matching [butterflymx.ErrForbidden] and wrapping a 403
+// [butterflymx.APIError] if the tenant doesn't have the access point.
+func (c *Client) UnlockDoor(ctx context.Context, tenantID butterflymx.ID, accessPointID butterflymx.ID, opts ...butterflymx.CallOption) error {
+	tenantID = c.tenantOr(ctx, tenantID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("UnlockDoor", tenantID, accessPointID); err != nil {
+		return err
+	}
+
+	tenant := findTenant(&c.data, tenantID)
+	if tenant == nil || findAccessPoint(tenant, accessPointID) == nil {
+		apiErr := &butterflymx.APIError{StatusCode: http.StatusForbidden}
+		return fmt.Errorf("API request failed: %w: %w", butterflymx.ErrForbidden, apiErr)
+	}
+
+	c.unlocks = append(c.unlocks, bmxtest.Unlock{
+		TenantID:      tenant.ID,
+		AccessPointID: butterflymx.AccessPointTaggedID(accessPointID),
+		Source:        "mobile_app",
+		At:            c.Now(),
+	})
+	return nil
+}
+
+// Unlocks returns all successful [Client.UnlockDoor] calls so far.
+func (c *Client) Unlocks() []bmxtest.Unlock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.unlocks)
+}
+
+// Keychains implements [butterflymx.Client].
+func (c *Client) Keychains(ctx context.Context, tenantID butterflymx.ID, status butterflymx.AccessCodeStatus, opts ...butterflymx.CallOption) (*butterflymx.ResultsWithReferences[butterflymx.Keychain], error) {
+	tenantID = c.tenantOr(ctx, tenantID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("Keychains", tenantID, status); err != nil {
+		return nil, err
+	}
+
+	results := &butterflymx.ResultsWithReferences[butterflymx.Keychain]{
+		Data: []butterflymx.Keychain{},
+		Refs: make(map[butterflymx.ID]butterflymx.RawReference),
+	}
+
+	tenant := findTenant(&c.data, tenantID)
+	if tenant == nil {
+		return results, nil
+	}
+
+	now := c.Now()
+	for i := range tenant.Keychains {
+		k := &tenant.Keychains[i]
+		if status == butterflymx.ActiveAccessCode && !k.IsActive(now) {
+			continue
+		}
+		keychain := buildKeychain(tenant, k, results.Refs, true)
+		addRef(results.Refs, butterflymx.TypeKeychain, keychain.ID, keychain)
+		results.Data = append(results.Data, keychain)
+	}
+
+	return results, nil
+}
+
+// KeychainPages implements [butterflymx.Client]. All keychains are yielded in
+// a single page, as returned by [Client.Keychains].
+func (c *Client) KeychainPages(ctx context.Context, tenantID butterflymx.ID, status butterflymx.AccessCodeStatus, opts ...butterflymx.CallOption) iter.Seq2[*butterflymx.ResultsWithReferences[butterflymx.Keychain], error] {
+	return func(yield func(*butterflymx.ResultsWithReferences[butterflymx.Keychain], error) bool) {
+		yield(c.Keychains(ctx, tenantID, status, opts...))
+	}
+}
+
+// Keychain implements [butterflymx.Client]. Like the real API, the devices
+// of the keychain are not included in the references.
+func (c *Client) Keychain(ctx context.Context, keychainID butterflymx.ID, opts ...butterflymx.CallOption) (*butterflymx.ResultWithReferences[butterflymx.Keychain], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("Keychain", keychainID); err != nil {
+		return nil, err
+	}
+
+	tenant, k := findKeychain(&c.data, keychainID)
+	if k == nil {
+		return nil, notFound("keychain", keychainID)
+	}
+
+	refs := make(map[butterflymx.ID]butterflymx.RawReference)
+	keychain := buildKeychain(tenant, k, refs, false)
+	addRef(refs, butterflymx.TypeKeychain, keychain.ID, keychain)
+
+	return &butterflymx.ResultWithReferences[butterflymx.Keychain]{Data: keychain, Refs: refs}, nil
+}
+
+// CreateCustomKeychain implements [butterflymx.Client].
+func (c *Client) CreateCustomKeychain(ctx context.Context, tenantID butterflymx.ID, accessPointIDs []butterflymx.ID, args butterflymx.CustomKeychainArgs, opts ...butterflymx.CallOption) (*butterflymx.ResultWithReferences[butterflymx.Keychain], error) {
+	tenantID = c.tenantOr(ctx, tenantID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("CreateCustomKeychain", tenantID, slices.Clone(accessPointIDs), args); err != nil {
+		return nil, err
+	}
+
+	tenant := findTenant(&c.data, tenantID)
+	if tenant == nil {
+		return nil, notFound("tenant", tenantID)
+	}
+
+	for _, apID := range accessPointIDs {
+		if findAccessPoint(tenant, apID) == nil {
+			return nil, fmt.Errorf("access point %v not found: %w", apID,
+				&butterflymx.APIError{StatusCode: http.StatusUnprocessableEntity})
+		}
+	}
+
+	tenant.Keychains = append(tenant.Keychains, bmxtest.Keychain{
+		ID:              c.newID(),
+		Name:            args.Name,
+		Kind:            butterflymx.CustomKeychain,
+		StartsAt:        args.StartsAt,
+		EndsAt:          args.EndsAt,
+		AllowUnitAccess: args.AllowUnitAccess,
+		AccessPointIDs:  slices.Clone(accessPointIDs),
+	})
+	k := &tenant.Keychains[len(tenant.Keychains)-1]
+
+	refs := make(map[butterflymx.ID]butterflymx.RawReference)
+	keychain := buildKeychain(tenant, k, refs, true)
+	addRef(refs, butterflymx.TypeKeychain, keychain.ID, keychain)
+
+	return &butterflymx.ResultWithReferences[butterflymx.Keychain]{Data: keychain, Refs: refs}, nil
+}
+
+// CreateVirtualKeys implements [butterflymx.Client]. The PIN code of each
+// created virtual key is derived from its ID.
+func (c *Client) CreateVirtualKeys(ctx context.Context, keychainID butterflymx.ID, virtualKeyArgs butterflymx.VirtualKeyArgs, opts ...butterflymx.CallOption) (*butterflymx.ResultsWithReferences[butterflymx.VirtualKey], error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("CreateVirtualKeys", keychainID, virtualKeyArgs); err != nil {
+		return nil, err
+	}
+
+	tenant, k := findKeychain(&c.data, keychainID)
+	if k == nil {
+		return nil, notFound("keychain", keychainID)
+	}
+
+	results := &butterflymx.ResultsWithReferences[butterflymx.VirtualKey]{
+		Data: make([]butterflymx.VirtualKey, 0, len(virtualKeyArgs.Recipients)),
+		Refs: make(map[butterflymx.ID]butterflymx.RawReference),
+	}
+
+	for _, recipient := range virtualKeyArgs.Recipients {
+		id := c.newID()
+		k.VirtualKeys = append(k.VirtualKeys, bmxtest.VirtualKey{
+			ID:      id,
+			Name:    recipient.Name,
+			Email:   recipient.DeliverTo,
+			PINCode: butterflymx.PINCode(fmt.Sprintf("%06d", int(id)%1000000)),
+			SentAt:  c.Now(),
+		})
+
+		vk := buildVirtualKey(tenant, &k.VirtualKeys[len(k.VirtualKeys)-1], nil)
+		addRef(results.Refs, butterflymx.TypeVirtualKey, vk.ID, vk)
+		results.Data = append(results.Data, vk)
+	}
+
+	return results, nil
+}
+
+// RevokeVirtualKey implements [butterflymx.Client].
+func (c *Client) RevokeVirtualKey(ctx context.Context, keychainID, virtualKeyID butterflymx.ID, opts ...butterflymx.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("RevokeVirtualKey", keychainID, virtualKeyID); err != nil {
+		return err
+	}
+
+	_, k := findKeychain(&c.data, keychainID)
+	if k == nil {
+		return notFound("keychain", keychainID)
+	}
+
+	i := slices.IndexFunc(k.VirtualKeys, func(vk bmxtest.VirtualKey) bool { return vk.ID == virtualKeyID })
+	if i == -1 {
+		return notFound("virtual key", virtualKeyID)
+	}
+
+	k.VirtualKeys = slices.Delete(k.VirtualKeys, i, i+1)
+	return nil
+}
+
+// Ping implements [butterflymx.Client]. It always succeeds unless an error
+// was set using [Client.SetError].
+func (c *Client) Ping(ctx context.Context, opts ...butterflymx.CallOption) (butterflymx.PingResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("Ping"); err != nil {
+		return butterflymx.PingResult{}, err
+	}
+
+	return butterflymx.PingResult{Reachable: true, TokenOK: true}, nil
+}
+
+// ValidateToken implements [butterflymx.Client]. It always succeeds unless an
+// error, such as an [*butterflymx.AuthError], was set using [Client.SetError].
+func (c *Client) ValidateToken(ctx context.Context, opts ...butterflymx.CallOption) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.record("ValidateToken")
+}
+
+// PingUnlock implements [butterflymx.Client]. It always succeeds unless an
+// error was set using [Client.SetError].
+func (c *Client) PingUnlock(ctx context.Context, opts ...butterflymx.CallOption) (butterflymx.PingResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.record("PingUnlock"); err != nil {
+		return butterflymx.PingResult{}, err
+	}
+
+	return butterflymx.PingResult{Reachable: true, TokenOK: true}, nil
+}
+
+// ProbeAccessPoint implements [butterflymx.Client] using
+// [butterflymx.ProbeAccessPoint].
+func (c *Client) ProbeAccessPoint(ctx context.Context, tenantID butterflymx.TaggedID, accessPointID butterflymx.ID, opts ...butterflymx.CallOption) (butterflymx.AccessPointProbe, error) {
+	c.mu.Lock()
+	err := c.record("ProbeAccessPoint", tenantID, accessPointID)
+	c.mu.Unlock()
+
+	if err != nil {
+		return butterflymx.AccessPointProbe{}, err
+	}
+	return butterflymx.ProbeAccessPoint(ctx, c, tenantID, accessPointID, opts...)
+}
+
+// DownloadMedia implements [butterflymx.Client]. It serves the media set using
+// [Client.SetMedia] and fails with a 404 [butterflymx.APIError] for any other
+// URL.
+func (c *Client) DownloadMedia(ctx context.Context, rawURL string, w io.Writer, opts ...butterflymx.CallOption) (butterflymx.MediaInfo, error) {
+	c.mu.Lock()
+	err := c.record("DownloadMedia", rawURL)
+	m, ok := c.media[rawURL]
+	c.mu.Unlock()
+
+	if err != nil {
+		return butterflymx.MediaInfo{}, err
+	}
+	if !ok {
+		return butterflymx.MediaInfo{}, notFound("media", rawURL)
+	}
+
+	n, err := w.Write(m.data)
+	return butterflymx.MediaInfo{ContentType: m.contentType, Size: int64(n)}, err
+}
+
+// ExportKeychains implements [butterflymx.Client] using
+// [butterflymx.ExportKeychains].
+func (c *Client) ExportKeychains(ctx context.Context, tenantID butterflymx.ID, w io.Writer, opts ...butterflymx.CallOption) error {
+	c.mu.Lock()
+	err := c.record("ExportKeychains", tenantID)
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return butterflymx.ExportKeychains(ctx, c, tenantID, w, opts...)
+}
+
+// ImportKeychains implements [butterflymx.Client] using
+// [butterflymx.ImportKeychains].
+func (c *Client) ImportKeychains(ctx context.Context, tenantID butterflymx.ID, r io.Reader, opts ...butterflymx.CallOption) (*butterflymx.KeychainImportResult, error) {
+	c.mu.Lock()
+	err := c.record("ImportKeychains", tenantID)
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	return butterflymx.ImportKeychains(ctx, c, tenantID, r, opts...)
+}
@@ -0,0 +1,196 @@
+package fakebmx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+)
+
+func testData() *bmxtest.Data {
+	return &bmxtest.Data{
+		Tenants: []bmxtest.Tenant{{
+			Tenant: butterflymx.Tenant{
+				ID:       butterflymx.NewTaggedID("tenant", 100),
+				Name:     "Tenant",
+				Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+				Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+			},
+			AccessPoints: []butterflymx.AccessPoint{
+				{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door", OpenDuration: 5, Online: true},
+				{ID: butterflymx.NewTaggedID("access_point", 401), Name: "Garage", OpenDuration: 10, Online: true},
+			},
+		}},
+	}
+}
+
+func TestClient(t *testing.T) {
+	client := New(testData())
+	ctx := t.Context()
+
+	tenants, err := butterflymx.CollectResults(client.Tenants(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tenants))
+
+	tenantID := tenants[0].ID
+	accessPoints, err := butterflymx.CollectResults(client.TenantAccessPoints(ctx, tenantID))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(accessPoints))
+
+	probe, err := client.ProbeAccessPoint(ctx, tenantID, 400)
+	assert.NoError(t, err)
+	assert.True(t, probe.Ready())
+
+	batched, err := client.AccessPointsOfTenants(ctx, []butterflymx.TaggedID{tenantID, butterflymx.TenantTaggedID(999)})
+	assert.NoError(t, err)
+	assert.Equal(t, accessPoints, batched[tenantID])
+	assert.Equal(t, 0, len(batched[butterflymx.TenantTaggedID(999)]))
+
+	now := time.Now().Truncate(time.Second)
+	keychain, err := client.CreateCustomKeychain(ctx, tenantID.Number, []butterflymx.ID{400}, butterflymx.CustomKeychainArgs{
+		Name:     "Guest",
+		StartsAt: now,
+		EndsAt:   now.Add(24 * time.Hour),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Guest", keychain.Data.Attributes.Name)
+
+	panel, err := keychain.Data.Relationships.Devices[0].Resolve(keychain.Refs)
+	assert.NoError(t, err)
+	assert.Equal(t, "Front Door", panel.Attributes.Name)
+
+	virtualKeys, err := client.CreateVirtualKeys(ctx, keychain.Data.ID, butterflymx.VirtualKeyArgs{
+		Recipients: []butterflymx.VirtualKeyRecipient{{Name: "guest", DeliverTo: "guest@example.com"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(virtualKeys.Data))
+	assert.NoError(t, virtualKeys.Data[0].Attributes.PINCode.Validate())
+
+	keychains, err := client.Keychains(ctx, tenantID.Number, butterflymx.ActiveAccessCode)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(keychains.Data))
+
+	for page, err := range client.KeychainPages(ctx, tenantID.Number, butterflymx.ActiveAccessCode) {
+		assert.NoError(t, err)
+		assert.Equal(t, keychains.Data, page.Data)
+	}
+
+	virtualKey, err := keychains.Data[0].Relationships.VirtualKeys[0].Resolve(keychains.Refs)
+	assert.NoError(t, err)
+	assert.Equal(t, "guest@example.com", virtualKey.Attributes.Email)
+
+	err = client.RevokeVirtualKey(ctx, keychain.Data.ID, virtualKey.ID)
+	assert.NoError(t, err)
+
+	single, err := client.Keychain(ctx, keychain.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(single.Data.Relationships.VirtualKeys))
+
+	err = client.UnlockDoor(ctx, tenantID.Number, 401)
+	assert.NoError(t, err)
+
+	err = client.UnlockDoor(ctx, tenantID.Number, 999)
+	assert.IsError(t, err, butterflymx.ErrForbidden)
+	var apiErr *butterflymx.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+	assert.Equal(t, 1, len(client.Unlocks()))
+	assert.Equal(t, butterflymx.NewTaggedID("access_point", 401), client.Unlocks()[0].AccessPointID)
+
+	assert.Equal(t, []Call{
+		{Method: "UnlockDoor", Args: []any{tenantID.Number, butterflymx.ID(401)}},
+		{Method: "UnlockDoor", Args: []any{tenantID.Number, butterflymx.ID(999)}},
+	}, client.CallsTo("UnlockDoor"))
+	assert.Equal(t, 14, len(client.Calls()))
+}
+
+func TestClient_SetError(t *testing.T) {
+	client := New(testData())
+
+	errDown := errors.New("down")
+	client.SetError("Tenants", errDown)
+
+	_, err := butterflymx.CollectResults(client.Tenants(t.Context()))
+	assert.IsError(t, err, errDown)
+
+	client.SetError("Tenants", nil)
+
+	_, err = butterflymx.CollectResults(client.Tenants(t.Context()))
+	assert.NoError(t, err)
+
+	assert.NoError(t, client.ValidateToken(t.Context()))
+	client.SetError("ValidateToken", &butterflymx.AuthError{Failure: butterflymx.AuthFailureAPITokenExpired})
+	assert.IsError(t, client.ValidateToken(t.Context()), butterflymx.ErrTokenExpired)
+
+	client.SetError("PingUnlock", errDown)
+
+	_, err = client.PingUnlock(t.Context())
+	assert.IsError(t, err, errDown)
+}
+
+func TestClient_defaultTenant(t *testing.T) {
+	client := New(testData())
+	client.DefaultTenant = 100
+
+	assert.NoError(t, client.UnlockDoor(t.Context(), 0, 400))
+	assert.IsError(t, client.UnlockDoor(butterflymx.ContextWithTenant(t.Context(), 7), 0, 400), butterflymx.ErrForbidden)
+	assert.NoError(t, client.UnlockDoor(butterflymx.ContextWithTenant(t.Context(), 7), 100, 401), "an explicit tenant should take precedence")
+
+	accessPoints, err := butterflymx.CollectResults(client.TenantAccessPoints(t.Context(), butterflymx.TaggedID{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(accessPoints))
+
+	assert.Equal(t, []Call{
+		{Method: "UnlockDoor", Args: []any{butterflymx.ID(100), butterflymx.ID(400)}},
+		{Method: "UnlockDoor", Args: []any{butterflymx.ID(7), butterflymx.ID(400)}},
+		{Method: "UnlockDoor", Args: []any{butterflymx.ID(100), butterflymx.ID(401)}},
+	}, client.CallsTo("UnlockDoor"))
+}
+
+func TestClient_ExportImportKeychains(t *testing.T) {
+	client := New(testData())
+	ctx := t.Context()
+
+	now := time.Now().Truncate(time.Second)
+	_, err := client.CreateCustomKeychain(ctx, 100, []butterflymx.ID{401}, butterflymx.CustomKeychainArgs{
+		Name:     "Guest",
+		StartsAt: now,
+		EndsAt:   now.Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	var backup bytes.Buffer
+	assert.NoError(t, client.ExportKeychains(ctx, 100, &backup))
+
+	result, err := client.ImportKeychains(ctx, 100, &backup)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Created))
+	assert.Equal(t, 0, len(result.Skipped))
+
+	keychains, err := client.Keychains(ctx, 100, butterflymx.ActiveAccessCode)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(keychains.Data))
+	assert.Equal(t, 2, len(client.CallsTo("CreateCustomKeychain")))
+}
+
+func TestClient_DownloadMedia(t *testing.T) {
+	client := New(testData())
+	client.SetMedia("https://example.com/thumb.jpg", "image/jpeg", []byte("jpeg"))
+
+	var buf bytes.Buffer
+	info, err := client.DownloadMedia(t.Context(), "https://example.com/thumb.jpg", &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, butterflymx.MediaInfo{ContentType: "image/jpeg", Size: 4}, info)
+	assert.Equal(t, "jpeg", buf.String())
+
+	_, err = client.DownloadMedia(t.Context(), "https://example.com/other.jpg", &buf)
+	var apiErr *butterflymx.APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -0,0 +1,141 @@
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func FuzzTaggedID(f *testing.F) {
+	f.Add("prod-tenant-12345")
+	f.Add("prod-access_point-50001")
+	f.Add("prod--1")
+	f.Add("dev-tenant-1")
+	f.Add("prod-tenant-")
+	f.Fuzz(func(t *testing.T, s string) {
+		var id TaggedID
+		if err := id.UnmarshalText([]byte(s)); err != nil {
+			return
+		}
+
+		text, err := id.MarshalText()
+		assert.NoError(t, err)
+
+		var again TaggedID
+		assert.NoError(t, again.UnmarshalText(text), "marshaled TaggedID %q should parse", text)
+		assert.Equal(t, id, again)
+	})
+}
+
+func FuzzPINCode(f *testing.F) {
+	f.Add("012345")
+	f.Add("")
+	f.Add("12a4")
+	f.Add("١٢٣")
+	f.Fuzz(func(t *testing.T, s string) {
+		var pin PINCode
+		if err := pin.UnmarshalText([]byte(s)); err != nil {
+			return
+		}
+
+		assert.NoError(t, pin.Validate())
+
+		var n int
+		for digit := range pin.Digits() {
+			assert.True(t, digit >= 0 && digit <= 9, "digit %d out of range", digit)
+			n++
+		}
+		assert.Equal(t, len(pin.String()), n)
+	})
+}
+
+func FuzzID(f *testing.F) {
+	f.Add(`"10001"`)
+	f.Add(`10001`)
+	f.Add(`"-1"`)
+	f.Add(`"99999999999999999999"`)
+	f.Add(`null`)
+	f.Fuzz(func(t *testing.T, s string) {
+		var id ID
+		if err := id.UnmarshalJSON([]byte(s)); err != nil {
+			return
+		}
+
+		b, err := id.MarshalJSON()
+		assert.NoError(t, err)
+
+		var again ID
+		assert.NoError(t, again.UnmarshalJSON(b), "marshaled ID %s should parse", b)
+		assert.Equal(t, id, again)
+	})
+}
+
+func FuzzTimestamp(f *testing.F) {
+	f.Add("08:00")
+	f.Add("23:59")
+	f.Add("24:00")
+	f.Add("8:0")
+	f.Fuzz(func(t *testing.T, s string) {
+		var ts Timestamp
+		if err := ts.UnmarshalText([]byte(s)); err != nil {
+			return
+		}
+
+		text, err := ts.MarshalText()
+		assert.NoError(t, err)
+
+		var again Timestamp
+		assert.NoError(t, again.UnmarshalText(text), "marshaled Timestamp %q should parse", text)
+		assert.Equal(t, ts, again)
+	})
+}
+
+func FuzzDatestamp(f *testing.F) {
+	f.Add("2023-01-01")
+	f.Add("0999-12-31")
+	f.Add("2023-02-30")
+	f.Add("2023-1-1")
+	f.Fuzz(func(t *testing.T, s string) {
+		var ds Datestamp
+		if err := ds.UnmarshalText([]byte(s)); err != nil {
+			return
+		}
+
+		text, err := ds.MarshalText()
+		assert.NoError(t, err)
+
+		var again Datestamp
+		assert.NoError(t, again.UnmarshalText(text), "marshaled Datestamp %q should parse", text)
+		assert.Equal(t, ds, again)
+	})
+}
+
+func FuzzRawReference(f *testing.F) {
+	f.Add([]byte(`{"id":"10001","type":"keychains","attributes":{"name":"Amazon Delivery"}}`))
+	f.Add([]byte(`{"id":"10001","type":"panels"}`))
+	f.Add([]byte(`{"id":10001}`))
+	f.Add([]byte(`{"id":"x","type":"keychains"}`))
+	f.Add([]byte(`{"id":"1","relationships":{"virtual_keys":{"data":[{"id":"2","type":"virtual_keys"}]}}}`))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var ref RawReference
+		if err := json.Unmarshal(b, &ref); err != nil {
+			return
+		}
+
+		// Decoding arbitrary objects must not panic, even if the data doesn't
+		// fit the target type.
+		unmarshalReference[Keychain](ref)
+		unmarshalReference[VirtualKey](ref)
+		unmarshalReference[DoorRelease](ref)
+		unmarshalReference[Panel](ref)
+
+		out, err := json.Marshal(ref)
+		assert.NoError(t, err)
+
+		var again RawReference
+		assert.NoError(t, json.Unmarshal(out, &again), "marshaled RawReference %s should parse", out)
+		assert.Equal(t, ref.ID, again.ID)
+		assert.Equal(t, ref.Type, again.Type)
+	})
+}
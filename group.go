@@ -0,0 +1,98 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// AccessPointGroup is a named set of access points that are usually unlocked
+// or provisioned together, such as all garage doors of a building or all
+// amenity rooms. The API has no notion of groups, so they are defined by the
+// caller, either by listing access point IDs or by matching access point names
+// with [GroupAccessPoints].
+type AccessPointGroup struct {
+	// Name is the name of the group, e.g. "Garage" or "Amenities".
+	Name string `json:"name"`
+	// AccessPointIDs are the numeric IDs of the access points in the group.
+	AccessPointIDs []ID `json:"access_point_ids"`
+}
+
+// GroupAccessPoints sorts access points into groups by name. patterns maps
+// each group name to [path.Match] patterns matched case-insensitively against
+// access point names, e.g. {"Garage": {"garage*", "*parking*"}}. An access
+// point may end up in multiple groups. Groups are returned sorted by name,
+// including groups that matched nothing.
+func GroupAccessPoints(accessPoints []AccessPoint, patterns map[string][]string) ([]AccessPointGroup, error) {
+	groups := make([]AccessPointGroup, 0, len(patterns))
+
+	for name, groupPatterns := range patterns {
+		group := AccessPointGroup{Name: name}
+		for _, ap := range accessPoints {
+			for _, pattern := range groupPatterns {
+				ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(ap.Name))
+				if err != nil {
+					return nil, fmt.Errorf("group %q: invalid pattern %q: %w", name, pattern, err)
+				}
+				if ok {
+					group.AccessPointIDs = append(group.AccessPointIDs, ap.ID.Number)
+					break
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	slices.SortFunc(groups, func(a, b AccessPointGroup) int { return strings.Compare(a.Name, b.Name) })
+	return groups, nil
+}
+
+// groupAccessPointIDs returns the IDs of all access points in the given
+// groups, without duplicates.
+func groupAccessPointIDs(groups []AccessPointGroup) []ID {
+	var ids []ID
+	for _, group := range groups {
+		for _, id := range group.AccessPointIDs {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// UnlockGroup unlocks every access point in the group for the given tenant.
+// All access points are attempted even if some fail; the returned error joins
+// the errors of the failed ones.
+func UnlockGroup(ctx context.Context, client Client, tenantID ID, group AccessPointGroup, opts ...CallOption) error {
+	if len(group.AccessPointIDs) == 0 {
+		return fmt.Errorf("group %q has no access points", group.Name)
+	}
+
+	var errs []error
+	for _, id := range group.AccessPointIDs {
+		if err := client.UnlockDoor(ctx, tenantID, id, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("access point %d: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreateGroupKeychain creates a custom keychain granting access to every
+// access point in the given groups. See [APIClient.CreateCustomKeychain].
+func CreateGroupKeychain(
+	ctx context.Context, client Client,
+	tenantID ID, groups []AccessPointGroup, args CustomKeychainArgs,
+	opts ...CallOption,
+) (*ResultWithReferences[Keychain], error) {
+	ids := groupAccessPointIDs(groups)
+	if len(ids) == 0 {
+		return nil, errors.New("groups have no access points")
+	}
+	return client.CreateCustomKeychain(ctx, tenantID, ids, args, opts...)
+}
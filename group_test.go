@@ -0,0 +1,84 @@
+package butterflymx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+var groupTestAccessPoints = []butterflymx.AccessPoint{
+	{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door"},
+	{ID: butterflymx.NewTaggedID("access_point", 401), Name: "Garage Gate"},
+	{ID: butterflymx.NewTaggedID("access_point", 402), Name: "Parking Elevator"},
+	{ID: butterflymx.NewTaggedID("access_point", 403), Name: "Pool"},
+}
+
+func TestGroupAccessPoints(t *testing.T) {
+	groups, err := butterflymx.GroupAccessPoints(groupTestAccessPoints, map[string][]string{
+		"Garage":    {"garage*", "*parking*"},
+		"Amenities": {"pool", "gym"},
+		"Lobby":     {"lobby*"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []butterflymx.AccessPointGroup{
+		{Name: "Amenities", AccessPointIDs: []butterflymx.ID{403}},
+		{Name: "Garage", AccessPointIDs: []butterflymx.ID{401, 402}},
+		{Name: "Lobby"},
+	}, groups)
+
+	_, err = butterflymx.GroupAccessPoints(groupTestAccessPoints, map[string][]string{"Bad": {"["}})
+	assert.Error(t, err)
+}
+
+func TestGroupHelpers(t *testing.T) {
+	tenantID := butterflymx.NewTaggedID("tenant", 100)
+	fake := fakebmx.New(&bmxtest.Data{
+		Tenants: []bmxtest.Tenant{{
+			Tenant: butterflymx.Tenant{
+				ID:       tenantID,
+				Name:     "Tenant",
+				Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+				Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+			},
+			AccessPoints: groupTestAccessPoints,
+		}},
+	})
+
+	garage := butterflymx.AccessPointGroup{Name: "Garage", AccessPointIDs: []butterflymx.ID{401, 402}}
+	amenities := butterflymx.AccessPointGroup{Name: "Amenities", AccessPointIDs: []butterflymx.ID{402, 403}}
+
+	t.Run("unlock", func(t *testing.T) {
+		assert.NoError(t, butterflymx.UnlockGroup(t.Context(), fake, tenantID.Number, garage))
+
+		var unlocked []butterflymx.ID
+		for _, unlock := range fake.Unlocks() {
+			unlocked = append(unlocked, unlock.AccessPointID.Number)
+		}
+		assert.Equal(t, []butterflymx.ID{401, 402}, unlocked)
+	})
+
+	t.Run("unlock partially fails", func(t *testing.T) {
+		group := butterflymx.AccessPointGroup{Name: "Mixed", AccessPointIDs: []butterflymx.ID{400, 999}}
+		err := butterflymx.UnlockGroup(t.Context(), fake, tenantID.Number, group)
+		assert.EqualError(t, err, "access point 999: API request failed: butterflymx: forbidden: status 403")
+	})
+
+	t.Run("keychain", func(t *testing.T) {
+		_, err := butterflymx.CreateGroupKeychain(t.Context(), fake, tenantID.Number,
+			[]butterflymx.AccessPointGroup{garage, amenities},
+			butterflymx.CustomKeychainArgs{
+				Name:     "Guest",
+				StartsAt: time.Now(),
+				EndsAt:   time.Now().Add(time.Hour),
+			})
+		assert.NoError(t, err)
+
+		calls := fake.CallsTo("CreateCustomKeychain")
+		assert.Equal(t, 1, len(calls))
+		assert.Equal[any](t, []butterflymx.ID{401, 402, 403}, calls[0].Args[1])
+	})
+}
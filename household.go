@@ -0,0 +1,56 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HouseholdKeychain is a keychain shared by the residents of a unit. See
+// [CreateHouseholdKeychain].
+type HouseholdKeychain struct {
+	// Keychain is the created keychain.
+	Keychain *ResultWithReferences[Keychain]
+	// VirtualKeys has the virtual key of every resident, in the order the
+	// residents were given. It is empty if creating them failed.
+	VirtualKeys []VirtualKey
+}
+
+// CreateHouseholdKeychain creates a single custom keychain for the tenant
+// granting access to the given access points, along with one virtual key per
+// resident, so that everyone living in the unit gets their own PIN code for
+// the same doors and schedule.
+//
+// The API's listing of the other residents of a unit hasn't been captured yet,
+// so the residents have to be given by the caller. They usually include the
+// tenant themselves.
+//
+// If the keychain is created but its virtual keys aren't, the keychain is
+// returned along with the error, so that the caller can retry with
+// [APIClient.CreateVirtualKeys].
+func CreateHouseholdKeychain(
+	ctx context.Context, client Client,
+	tenantID ID, accessPointIDs []ID, args CustomKeychainArgs, residents []VirtualKeyRecipient,
+	opts ...CallOption,
+) (*HouseholdKeychain, error) {
+	if len(residents) == 0 {
+		return nil, errors.New("household has no residents")
+	}
+
+	keychain, err := client.CreateCustomKeychain(ctx, tenantID, accessPointIDs, args, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keychain: %w", err)
+	}
+
+	household := &HouseholdKeychain{Keychain: keychain}
+
+	virtualKeys, err := client.CreateVirtualKeys(ctx, keychain.Data.ID, VirtualKeyArgs{Recipients: residents}, opts...)
+	if err != nil {
+		return household, fmt.Errorf("failed to create virtual keys of keychain %d: %w", keychain.Data.ID, err)
+	}
+	household.VirtualKeys = virtualKeys.Data
+
+	return household, nil
+}
@@ -0,0 +1,74 @@
+package butterflymx_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestCreateHouseholdKeychain(t *testing.T) {
+	tenantID := butterflymx.NewTaggedID("tenant", 100)
+	newFake := func() *fakebmx.Client {
+		return fakebmx.New(&bmxtest.Data{
+			Tenants: []bmxtest.Tenant{{
+				Tenant: butterflymx.Tenant{
+					ID:       tenantID,
+					Name:     "Tenant",
+					Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+					Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+				},
+				AccessPoints: groupTestAccessPoints,
+			}},
+		})
+	}
+
+	args := butterflymx.CustomKeychainArgs{
+		Name:     "Family",
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(365 * 24 * time.Hour),
+	}
+	residents := []butterflymx.VirtualKeyRecipient{
+		{Name: "Jane", DeliverTo: "jane@example.com"},
+		{Name: "John", DeliverTo: "john@example.com"},
+	}
+
+	t.Run("created", func(t *testing.T) {
+		fake := newFake()
+		household, err := butterflymx.CreateHouseholdKeychain(t.Context(), fake, tenantID.Number,
+			[]butterflymx.ID{400, 401}, args, residents)
+		assert.NoError(t, err)
+		assert.Equal(t, "Family", household.Keychain.Data.Attributes.Name)
+		assert.Equal(t, 2, len(household.VirtualKeys))
+		assert.Equal(t, "Jane", household.VirtualKeys[0].Attributes.Name)
+		assert.Equal(t, "John", household.VirtualKeys[1].Attributes.Name)
+		assert.NotEqual(t, household.VirtualKeys[0].Attributes.PINCode, household.VirtualKeys[1].Attributes.PINCode)
+
+		assert.Equal(t, 1, len(fake.CallsTo("CreateCustomKeychain")))
+		assert.Equal(t, 1, len(fake.CallsTo("CreateVirtualKeys")))
+	})
+
+	t.Run("virtual keys fail", func(t *testing.T) {
+		fake := newFake()
+		errBoom := errors.New("boom")
+		fake.SetError("CreateVirtualKeys", errBoom)
+
+		household, err := butterflymx.CreateHouseholdKeychain(t.Context(), fake, tenantID.Number,
+			[]butterflymx.ID{400}, args, residents)
+		assert.IsError(t, err, errBoom)
+		assert.NotZero(t, household.Keychain)
+		assert.Equal(t, 0, len(household.VirtualKeys))
+	})
+
+	t.Run("no residents", func(t *testing.T) {
+		fake := newFake()
+		_, err := butterflymx.CreateHouseholdKeychain(t.Context(), fake, tenantID.Number,
+			[]butterflymx.ID{400}, args, nil)
+		assert.Error(t, err)
+		assert.Equal(t, 0, len(fake.Calls()))
+	})
+}
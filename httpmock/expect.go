@@ -0,0 +1,192 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Expectation is a fluent builder for request assertions. Use [Expect] to
+// create one and pass its [Expectation.Check] method as a
+// [RoundTripRequestCheck]:
+//
+//	RequestCheck: httpmock.Expect().
+//		Post("/v3/keychains/custom").
+//		JSONPath("data.attributes.kind", "custom").
+//		Check,
+//
+// Unlike comparing whole request bodies, an Expectation only asserts what it
+// is told to, so it doesn't break when an unrelated field is added.
+type Expectation struct {
+	checks []func(t *testing.T, req *http.Request, body []byte)
+}
+
+// Expect creates a new, empty [Expectation].
+func Expect() *Expectation {
+	return &Expectation{}
+}
+
+func (e *Expectation) add(check func(t *testing.T, req *http.Request, body []byte)) *Expectation {
+	e.checks = append(e.checks, check)
+	return e
+}
+
+// Request asserts the request method and URL path. The path is matched using
+// [path.Match] syntax.
+func (e *Expectation) Request(method, pattern string) *Expectation {
+	return e.add(func(t *testing.T, req *http.Request, _ []byte) {
+		t.Helper()
+		if req.Method != method {
+			t.Errorf("httpmock.Expect: expected method %s, got %s", method, req.Method)
+		}
+		if ok, err := path.Match(pattern, req.URL.Path); err != nil || !ok {
+			t.Errorf("httpmock.Expect: expected path %s, got %s", pattern, req.URL.Path)
+		}
+	})
+}
+
+// Get is a shorthand for Request(http.MethodGet, pattern).
+func (e *Expectation) Get(pattern string) *Expectation {
+	return e.Request(http.MethodGet, pattern)
+}
+
+// Post is a shorthand for Request(http.MethodPost, pattern).
+func (e *Expectation) Post(pattern string) *Expectation {
+	return e.Request(http.MethodPost, pattern)
+}
+
+// Put is a shorthand for Request(http.MethodPut, pattern).
+func (e *Expectation) Put(pattern string) *Expectation {
+	return e.Request(http.MethodPut, pattern)
+}
+
+// Patch is a shorthand for Request(http.MethodPatch, pattern).
+func (e *Expectation) Patch(pattern string) *Expectation {
+	return e.Request(http.MethodPatch, pattern)
+}
+
+// Delete is a shorthand for Request(http.MethodDelete, pattern).
+func (e *Expectation) Delete(pattern string) *Expectation {
+	return e.Request(http.MethodDelete, pattern)
+}
+
+// Header asserts that the request header key has the given value.
+func (e *Expectation) Header(key, value string) *Expectation {
+	return e.add(func(t *testing.T, req *http.Request, _ []byte) {
+		t.Helper()
+		if got := req.Header.Get(key); got != value {
+			t.Errorf("httpmock.Expect: expected header %s to be %q, got %q", key, value, got)
+		}
+	})
+}
+
+// Query asserts that the URL query parameter key has the given value.
+func (e *Expectation) Query(key, value string) *Expectation {
+	return e.add(func(t *testing.T, req *http.Request, _ []byte) {
+		t.Helper()
+		if got := req.URL.Query().Get(key); got != value {
+			t.Errorf("httpmock.Expect: expected query parameter %s to be %q, got %q", key, value, got)
+		}
+	})
+}
+
+// JSONPath asserts that the value at the given path in the JSON request body
+// equals want. The path is a dot-separated list of object member names and
+// array indices, e.g. "data.relationships.access_points.data.0.id".
+//
+// Values are compared after round-tripping want through JSON, so want can be
+// any value that marshals to the expected JSON, such as a string, a number, a
+// map or a struct.
+func (e *Expectation) JSONPath(path string, want any) *Expectation {
+	return e.add(func(t *testing.T, _ *http.Request, body []byte) {
+		t.Helper()
+
+		got, err := lookupJSONPath(body, path)
+		if err != nil {
+			t.Errorf("httpmock.Expect: JSON path %q: %v", path, err)
+			return
+		}
+
+		wantJSON, err := json.Marshal(want)
+		if err != nil {
+			t.Errorf("httpmock.Expect: JSON path %q: failed to marshal expected value: %v", path, err)
+			return
+		}
+		var wantValue any
+		if err := json.Unmarshal(wantJSON, &wantValue); err != nil {
+			t.Errorf("httpmock.Expect: JSON path %q: failed to unmarshal expected value: %v", path, err)
+			return
+		}
+
+		if !reflect.DeepEqual(wantValue, got) {
+			gotJSON, _ := json.Marshal(got)
+			t.Errorf("httpmock.Expect: JSON path %q: expected %s, got %s", path, wantJSON, gotJSON)
+		}
+	})
+}
+
+// JSONPathExists asserts that the given path exists in the JSON request body.
+func (e *Expectation) JSONPathExists(path string) *Expectation {
+	return e.add(func(t *testing.T, _ *http.Request, body []byte) {
+		t.Helper()
+		if _, err := lookupJSONPath(body, path); err != nil {
+			t.Errorf("httpmock.Expect: JSON path %q: %v", path, err)
+		}
+	})
+}
+
+// Check runs all assertions against the request. It has the signature of a
+// [RoundTripRequestCheck]. The request body is restored afterwards.
+func (e *Expectation) Check(t *testing.T, req *http.Request) {
+	t.Helper()
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			t.Fatalf("httpmock.Expect: failed to read request body: %v", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	for _, check := range e.checks {
+		check(t, req, body)
+	}
+}
+
+func lookupJSONPath(body []byte, path string) (any, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+
+	for segment := range strings.SplitSeq(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("%q: no such member", segment)
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("%q: no such index", segment)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("%q: not an object or array", segment)
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,47 @@
+package httpmock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	body := []byte(`{"data":{"type":"keychains","relationships":{"access_points":{"data":[{"id":"1"},{"id":"2"}]}}}}`)
+
+	v, err := lookupJSONPath(body, "data.type")
+	assert.NoError(t, err)
+	assert.Equal(t, any("keychains"), v)
+
+	v, err = lookupJSONPath(body, "data.relationships.access_points.data.1.id")
+	assert.NoError(t, err)
+	assert.Equal(t, any("2"), v)
+
+	_, err = lookupJSONPath(body, "data.relationships.access_points.data.2")
+	assert.Error(t, err)
+
+	_, err = lookupJSONPath(body, "data.type.name")
+	assert.Error(t, err)
+}
+
+func TestExpectation(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "https://example.com/v3/keychains/custom?include=devices",
+		strings.NewReader(`{"data":{"type":"keychains","attributes":{"kind":"custom","allow_unit_access":false}}}`))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	Expect().
+		Post("/v3/keychains/*").
+		Header("Content-Type", "application/json").
+		Query("include", "devices").
+		JSONPath("data.attributes.kind", "custom").
+		JSONPath("data.attributes", map[string]any{"kind": "custom", "allow_unit_access": false}).
+		JSONPathExists("data.type").
+		Check(t, req)
+
+	// The body must still be readable afterwards.
+	assert.NotZero(t, req.Body)
+	Expect().JSONPath("data.type", "keychains").Check(t, req)
+}
@@ -0,0 +1,173 @@
+package httpmock
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Faults configures the faults injected by a [FaultTransport]. Each rate is a
+// probability between 0 and 1 that is rolled independently for every request,
+// in the order the fields are declared. At most one fault is injected per
+// request.
+type Faults struct {
+	// Latency is added to every request before it is sent.
+	Latency time.Duration
+	// TimeoutRate is the rate of requests that hang until their context is
+	// done, or fail with a timeout error if the context has no deadline.
+	TimeoutRate float64
+	// ResetRate is the rate of requests that fail with a connection reset.
+	ResetRate float64
+	// StatusRate is the rate of requests that are answered with Status
+	// without reaching the underlying transport.
+	StatusRate float64
+	// Status is the status code used for StatusRate. It defaults to 503.
+	Status int
+	// MalformedRate is the rate of responses whose body is truncated, making
+	// it invalid JSON.
+	MalformedRate float64
+	// MaxFaults is the maximum number of faults to inject. Zero means no
+	// limit. Once reached, all requests are passed through unchanged.
+	MaxFaults int
+	// Seed seeds the random number generator, making the injected faults
+	// deterministic for a given sequence of requests.
+	Seed uint64
+}
+
+// errFaultTimeout is returned for injected timeouts. It implements
+// [net.Error] so that it is treated like a real network timeout.
+type errFaultTimeout struct{}
+
+func (errFaultTimeout) Error() string   { return "httpmock: injected timeout" }
+func (errFaultTimeout) Timeout() bool   { return true }
+func (errFaultTimeout) Temporary() bool { return true }
+
+var _ net.Error = errFaultTimeout{}
+
+// FaultTransport is an http.RoundTripper that wraps another RoundTripper and
+// injects faults into its requests, such as latency, timeouts, connection
+// resets, error statuses and malformed bodies. It is useful for testing how
+// code behaves under adverse network conditions.
+type FaultTransport struct {
+	transport http.RoundTripper
+	faults    Faults
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected int
+}
+
+// NewFaultTransport creates a new [FaultTransport] wrapping the given
+// transport. If transport is nil, [http.DefaultTransport] is used.
+func NewFaultTransport(transport http.RoundTripper, faults Faults) *FaultTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if faults.Status == 0 {
+		faults.Status = http.StatusServiceUnavailable
+	}
+	return &FaultTransport{
+		transport: transport,
+		faults:    faults,
+		rand:      rand.New(rand.NewPCG(faults.Seed, faults.Seed)),
+	}
+}
+
+// Injected returns the number of faults injected so far.
+func (f *FaultTransport) Injected() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.injected
+}
+
+type fault int
+
+const (
+	noFault fault = iota
+	timeoutFault
+	resetFault
+	statusFault
+	malformedFault
+)
+
+func (f *FaultTransport) roll() fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.faults.MaxFaults > 0 && f.injected >= f.faults.MaxFaults {
+		return noFault
+	}
+
+	rates := []struct {
+		fault fault
+		rate  float64
+	}{
+		{timeoutFault, f.faults.TimeoutRate},
+		{resetFault, f.faults.ResetRate},
+		{statusFault, f.faults.StatusRate},
+		{malformedFault, f.faults.MalformedRate},
+	}
+	for _, r := range rates {
+		if r.rate > 0 && f.rand.Float64() < r.rate {
+			f.injected++
+			return r.fault
+		}
+	}
+
+	return noFault
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (f *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.faults.Latency > 0 {
+		select {
+		case <-time.After(f.faults.Latency):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	switch f.roll() {
+	case timeoutFault:
+		if _, ok := req.Context().Deadline(); ok {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return nil, errFaultTimeout{}
+
+	case resetFault:
+		return nil, &net.OpError{
+			Op:  "read",
+			Net: "tcp",
+			Err: os.NewSyscallError("read", syscall.ECONNRESET),
+		}
+
+	case statusFault:
+		return newResponse(req, RoundTripResponse{Status: f.faults.Status})
+
+	case malformedFault:
+		resp, err := f.transport.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body[:len(body)/2]))
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+
+	default:
+		return f.transport.RoundTrip(req)
+	}
+}
@@ -0,0 +1,88 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files instead of comparing against them")
+
+// AssertGoldenJSON asserts that the JSON value got matches the golden file at
+// path. Both are canonicalized and indented before being compared, so key
+// order and whitespace don't matter, and a mismatch is reported as a
+// line-by-line diff.
+//
+// When the test binary is run with -update, the golden file is overwritten
+// with got instead:
+//
+//	go test -run TestAPIClient_CreateCustomKeychain -update
+func AssertGoldenJSON(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	gotJSON, err := normalizeGoldenJSON(got)
+	if err != nil {
+		t.Fatalf("httpmock.AssertGoldenJSON: invalid JSON value: %v", err)
+	}
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("httpmock.AssertGoldenJSON: failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, gotJSON, 0644); err != nil {
+			t.Fatalf("httpmock.AssertGoldenJSON: failed to update golden file: %v", err)
+		}
+		t.Logf("httpmock.AssertGoldenJSON: updated golden file %q", path)
+		return
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("httpmock.AssertGoldenJSON: failed to read golden file (run with -update to create it): %v", err)
+	}
+	wantJSON, err := normalizeGoldenJSON(b)
+	if err != nil {
+		t.Fatalf("httpmock.AssertGoldenJSON: invalid JSON in golden file %q: %v", path, err)
+	}
+
+	assert.Equal(t, string(wantJSON), string(gotJSON), "JSON does not match golden file %q (run with -update to update it)", path)
+}
+
+// MatchGoldenJSON creates a [RoundTripRequestCheck] that asserts that the
+// request body matches the golden file at path using [AssertGoldenJSON]. The
+// request body is restored afterwards.
+func MatchGoldenJSON(path string) RoundTripRequestCheck {
+	return func(t *testing.T, req *http.Request) {
+		t.Helper()
+
+		if req.Body == nil {
+			t.Fatalf("httpmock.MatchGoldenJSON: request has no body")
+		}
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			t.Fatalf("httpmock.MatchGoldenJSON: failed to read request body: %v", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		AssertGoldenJSON(t, path, body)
+	}
+}
+
+func normalizeGoldenJSON(b []byte) ([]byte, error) {
+	v := jsontext.Value(bytes.Clone(b))
+	if err := v.Canonicalize(); err != nil {
+		return nil, err
+	}
+	if err := v.Indent(jsontext.WithIndent("  ")); err != nil {
+		return nil, err
+	}
+	return append(v, '\n'), nil
+}
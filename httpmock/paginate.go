@@ -0,0 +1,269 @@
+package httpmock
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// PageOpts configures how a [Paginator] splits a fixture into pages.
+type PageOpts struct {
+	// Pages is the number of pages to split the fixture's items into. Items
+	// are distributed as evenly as possible. It defaults to 1.
+	Pages int
+	// EmptyLastPage appends an extra page with no items.
+	EmptyLastPage bool
+	// LoopCursor makes the last page point back to itself as the next page,
+	// simulating a server that never stops paginating.
+	LoopCursor bool
+	// FailPage is the 1-based page number that fails with FailStatus instead
+	// of being served. Zero means no page fails.
+	FailPage int
+	// FailStatus is the status code used for FailPage. It defaults to 500.
+	FailStatus int
+}
+
+// Paginator is an http.RoundTripper that serves a single list fixture across
+// multiple synthetic pages, rewriting the pagination metadata of each page.
+// It is useful for exercising pagination edge cases of listing methods
+// without needing one fixture per page.
+//
+// Two pagination styles are supported: JSON:API pagination using the
+// page[number] query parameter and links.next (see [NewJSONAPIPaginator]),
+// and GraphQL connections using the after variable and pageInfo (see
+// [NewGraphQLPaginator]).
+type Paginator struct {
+	t     *testing.T
+	opts  PageOpts
+	pages [][]any
+
+	// fixture is the decoded fixture, with the item list at itemsPath.
+	fixture   map[string]any
+	itemsPath []string
+	graphQL   bool
+
+	mu       sync.Mutex
+	requests []int
+}
+
+// NewJSONAPIPaginator creates a [Paginator] for a JSON:API list fixture. The
+// items in the fixture's "data" member are split into pages, while "included"
+// is served with every page. The requested page is taken from the
+// page[number] query parameter.
+func NewJSONAPIPaginator(t *testing.T, fixture []byte, opts PageOpts) *Paginator {
+	return newPaginator(t, fixture, "data", false, opts)
+}
+
+// NewGraphQLPaginator creates a [Paginator] for a GraphQL connection fixture.
+// connectionPath is the dot-separated path to the connection object within
+// the response, e.g. "data.tenants" or "data.nodes.0.accessPoints". The
+// connection's "nodes" are split into pages, and the requested page is taken
+// from the "after" variable of the request.
+func NewGraphQLPaginator(t *testing.T, fixture []byte, connectionPath string, opts PageOpts) *Paginator {
+	return newPaginator(t, fixture, connectionPath+".nodes", true, opts)
+}
+
+func newPaginator(t *testing.T, fixture []byte, itemsPath string, graphQL bool, opts PageOpts) *Paginator {
+	opts.Pages = max(opts.Pages, 1)
+	if opts.FailStatus == 0 {
+		opts.FailStatus = http.StatusInternalServerError
+	}
+
+	p := &Paginator{
+		t:         t,
+		opts:      opts,
+		itemsPath: strings.Split(itemsPath, "."),
+		graphQL:   graphQL,
+	}
+
+	if err := json.Unmarshal(fixture, &p.fixture); err != nil {
+		t.Fatalf("httpmock.Paginator: failed to unmarshal fixture: %v", err)
+	}
+
+	items, ok := lookupPath(p.fixture, p.itemsPath).([]any)
+	if !ok {
+		t.Fatalf("httpmock.Paginator: fixture has no list at %q", itemsPath)
+	}
+
+	for i := range opts.Pages {
+		start := i * len(items) / opts.Pages
+		end := (i + 1) * len(items) / opts.Pages
+		p.pages = append(p.pages, items[start:end])
+	}
+	if opts.EmptyLastPage {
+		p.pages = append(p.pages, []any{})
+	}
+
+	return p
+}
+
+// Requests returns the 1-based page numbers requested so far, in order.
+func (p *Paginator) Requests() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]int(nil), p.requests...)
+}
+
+// PageCount returns the number of pages served by the paginator, including
+// the empty last page if enabled.
+func (p *Paginator) PageCount() int {
+	return len(p.pages)
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (p *Paginator) RoundTrip(req *http.Request) (*http.Response, error) {
+	page, err := p.requestedPage(req)
+	if err != nil {
+		p.t.Errorf("httpmock.Paginator: %v", err)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.requests = append(p.requests, page)
+	p.mu.Unlock()
+
+	if page < 1 || page > len(p.pages) {
+		err := fmt.Errorf("page %d out of range (1-%d)", page, len(p.pages))
+		p.t.Errorf("httpmock.Paginator: %v", err)
+		return nil, err
+	}
+
+	if page == p.opts.FailPage {
+		return newResponse(req, RoundTripResponse{Status: p.opts.FailStatus})
+	}
+
+	body, err := json.Marshal(p.render(page))
+	if err != nil {
+		return nil, err
+	}
+
+	return newResponse(req, RoundTripResponse{
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    body,
+	})
+}
+
+func (p *Paginator) requestedPage(req *http.Request) (int, error) {
+	if !p.graphQL {
+		number := req.URL.Query().Get("page[number]")
+		if number == "" {
+			return 1, nil
+		}
+		return strconv.Atoi(number)
+	}
+
+	var body struct {
+		Variables struct {
+			After *string `json:"after"`
+		} `json:"variables"`
+	}
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return 0, err
+		}
+		if err := json.Unmarshal(b, &body); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal GraphQL request: %w", err)
+		}
+	}
+	if body.Variables.After == nil {
+		return 1, nil
+	}
+	cursor, ok := strings.CutPrefix(*body.Variables.After, "page-")
+	if !ok {
+		return 0, fmt.Errorf("unknown cursor %q", *body.Variables.After)
+	}
+	return strconv.Atoi(cursor)
+}
+
+// render returns a copy of the fixture with only the items of the given page
+// and rewritten pagination metadata.
+func (p *Paginator) render(page int) map[string]any {
+	next := page + 1
+	hasNext := next <= len(p.pages)
+	if p.opts.LoopCursor && !hasNext {
+		next, hasNext = page, true
+	}
+
+	// Only the maps along the items path are copied, since nothing else is
+	// modified.
+	root := clonePath(p.fixture, p.itemsPath[:len(p.itemsPath)-1])
+	parent, _ := lookupPath(root, p.itemsPath[:len(p.itemsPath)-1]).(map[string]any)
+	parent[p.itemsPath[len(p.itemsPath)-1]] = p.pages[page-1]
+
+	if p.graphQL {
+		pageInfo := map[string]any{"hasNextPage": hasNext, "endCursor": nil}
+		if hasNext {
+			pageInfo["endCursor"] = "page-" + strconv.Itoa(next)
+		}
+		parent["pageInfo"] = pageInfo
+	} else {
+		links := map[string]any{"next": nil}
+		if hasNext {
+			links["next"] = "https://api.butterflymx.com/v3/page?page%5Bnumber%5D=" + strconv.Itoa(next)
+		}
+		root["links"] = links
+	}
+
+	return root
+}
+
+func lookupPath(v any, path []string) any {
+	for _, segment := range path {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[segment]
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// clonePath shallow-copies every map and slice along the given path, so that
+// the container at the end of the path can be modified without affecting v.
+func clonePath(v map[string]any, path []string) map[string]any {
+	root := maps.Clone(v)
+
+	var node any = root
+	for _, segment := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child := cloneShallow(n[segment])
+			n[segment] = child
+			node = child
+		case []any:
+			i, _ := strconv.Atoi(segment)
+			child := cloneShallow(n[i])
+			n[i] = child
+			node = child
+		}
+	}
+
+	return root
+}
+
+func cloneShallow(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return maps.Clone(v)
+	case []any:
+		return slices.Clone(v)
+	default:
+		return v
+	}
+}
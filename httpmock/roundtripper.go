@@ -1,12 +1,25 @@
-// Package httpmock provides a mock HTTP RoundTripper for testing purposes.
+// Package httpmock provides mock HTTP RoundTrippers for testing code that uses
+// the ButterflyMX API client.
+//
+// Three flavors of mock transports are provided:
+//
+//   - [NewSequence] serves a fixed sequence of responses without checking the
+//     requests.
+//   - [NewRoundTripper] serves a fixed sequence of responses and checks each
+//     request using a [RoundTripRequestCheck].
+//   - [NewRouter] serves responses based on the request method and path, in
+//     any order.
+//
+// In addition, [FaultTransport] wraps any transport to inject network faults.
+// [MatchGoldenJSON] compares request bodies against golden files, which can
+// be updated by running the tests with -update.
 package httpmock
 
 import (
-	"bytes"
 	"encoding/json/v2"
 	"errors"
-	"io"
 	"net/http"
+	"sync"
 	"testing"
 )
 
@@ -52,9 +65,11 @@ type RoundTripResponse struct {
 }
 
 // RoundTripper is a simplistic http.RoundTripper that serves a pre-defined
-// sequence of responses.
+// sequence of responses. It is safe for concurrent use, although concurrent
+// requests are served in an unspecified order.
 type RoundTripper struct {
 	t     *testing.T
+	mu    sync.Mutex
 	resps []RoundTrip
 	index int
 }
@@ -68,38 +83,32 @@ func NewRoundTripper(t *testing.T, resps []RoundTrip) *RoundTripper {
 	}
 }
 
+// NewSequence creates a new [RoundTripper] that serves the given responses in
+// order without checking the requests.
+func NewSequence(t *testing.T, resps ...RoundTripResponse) *RoundTripper {
+	rts := make([]RoundTrip, len(resps))
+	for i, resp := range resps {
+		rts[i] = RoundTrip{Response: resp}
+	}
+	return NewRoundTripper(t, rts)
+}
+
 // RoundTrip implements the http.RoundTripper interface.
 func (m *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
 	if m.index >= len(m.resps) {
-		m.t.Errorf("MockRoundTripper: no more responses configured (index %d out of %d)", m.index, len(m.resps))
-		return nil, errors.New("no more responses configured in MockRoundTripper")
+		m.mu.Unlock()
+		m.t.Errorf("httpmock.RoundTripper: no more responses configured (index %d out of %d)", m.index, len(m.resps))
+		return nil, errors.New("no more responses configured in httpmock.RoundTripper")
 	}
 
 	rt := m.resps[m.index]
 	m.index++
+	m.mu.Unlock()
 
 	if rt.RequestCheck != nil {
 		rt.RequestCheck(m.t, req)
 	}
 
-	if rt.Response.Error != nil {
-		return nil, rt.Response.Error
-	}
-
-	statusCode := rt.Response.Status
-	if statusCode == 0 {
-		statusCode = http.StatusOK
-	}
-
-	header := make(http.Header, len(rt.Response.Headers))
-	for k, v := range rt.Response.Headers {
-		header.Add(k, v)
-	}
-
-	return &http.Response{
-		StatusCode: statusCode,
-		Body:       io.NopCloser(bytes.NewReader(rt.Response.Body)),
-		Header:     header,
-		Request:    req,
-	}, nil
+	return newResponse(req, rt.Response)
 }
@@ -0,0 +1,147 @@
+package httpmock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sync"
+	"testing"
+)
+
+// Unlimited can be used as [Route.Times] to serve a route any number of times.
+const Unlimited = -1
+
+// Route defines a response that is served for all requests matching the
+// route's method and path pattern, in any order.
+type Route struct {
+	// Method is the HTTP method to match. An empty method matches any method.
+	Method string
+	// Path is the URL path pattern to match, using [path.Match] syntax, e.g.
+	// "/v3/keychains/*".
+	Path string
+	// Times is the number of times the route is expected to be served. Zero
+	// means once; use [Unlimited] for a route that may be served any number of
+	// times, but at least once.
+	Times int
+
+	RequestCheck RoundTripRequestCheck
+	Response     RoundTripResponse
+}
+
+func (r *Route) matches(req *http.Request) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+	ok, err := path.Match(r.Path, req.URL.Path)
+	return err == nil && ok
+}
+
+func (r *Route) String() string {
+	method := r.Method
+	if method == "" {
+		method = "*"
+	}
+	return method + " " + r.Path
+}
+
+// Router is an http.RoundTripper that serves responses based on
+// [Route]s instead of a strict sequence. Routes are tried in order, and the
+// first matching route that hasn't been used up serves the request.
+//
+// When the test finishes, the Router fails the test if any route was not
+// served as many times as expected.
+type Router struct {
+	t      *testing.T
+	mu     sync.Mutex
+	routes []Route
+	hits   []int
+}
+
+// NewRouter creates a new [Router] serving the given routes.
+func NewRouter(t *testing.T, routes ...Route) *Router {
+	r := &Router{
+		t:      t,
+		routes: routes,
+		hits:   make([]int, len(routes)),
+	}
+	t.Cleanup(r.assertDone)
+	return r
+}
+
+func (r *Router) assertDone() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, route := range r.routes {
+		switch {
+		case route.Times == Unlimited && r.hits[i] == 0:
+			r.t.Errorf("httpmock.Router: route %s was never hit", &route)
+		case route.Times != Unlimited && r.hits[i] < max(route.Times, 1):
+			r.t.Errorf("httpmock.Router: route %s was hit %d of %d times", &route, r.hits[i], max(route.Times, 1))
+		}
+	}
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Router) RoundTrip(req *http.Request) (*http.Response, error) {
+	route, err := r.match(req)
+	if err != nil {
+		r.t.Error(err)
+		return nil, err
+	}
+
+	if route.RequestCheck != nil {
+		route.RequestCheck(r.t, req)
+	}
+
+	return newResponse(req, route.Response)
+}
+
+func (r *Router) match(req *http.Request) (*Route, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var exhausted bool
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.matches(req) {
+			continue
+		}
+		if route.Times != Unlimited && r.hits[i] >= max(route.Times, 1) {
+			exhausted = true
+			continue
+		}
+		r.hits[i]++
+		return route, nil
+	}
+
+	if exhausted {
+		return nil, fmt.Errorf("httpmock.Router: all routes matching %s %s were used up", req.Method, req.URL.Path)
+	}
+	return nil, fmt.Errorf("httpmock.Router: no route matches %s %s", req.Method, req.URL.Path)
+}
+
+func newResponse(req *http.Request, r RoundTripResponse) (*http.Response, error) {
+	if r.Error != nil {
+		return nil, r.Error
+	}
+
+	statusCode := r.Status
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	header := make(http.Header, len(r.Headers))
+	for k, v := range r.Headers {
+		header.Add(k, v)
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(bytes.NewReader(r.Body)),
+		Header:     header,
+		Request:    req,
+	}, nil
+}
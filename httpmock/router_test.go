@@ -0,0 +1,56 @@
+package httpmock
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter(t,
+		Route{
+			Method:   http.MethodGet,
+			Path:     "/v3/keychains/*",
+			Times:    2,
+			Response: RoundTripResponse{Body: []byte(`keychain`)},
+		},
+		Route{
+			Method:   http.MethodPost,
+			Path:     "/denizen/v1/graphql",
+			Times:    Unlimited,
+			Response: RoundTripResponse{Body: []byte(`graphql`)},
+		},
+		Route{
+			Path:     "/v3/keychains/*",
+			Response: RoundTripResponse{Status: http.StatusNotFound},
+		},
+	)
+	client := &http.Client{Transport: router}
+
+	get := func(method, url string) (int, string) {
+		req, err := http.NewRequest(method, url, nil)
+		assert.NoError(t, err)
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+
+	// Routes can be hit in any order.
+	_, body := get(http.MethodPost, "https://example.com/denizen/v1/graphql")
+	assert.Equal(t, "graphql", body)
+	_, body = get(http.MethodGet, "https://example.com/v3/keychains/1")
+	assert.Equal(t, "keychain", body)
+	_, body = get(http.MethodPost, "https://example.com/denizen/v1/graphql")
+	assert.Equal(t, "graphql", body)
+	_, body = get(http.MethodGet, "https://example.com/v3/keychains/2")
+	assert.Equal(t, "keychain", body)
+
+	// Once the first route is used up, the next matching route is served.
+	status, _ := get(http.MethodGet, "https://example.com/v3/keychains/3")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -52,11 +52,49 @@ var (
 	_ encoding.TextUnmarshaler = (*TaggedID)(nil)
 )
 
+// Known types of a [TaggedID].
+const (
+	TaggedTypeTenant      = "tenant"
+	TaggedTypeAccessPoint = "access_point"
+	TaggedTypeBuilding    = "building"
+	TaggedTypeUnit        = "unit"
+)
+
 // NewTaggedID creates a new TaggedID with the "prod" prefix.
 func NewTaggedID(typ string, id ID) TaggedID {
 	return TaggedID{"prod", typ, id}
 }
 
+// TenantTaggedID creates a new TaggedID of a tenant.
+func TenantTaggedID(id ID) TaggedID {
+	return NewTaggedID(TaggedTypeTenant, id)
+}
+
+// AccessPointTaggedID creates a new TaggedID of an access point.
+func AccessPointTaggedID(id ID) TaggedID {
+	return NewTaggedID(TaggedTypeAccessPoint, id)
+}
+
+// BuildingTaggedID creates a new TaggedID of a building.
+func BuildingTaggedID(id ID) TaggedID {
+	return NewTaggedID(TaggedTypeBuilding, id)
+}
+
+// UnitTaggedID creates a new TaggedID of a unit.
+func UnitTaggedID(id ID) TaggedID {
+	return NewTaggedID(TaggedTypeUnit, id)
+}
+
+// AsType returns the numeric ID of t if it has the given type, such as
+// [TaggedTypeTenant]. Otherwise, it returns an error wrapping
+// [ErrInvalidTaggedID].
+func (t TaggedID) AsType(typ string) (ID, error) {
+	if t.Type != typ {
+		return 0, fmt.Errorf("%w: %s is not of type %q", ErrInvalidTaggedID, t, typ)
+	}
+	return t.Number, nil
+}
+
 // String returns the string representation of the TaggedID.
 func (t TaggedID) String() string {
 	return fmt.Sprintf("%s-%s-%d", t.Prefix, t.Type, t.Number)
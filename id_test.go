@@ -0,0 +1,25 @@
+package butterflymx
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestTaggedID_AsType(t *testing.T) {
+	assert.Equal(t, "prod-tenant-12345", TenantTaggedID(12345).String())
+	assert.Equal(t, "prod-access_point-50001", AccessPointTaggedID(50001).String())
+
+	id, err := TenantTaggedID(12345).AsType(TaggedTypeTenant)
+	assert.NoError(t, err)
+	assert.Equal(t, ID(12345), id)
+
+	_, err = AccessPointTaggedID(50001).AsType(TaggedTypeTenant)
+	assert.IsError(t, err, ErrInvalidTaggedID)
+
+	// No request should be made for a mistyped tenant ID.
+	client := newTestAPIClient(t, httpmock.NewRoundTripper(t, nil))
+	_, err = CollectResults(client.TenantAccessPoints(t.Context(), UnitTaggedID(40001)))
+	assert.IsError(t, err, ErrInvalidTaggedID)
+}
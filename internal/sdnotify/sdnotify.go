@@ -0,0 +1,60 @@
+// Package sdnotify implements the systemd service notification protocol.
+//
+// See sd_notify(3) for details on the protocol.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Common notification states.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends the given state to the service manager. It returns false if
+// notifications are not supported, i.e. $NOTIFY_SOCKET is not set.
+func Notify(state string) (bool, error) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return false, nil
+	}
+
+	// Abstract namespace sockets are written with a leading @.
+	if socketPath[0] == '@' {
+		socketPath = "\x00" + socketPath[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// WatchdogInterval returns the interval at which the service manager expects
+// [Watchdog] notifications. It returns 0 if the watchdog is not enabled for
+// this process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
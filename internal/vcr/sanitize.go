@@ -0,0 +1,316 @@
+package vcr
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted is the placeholder that replaces sensitive values.
+const Redacted = "<REDACTED>"
+
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+}
+
+var sensitiveNames = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"password":      true,
+	"client_secret": true,
+}
+
+// sensitiveParams are only sensitive in URL queries, since they are commonly
+// used as harmless JSON field names.
+var sensitiveParams = map[string]bool{
+	"code":          true,
+	"state":         true,
+	"code_verifier": true,
+}
+
+var pinNames = map[string]bool{
+	"pin":      true,
+	"pinCode":  true,
+	"pin_code": true,
+}
+
+var phoneNames = map[string]bool{
+	"phone":        true,
+	"phone_number": true,
+	"phoneNumber":  true,
+	"deliver_to":   true,
+}
+
+var firstNameNames = map[string]bool{
+	"first_name": true,
+	"firstName":  true,
+}
+
+var lastNameNames = map[string]bool{
+	"last_name": true,
+	"lastName":  true,
+}
+
+var signatureParams = []string{
+	"signature",
+	"x-amz-signature",
+	"x-amz-credential",
+	"x-amz-security-token",
+}
+
+// Sanitizer sanitizes interactions. Besides credentials, it replaces personal
+// information such as emails, names, building names and unit labels with
+// fakes. The same value is always replaced with the same fake by a single
+// Sanitizer, so that values referring to each other across interactions still
+// match after sanitizing.
+//
+// A Sanitizer is not safe for concurrent use.
+type Sanitizer struct {
+	fakes map[fakeKind]map[string]string
+}
+
+type fakeKind uint8
+
+const (
+	fakeEmail fakeKind = iota
+	fakePhone
+	fakeName
+	fakeFirstName
+	fakeLastName
+	fakeBuilding
+	fakeUnit
+)
+
+var fakeFormats = map[fakeKind]string{
+	fakeEmail:     "user%d@example.com",
+	fakePhone:     "+1555555%04d",
+	fakeName:      "Name %d",
+	fakeFirstName: "First%d",
+	fakeLastName:  "Last%d",
+	fakeBuilding:  "Building %d",
+	fakeUnit:      "Unit %d",
+}
+
+// NewSanitizer creates a new [Sanitizer].
+func NewSanitizer() *Sanitizer {
+	return &Sanitizer{fakes: make(map[fakeKind]map[string]string)}
+}
+
+func (s *Sanitizer) fake(kind fakeKind, value string) string {
+	fakes := s.fakes[kind]
+	if fakes == nil {
+		fakes = make(map[string]string)
+		s.fakes[kind] = fakes
+	}
+	fake, ok := fakes[value]
+	if !ok {
+		fake = fmt.Sprintf(fakeFormats[kind], len(fakes)+1)
+		fakes[value] = fake
+	}
+	return fake
+}
+
+// SanitizeInteraction returns a copy of the interaction with all sensitive
+// values replaced using a new [Sanitizer].
+func SanitizeInteraction(in Interaction) (Interaction, error) {
+	return NewSanitizer().Interaction(in)
+}
+
+// Interaction returns a copy of the interaction with all sensitive values
+// replaced.
+func (s *Sanitizer) Interaction(in Interaction) (Interaction, error) {
+	u, err := url.Parse(in.Request.URL)
+	if err != nil {
+		return in, fmt.Errorf("invalid request URL: %w", err)
+	}
+	in.Request.URL = SanitizeURL(u).String()
+	in.Request.Header = SanitizeHeader(in.Request.Header)
+	in.Response.Header = SanitizeHeader(in.Response.Header)
+
+	if in.Request.Body, err = s.body(in.Request.Body); err != nil {
+		return in, fmt.Errorf("request body: %w", err)
+	}
+	if in.Response.Body, err = s.body(in.Response.Body); err != nil {
+		return in, fmt.Errorf("response body: %w", err)
+	}
+
+	return in, nil
+}
+
+func (s *Sanitizer) body(b Body) (Body, error) {
+	if b.JSON == nil {
+		return b, nil
+	}
+	sanitized, err := s.JSON(b.JSON)
+	if err != nil {
+		return b, err
+	}
+	return Body{JSON: sanitized}, nil
+}
+
+// SanitizeHeader returns a copy of the header with credentials redacted.
+func SanitizeHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	h = h.Clone()
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, Redacted)
+		}
+	}
+	return h
+}
+
+// SanitizeURL returns a copy of the URL with credentials and signatures
+// redacted from its query. The query is always re-encoded so that sanitized
+// URLs can be compared against each other.
+func SanitizeURL(u *url.URL) *url.URL {
+	u2 := *u
+	query := u2.Query()
+	for name := range query {
+		if isSensitiveName(name) || sensitiveParams[strings.ToLower(name)] || isSignatureParam(name) {
+			query.Set(name, Redacted)
+		}
+	}
+	u2.RawQuery = query.Encode()
+	return &u2
+}
+
+// SanitizeJSON returns a copy of the JSON value with sensitive string values
+// replaced using a new [Sanitizer].
+func SanitizeJSON(v jsontext.Value) (jsontext.Value, error) {
+	return NewSanitizer().JSON(v)
+}
+
+// JSON returns a copy of the JSON value with sensitive string values replaced.
+// Tokens and other credentials, as well as all URLs stored in *_url fields or
+// carrying a signature, become [Redacted]. PIN codes are replaced with zeroes
+// of the same length so that they still parse as a PIN code. Emails, phone
+// numbers, names and unit labels are replaced with fakes, e.g.
+// user1@example.com or "Name 1". The order of object members and the
+// formatting of numbers are preserved.
+func (s *Sanitizer) JSON(v jsontext.Value) (jsontext.Value, error) {
+	dec := jsontext.NewDecoder(bytes.NewReader(v))
+
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+
+	// parents holds the names of the members containing the current value.
+	var parents []string
+	var name string
+	for {
+		tok, err := dec.ReadToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		kind, length := dec.StackIndex(dec.StackDepth())
+		isName := kind == '{' && length%2 == 1
+
+		switch {
+		case isName:
+			name = tok.String()
+		case tok.Kind() == '{' || tok.Kind() == '[':
+			parents = append(parents, name)
+			name = ""
+		case tok.Kind() == '}' || tok.Kind() == ']':
+			parents = parents[:len(parents)-1]
+			name = ""
+		case tok.Kind() == '"':
+			var parent string
+			if len(parents) > 0 {
+				parent = parents[len(parents)-1]
+			}
+			tok = jsontext.String(s.sanitizeString(parent, name, tok.String()))
+			name = ""
+		default:
+			name = ""
+		}
+
+		if err := enc.WriteToken(tok); err != nil {
+			return nil, err
+		}
+	}
+
+	return jsontext.Value(bytes.TrimSpace(buf.Bytes())), nil
+}
+
+// sanitizeString sanitizes the string value of the member with the given name
+// inside the member named parent. Strings within arrays have no name.
+func (s *Sanitizer) sanitizeString(parent, name, value string) string {
+	switch {
+	case value == "":
+		return value
+	case pinNames[name]:
+		return strings.Repeat("0", len(value))
+	case isSensitiveName(name):
+		return Redacted
+	case strings.HasSuffix(name, "_url") || strings.HasSuffix(name, "Url") || strings.HasSuffix(name, "URL"):
+		return Redacted
+	case hasSignature(value):
+		return Redacted
+	case isEmail(value):
+		return s.fake(fakeEmail, value)
+	case phoneNames[name]:
+		return s.fake(fakePhone, value)
+	case name == "email":
+		return s.fake(fakeEmail, value)
+	case firstNameNames[name]:
+		return s.fake(fakeFirstName, value)
+	case lastNameNames[name]:
+		return s.fake(fakeLastName, value)
+	case name == "name" && parent == "building":
+		return s.fake(fakeBuilding, value)
+	case name == "name":
+		return s.fake(fakeName, value)
+	case name == "label":
+		return s.fake(fakeUnit, value)
+	default:
+		return value
+	}
+}
+
+// isEmail returns true if the value looks like an email address.
+func isEmail(value string) bool {
+	local, domain, ok := strings.Cut(value, "@")
+	return ok && local != "" && strings.Contains(domain, ".") && !strings.ContainsAny(value, " /:")
+}
+
+func isSensitiveName(name string) bool {
+	return sensitiveNames[strings.ToLower(name)]
+}
+
+func isSignatureParam(name string) bool {
+	for _, param := range signatureParams {
+		if strings.EqualFold(name, param) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSignature(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.RawQuery == "" {
+		return false
+	}
+	for name := range u.Query() {
+		if isSignatureParam(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+// Package vcr records real HTTP interactions into cassette files and replays
+// them in tests.
+//
+// Cassettes are sanitized before being written: credentials, PIN codes, signed
+// URLs and personal information are replaced so that cassettes can be
+// committed alongside the tests that use them.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// RecordEnv is the environment variable that switches [New] into recording
+// mode when set to a non-empty value.
+const RecordEnv = "BUTTERFLYMX_VCR_RECORD"
+
+// Cassette is a recorded sequence of HTTP interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded HTTP request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitzero"`
+}
+
+// Response is a recorded HTTP response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitzero"`
+}
+
+// Body is a recorded HTTP body. JSON bodies are stored inline so that
+// cassettes stay readable, while all other bodies are stored as text.
+type Body struct {
+	JSON jsontext.Value `json:"json,omitzero"`
+	Text string         `json:"text,omitzero"`
+}
+
+func newBody(b []byte) Body {
+	if len(b) == 0 {
+		return Body{}
+	}
+	if jsontext.Value(b).IsValid() {
+		return Body{JSON: jsontext.Value(bytes.Clone(b))}
+	}
+	return Body{Text: string(b)}
+}
+
+// Bytes returns the raw body.
+func (b Body) Bytes() []byte {
+	if b.JSON != nil {
+		return b.JSON
+	}
+	return []byte(b.Text)
+}
+
+// New returns a transport for use in tests. If [RecordEnv] is set, real
+// requests are made and recorded into the cassette at path once the test
+// finishes. Otherwise, the cassette at path is replayed.
+func New(t testing.TB, path string) http.RoundTripper {
+	if os.Getenv(RecordEnv) == "" {
+		return Load(t, path)
+	}
+
+	r := NewRecorder(http.DefaultTransport)
+	t.Cleanup(func() {
+		if err := r.Save(path); err != nil {
+			t.Errorf("vcr: failed to save cassette: %v", err)
+		}
+	})
+	return r
+}
+
+// Recorder is an [http.RoundTripper] that records all interactions made
+// through it.
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a new [Recorder] that makes requests using the given
+// transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{transport: transport}
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("vcr: failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("vcr: failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   newBody(reqBody),
+		},
+		Response: Response{
+			Status: resp.StatusCode,
+			Header: resp.Header.Clone(),
+			Body:   newBody(respBody),
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Cassette returns a sanitized copy of everything recorded so far.
+func (r *Recorder) Cassette() (*Cassette, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cassette := &Cassette{
+		Interactions: make([]Interaction, len(r.cassette.Interactions)),
+	}
+	sanitizer := NewSanitizer()
+	for i, in := range r.cassette.Interactions {
+		sanitized, err := sanitizer.Interaction(in)
+		if err != nil {
+			return nil, fmt.Errorf("interaction %d: %w", i, err)
+		}
+		cassette.Interactions[i] = sanitized
+	}
+	return cassette, nil
+}
+
+// Save writes the sanitized cassette to the given path.
+func (r *Recorder) Save(path string) error {
+	cassette, err := r.Cassette()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cassette, json.Deterministic(true), jsontext.WithIndent("  "))
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Replayer is an [http.RoundTripper] that serves responses from a cassette.
+// Requests are matched by method and URL against interactions that haven't
+// been replayed yet, in recorded order.
+type Replayer struct {
+	t        testing.TB
+	cassette *Cassette
+
+	mu   sync.Mutex
+	used []bool
+}
+
+// Load loads the cassette at path into a new [Replayer]. The test fails
+// immediately if the cassette cannot be read.
+func Load(t testing.TB, path string) *Replayer {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("vcr: failed to read cassette (record it with %s=1): %v", RecordEnv, err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(b, &cassette); err != nil {
+		t.Fatalf("vcr: failed to parse cassette %q: %v", path, err)
+	}
+
+	return NewReplayer(t, &cassette)
+}
+
+// NewReplayer creates a new [Replayer] for the given cassette.
+func NewReplayer(t testing.TB, cassette *Cassette) *Replayer {
+	return &Replayer{
+		t:        t,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	url := SanitizeURL(req.URL).String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Request.Method != req.Method || in.Request.URL != url {
+			continue
+		}
+		r.used[i] = true
+
+		return &http.Response{
+			StatusCode: in.Response.Status,
+			Header:     in.Response.Header.Clone(),
+			Body:       io.NopCloser(bytes.NewReader(in.Response.Body.Bytes())),
+			Request:    req,
+		}, nil
+	}
+
+	r.t.Errorf("vcr: no recorded interaction for %s %s", req.Method, url)
+	return nil, errors.New("vcr: no recorded interaction")
+}
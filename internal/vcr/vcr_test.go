@@ -0,0 +1,72 @@
+package vcr
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"data":{"id":"1","attributes":{"pin":"123456","token":"secret","thumb_url":"https://x/y.jpg","name":"Front Door","count":10}}}`)
+	}))
+	defer server.Close()
+
+	recorder := NewRecorder(server.Client().Transport)
+	client := &http.Client{Transport: recorder}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v3/things?access_token=secret&page=1", strings.NewReader(`{"password":"hunter2"}`))
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"pin":"123456"`, "caller must see the real response")
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NoError(t, recorder.Save(path))
+
+	cassette := Load(t, path).cassette
+	assert.Equal(t, 1, len(cassette.Interactions))
+
+	in := cassette.Interactions[0]
+	assert.NoError(t, in.Request.Body.JSON.Compact())
+	assert.NoError(t, in.Response.Body.JSON.Compact())
+	assert.Equal(t, Redacted, in.Request.Header.Get("Authorization"))
+	assert.Contains(t, in.Request.URL, "access_token=%3CREDACTED%3E")
+	assert.Equal(t, `{"password":"<REDACTED>"}`, string(in.Request.Body.JSON))
+	assert.Equal(t,
+		`{"data":{"id":"1","attributes":{"pin":"000000","token":"<REDACTED>","thumb_url":"<REDACTED>","name":"Name 1","count":10}}}`,
+		string(in.Response.Body.JSON))
+
+	// Replaying matches on the sanitized URL.
+	replay := &http.Client{Transport: Load(t, path)}
+	resp, err = replay.Post(server.URL+"/v3/things?page=1&access_token=other", "application/json", nil)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"000000"`)
+}
+
+func TestSanitizeJSON(t *testing.T) {
+	sanitizer := NewSanitizer()
+
+	tenant, err := sanitizer.JSON([]byte(`{"firstName":"Jane","lastName":"Doe","name":"Jane Doe","unit":{"label":"Apt 4B"},"building":{"name":"Hunter Capital"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"firstName":"First1","lastName":"Last1","name":"Name 1","unit":{"label":"Unit 1"},"building":{"name":"Building 1"}}`,
+		string(tenant))
+
+	// The same values are replaced with the same fakes across bodies.
+	recipients, err := sanitizer.JSON([]byte(`{"recipients":[{"name":"Jane Doe","deliver_to":"jane@doe.net"},{"name":"John","deliver_to":"5551234567"}],"emails":["jane@doe.net"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t,
+		`{"recipients":[{"name":"Name 1","deliver_to":"user1@example.com"},{"name":"Name 2","deliver_to":"+15555550001"}],"emails":["user1@example.com"]}`,
+		string(recipients))
+}
@@ -0,0 +1,89 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"slices"
+	"time"
+)
+
+// KeychainTemplate is a reusable schedule for keychains, such as "weekend
+// guest" or "weekday cleaner from 9 to 12", that is expanded into
+// [CustomKeychainArgs] for concrete dates using [KeychainTemplate.Expand].
+//
+// The API only allows creating custom keychains, which have a single start
+// and end time, so a template expands into one keychain per window of access.
+type KeychainTemplate struct {
+	// Name is the name of the created keychains.
+	Name string
+	// Weekdays are the days of the week on which access is granted. If
+	// empty, access is granted every day.
+	Weekdays []Weekday
+	// From and Until are the times of day, as offsets from midnight, between
+	// which access is granted. Until defaults to the end of the day. Windows
+	// of whole days on consecutive days are merged into a single keychain.
+	From, Until time.Duration
+	// AllowUnitAccess is passed on to [CustomKeychainArgs.AllowUnitAccess].
+	AllowUnitAccess bool
+}
+
+// Common keychain templates.
+var (
+	// WeekendGuestTemplate grants access from Saturday to the end of Sunday.
+	WeekendGuestTemplate = KeychainTemplate{
+		Name:     "Weekend guest",
+		Weekdays: []Weekday{Saturday, Sunday},
+	}
+	// WeekdayCleanerTemplate grants access from 9:00 to 12:00 on weekdays.
+	WeekdayCleanerTemplate = KeychainTemplate{
+		Name:     "Cleaner",
+		Weekdays: []Weekday{Monday, Tuesday, Wednesday, Thursday, Friday},
+		From:     9 * time.Hour,
+		Until:    12 * time.Hour,
+	}
+)
+
+// Expand returns the keychains granting the template's access for the given
+// number of days, starting on the date of start in loc, which should be the
+// building's time zone. Times of day are wall clock times in loc, so they stay
+// the same across daylight saving time changes.
+func (t KeychainTemplate) Expand(start time.Time, days int, loc *time.Location) []CustomKeychainArgs {
+	start = start.In(loc)
+	year, month, day := start.Date()
+
+	var args []CustomKeychainArgs
+	for i := range days {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, loc)
+		if len(t.Weekdays) > 0 && !slices.ContainsFunc(t.Weekdays, func(w Weekday) bool {
+			return w.ToTimeWeekday() == date.Weekday()
+		}) {
+			continue
+		}
+
+		startsAt := wallClock(date, t.From)
+		endsAt := time.Date(year, month, day+i+1, 0, 0, 0, 0, loc)
+		if t.Until > 0 {
+			endsAt = wallClock(date, t.Until)
+		}
+
+		if n := len(args); n > 0 && args[n-1].EndsAt.Equal(startsAt) {
+			args[n-1].EndsAt = endsAt
+			continue
+		}
+
+		args = append(args, CustomKeychainArgs{
+			Name:            t.Name,
+			StartsAt:        startsAt,
+			EndsAt:          endsAt,
+			AllowUnitAccess: t.AllowUnitAccess,
+		})
+	}
+	return args
+}
+
+// wallClock returns the time that is offset after midnight of date on the
+// wall clock of date's location.
+func wallClock(date time.Time, offset time.Duration) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, 0, 0, int(offset), date.Location())
+}
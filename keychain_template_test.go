@@ -0,0 +1,40 @@
+package butterflymx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestKeychainTemplate_Expand(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// Friday, 2025-03-07. Daylight saving time starts on Sunday, 2025-03-09.
+	start := time.Date(2025, 3, 7, 15, 0, 0, 0, loc)
+
+	t.Run("weekend guest", func(t *testing.T) {
+		args := WeekendGuestTemplate.Expand(start, 7, loc)
+		assert.Equal(t, []CustomKeychainArgs{{
+			Name:     "Weekend guest",
+			StartsAt: time.Date(2025, 3, 8, 0, 0, 0, 0, loc),
+			EndsAt:   time.Date(2025, 3, 10, 0, 0, 0, 0, loc),
+		}}, args)
+	})
+
+	t.Run("weekday cleaner", func(t *testing.T) {
+		args := WeekdayCleanerTemplate.Expand(start, 4, loc)
+		assert.Equal(t, 2, len(args))
+		assert.Equal(t, time.Date(2025, 3, 7, 9, 0, 0, 0, loc), args[0].StartsAt)
+		assert.Equal(t, time.Date(2025, 3, 7, 12, 0, 0, 0, loc), args[0].EndsAt)
+		assert.Equal(t, time.Date(2025, 3, 10, 9, 0, 0, 0, loc), args[1].StartsAt)
+		assert.Equal(t, "09:00", args[1].StartsAt.Format("15:04"), "wall clock time should survive DST")
+		assert.Equal(t, 3*time.Hour, args[1].EndsAt.Sub(args[1].StartsAt))
+	})
+
+	t.Run("every day", func(t *testing.T) {
+		args := KeychainTemplate{Name: "Dog walker", From: 17 * time.Hour, Until: 18 * time.Hour}.Expand(start, 3, time.UTC)
+		assert.Equal(t, 3, len(args))
+	})
+}
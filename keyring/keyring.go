@@ -0,0 +1,154 @@
+//go:build goexperiment.jsonv2
+
+// Package keyring provides a [butterflymx.TokenStore] backed by the OS
+// keyring, so that desktop users don't have to keep bearer tokens in
+// plaintext files.
+//
+// To avoid cgo and extra dependencies, the keyring is accessed through the
+// command-line tools that ship with the OS: security(1) on macOS and
+// secret-tool(1) from libsecret on Linux and other Unix systems. Other
+// platforms are not supported.
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"libdb.so/go-butterflymx"
+)
+
+// ErrUnsupported is returned on platforms without a supported keyring.
+var ErrUnsupported = errors.New("keyring: unsupported platform " + runtime.GOOS)
+
+// Store is a [butterflymx.TokenStore] that keeps tokens in the OS keyring.
+// Each token is stored as a separate secret identified by Service, Account and
+// the key of the token.
+type Store struct {
+	// Service identifies the application, e.g. "butterflymx-exporter".
+	Service string
+	// Account identifies the ButterflyMX account, e.g. its email address.
+	Account string
+
+	// run runs a command with the given stdin and returns its stdout. It is
+	// replaced in tests.
+	run func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error)
+}
+
+var _ butterflymx.TokenStore = (*Store)(nil)
+
+// New creates a new [Store].
+func New(service, account string) *Store {
+	return &Store{Service: service, Account: account}
+}
+
+// Load implements [butterflymx.TokenStore].
+func (s *Store) Load(ctx context.Context, key string) ([]byte, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := s.exec(ctx, nil, "security", "find-generic-password", "-s", s.Service, "-a", s.account(key), "-w")
+		if err != nil {
+			// security exits with 44 if the item doesn't exist.
+			if exitCode(err) == 44 {
+				return nil, butterflymx.ErrTokenNotFound
+			}
+			return nil, err
+		}
+		return bytes.TrimSuffix(out, []byte("\n")), nil
+	case "windows", "plan9", "js", "wasip1":
+		return nil, ErrUnsupported
+	default:
+		out, err := s.exec(ctx, nil, "secret-tool", "lookup", "service", s.Service, "account", s.account(key))
+		if err != nil {
+			// secret-tool exits with 1 and prints nothing if the item
+			// doesn't exist.
+			if exitCode(err) == 1 && len(out) == 0 {
+				return nil, butterflymx.ErrTokenNotFound
+			}
+			return nil, err
+		}
+		return out, nil
+	}
+}
+
+// Save implements [butterflymx.TokenStore].
+func (s *Store) Save(ctx context.Context, key string, token []byte) error {
+	switch runtime.GOOS {
+	case "darwin":
+		// The token is passed through an interactive session rather than as
+		// an argument, which would expose it to other users through the
+		// process list.
+		cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			quote(s.Service), quote(s.account(key)), quote(string(token)))
+		_, err := s.exec(ctx, []byte(cmd), "security", "-i")
+		return err
+	case "windows", "plan9", "js", "wasip1":
+		return ErrUnsupported
+	default:
+		label := fmt.Sprintf("%s (%s)", s.Service, s.account(key))
+		_, err := s.exec(ctx, token, "secret-tool", "store", "--label", label, "service", s.Service, "account", s.account(key))
+		return err
+	}
+}
+
+// Delete implements [butterflymx.TokenStore].
+func (s *Store) Delete(ctx context.Context, key string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := s.exec(ctx, nil, "security", "delete-generic-password", "-s", s.Service, "-a", s.account(key))
+		if exitCode(err) == 44 {
+			return nil
+		}
+		return err
+	case "windows", "plan9", "js", "wasip1":
+		return ErrUnsupported
+	default:
+		// secret-tool clear succeeds even if nothing matched.
+		_, err := s.exec(ctx, nil, "secret-tool", "clear", "service", s.Service, "account", s.account(key))
+		return err
+	}
+}
+
+// account returns the keyring account of the token with the given key.
+func (s *Store) account(key string) string {
+	return s.Account + "/" + key
+}
+
+func (s *Store) exec(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+	if s.run != nil {
+		return s.run(ctx, stdin, name, args...)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.Bytes(), fmt.Errorf("keyring: %s: %w: %s", name, err, msg)
+		}
+		return stdout.Bytes(), fmt.Errorf("keyring: %s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// exitCode returns the exit code of the command that failed with err, or -1
+// if err is not an exit error.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// quote quotes s for the command parser of security -i, which splits words
+// like a shell.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,71 @@
+package keyring
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+)
+
+// fakeSecretTool emulates secret-tool(1) using a map.
+func fakeSecretTool(t *testing.T, secrets map[string]string) func(context.Context, []byte, string, ...string) ([]byte, error) {
+	notFound := exec.Command("sh", "-c", "exit 1").Run()
+
+	return func(ctx context.Context, stdin []byte, name string, args ...string) ([]byte, error) {
+		assert.Equal(t, "secret-tool", name)
+
+		i := slices.Index(args, "account")
+		assert.True(t, i >= 0, "account attribute missing: %q", args)
+		account := args[i+1]
+
+		switch args[0] {
+		case "lookup":
+			secret, ok := secrets[account]
+			if !ok {
+				return nil, notFound
+			}
+			return []byte(secret), nil
+		case "store":
+			secrets[account] = string(stdin)
+			return nil, nil
+		case "clear":
+			delete(secrets, account)
+			return nil, nil
+		default:
+			t.Fatalf("unexpected secret-tool command %q", args)
+			return nil, nil
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("test emulates secret-tool")
+	}
+
+	secrets := make(map[string]string)
+	store := New("butterflymx-test", "jane@example.com")
+	store.run = fakeSecretTool(t, secrets)
+
+	_, err := store.Load(t.Context(), butterflymx.APITokenStoreKey)
+	assert.IsError(t, err, butterflymx.ErrTokenNotFound)
+
+	assert.NoError(t, store.Save(t.Context(), butterflymx.APITokenStoreKey, []byte("token")))
+	assert.Equal(t, map[string]string{"jane@example.com/api-token": "token"}, secrets)
+
+	token, err := store.Load(t.Context(), butterflymx.APITokenStoreKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "token", string(token))
+
+	assert.NoError(t, store.Delete(t.Context(), butterflymx.APITokenStoreKey))
+	_, err = store.Load(t.Context(), butterflymx.APITokenStoreKey)
+	assert.IsError(t, err, butterflymx.ErrTokenNotFound)
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, `"a \"b\" \\c"`, quote(`a "b" \c`))
+}
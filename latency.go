@@ -0,0 +1,111 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultUnlockLatencySamples is the default
+// [UnlockLatencyTracker.Samples].
+const DefaultUnlockLatencySamples = 100
+
+// UnlockLatencyTracker records how long door unlocks take per access point,
+// so that degraded panels can be noticed before guests complain. Set it as
+// [APIClientOpts.UnlockLatency]; the same tracker may be shared by several
+// clients.
+//
+// The latency of an unlock is the duration of the whole
+// [APIClient.UnlockDoor] call, including retries. Only successful unlocks are
+// recorded, since failed ones are already reported as errors.
+//
+// The zero value keeps the last [DefaultUnlockLatencySamples] latencies of
+// each access point without a threshold. An UnlockLatencyTracker is safe for
+// concurrent use, but its fields must not be modified once it is in use.
+type UnlockLatencyTracker struct {
+	// Samples is the number of latest latencies kept per access point. It
+	// defaults to [DefaultUnlockLatencySamples]; negative values are treated
+	// as 1.
+	Samples int
+	// Threshold is the latency above which OnSlowUnlock is called. Zero
+	// disables it.
+	Threshold time.Duration
+	// OnSlowUnlock is called for every unlock that took longer than
+	// Threshold. It is called synchronously from the goroutine making the
+	// request, so it should return quickly.
+	OnSlowUnlock func(accessPointID ID, latency time.Duration)
+
+	mu      sync.Mutex
+	windows map[ID]*latencyWindow
+}
+
+// latencyWindow is a ring buffer of the latest latencies.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+// UnlockLatencyStats summarizes the latencies recorded for an access point.
+type UnlockLatencyStats struct {
+	// Count is the number of latencies the stats are computed from.
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// record records the latency of an unlock of the access point with the given
+// numeric ID.
+func (l *UnlockLatencyTracker) record(accessPointID ID, latency time.Duration) {
+	l.mu.Lock()
+	if l.windows == nil {
+		l.windows = make(map[ID]*latencyWindow)
+	}
+	w := l.windows[accessPointID]
+	if w == nil {
+		w = &latencyWindow{}
+		l.windows[accessPointID] = w
+	}
+	if size := max(use(l.Samples, DefaultUnlockLatencySamples), 1); len(w.samples) < size {
+		w.samples = append(w.samples, latency)
+	} else {
+		w.samples[w.next] = latency
+		w.next = (w.next + 1) % size
+	}
+	l.mu.Unlock()
+
+	if l.Threshold > 0 && latency > l.Threshold && l.OnSlowUnlock != nil {
+		l.OnSlowUnlock(accessPointID, latency)
+	}
+}
+
+// Stats returns the latency stats of every access point that has been
+// unlocked, keyed by the access point's numeric ID.
+func (l *UnlockLatencyTracker) Stats() map[ID]UnlockLatencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[ID]UnlockLatencyStats, len(l.windows))
+	for accessPointID, w := range l.windows {
+		sorted := slices.Sorted(slices.Values(w.samples))
+		stats[accessPointID] = UnlockLatencyStats{
+			Count: len(sorted),
+			P50:   percentile(sorted, 0.50),
+			P90:   percentile(sorted, 0.90),
+			P99:   percentile(sorted, 0.99),
+			Max:   sorted[len(sorted)-1],
+		}
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of the sorted, non-empty latencies
+// using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,66 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestUnlockLatencyTracker(t *testing.T) {
+	var slow []time.Duration
+	tracker := &UnlockLatencyTracker{
+		Samples:      10,
+		Threshold:    500 * time.Millisecond,
+		OnSlowUnlock: func(accessPointID ID, latency time.Duration) { slow = append(slow, latency) },
+	}
+
+	// The first 5 samples are pushed out of the window.
+	for range 5 {
+		tracker.record(100, 10*time.Second)
+	}
+	for i := range 10 {
+		tracker.record(100, time.Duration(i+1)*100*time.Millisecond)
+	}
+
+	assert.Equal(t, map[ID]UnlockLatencyStats{
+		100: {
+			Count: 10,
+			P50:   500 * time.Millisecond,
+			P90:   900 * time.Millisecond,
+			P99:   time.Second,
+			Max:   time.Second,
+		},
+	}, tracker.Stats())
+	assert.Equal(t, 10, len(slow))
+
+	client := NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient: &http.Client{Transport: httpmock.NewSequence(t,
+			httpmock.RoundTripResponse{Body: []byte(`{}`)},
+			httpmock.RoundTripResponse{Status: http.StatusBadRequest},
+		)},
+		Logger:        slogt.New(t),
+		UnlockLatency: tracker,
+	})
+
+	assert.NoError(t, client.UnlockDoor(t.Context(), 1, 200))
+	assert.Error(t, client.UnlockDoor(t.Context(), 1, 200))
+	assert.Equal(t, 1, tracker.Stats()[200].Count, "only successful unlocks should be recorded")
+}
+
+func TestUnlockLatencyTracker_negativeSamples(t *testing.T) {
+	tracker := &UnlockLatencyTracker{Samples: -1}
+	tracker.record(100, time.Second)
+	tracker.record(100, 2*time.Second)
+
+	assert.Equal(t, UnlockLatencyStats{
+		Count: 1,
+		P50:   2 * time.Second,
+		P90:   2 * time.Second,
+		P99:   2 * time.Second,
+		Max:   2 * time.Second,
+	}, tracker.Stats()[100])
+}
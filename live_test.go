@@ -0,0 +1,87 @@
+//go:build live
+
+package butterflymx
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/neilotoole/slogt"
+)
+
+// The live tests run read-only calls against the real ButterflyMX API to
+// verify that the library still matches its actual responses. Run them with:
+//
+//	BUTTERFLYMX_API_TOKEN=... go test -tags live -run TestLive ./...
+
+func newLiveAPIClient(t *testing.T) *APIClient {
+	apiToken := os.Getenv("BUTTERFLYMX_API_TOKEN")
+	if apiToken == "" {
+		t.Skip("BUTTERFLYMX_API_TOKEN is not set")
+	}
+	return NewAPIClient(APIStaticToken(apiToken), &APIClientOpts{
+		Logger: slogt.New(t),
+	})
+}
+
+func TestLive(t *testing.T) {
+	client := newLiveAPIClient(t)
+	ctx := t.Context()
+
+	t.Run("Ping", func(t *testing.T) {
+		result, err := client.Ping(ctx)
+		assert.NoError(t, err)
+		assert.True(t, result.Reachable)
+		assert.True(t, result.TokenOK)
+	})
+
+	tenants, err := CollectResults(client.Tenants(ctx))
+	assert.NoError(t, err)
+	assert.NotZero(t, len(tenants), "account should have at least one tenant")
+
+	for _, tenant := range tenants {
+		t.Run("Tenant "+tenant.ID.String(), func(t *testing.T) {
+			assert.Equal(t, "tenant", tenant.ID.Type)
+			assert.NotZero(t, tenant.Name)
+			assert.NoError(t, tenant.PINCode.Validate())
+			assert.Equal(t, "unit", tenant.Unit.ID.Type)
+			assert.Equal(t, "building", tenant.Building.ID.Type)
+
+			accessPoints, err := CollectResults(client.TenantAccessPoints(ctx, tenant.ID))
+			assert.NoError(t, err)
+			for _, ap := range accessPoints {
+				assert.Equal(t, "access_point", ap.ID.Type)
+				assert.NotZero(t, ap.Name)
+			}
+
+			keychains, err := client.Keychains(ctx, tenant.ID.Number, ActiveAccessCode)
+			assert.NoError(t, err)
+
+			for _, keychain := range keychains.Data {
+				assert.NotZero(t, keychain.ID)
+				assert.NotZero(t, keychain.Attributes.Kind)
+
+				virtualKeys, err := CollectResults(keychain.Relationships.VirtualKeys.Resolve(keychains.Refs))
+				assert.NoError(t, err, "virtual keys of keychain %v should resolve", keychain.ID)
+
+				for _, vk := range virtualKeys {
+					assert.NoError(t, vk.Attributes.PINCode.Validate())
+
+					_, err := CollectResults(vk.Relationships.DoorReleases.Resolve(keychains.Refs))
+					assert.NoError(t, err, "door releases of virtual key %v should resolve", vk.ID)
+				}
+
+				_, err = CollectResults(keychain.Relationships.Devices.Resolve(keychains.Refs))
+				assert.NoError(t, err, "devices of keychain %v should resolve", keychain.ID)
+			}
+
+			if len(keychains.Data) > 0 {
+				single, err := client.Keychain(ctx, keychains.Data[0].ID)
+				assert.NoError(t, err)
+				assert.Equal(t, keychains.Data[0].ID, single.Data.ID)
+				assert.Equal(t, keychains.Data[0].Attributes.Name, single.Data.Attributes.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// MediaInfo describes media downloaded by [APIClient.DownloadMedia].
+type MediaInfo struct {
+	// ContentType is the Content-Type of the media, e.g. "image/jpeg".
+	ContentType string
+	// Size is the number of bytes written.
+	Size int64
+}
+
+// DownloadMedia downloads the media at rawURL, such as the ThumbURL or
+// MediumURL of a [DoorRelease], and streams it into w. Failed requests are
+// retried like any other request until the first byte is written to w.
+//
+// The API token is only sent if rawURL points to the same host as
+// [APIClientOpts.APIBaseURL] or [APIClientOpts.UnlockAPIBaseURL], so that it
+// isn't leaked to storage hosts that media may redirect to or be served from.
+// [APIClientOpts.MaxResponseSize] doesn't apply, since the media isn't held in
+// memory.
+//
+// Some panels record a short video clip of every release, but the API calls
+// that list them haven't been captured yet, so there is no way to find them
+// through this package. Clip URLs obtained elsewhere can still be downloaded
+// using DownloadMedia.
+func (c *APIClient) DownloadMedia(ctx context.Context, rawURL string, w io.Writer, opts ...CallOption) (MediaInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return MediaInfo{}, fmt.Errorf("invalid media URL: %w", err)
+	}
+
+	call := newCallOptions(opts)
+	ctx, cancel := call.context(ctx)
+	defer cancel()
+
+	req, err := c.createRequest(ctx, railsProfile, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return MediaInfo{}, err
+	}
+	req.Header.Set("Accept", "*/*")
+	call.applyHeader(req)
+
+	return c.doMediaRequest(req, call, w, c.isAPIHost(u.Host))
+}
+
+// doMediaRequest performs req like [APIClient.doJSONRequest], but copies the
+// response body into w instead of decoding it. The API token is only sent if
+// sendToken is true.
+func (c *APIClient) doMediaRequest(req *http.Request, call callOptions, w io.Writer, sendToken bool) (MediaInfo, error) {
+	var info MediaInfo
+	err := c.doRetriedRequest(req, call, sendToken, func(resp *http.Response) error {
+		info.ContentType = resp.Header.Get("Content-Type")
+		n, err := io.Copy(w, resp.Body)
+		info.Size = n
+		if err != nil {
+			return fmt.Errorf("failed to download media: %w", err)
+		}
+		return nil
+	})
+	return info, err
+}
+
+// isAPIHost returns true if host is the host of one of the APIs the client
+// talks to.
+func (c *APIClient) isAPIHost(host string) bool {
+	for _, base := range []string{c.opts.APIBaseURL, c.opts.UnlockAPIBaseURL} {
+		if u, err := url.Parse(base); err == nil && u.Host == host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package butterflymx
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/cenkalti/backoff/v5"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestAPIClient_DownloadMedia(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0 not really a JPEG")
+	newClient := func(mockrt http.RoundTripper) *APIClient {
+		return NewAPIClient(mockToken, &APIClientOpts{
+			HTTPClient:     &http.Client{Transport: mockrt},
+			Logger:         slogt.New(t),
+			RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+		})
+	}
+
+	t.Run("api host", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: requestCheckAuthorizationBearer,
+				Response:     httpmock.RoundTripResponse{Status: http.StatusBadGateway},
+			},
+			{
+				RequestCheck: httpmock.ChainRoundTripRequestChecks(
+					requestCheckAuthorizationBearer,
+					func(t *testing.T, req *http.Request) {
+						assert.Equal(t, APIBaseURL+"/v3/door_releases/30001/thumb.jpg", req.URL.String())
+					},
+				),
+				Response: httpmock.RoundTripResponse{
+					Headers: map[string]string{"Content-Type": "image/jpeg"},
+					Body:    jpeg,
+				},
+			},
+		})
+
+		var buf bytes.Buffer
+		info, err := newClient(mockrt).DownloadMedia(t.Context(), APIBaseURL+"/v3/door_releases/30001/thumb.jpg", &buf)
+		assert.NoError(t, err)
+		assert.Equal(t, MediaInfo{ContentType: "image/jpeg", Size: int64(len(jpeg))}, info)
+		assert.Equal(t, jpeg, buf.Bytes())
+	})
+
+	t.Run("other host", func(t *testing.T) {
+		mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+			{
+				RequestCheck: func(t *testing.T, req *http.Request) {
+					assert.Equal(t, "", req.Header.Get("Authorization"), "the API token must not be leaked")
+				},
+				Response: httpmock.RoundTripResponse{Status: http.StatusForbidden},
+			},
+		})
+
+		var buf bytes.Buffer
+		_, err := newClient(mockrt).DownloadMedia(t.Context(), "https://media.example.com/clip.mp4", &buf)
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+		assert.Equal(t, 0, buf.Len())
+	})
+}
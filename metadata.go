@@ -0,0 +1,132 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Metadata holds caller-defined labels for a keychain or virtual key, such as
+// a booking ID, a guest name or the system that created it. The ButterflyMX
+// API has no field for custom metadata, so it is kept client-side in a
+// [MetadataStore].
+type Metadata map[string]string
+
+// MetadataKey identifies the object that [Metadata] belongs to.
+type MetadataKey struct {
+	Type ObjectType `json:"type"`
+	ID   ID         `json:"id,string"`
+}
+
+// KeychainMetadataKey returns the [MetadataKey] of the keychain with the given
+// ID.
+func KeychainMetadataKey(id ID) MetadataKey {
+	return MetadataKey{Type: TypeKeychain, ID: id}
+}
+
+// VirtualKeyMetadataKey returns the [MetadataKey] of the virtual key with the
+// given ID.
+func VirtualKeyMetadataKey(id ID) MetadataKey {
+	return MetadataKey{Type: TypeVirtualKey, ID: id}
+}
+
+// String returns the key formatted as "type/id".
+func (k MetadataKey) String() string {
+	return fmt.Sprintf("%s/%d", k.Type, k.ID)
+}
+
+// MetadataStore stores [Metadata] of keychains and virtual keys. It can be
+// implemented on top of any database; [MemoryMetadataStore] is an in-memory
+// implementation.
+//
+// Implementations must be safe for concurrent use.
+type MetadataStore interface {
+	// SetMetadata replaces the metadata of the object with the given key.
+	SetMetadata(ctx context.Context, key MetadataKey, md Metadata) error
+	// Metadata returns the metadata of the object with the given key, or nil
+	// if it has none.
+	Metadata(ctx context.Context, key MetadataKey) (Metadata, error)
+	// DeleteMetadata removes the metadata of the object with the given key.
+	// Deleting metadata that doesn't exist is not an error.
+	DeleteMetadata(ctx context.Context, key MetadataKey) error
+	// FindMetadata returns the keys of all objects whose metadata has the
+	// given label set to value.
+	FindMetadata(ctx context.Context, label, value string) ([]MetadataKey, error)
+}
+
+// MemoryMetadataStore is a [MetadataStore] that keeps metadata in memory.
+// The zero value is ready to use.
+type MemoryMetadataStore struct {
+	mu sync.RWMutex
+	m  map[MetadataKey]Metadata
+}
+
+var _ MetadataStore = (*MemoryMetadataStore)(nil)
+
+// SetMetadata implements [MetadataStore].
+func (s *MemoryMetadataStore) SetMetadata(ctx context.Context, key MetadataKey, md Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.m == nil {
+		s.m = make(map[MetadataKey]Metadata)
+	}
+	s.m[key] = maps.Clone(md)
+	return nil
+}
+
+// Metadata implements [MetadataStore].
+func (s *MemoryMetadataStore) Metadata(ctx context.Context, key MetadataKey) (Metadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return maps.Clone(s.m[key]), nil
+}
+
+// DeleteMetadata implements [MetadataStore].
+func (s *MemoryMetadataStore) DeleteMetadata(ctx context.Context, key MetadataKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, key)
+	return nil
+}
+
+// FindMetadata implements [MetadataStore]. The keys are sorted by type and
+// then by ID.
+func (s *MemoryMetadataStore) FindMetadata(ctx context.Context, label, value string) ([]MetadataKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []MetadataKey
+	for key, md := range s.m {
+		if v, ok := md[label]; ok && v == value {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b MetadataKey) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID, b.ID))
+	})
+	return keys, nil
+}
+
+// TagKeychain sets md as the metadata of the given keychain and of every
+// virtual key it references, so that either can be looked up later. It is
+// meant to be called right after creating a keychain, e.g. with the result of
+// [APIClient.CreateCustomKeychain].
+func TagKeychain(ctx context.Context, store MetadataStore, keychain *Keychain, md Metadata) error {
+	if err := store.SetMetadata(ctx, KeychainMetadataKey(keychain.ID), md); err != nil {
+		return fmt.Errorf("failed to set metadata of keychain %d: %w", keychain.ID, err)
+	}
+	for _, ref := range keychain.Relationships.VirtualKeys {
+		if err := store.SetMetadata(ctx, VirtualKeyMetadataKey(ref.ID), md); err != nil {
+			return fmt.Errorf("failed to set metadata of virtual key %d: %w", ref.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package butterflymx
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func TestTagKeychain(t *testing.T) {
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	mockrt := httpmock.NewRoundTripper(t, []httpmock.RoundTrip{
+		{Response: httpmock.RoundTripResponse{Status: http.StatusOK, Body: accessCodesResponse}},
+	})
+
+	results, err := newTestAPIClient(t, mockrt).Keychains(t.Context(), 10001, ActiveAccessCode)
+	assert.NoError(t, err)
+
+	var store MemoryMetadataStore
+	md := Metadata{"booking": "B-42", "guest": "Jane Doe"}
+	assert.NoError(t, TagKeychain(t.Context(), &store, &results.Data[0], md))
+	assert.NoError(t, TagKeychain(t.Context(), &store, &results.Data[1], Metadata{"booking": "B-43"}))
+
+	// The store keeps its own copy.
+	md["guest"] = "John Doe"
+
+	got, err := store.Metadata(t.Context(), KeychainMetadataKey(20001))
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{"booking": "B-42", "guest": "Jane Doe"}, got)
+
+	got, err = store.Metadata(t.Context(), VirtualKeyMetadataKey(20002))
+	assert.NoError(t, err)
+	assert.Equal(t, "B-42", got["booking"])
+
+	keys, err := store.FindMetadata(t.Context(), "booking", "B-42")
+	assert.NoError(t, err)
+	assert.Equal(t, []MetadataKey{KeychainMetadataKey(20001), VirtualKeyMetadataKey(20002)}, keys)
+
+	assert.NoError(t, store.DeleteMetadata(t.Context(), KeychainMetadataKey(20001)))
+	got, err = store.Metadata(t.Context(), KeychainMetadataKey(20001))
+	assert.NoError(t, err)
+	assert.Zero(t, got)
+}
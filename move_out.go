@@ -0,0 +1,74 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RevokedVirtualKey is a virtual key revoked by [MoveOutTenant].
+type RevokedVirtualKey struct {
+	// KeychainID is the numeric ID of the keychain the virtual key belonged
+	// to.
+	KeychainID ID
+	// KeychainName is the name of that keychain.
+	KeychainName string
+	// VirtualKey is the revoked virtual key as it was before being revoked.
+	VirtualKey VirtualKey
+}
+
+// MoveOutResult describes what [MoveOutTenant] removed.
+type MoveOutResult struct {
+	// Revoked holds every virtual key that was revoked, in the order the
+	// keychains were listed.
+	Revoked []RevokedVirtualKey
+	// Failed holds the virtual keys that couldn't be revoked. The returned
+	// error has the reasons.
+	Failed []RevokedVirtualKey
+}
+
+// MoveOutTenant revokes every virtual key of the tenant's active keychains, as
+// is done at the end of a lease, and reports what was removed. The options are
+// passed to every API call made.
+//
+// A virtual key that fails to be revoked doesn't stop the others from being
+// revoked; the returned error joins the errors of every failed virtual key.
+// The result is returned unless the keychains can't be listed.
+//
+// The API has no way to delete keychains, so they are left behind without
+// virtual keys. The API calls for delivery PINs haven't been captured yet, so
+// those must still be cleared through the app.
+func MoveOutTenant(ctx context.Context, client Client, tenantID ID, opts ...CallOption) (*MoveOutResult, error) {
+	keychains, err := client.Keychains(ctx, tenantID, ActiveAccessCode, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keychains: %w", err)
+	}
+
+	result := &MoveOutResult{}
+	var errs []error
+
+	for _, keychain := range keychains.Data {
+		for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(keychains.Refs) {
+			if err != nil {
+				errs = append(errs, fmt.Errorf("keychain %d: %w", keychain.ID, err))
+				continue
+			}
+
+			revoked := RevokedVirtualKey{
+				KeychainID:   keychain.ID,
+				KeychainName: keychain.Attributes.Name,
+				VirtualKey:   *virtualKey,
+			}
+			if err := client.RevokeVirtualKey(ctx, keychain.ID, virtualKey.ID, opts...); err != nil {
+				result.Failed = append(result.Failed, revoked)
+				errs = append(errs, fmt.Errorf("failed to revoke virtual key %d of keychain %d: %w", virtualKey.ID, keychain.ID, err))
+				continue
+			}
+			result.Revoked = append(result.Revoked, revoked)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
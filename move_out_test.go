@@ -0,0 +1,93 @@
+package butterflymx_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestMoveOutTenant(t *testing.T) {
+	tenantID := butterflymx.TenantTaggedID(100)
+	newFake := func() *fakebmx.Client {
+		return fakebmx.New(&bmxtest.Data{
+			Tenants: []bmxtest.Tenant{{
+				Tenant: butterflymx.Tenant{
+					ID:       tenantID,
+					Unit:     butterflymx.Unit{ID: butterflymx.UnitTaggedID(200), Label: "Apt 1"},
+					Building: butterflymx.Building{ID: butterflymx.BuildingTaggedID(300), Name: "Building"},
+				},
+				AccessPoints: groupTestAccessPoints,
+				Keychains: []bmxtest.Keychain{
+					{
+						ID:             1000,
+						Name:           "Family",
+						Kind:           butterflymx.CustomKeychain,
+						EndsAt:         time.Now().Add(time.Hour),
+						AccessPointIDs: []butterflymx.ID{400},
+						VirtualKeys: []bmxtest.VirtualKey{
+							{ID: 2000, Name: "Jane", Email: "jane@example.com", PINCode: "111111"},
+							{ID: 2001, Name: "John", Email: "john@example.com", PINCode: "222222"},
+						},
+					},
+					{
+						ID:             1001,
+						Name:           "Expired",
+						Kind:           butterflymx.CustomKeychain,
+						EndsAt:         time.Now().Add(-time.Hour),
+						AccessPointIDs: []butterflymx.ID{400},
+						VirtualKeys: []bmxtest.VirtualKey{
+							{ID: 2002, Name: "Alex", Email: "alex@example.com", PINCode: "333333"},
+						},
+					},
+				},
+			}},
+		})
+	}
+
+	t.Run("moved out", func(t *testing.T) {
+		fake := newFake()
+		result, err := butterflymx.MoveOutTenant(t.Context(), fake, tenantID.Number)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(result.Revoked))
+		assert.Equal(t, 0, len(result.Failed))
+		assert.Equal(t, butterflymx.ID(1000), result.Revoked[0].KeychainID)
+		assert.Equal(t, "Family", result.Revoked[0].KeychainName)
+		assert.Equal(t, "Jane", result.Revoked[0].VirtualKey.Attributes.Name)
+		assert.Equal(t, "John", result.Revoked[1].VirtualKey.Attributes.Name)
+
+		keychain, err := fake.Keychain(t.Context(), 1000)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(keychain.Data.Relationships.VirtualKeys))
+
+		keychain, err = fake.Keychain(t.Context(), 1001)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keychain.Data.Relationships.VirtualKeys), "expired keychains should be left alone")
+	})
+
+	t.Run("revoke fails", func(t *testing.T) {
+		fake := newFake()
+		errBoom := errors.New("boom")
+		fake.SetError("RevokeVirtualKey", errBoom)
+
+		result, err := butterflymx.MoveOutTenant(t.Context(), fake, tenantID.Number)
+		assert.IsError(t, err, errBoom)
+		assert.Equal(t, 0, len(result.Revoked))
+		assert.Equal(t, 2, len(result.Failed))
+		assert.Equal(t, 2, len(fake.CallsTo("RevokeVirtualKey")))
+	})
+
+	t.Run("list fails", func(t *testing.T) {
+		fake := newFake()
+		errBoom := errors.New("boom")
+		fake.SetError("Keychains", errBoom)
+
+		_, err := butterflymx.MoveOutTenant(t.Context(), fake, tenantID.Number)
+		assert.IsError(t, err, errBoom)
+		assert.Equal(t, 0, len(fake.CallsTo("RevokeVirtualKey")))
+	})
+}
@@ -0,0 +1,63 @@
+package butterflymx
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
+
+// APITokenSourceToOAuth2 adapts src to an [oauth2.TokenSource], so that API
+// tokens can be used with the rest of the Go OAuth2 ecosystem, such as
+// [oauth2.NewClient]. The returned tokens are bearer tokens whose Expiry is
+// read using [APIStaticToken.Expiry]. If the expiry isn't known, it is left
+// zero, which [oauth2.ReuseTokenSource] treats as never expiring, so src
+// should do its own caching, e.g. using [ReuseAPITokenSource].
+//
+// ctx is used for every call to src, so it should outlive the returned token
+// source. Since [oauth2.TokenSource] has no way to ask for a renewal, src is
+// always called with renew set to false.
+func APITokenSourceToOAuth2(ctx context.Context, src APITokenSource) oauth2.TokenSource {
+	return apiOAuth2TokenSource{ctx, src}
+}
+
+type apiOAuth2TokenSource struct {
+	ctx context.Context
+	src APITokenSource
+}
+
+func (s apiOAuth2TokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.src.APIToken(s.ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	expiry, _ := token.Expiry()
+	return &oauth2.Token{
+		AccessToken: string(token),
+		TokenType:   "Bearer",
+		Expiry:      expiry,
+	}, nil
+}
+
+// APITokenSourceFromOAuth2 adapts src, whose access tokens are API tokens, to
+// an [APITokenSource]. It is the inverse of [APITokenSourceToOAuth2], and
+// allows OAuth2 caching wrappers such as [oauth2.ReuseTokenSource] to be used
+// for API tokens.
+//
+// The renew parameter is ignored, since [oauth2.TokenSource] has no way to
+// force a renewal. Wrap the result using [ReuseAPITokenSource] if renewals
+// must be obeyed.
+func APITokenSourceFromOAuth2(src oauth2.TokenSource) APITokenSource {
+	return oauth2APITokenSourceAdapter{src}
+}
+
+type oauth2APITokenSourceAdapter struct {
+	src oauth2.TokenSource
+}
+
+func (s oauth2APITokenSourceAdapter) APIToken(ctx context.Context, _ bool) (APIStaticToken, error) {
+	token, err := s.src.Token()
+	if err != nil {
+		return "", err
+	}
+	return APIStaticToken(token.AccessToken), nil
+}
@@ -0,0 +1,34 @@
+package butterflymx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"golang.org/x/oauth2"
+)
+
+func TestAPITokenSourceToOAuth2(t *testing.T) {
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	apiToken := testJWT(t, exp)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer "+string(apiToken), r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(server.Close)
+
+	src := APITokenSourceToOAuth2(t.Context(), apiToken)
+	token, err := src.Token()
+	assert.NoError(t, err)
+	assert.True(t, exp.Equal(token.Expiry), "got %v", token.Expiry)
+
+	resp, err := oauth2.NewClient(t.Context(), src).Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	back, err := APITokenSourceFromOAuth2(oauth2.ReuseTokenSource(nil, src)).APIToken(t.Context(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, apiToken, back)
+}
@@ -0,0 +1,69 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// LoadOAuth2Token loads the OAuth2 token saved in store by
+// [StoreOAuth2TokenSource]. It returns [ErrTokenNotFound] if there is none.
+// The token can be passed to [AuthFlowClient.TokenSource] to resume the
+// session.
+func LoadOAuth2Token(ctx context.Context, store TokenStore) (*oauth2.Token, error) {
+	b, err := store.Load(ctx, OAuth2TokenStoreKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var token oauth2.Token
+	if err := json.Unmarshal(b, &token); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stored OAuth2 token: %w", err)
+	}
+	return &token, nil
+}
+
+// StoreOAuth2TokenSource returns a token source that saves every new token
+// returned by src to store, such as after it is refreshed. Refresh tokens may
+// be rotated on every refresh, so the stored token must be kept up to date for
+// the session to be resumable.
+func StoreOAuth2TokenSource(src oauth2.TokenSource, store TokenStore) oauth2.TokenSource {
+	return &storedOAuth2TokenSource{src: src, store: store}
+}
+
+type storedOAuth2TokenSource struct {
+	src   oauth2.TokenSource
+	store TokenStore
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *storedOAuth2TokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.src.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.AccessToken == s.last {
+		return token, nil
+	}
+
+	b, err := json.Marshal(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal OAuth2 token: %w", err)
+	}
+	if err := s.store.Save(context.Background(), OAuth2TokenStoreKey, b); err != nil {
+		return nil, fmt.Errorf("failed to save OAuth2 token: %w", err)
+	}
+	s.last = token.AccessToken
+
+	return token, nil
+}
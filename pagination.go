@@ -0,0 +1,45 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import "fmt"
+
+// pageGuard stops paginated calls that would otherwise never end, either
+// because the server keeps returning the same cursor or because there are
+// more pages than [APIClientOpts.MaxPages].
+type pageGuard struct {
+	maxPages int
+	seen     map[string]struct{}
+}
+
+// newPageGuard creates a pageGuard for a call starting after the given cursor,
+// or from the beginning if it is nil.
+func (c *APIClient) newPageGuard(start *string) *pageGuard {
+	g := &pageGuard{
+		maxPages: c.opts.MaxPages,
+		seen:     make(map[string]struct{}),
+	}
+	if start != nil {
+		g.seen[*start] = struct{}{}
+	}
+	return g
+}
+
+// checkPage returns an error wrapping [ErrTooManyPages] if the 1-based page
+// is over the limit.
+func (g *pageGuard) checkPage(page int) error {
+	if g.maxPages > 0 && page > g.maxPages {
+		return fmt.Errorf("%w: more than %d pages", ErrTooManyPages, g.maxPages)
+	}
+	return nil
+}
+
+// checkCursor returns an error wrapping [ErrPaginationLoop] if the cursor or
+// next page link of the next page was already returned by the server.
+func (g *pageGuard) checkCursor(cursor string) error {
+	if _, ok := g.seen[cursor]; ok {
+		return fmt.Errorf("%w: cursor %q was returned twice", ErrPaginationLoop, cursor)
+	}
+	g.seen[cursor] = struct{}{}
+	return nil
+}
@@ -0,0 +1,242 @@
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/cenkalti/backoff/v5"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+func newPaginatedAPIClient(t *testing.T, paginator *httpmock.Paginator) *APIClient {
+	return NewAPIClient(mockToken, &APIClientOpts{
+		HTTPClient:     &http.Client{Transport: paginator},
+		Logger:         slogt.New(t),
+		RequestBackoff: func() backoff.BackOff { return &backoff.ZeroBackOff{} },
+	})
+}
+
+func TestAPIClient_Keychains_pagination(t *testing.T) {
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	t.Run("pages", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 3})
+
+		var progress []Progress
+		results, err := newPaginatedAPIClient(t, paginator).Keychains(t.Context(), 10001, ActiveAccessCode,
+			WithProgress(func(p Progress) { progress = append(progress, p) }))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(results.Data))
+		assert.Equal(t, []int{1, 2, 3}, paginator.Requests())
+		assert.Equal(t, []Progress{{Pages: 1, Items: 1}, {Pages: 2, Items: 2}, {Pages: 3, Items: 4}}, progress)
+	})
+
+	t.Run("shared includes", func(t *testing.T) {
+		single, err := newPaginatedAPIClient(t, httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{})).
+			Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.NoError(t, err)
+
+		// Every page carries the same included objects, which must only be
+		// kept once.
+		paged, err := newPaginatedAPIClient(t, httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 3})).
+			Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.NoError(t, err)
+		assert.Equal(t, len(single.Refs), len(paged.Refs))
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 2})
+
+		var keychains int
+		for page, err := range newPaginatedAPIClient(t, paginator).KeychainPages(t.Context(), 10001, ActiveAccessCode) {
+			assert.NoError(t, err)
+			for _, keychain := range page.Data {
+				_, err := CollectResults(keychain.Relationships.VirtualKeys.Resolve(page.Refs))
+				assert.NoError(t, err, "references should resolve within their page")
+			}
+			keychains += len(page.Data)
+			break
+		}
+		assert.Equal(t, 2, keychains)
+		assert.Equal(t, []int{1}, paginator.Requests(), "breaking early should stop fetching pages")
+	})
+
+	t.Run("empty last page", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 2, EmptyLastPage: true})
+
+		results, err := newPaginatedAPIClient(t, paginator).Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(results.Data))
+		assert.Equal(t, []int{1, 2, 3}, paginator.Requests())
+	})
+
+	t.Run("failing page", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{
+			Pages:      3,
+			FailPage:   2,
+			FailStatus: http.StatusForbidden,
+		})
+
+		_, err := newPaginatedAPIClient(t, paginator).Keychains(t.Context(), 10001, ActiveAccessCode)
+		var pageErr *PaginationError
+		assert.True(t, errors.As(err, &pageErr), "error should be a PaginationError: %v", err)
+		assert.Equal(t, 2, pageErr.Page)
+		assert.Equal(t, 1, pageErr.Fetched)
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr), "error should wrap the APIError: %v", err)
+		assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+
+		// The 403 is retried once with a renewed token.
+		assert.Equal(t, []int{1, 2, 2}, paginator.Requests())
+	})
+
+	t.Run("partial results", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{
+			Pages:      3,
+			FailPage:   3,
+			FailStatus: http.StatusForbidden,
+		})
+
+		results, err := newPaginatedAPIClient(t, paginator).Keychains(t.Context(), 10001, ActiveAccessCode, WithPartialResults())
+		assert.Error(t, err)
+		assert.NotZero(t, results)
+		assert.Equal(t, 2, len(results.Data), "keychains of the first two pages should be kept")
+	})
+
+	t.Run("looping pages", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 2, LoopCursor: true})
+
+		_, err := newPaginatedAPIClient(t, paginator).Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.IsError(t, err, ErrPaginationLoop)
+		assert.Equal(t, []int{1, 2}, paginator.Requests())
+	})
+
+	t.Run("too many pages", func(t *testing.T) {
+		paginator := httpmock.NewJSONAPIPaginator(t, accessCodesResponse, httpmock.PageOpts{Pages: 3})
+		client := newPaginatedAPIClient(t, paginator)
+		client.opts.MaxPages = 2
+
+		_, err := client.Keychains(t.Context(), 10001, ActiveAccessCode)
+		assert.IsError(t, err, ErrTooManyPages)
+		var pageErr *PaginationError
+		assert.True(t, errors.As(err, &pageErr))
+		assert.Equal(t, 3, pageErr.Page)
+		assert.Equal(t, []int{1, 2}, paginator.Requests())
+	})
+}
+
+func TestAPIClient_Tenants_pagination(t *testing.T) {
+	var tenants []Tenant
+	for i := range 5 {
+		tenants = append(tenants, Tenant{
+			ID:       NewTaggedID("tenant", ID(100+i)),
+			Name:     fmt.Sprintf("Tenant %d", i),
+			PINCode:  "1234",
+			Unit:     Unit{ID: NewTaggedID("unit", 200), Label: "Apt 1"},
+			Building: Building{ID: NewTaggedID("building", 300), Name: "Building"},
+		})
+	}
+	fixture, err := json.Marshal(map[string]any{
+		"data": map[string]any{
+			"tenants": map[string]any{
+				"nodes":    tenants,
+				"pageInfo": map[string]any{"hasNextPage": false},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("pages", func(t *testing.T) {
+		paginator := httpmock.NewGraphQLPaginator(t, fixture, "data.tenants", httpmock.PageOpts{Pages: 3})
+
+		got, err := CollectResults(newPaginatedAPIClient(t, paginator).Tenants(t.Context()))
+		assert.NoError(t, err)
+		assert.Equal(t, tenants, got)
+		assert.Equal(t, []int{1, 2, 3}, paginator.Requests())
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		paginator := httpmock.NewGraphQLPaginator(t, fixture, "data.tenants", httpmock.PageOpts{Pages: 3})
+		client := newPaginatedAPIClient(t, paginator)
+
+		// Pages hold tenants [0:1], [1:3] and [3:5]. Stop in the middle of
+		// the second page.
+		var cursor string
+		var got []Tenant
+		for tenant, err := range client.Tenants(t.Context(), WithCursor(&cursor)) {
+			assert.NoError(t, err)
+			got = append(got, tenant)
+			if len(got) == 2 {
+				break
+			}
+		}
+		assert.Equal(t, "page-2", cursor, "cursor should point past the last fully consumed page")
+
+		rest, err := CollectResults(client.Tenants(t.Context(), WithCursor(&cursor)))
+		assert.NoError(t, err)
+		assert.Equal(t, tenants[1:], rest)
+		assert.Equal(t, []int{1, 2, 2, 3}, paginator.Requests())
+		assert.Equal(t, "page-3", cursor, "cursor should be kept after the last page")
+	})
+
+	t.Run("failing page", func(t *testing.T) {
+		paginator := httpmock.NewGraphQLPaginator(t, fixture, "data.tenants", httpmock.PageOpts{
+			Pages:      3,
+			FailPage:   3,
+			FailStatus: http.StatusBadRequest,
+		})
+
+		var got []Tenant
+		var pageErr *PaginationError
+		for tenant, err := range newPaginatedAPIClient(t, paginator).Tenants(t.Context()) {
+			if err != nil {
+				assert.True(t, errors.As(err, &pageErr), "error should be a PaginationError: %v", err)
+				break
+			}
+			got = append(got, tenant)
+		}
+		assert.Equal(t, tenants[:3], got, "tenants before the failing page should be yielded")
+		assert.Equal(t, []int{1, 2, 3}, paginator.Requests())
+		assert.Equal(t, PaginationError{Page: 3, Cursor: "page-3", Fetched: 3, Err: pageErr.Err}, *pageErr)
+	})
+
+	t.Run("looping cursor", func(t *testing.T) {
+		paginator := httpmock.NewGraphQLPaginator(t, fixture, "data.tenants", httpmock.PageOpts{Pages: 3, LoopCursor: true})
+
+		got, err := CollectResults(newPaginatedAPIClient(t, paginator).Tenants(t.Context()))
+		assert.IsError(t, err, ErrPaginationLoop)
+		assert.Equal(t, tenants, got, "every page should be yielded once")
+		assert.Equal(t, []int{1, 2, 3}, paginator.Requests())
+	})
+}
+
+func TestAPIClient_TenantAccessPoints_multipleTenants(t *testing.T) {
+	response := []byte(`{"data": {"nodes": [
+		{"accessPoints": {"nodes": [], "pageInfo": {"hasNextPage": false, "endCursor": ""}}},
+		{"accessPoints": {"nodes": [], "pageInfo": {"hasNextPage": false, "endCursor": ""}}}
+	]}}`)
+
+	t.Run("TenantAccessPoints", func(t *testing.T) {
+		client := newTestAPIClient(t, httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: response}))
+
+		_, err := CollectResults(client.TenantAccessPoints(t.Context(), TenantTaggedID(100)))
+		var pageErr *PaginationError
+		assert.True(t, errors.As(err, &pageErr), "error should be a PaginationError: %v", err)
+		assert.Equal(t, 1, pageErr.Page)
+	})
+
+	t.Run("AccessPointsOfTenants", func(t *testing.T) {
+		client := newTestAPIClient(t, httpmock.NewSequence(t, httpmock.RoundTripResponse{Body: response}))
+
+		_, err := client.AccessPointsOfTenants(t.Context(), []TaggedID{TenantTaggedID(100)})
+		var pageErr *PaginationError
+		assert.True(t, errors.As(err, &pageErr), "error should be a PaginationError: %v", err)
+		assert.Equal(t, 1, pageErr.Page)
+	})
+}
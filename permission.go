@@ -0,0 +1,64 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"fmt"
+)
+
+// NotPermittedError is returned by [CheckUnlockPermitted] if the tenant has no
+// access to the access point. It matches [ErrForbidden].
+type NotPermittedError struct {
+	// TenantID is the numeric ID of the tenant, or 0 if the default tenant
+	// was used.
+	TenantID ID
+	// AccessPointID is the numeric ID of the access point.
+	AccessPointID ID
+}
+
+// Error implements the error interface.
+func (e *NotPermittedError) Error() string {
+	if e.TenantID == 0 {
+		return fmt.Sprintf("tenant may not unlock access point %d", e.AccessPointID)
+	}
+	return fmt.Sprintf("tenant %d may not unlock access point %d", e.TenantID, e.AccessPointID)
+}
+
+// Is reports whether target is [ErrForbidden].
+func (e *NotPermittedError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// CheckUnlockPermitted returns a [*NotPermittedError] if the access point
+// isn't one of the tenant's, as listed by [APIClient.TenantAccessPoints]. It
+// lets callers reject an unlock themselves instead of having the Unlock API
+// reject it, such as in households where residents have access to different
+// doors. A tenantID of 0 stands for the default tenant, as with
+// [APIClient.UnlockDoor].
+func CheckUnlockPermitted(ctx context.Context, client Client, tenantID, accessPointID ID, opts ...CallOption) error {
+	var taggedTenantID TaggedID
+	if tenantID != 0 {
+		taggedTenantID = TenantTaggedID(tenantID)
+	}
+
+	for ap, err := range client.TenantAccessPoints(ctx, taggedTenantID, opts...) {
+		if err != nil {
+			return fmt.Errorf("failed to list access points: %w", err)
+		}
+		if ap.ID.Number == accessPointID {
+			return nil
+		}
+	}
+
+	return &NotPermittedError{TenantID: tenantID, AccessPointID: accessPointID}
+}
+
+// UnlockDoorIfPermitted unlocks the access point using
+// [APIClient.UnlockDoor] if [CheckUnlockPermitted] passes.
+func UnlockDoorIfPermitted(ctx context.Context, client Client, tenantID, accessPointID ID, opts ...CallOption) error {
+	if err := CheckUnlockPermitted(ctx, client, tenantID, accessPointID, opts...); err != nil {
+		return err
+	}
+	return client.UnlockDoor(ctx, tenantID, accessPointID, opts...)
+}
@@ -0,0 +1,48 @@
+package butterflymx_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestUnlockDoorIfPermitted(t *testing.T) {
+	tenantID := butterflymx.TenantTaggedID(100)
+	fake := fakebmx.New(&bmxtest.Data{
+		Tenants: []bmxtest.Tenant{{
+			Tenant:       butterflymx.Tenant{ID: tenantID},
+			AccessPoints: groupTestAccessPoints[:2],
+		}},
+	})
+
+	t.Run("permitted", func(t *testing.T) {
+		err := butterflymx.UnlockDoorIfPermitted(t.Context(), fake, tenantID.Number, 401)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(fake.Unlocks()))
+	})
+
+	t.Run("not permitted", func(t *testing.T) {
+		calls := len(fake.CallsTo("UnlockDoor"))
+
+		err := butterflymx.UnlockDoorIfPermitted(t.Context(), fake, tenantID.Number, 403)
+		var notPermitted *butterflymx.NotPermittedError
+		assert.True(t, errors.As(err, &notPermitted))
+		assert.Equal(t, butterflymx.ID(403), notPermitted.AccessPointID)
+		assert.IsError(t, err, butterflymx.ErrForbidden)
+		assert.Equal(t, calls, len(fake.CallsTo("UnlockDoor")), "the Unlock API should not be called")
+	})
+
+	t.Run("list fails", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		fake.SetError("TenantAccessPoints", errBoom)
+		defer fake.SetError("TenantAccessPoints", nil)
+
+		err := butterflymx.CheckUnlockPermitted(t.Context(), fake, tenantID.Number, 400)
+		assert.IsError(t, err, errBoom)
+		assert.NotIsError(t, err, butterflymx.ErrForbidden)
+	})
+}
@@ -0,0 +1,130 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultProppedDoorMaxReleases is the default
+// [ProppedDoorDetector.MaxReleases].
+const DefaultProppedDoorMaxReleases = 3
+
+// DefaultProppedDoorOpenDuration is the default
+// [ProppedDoorDetector.OpenDuration].
+const DefaultProppedDoorOpenDuration = 5 * time.Second
+
+// ProppedDoorAlert is reported by a [ProppedDoorDetector] when a door is
+// released repeatedly without relocking in between.
+type ProppedDoorAlert struct {
+	// PanelID is the ID of the panel the door was released at.
+	PanelID ID
+	// PanelName is the name of that panel, if known.
+	PanelName string
+	// OpenDuration is how long the door was assumed to stay unlocked after
+	// each release.
+	OpenDuration time.Duration
+	// Releases are the releases that kept the door unlocked, oldest first.
+	Releases []DoorReleaseEvent
+}
+
+// ProppedDoorDetector detects doors that are held open by being released over
+// and over, such as by someone propping a door through the app or a panel.
+// A door counts as held open if it is released more than MaxReleases times in
+// a row, each release happening before the door relocked after the previous
+// one.
+//
+// Door releases only refer to the panel they were made at, so doors are told
+// apart by panel. The zero value is ready to use. A ProppedDoorDetector is
+// safe for concurrent use, but its fields must not be modified once it is in
+// use.
+type ProppedDoorDetector struct {
+	// MaxReleases is the number of releases in a row allowed before the door
+	// counts as held open. It defaults to [DefaultProppedDoorMaxReleases].
+	MaxReleases int
+	// Topology is used to find out how long a door stays unlocked after a
+	// release. See [Topology.OpenDurationOfPanel]. It may be nil.
+	Topology *Topology
+	// OpenDuration is how long a door is assumed to stay unlocked after a
+	// release if Topology doesn't know. It defaults to
+	// [DefaultProppedDoorOpenDuration].
+	OpenDuration time.Duration
+	// OnProppedDoor, if set, is called once per streak of releases, when
+	// the streak exceeds MaxReleases. It is called synchronously from
+	// [ProppedDoorDetector.Observe], so it should return quickly.
+	OnProppedDoor func(alert ProppedDoorAlert)
+
+	mu      sync.Mutex
+	streaks map[ID]*releaseStreak // by panel ID
+}
+
+// releaseStreak holds the releases of a door that were each made before the
+// door relocked after the previous one.
+type releaseStreak struct {
+	releases []DoorReleaseEvent
+	alerted  bool
+}
+
+// Observe records a door release event and reports whether it made the door
+// count as held open, in which case the alert is also passed to
+// OnProppedDoor. Events of the same panel must be observed in the order they
+// were logged; events older than the last one of their panel are ignored.
+// Events without a panel are ignored.
+func (d *ProppedDoorDetector) Observe(event DoorReleaseEvent) (ProppedDoorAlert, bool) {
+	if event.PanelID == 0 {
+		return ProppedDoorAlert{}, false
+	}
+	openDuration := d.openDuration(event.PanelID)
+
+	d.mu.Lock()
+	if d.streaks == nil {
+		d.streaks = make(map[ID]*releaseStreak)
+	}
+	streak := d.streaks[event.PanelID]
+	if streak == nil {
+		streak = &releaseStreak{}
+		d.streaks[event.PanelID] = streak
+	}
+
+	if n := len(streak.releases); n > 0 {
+		last := streak.releases[n-1].LoggedAt
+		if event.LoggedAt.Before(last) {
+			d.mu.Unlock()
+			return ProppedDoorAlert{}, false
+		}
+		if event.LoggedAt.Sub(last) > openDuration {
+			// The door relocked since the last release.
+			*streak = releaseStreak{}
+		}
+	}
+	streak.releases = append(streak.releases, event)
+
+	if streak.alerted || len(streak.releases) <= use(d.MaxReleases, DefaultProppedDoorMaxReleases) {
+		d.mu.Unlock()
+		return ProppedDoorAlert{}, false
+	}
+	streak.alerted = true
+	alert := ProppedDoorAlert{
+		PanelID:      event.PanelID,
+		PanelName:    event.PanelName,
+		OpenDuration: openDuration,
+		Releases:     slices.Clone(streak.releases),
+	}
+	d.mu.Unlock()
+
+	if d.OnProppedDoor != nil {
+		d.OnProppedDoor(alert)
+	}
+	return alert, true
+}
+
+func (d *ProppedDoorDetector) openDuration(panelID ID) time.Duration {
+	if d.Topology != nil {
+		if openDuration, ok := d.Topology.OpenDurationOfPanel(panelID); ok {
+			return openDuration
+		}
+	}
+	return use(d.OpenDuration, DefaultProppedDoorOpenDuration)
+}
@@ -0,0 +1,72 @@
+package butterflymx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestProppedDoorDetector(t *testing.T) {
+	topology := NewTopology()
+	topology.AddTenant(
+		Tenant{Building: Building{ID: BuildingTaggedID(300)}},
+		[]AccessPoint{
+			{ID: AccessPointTaggedID(400), OpenDuration: 5},
+			{ID: AccessPointTaggedID(401), OpenDuration: 10},
+		},
+	)
+	var panel Panel
+	panel.ID = 500
+	panel.Relationships.Building.Data = &RawReference{ID: 300, Type: TypeBuilding}
+	topology.addPanel(&panel)
+
+	openDuration, ok := topology.OpenDurationOfPanel(500)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, openDuration)
+	_, ok = topology.OpenDurationOfPanel(501)
+	assert.False(t, ok)
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	release := func(panelID ID, after time.Duration) DoorReleaseEvent {
+		var event DoorReleaseEvent
+		event.PanelID = panelID
+		event.LoggedAt = start.Add(after)
+		return event
+	}
+
+	var alerts []ProppedDoorAlert
+	detector := &ProppedDoorDetector{
+		MaxReleases:   2,
+		Topology:      topology,
+		OnProppedDoor: func(alert ProppedDoorAlert) { alerts = append(alerts, alert) },
+	}
+
+	observe := func(event DoorReleaseEvent) bool {
+		_, alerted := detector.Observe(event)
+		return alerted
+	}
+
+	// Panel 500 stays unlocked for 10s after each release.
+	assert.False(t, observe(release(500, 0)))
+	assert.False(t, observe(release(500, 8*time.Second)))
+	assert.True(t, observe(release(500, 16*time.Second)), "third release in a row should alert")
+	assert.False(t, observe(release(500, 24*time.Second)), "a streak should only alert once")
+	assert.False(t, observe(release(500, 20*time.Second)), "older events should be ignored")
+
+	// The door relocked, so a new streak starts.
+	assert.False(t, observe(release(500, time.Minute)))
+	assert.False(t, observe(release(500, time.Minute+5*time.Second)))
+	assert.True(t, observe(release(500, time.Minute+10*time.Second)))
+
+	// Panel 501 is unknown to the topology, so the default of 5s is used.
+	assert.False(t, observe(release(501, 0)))
+	assert.False(t, observe(release(501, 8*time.Second)))
+	assert.False(t, observe(release(501, 16*time.Second)))
+
+	assert.Equal(t, 2, len(alerts))
+	assert.Equal(t, ID(500), alerts[0].PanelID)
+	assert.Equal(t, 10*time.Second, alerts[0].OpenDuration)
+	assert.Equal(t, 3, len(alerts[0].Releases))
+	assert.Equal(t, start, alerts[0].Releases[0].LoggedAt)
+}
@@ -0,0 +1,211 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProvisionEntry is a keychain to create using [ProvisionKeychains], such as
+// one row of a property manager's spreadsheet.
+type ProvisionEntry struct {
+	// TenantID is the numeric ID of the tenant to create the keychain for.
+	TenantID ID
+	// AccessPointIDs are the numeric IDs of the access points that the
+	// keychain grants access to.
+	AccessPointIDs []ID
+	// Keychain holds the attributes of the keychain.
+	Keychain CustomKeychainArgs
+	// Recipients get a virtual key each. If empty, the keychain is created
+	// without virtual keys.
+	Recipients []VirtualKeyRecipient
+}
+
+// ProvisionOpts holds optional parameters for [ProvisionKeychains].
+type ProvisionOpts struct {
+	// Bulk configures the pacing and progress reporting of the entries. See
+	// [RunBulk].
+	Bulk BulkOpts
+	// Rollback makes [ProvisionKeychains] revoke every virtual key it created
+	// if any entry fails, so that a batch is either provisioned completely or
+	// not at all.
+	Rollback bool
+}
+
+// ProvisionResult describes the outcome of [ProvisionKeychains]. Each slice
+// holds the outcome of the entry at the same index.
+type ProvisionResult struct {
+	// Keychains holds the created keychain of each entry, or nil if it wasn't
+	// created.
+	Keychains []*ResultWithReferences[Keychain]
+	// VirtualKeys holds the created virtual keys of each entry.
+	VirtualKeys [][]VirtualKey
+	// Errors holds the error of each entry, or nil if it succeeded.
+	Errors []error
+	// RolledBack is true if the virtual keys of the batch were revoked
+	// because an entry failed.
+	RolledBack bool
+}
+
+// ProvisionKeychains creates a keychain with virtual keys for each entry, for
+// example to hand out access to every unit of a building at once. The entries
+// are paced using [RunBulk] to avoid being rate limited, and opts.Bulk.Progress
+// is called after each entry. The options are passed to every API call made.
+//
+// If an entry fails, the others are still provisioned, unless opts.Rollback is
+// set, in which case every virtual key created by the batch is revoked once
+// all entries are done. The API has no way to delete keychains, so the
+// keychains themselves are left behind without virtual keys.
+//
+// The returned error joins the errors of the failed entries and of the
+// rollback, if any. The result is always returned.
+func ProvisionKeychains(ctx context.Context, client Client, entries []ProvisionEntry, opts *ProvisionOpts, callOpts ...CallOption) (*ProvisionResult, error) {
+	opts = use(opts, &ProvisionOpts{})
+
+	result := &ProvisionResult{
+		Keychains:   make([]*ResultWithReferences[Keychain], len(entries)),
+		VirtualKeys: make([][]VirtualKey, len(entries)),
+	}
+
+	ops := make([]BulkOp, len(entries))
+	for i, entry := range entries {
+		ops[i] = func(ctx context.Context) error {
+			// Retries of rate limited entries must not create the keychain
+			// again.
+			if result.Keychains[i] == nil {
+				keychain, err := client.CreateCustomKeychain(ctx, entry.TenantID, entry.AccessPointIDs, entry.Keychain, callOpts...)
+				if err != nil {
+					return fmt.Errorf("failed to create keychain: %w", err)
+				}
+				result.Keychains[i] = keychain
+			}
+
+			if len(entry.Recipients) == 0 {
+				return nil
+			}
+
+			keychainID := result.Keychains[i].Data.ID
+			virtualKeys, err := client.CreateVirtualKeys(ctx, keychainID, VirtualKeyArgs{Recipients: entry.Recipients}, callOpts...)
+			if err != nil {
+				return fmt.Errorf("failed to create virtual keys of keychain %d: %w", keychainID, err)
+			}
+			result.VirtualKeys[i] = virtualKeys.Data
+			return nil
+		}
+	}
+
+	result.Errors = RunBulk(ctx, ops, &opts.Bulk)
+
+	var errs []error
+	for i, err := range result.Errors {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry %d (tenant %d, %q): %w", i, entries[i].TenantID, entries[i].Keychain.Name, err))
+		}
+	}
+
+	if len(errs) > 0 && opts.Rollback {
+		result.RolledBack = true
+		// The batch failed, possibly because ctx ended, so the rollback
+		// must not depend on it.
+		rollbackCtx := context.WithoutCancel(ctx)
+		for i, virtualKeys := range result.VirtualKeys {
+			for _, virtualKey := range virtualKeys {
+				keychainID := result.Keychains[i].Data.ID
+				if err := client.RevokeVirtualKey(rollbackCtx, keychainID, virtualKey.ID, callOpts...); err != nil {
+					errs = append(errs, fmt.Errorf("rollback: failed to revoke virtual key %d of keychain %d: %w", virtualKey.ID, keychainID, err))
+				}
+			}
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// ProvisionCSVHeader is the header expected by [ReadProvisionCSV].
+var ProvisionCSVHeader = []string{"tenant_id", "access_point_ids", "name", "starts_at", "ends_at", "recipients"}
+
+// ReadProvisionCSV reads [ProvisionEntry] values from CSV, one keychain per
+// row, with the columns of [ProvisionCSVHeader]:
+//
+//   - tenant_id is the numeric ID of the tenant.
+//   - access_point_ids are numeric access point IDs separated by spaces.
+//   - starts_at and ends_at are in RFC 3339 format.
+//   - recipients are email addresses separated by spaces, each of which gets a
+//     virtual key. The email address is also used as the recipient's name.
+//
+// The first row must be the header.
+func ReadProvisionCSV(r io.Reader) ([]ProvisionEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(ProvisionCSVHeader)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if !slices.Equal(header, ProvisionCSVHeader) {
+		return nil, fmt.Errorf("unexpected header %q, expected %q", header, ProvisionCSVHeader)
+	}
+
+	var entries []ProvisionEntry
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		entry, err := parseProvisionRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func parseProvisionRecord(record []string) (ProvisionEntry, error) {
+	var entry ProvisionEntry
+
+	tenantID, err := strconv.Atoi(record[0])
+	if err != nil {
+		return entry, fmt.Errorf("invalid tenant_id: %w", err)
+	}
+	entry.TenantID = ID(tenantID)
+
+	for _, field := range strings.Fields(record[1]) {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return entry, fmt.Errorf("invalid access_point_ids: %w", err)
+		}
+		entry.AccessPointIDs = append(entry.AccessPointIDs, ID(id))
+	}
+	if len(entry.AccessPointIDs) == 0 {
+		return entry, errors.New("access_point_ids is empty")
+	}
+
+	entry.Keychain.Name = record[2]
+
+	if entry.Keychain.StartsAt, err = time.Parse(time.RFC3339, record[3]); err != nil {
+		return entry, fmt.Errorf("invalid starts_at: %w", err)
+	}
+	if entry.Keychain.EndsAt, err = time.Parse(time.RFC3339, record[4]); err != nil {
+		return entry, fmt.Errorf("invalid ends_at: %w", err)
+	}
+
+	for _, email := range strings.Fields(record[5]) {
+		entry.Recipients = append(entry.Recipients, VirtualKeyRecipient{Name: email, DeliverTo: email})
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,94 @@
+package butterflymx_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestProvisionKeychains(t *testing.T) {
+	newFake := func() *fakebmx.Client {
+		tenant := func(id, unit int) bmxtest.Tenant {
+			return bmxtest.Tenant{
+				Tenant: butterflymx.Tenant{
+					ID:       butterflymx.TenantTaggedID(butterflymx.ID(id)),
+					Unit:     butterflymx.Unit{ID: butterflymx.UnitTaggedID(butterflymx.ID(unit))},
+					Building: butterflymx.Building{ID: butterflymx.BuildingTaggedID(300), Name: "Building"},
+				},
+				AccessPoints: groupTestAccessPoints,
+			}
+		}
+		return fakebmx.New(&bmxtest.Data{
+			Tenants: []bmxtest.Tenant{tenant(100, 200), tenant(101, 201)},
+		})
+	}
+
+	csvInput := strings.Join([]string{
+		"tenant_id,access_point_ids,name,starts_at,ends_at,recipients",
+		"100,400 401,Move-in,2025-01-01T00:00:00Z,2026-01-01T00:00:00Z,jane@example.com john@example.com",
+		"101,400,Move-in,2025-01-01T00:00:00Z,2026-01-01T00:00:00Z,alex@example.com",
+	}, "\n")
+
+	entries, err := butterflymx.ReadProvisionCSV(strings.NewReader(csvInput))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, butterflymx.ProvisionEntry{
+		TenantID:       100,
+		AccessPointIDs: []butterflymx.ID{400, 401},
+		Keychain: butterflymx.CustomKeychainArgs{
+			Name:     "Move-in",
+			StartsAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndsAt:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Recipients: []butterflymx.VirtualKeyRecipient{
+			{Name: "jane@example.com", DeliverTo: "jane@example.com"},
+			{Name: "john@example.com", DeliverTo: "john@example.com"},
+		},
+	}, entries[0])
+
+	bulk := butterflymx.BulkOpts{Concurrency: 1, Interval: time.Nanosecond}
+
+	t.Run("provisioned", func(t *testing.T) {
+		fake := newFake()
+		var progress []butterflymx.BulkProgress
+		opts := &butterflymx.ProvisionOpts{Bulk: bulk}
+		opts.Bulk.Progress = func(p butterflymx.BulkProgress) { progress = append(progress, p) }
+
+		result, err := butterflymx.ProvisionKeychains(t.Context(), fake, entries, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(result.VirtualKeys[0]))
+		assert.Equal(t, 1, len(result.VirtualKeys[1]))
+		assert.Equal(t, []butterflymx.BulkProgress{{Total: 2, Done: 1}, {Total: 2, Done: 2}}, progress)
+		assert.False(t, result.RolledBack)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		fake := newFake()
+		failing := append(entries[:1:1], butterflymx.ProvisionEntry{
+			TenantID:       101,
+			AccessPointIDs: []butterflymx.ID{999},
+			Keychain:       entries[1].Keychain,
+		})
+
+		result, err := butterflymx.ProvisionKeychains(t.Context(), fake, failing, &butterflymx.ProvisionOpts{Bulk: bulk, Rollback: true})
+		assert.Error(t, err)
+		assert.NoError(t, result.Errors[0])
+		assert.Error(t, result.Errors[1])
+		assert.True(t, result.RolledBack)
+
+		assert.Equal(t, 2, len(fake.CallsTo("RevokeVirtualKey")))
+		keychain, err := fake.Keychain(t.Context(), result.Keychains[0].Data.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(keychain.Data.Relationships.VirtualKeys))
+	})
+
+	t.Run("invalid CSV", func(t *testing.T) {
+		_, err := butterflymx.ReadProvisionCSV(strings.NewReader("tenant_id,access_point_ids,name,starts_at,ends_at,recipients\n100,,Move-in,2025-01-01T00:00:00Z,2026-01-01T00:00:00Z,\n"))
+		assert.EqualError(t, err, "line 2: access_point_ids is empty")
+	})
+}
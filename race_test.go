@@ -0,0 +1,211 @@
+package butterflymx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"github.com/neilotoole/slogt"
+	"libdb.so/go-butterflymx/httpmock"
+)
+
+// These tests are most useful when run with -race.
+
+// countingTokenSource hands out a new token on every call.
+type countingTokenSource struct {
+	calls atomic.Int32
+}
+
+func (s *countingTokenSource) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
+	n := s.calls.Add(1)
+	return APIStaticToken(fmt.Sprintf("token-%d", n)), nil
+}
+
+type tokenSourceFunc func(ctx context.Context, renew bool) (APIStaticToken, error)
+
+func (f tokenSourceFunc) APIToken(ctx context.Context, renew bool) (APIStaticToken, error) {
+	return f(ctx, renew)
+}
+
+func TestAPIClient_concurrent(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+	accessCodesResponse := readFileAsResponseBody(t, "testdata/api-get-v3-access-codes.json")
+
+	router := httpmock.NewRouter(t,
+		httpmock.Route{
+			Method:   http.MethodGet,
+			Path:     "/v3/keychains/*",
+			Times:    httpmock.Unlimited,
+			Response: httpmock.RoundTripResponse{Body: keychainResponse},
+		},
+		httpmock.Route{
+			Method:   http.MethodGet,
+			Path:     "/v3/access_codes",
+			Times:    httpmock.Unlimited,
+			Response: httpmock.RoundTripResponse{Body: accessCodesResponse},
+		},
+	)
+
+	src := &countingTokenSource{}
+	client := NewAPIClient(ReuseAPITokenSource(src), &APIClientOpts{
+		HTTPClient: &http.Client{Transport: router},
+		Logger:     slogt.New(t),
+	})
+
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Go(func() {
+			_, err := client.Keychain(t.Context(), 10001)
+			assert.NoError(t, err)
+		})
+		wg.Go(func() {
+			results, err := client.Keychains(t.Context(), 10001, ActiveAccessCode)
+			assert.NoError(t, err)
+
+			// Resolving references of a shared result is read-only.
+			for _, keychain := range results.Data {
+				_, err := CollectResults(keychain.Relationships.VirtualKeys.Resolve(results.Refs))
+				assert.NoError(t, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, int(src.calls.Load()), "token should be fetched once and reused")
+}
+
+func TestReuseAPITokenSource_concurrent(t *testing.T) {
+	var inflight atomic.Int32
+	src := tokenSourceFunc(func(ctx context.Context, renew bool) (APIStaticToken, error) {
+		if inflight.Add(1) > 1 {
+			t.Error("underlying token source called concurrently")
+		}
+		defer inflight.Add(-1)
+		return "token", nil
+	})
+	reused := ReuseAPITokenSource(src)
+
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Go(func() {
+			token, err := reused.APIToken(t.Context(), i%4 == 0)
+			assert.NoError(t, err)
+			assert.Equal(t, "token", token)
+		})
+	}
+	wg.Wait()
+}
+
+// blockingRoundTripper serves the same response to every request, but only
+// once release is closed.
+type blockingRoundTripper struct {
+	release  chan struct{}
+	body     []byte
+	requests atomic.Int32
+}
+
+func (rt *blockingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests.Add(1)
+	select {
+	case <-rt.release:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(rt.body)),
+		Request:    req,
+	}, nil
+}
+
+func TestAPIClient_coalescing(t *testing.T) {
+	keychainResponse := readFileAsResponseBody(t, "testdata/api-get-v3-keychains-id.json")
+
+	test := func(t *testing.T, disable bool, opts func(i int) []CallOption) int {
+		var requests int
+		synctest.Test(t, func(t *testing.T) {
+			rt := &blockingRoundTripper{release: make(chan struct{}), body: keychainResponse}
+			client := NewAPIClient(mockToken, &APIClientOpts{
+				HTTPClient:        &http.Client{Transport: rt},
+				Logger:            slogt.New(t),
+				DisableCoalescing: disable,
+			})
+
+			var wg sync.WaitGroup
+			for i := range 8 {
+				wg.Go(func() {
+					keychain, err := client.Keychain(t.Context(), 10001, opts(i)...)
+					assert.NoError(t, err)
+					assert.Equal(t, ID(10001), keychain.Data.ID)
+				})
+			}
+
+			// Wait for every goroutine to either be in the round tripper or
+			// waiting on another one that is.
+			synctest.Wait()
+			close(rt.release)
+			wg.Wait()
+
+			requests = int(rt.requests.Load())
+		})
+		return requests
+	}
+
+	noOpts := func(int) []CallOption { return nil }
+
+	t.Run("coalesced", func(t *testing.T) {
+		assert.Equal(t, 1, test(t, false, noOpts))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		assert.Equal(t, 8, test(t, true, noOpts))
+	})
+
+	t.Run("different timeouts", func(t *testing.T) {
+		// A call must not be bound by the timeout of another call.
+		requests := test(t, false, func(i int) []CallOption {
+			return []CallOption{WithTimeout(time.Duration(i%2+1) * time.Minute)}
+		})
+		assert.Equal(t, 2, requests)
+	})
+
+	t.Run("canceled leader", func(t *testing.T) {
+		synctest.Test(t, func(t *testing.T) {
+			rt := &blockingRoundTripper{release: make(chan struct{}), body: keychainResponse}
+			client := NewAPIClient(mockToken, &APIClientOpts{
+				HTTPClient: &http.Client{Transport: rt},
+				Logger:     slogt.New(t),
+			})
+
+			leaderCtx, cancelLeader := context.WithCancel(t.Context())
+			var wg sync.WaitGroup
+			wg.Go(func() {
+				_, err := client.Keychain(leaderCtx, 10001)
+				assert.IsError(t, err, context.Canceled)
+			})
+			synctest.Wait()
+
+			wg.Go(func() {
+				_, err := client.Keychain(t.Context(), 10001)
+				assert.NoError(t, err, "follower should retry on its own")
+			})
+			synctest.Wait()
+
+			cancelLeader()
+			synctest.Wait()
+			close(rt.release)
+			wg.Wait()
+
+			assert.Equal(t, 2, int(rt.requests.Load()))
+		})
+	})
+}
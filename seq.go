@@ -0,0 +1,116 @@
+package butterflymx
+
+import (
+	"errors"
+	"iter"
+)
+
+// ErrNoResults is returned by [First] when the iterator yields nothing.
+var ErrNoResults = errors.New("no results")
+
+// CollectResults collects all results from the given iterator into a slice,
+// returning an error if any occurred during iteration.
+func CollectResults[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var results []T
+	for v, err := range seq {
+		if err != nil {
+			return results, err
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+// CollectN is like [CollectResults], but stops after collecting n results.
+// Since the iteration stops early, no further pages are fetched.
+func CollectN[T any](seq iter.Seq2[T, error], n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	results := make([]T, 0, n)
+	for v, err := range seq {
+		if err != nil {
+			return results, err
+		}
+		results = append(results, v)
+		if len(results) == n {
+			break
+		}
+	}
+	return results, nil
+}
+
+// First returns the first result of the given iterator, or [ErrNoResults] if
+// there is none.
+func First[T any](seq iter.Seq2[T, error]) (T, error) {
+	for v, err := range seq {
+		return v, err
+	}
+	var zero T
+	return zero, ErrNoResults
+}
+
+// CollectMap collects all results from the given iterator into a map keyed by
+// keyFn, returning an error if any occurred during iteration. If multiple
+// results have the same key, the last one is kept.
+func CollectMap[K comparable, T any](seq iter.Seq2[T, error], keyFn func(T) K) (map[K]T, error) {
+	results := make(map[K]T)
+	for v, err := range seq {
+		if err != nil {
+			return results, err
+		}
+		results[keyFn(v)] = v
+	}
+	return results, nil
+}
+
+// FilterSeq2 returns an iterator over the results of seq for which keep
+// returns true. Errors are always passed through.
+func FilterSeq2[T any](seq iter.Seq2[T, error], keep func(T) bool) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if err == nil && !keep(v) {
+				continue
+			}
+			if !yield(v, err) {
+				return
+			}
+		}
+	}
+}
+
+// MapSeq2 returns an iterator over the results of seq transformed by fn.
+// Errors are passed through with the zero value of U.
+func MapSeq2[T, U any](seq iter.Seq2[T, error], fn func(T) U) iter.Seq2[U, error] {
+	return func(yield func(U, error) bool) {
+		for v, err := range seq {
+			var u U
+			if err == nil {
+				u = fn(v)
+			}
+			if !yield(u, err) {
+				return
+			}
+		}
+	}
+}
+
+// LimitSeq2 returns an iterator over at most the first n results of seq. An
+// error counts as a result. The iteration over seq stops once the limit is
+// reached, so no further pages are fetched.
+func LimitSeq2[T any](seq iter.Seq2[T, error], n int) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if n <= 0 {
+			return
+		}
+		var i int
+		for v, err := range seq {
+			if !yield(v, err) {
+				return
+			}
+			if i++; i == n {
+				return
+			}
+		}
+	}
+}
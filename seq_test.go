@@ -0,0 +1,107 @@
+package butterflymx
+
+import (
+	"errors"
+	"iter"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// seqOf returns an iterator over vs, followed by err if it is not nil. It
+// records how many values were pulled in *pulled.
+func seqOf[T any](pulled *int, err error, vs ...T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for _, v := range vs {
+			*pulled++
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+func TestCollectN(t *testing.T) {
+	var pulled int
+	got, err := CollectN(seqOf(&pulled, nil, 1, 2, 3, 4), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, got)
+	assert.Equal(t, 2, pulled, "iteration should stop after n results")
+
+	got, err = CollectN(seqOf(&pulled, nil, 1), 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1}, got)
+
+	errBoom := errors.New("boom")
+	got, err = CollectN(seqOf(&pulled, errBoom, 1), 5)
+	assert.IsError(t, err, errBoom)
+	assert.Equal(t, []int{1}, got)
+}
+
+func TestFirst(t *testing.T) {
+	var pulled int
+	got, err := First(seqOf(&pulled, nil, "a", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", got)
+	assert.Equal(t, 1, pulled)
+
+	_, err = First(seqOf[string](&pulled, nil))
+	assert.IsError(t, err, ErrNoResults)
+
+	errBoom := errors.New("boom")
+	_, err = First(seqOf[string](&pulled, errBoom))
+	assert.IsError(t, err, errBoom)
+}
+
+func TestCollectMap(t *testing.T) {
+	var pulled int
+	accessPoints := []AccessPoint{
+		{ID: NewTaggedID("access_point", 1), Name: "Front Door"},
+		{ID: NewTaggedID("access_point", 2), Name: "Garage"},
+	}
+
+	got, err := CollectMap(seqOf(&pulled, nil, accessPoints...), func(ap AccessPoint) string { return ap.Name })
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]AccessPoint{
+		"Front Door": accessPoints[0],
+		"Garage":     accessPoints[1],
+	}, got)
+}
+
+func TestSeq2Combinators(t *testing.T) {
+	var pulled int
+	accessPoints := []AccessPoint{
+		{ID: NewTaggedID("access_point", 1), Name: "Front Door", Online: true},
+		{ID: NewTaggedID("access_point", 2), Name: "Garage", Online: false},
+		{ID: NewTaggedID("access_point", 3), Name: "Pool", Online: true},
+		{ID: NewTaggedID("access_point", 4), Name: "Gym", Online: true},
+	}
+
+	online := FilterSeq2(seqOf(&pulled, nil, accessPoints...), func(ap AccessPoint) bool { return ap.Online })
+	names := MapSeq2(online, func(ap AccessPoint) string { return ap.Name })
+
+	got, err := CollectResults(LimitSeq2(names, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Front Door", "Pool"}, got)
+	assert.Equal(t, 3, pulled, "iteration should stop once the limit is reached")
+
+	t.Run("errors", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		seq := seqOf(&pulled, errBoom, accessPoints...)
+		seq = FilterSeq2(seq, func(AccessPoint) bool { return false })
+
+		got, err := CollectResults(MapSeq2(seq, func(ap AccessPoint) string { return ap.Name }))
+		assert.IsError(t, err, errBoom, "errors should not be filtered out")
+		assert.Zero(t, got)
+	})
+
+	t.Run("limit zero", func(t *testing.T) {
+		got, err := CollectResults(LimitSeq2(seqOf(&pulled, nil, 1, 2), 0))
+		assert.NoError(t, err)
+		assert.Zero(t, got)
+	})
+}
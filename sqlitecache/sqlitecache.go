@@ -0,0 +1,475 @@
+//go:build goexperiment.jsonv2
+
+// Package sqlitecache mirrors ButterflyMX data into a local SQLite database.
+//
+// The cache stores tenants, access points, keychains and door releases so that
+// they can be queried offline, e.g. for dashboards over historical access
+// logs. This package does not import a SQLite driver itself; the caller is
+// expected to open the [sql.DB] using a driver of their choice, such as
+// modernc.org/sqlite or github.com/mattn/go-sqlite3.
+package sqlitecache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"libdb.so/go-butterflymx"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS tenants (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL,
+	unit_id     TEXT NOT NULL,
+	unit_label  TEXT NOT NULL,
+	building_id TEXT NOT NULL,
+	building    TEXT NOT NULL,
+	synced_at   INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_points (
+	id            TEXT NOT NULL,
+	tenant_id     TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	open_duration INTEGER NOT NULL,
+	online        INTEGER NOT NULL,
+	synced_at     INTEGER NOT NULL,
+	PRIMARY KEY (id, tenant_id)
+);
+
+CREATE TABLE IF NOT EXISTS keychains (
+	id        INTEGER PRIMARY KEY,
+	tenant_id TEXT NOT NULL,
+	name      TEXT NOT NULL,
+	kind      TEXT NOT NULL,
+	starts_at INTEGER NOT NULL,
+	ends_at   INTEGER NOT NULL,
+	active    INTEGER NOT NULL,
+	synced_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS door_releases (
+	id             INTEGER PRIMARY KEY,
+	keychain_id    INTEGER NOT NULL,
+	virtual_key_id INTEGER NOT NULL,
+	panel_id       INTEGER NOT NULL,
+	panel_name     TEXT NOT NULL,
+	name           TEXT NOT NULL,
+	release_method TEXT NOT NULL,
+	logged_at      INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS door_releases_logged_at ON door_releases (logged_at);
+`
+
+// Cache is a SQLite-backed mirror of the ButterflyMX data visible to a single
+// account.
+//
+// A Cache is safe for concurrent use. Concurrent calls to [Cache.Sync] are
+// serialized, while queries may run concurrently with a sync and see the
+// state before it.
+type Cache struct {
+	db     *sql.DB
+	client butterflymx.Client
+	syncMu sync.Mutex
+}
+
+// New creates a new [Cache] using the given database and client. It creates
+// the database schema if it does not exist yet.
+func New(ctx context.Context, db *sql.DB, client butterflymx.Client) (*Cache, error) {
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+	return &Cache{db: db, client: client}, nil
+}
+
+// SyncStats describes what a single [Cache.Sync] call changed.
+type SyncStats struct {
+	Tenants         int
+	AccessPoints    int
+	Keychains       int
+	NewDoorReleases int
+}
+
+// snapshot is the state of a tenant fetched from the API by [Cache.Sync].
+type snapshot struct {
+	tenant       butterflymx.Tenant
+	accessPoints []butterflymx.AccessPoint
+	keychains    []butterflymx.Keychain
+	releases     []DoorRelease
+}
+
+// Sync performs a full sync of the cache from the API.
+//
+// The API has no way to only list what changed since a given time, so every
+// tenant, access point and active keychain is fetched on every call. All of
+// them are fetched before anything is written, so a failed fetch leaves the
+// cache untouched, and the database is only locked for the duration of the
+// writes.
+//
+// Tenants, access points and active keychains are replaced with their current
+// state. Keychains that are no longer active are kept but marked as inactive.
+// Door releases are only ever added, so the cache accumulates history beyond
+// what the API returns at any given time.
+func (c *Cache) Sync(ctx context.Context) (SyncStats, error) {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	snapshots, err := c.fetch(ctx)
+	if err != nil {
+		return SyncStats{}, err
+	}
+	// Nanoseconds make sure that rows written by a previous sync within the
+	// same second are still considered stale.
+	return c.store(ctx, snapshots, time.Now().UnixNano())
+}
+
+// fetch fetches the state of every tenant from the API.
+func (c *Cache) fetch(ctx context.Context) ([]snapshot, error) {
+	tenants, err := butterflymx.CollectResults(c.client.Tenants(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tenants: %w", err)
+	}
+
+	snapshots := make([]snapshot, len(tenants))
+	for i, tenant := range tenants {
+		s := &snapshots[i]
+		s.tenant = tenant
+
+		s.accessPoints, err = butterflymx.CollectResults(c.client.TenantAccessPoints(ctx, tenant.ID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch access points for tenant %v: %w", tenant.ID, err)
+		}
+
+		keychains, err := c.client.Keychains(ctx, tenant.ID.Number, butterflymx.ActiveAccessCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch keychains for tenant %v: %w", tenant.ID, err)
+		}
+		s.keychains = keychains.Data
+
+		for _, keychain := range keychains.Data {
+			releases, err := keychainDoorReleases(keychain, keychains.Refs)
+			if err != nil {
+				return nil, err
+			}
+			s.releases = append(s.releases, releases...)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// store writes the fetched snapshots in a single transaction.
+func (c *Cache) store(ctx context.Context, snapshots []snapshot, syncedAt int64) (SyncStats, error) {
+	var stats SyncStats
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, s := range snapshots {
+		if err := syncTenant(ctx, tx, s.tenant, syncedAt); err != nil {
+			return stats, err
+		}
+		stats.Tenants++
+
+		for _, ap := range s.accessPoints {
+			if err := syncAccessPoint(ctx, tx, s.tenant.ID, ap, syncedAt); err != nil {
+				return stats, err
+			}
+			stats.AccessPoints++
+		}
+
+		for _, keychain := range s.keychains {
+			if err := syncKeychain(ctx, tx, s.tenant.ID, keychain, syncedAt); err != nil {
+				return stats, err
+			}
+			stats.Keychains++
+		}
+
+		n, err := syncDoorReleases(ctx, tx, s.releases)
+		if err != nil {
+			return stats, err
+		}
+		stats.NewDoorReleases += n
+	}
+
+	// Anything that wasn't touched by this sync no longer exists upstream.
+	if err := errors.Join(
+		execStale(ctx, tx, `DELETE FROM tenants WHERE synced_at < ?`, syncedAt),
+		execStale(ctx, tx, `DELETE FROM access_points WHERE synced_at < ?`, syncedAt),
+		execStale(ctx, tx, `UPDATE keychains SET active = 0 WHERE synced_at < ?`, syncedAt),
+	); err != nil {
+		return stats, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("failed to commit sync: %w", err)
+	}
+
+	return stats, nil
+}
+
+func syncTenant(ctx context.Context, tx *sql.Tx, tenant butterflymx.Tenant, syncedAt int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tenants (id, name, unit_id, unit_label, building_id, building, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			unit_id = excluded.unit_id,
+			unit_label = excluded.unit_label,
+			building_id = excluded.building_id,
+			building = excluded.building,
+			synced_at = excluded.synced_at`,
+		tenant.ID.String(), tenant.Name,
+		tenant.Unit.ID.String(), tenant.Unit.Label,
+		tenant.Building.ID.String(), tenant.Building.Name,
+		syncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store tenant %v: %w", tenant.ID, err)
+	}
+	return nil
+}
+
+func syncAccessPoint(ctx context.Context, tx *sql.Tx, tenantID butterflymx.TaggedID, ap butterflymx.AccessPoint, syncedAt int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO access_points (id, tenant_id, name, open_duration, online, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id, tenant_id) DO UPDATE SET
+			name = excluded.name,
+			open_duration = excluded.open_duration,
+			online = excluded.online,
+			synced_at = excluded.synced_at`,
+		ap.ID.String(), tenantID.String(), ap.Name, ap.OpenDuration, ap.Online, syncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store access point %v: %w", ap.ID, err)
+	}
+	return nil
+}
+
+func syncKeychain(ctx context.Context, tx *sql.Tx, tenantID butterflymx.TaggedID, keychain butterflymx.Keychain, syncedAt int64) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO keychains (id, tenant_id, name, kind, starts_at, ends_at, active, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			name = excluded.name,
+			kind = excluded.kind,
+			starts_at = excluded.starts_at,
+			ends_at = excluded.ends_at,
+			active = 1,
+			synced_at = excluded.synced_at`,
+		int(keychain.ID), tenantID.String(),
+		keychain.Attributes.Name, string(keychain.Attributes.Kind),
+		keychain.Attributes.StartsAt.Unix(), keychain.Attributes.EndsAt.Unix(),
+		syncedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store keychain %v: %w", keychain.ID, err)
+	}
+	return nil
+}
+
+// keychainDoorReleases resolves all door releases of the given keychain.
+func keychainDoorReleases(keychain butterflymx.Keychain, refs map[butterflymx.ID]butterflymx.RawReference) ([]DoorRelease, error) {
+	var releases []DoorRelease
+
+	for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(refs) {
+		if err != nil {
+			return nil, fmt.Errorf("keychain %v: failed to resolve virtual key: %w", keychain.ID, err)
+		}
+
+		for release, err := range virtualKey.Relationships.DoorReleases.Resolve(refs) {
+			if err != nil {
+				return nil, fmt.Errorf("virtual key %v: failed to resolve door release: %w", virtualKey.ID, err)
+			}
+
+			r := DoorRelease{
+				ID:            release.ID,
+				KeychainID:    keychain.ID,
+				VirtualKeyID:  virtualKey.ID,
+				Name:          release.Attributes.Name,
+				ReleaseMethod: release.Attributes.ReleaseMethod,
+				LoggedAt:      release.Attributes.LoggedAt,
+			}
+			if panelRef := release.Relationships.Panel.Data; panelRef != nil {
+				panel, err := panelRef.Resolve(refs)
+				if err != nil {
+					return nil, fmt.Errorf("door release %v: failed to resolve panel: %w", release.ID, err)
+				}
+				r.PanelID = panel.ID
+				r.PanelName = panel.Attributes.Name
+			}
+			releases = append(releases, r)
+		}
+	}
+
+	return releases, nil
+}
+
+// syncDoorReleases stores the given door releases that are not already in the
+// cache. It returns the number of newly stored releases.
+func syncDoorReleases(ctx context.Context, tx *sql.Tx, releases []DoorRelease) (int, error) {
+	var n int
+
+	for _, r := range releases {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO door_releases (id, keychain_id, virtual_key_id, panel_id, panel_name, name, release_method, logged_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			int(r.ID), int(r.KeychainID), int(r.VirtualKeyID),
+			int(r.PanelID), r.PanelName,
+			r.Name, r.ReleaseMethod,
+			r.LoggedAt.Unix())
+		if err != nil {
+			return n, fmt.Errorf("failed to store door release %v: %w", r.ID, err)
+		}
+
+		if affected, err := res.RowsAffected(); err == nil {
+			n += int(affected)
+		}
+	}
+
+	return n, nil
+}
+
+func execStale(ctx context.Context, tx *sql.Tx, query string, syncedAt int64) error {
+	if _, err := tx.ExecContext(ctx, query, syncedAt); err != nil {
+		return fmt.Errorf("failed to prune stale rows: %w", err)
+	}
+	return nil
+}
+
+// DoorRelease is a door release as stored in the cache.
+type DoorRelease struct {
+	ID            butterflymx.ID
+	KeychainID    butterflymx.ID
+	VirtualKeyID  butterflymx.ID
+	PanelID       butterflymx.ID
+	PanelName     string
+	Name          string
+	ReleaseMethod string
+	LoggedAt      time.Time
+}
+
+// DoorReleases returns all cached door releases logged at or after since,
+// ordered from oldest to newest.
+func (c *Cache) DoorReleases(ctx context.Context, since time.Time) ([]DoorRelease, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, keychain_id, virtual_key_id, panel_id, panel_name, name, release_method, logged_at
+		FROM door_releases
+		WHERE logged_at >= ?
+		ORDER BY logged_at ASC`,
+		since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query door releases: %w", err)
+	}
+	defer rows.Close()
+
+	var releases []DoorRelease
+	for rows.Next() {
+		var r DoorRelease
+		var loggedAt int64
+		if err := rows.Scan(
+			&r.ID, &r.KeychainID, &r.VirtualKeyID,
+			&r.PanelID, &r.PanelName, &r.Name, &r.ReleaseMethod,
+			&loggedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan door release: %w", err)
+		}
+		r.LoggedAt = time.Unix(loggedAt, 0)
+		releases = append(releases, r)
+	}
+
+	return releases, rows.Err()
+}
+
+// AccessPoint is an access point as stored in the cache.
+type AccessPoint struct {
+	ID           butterflymx.TaggedID
+	TenantID     butterflymx.TaggedID
+	Name         string
+	OpenDuration int
+	Online       bool
+}
+
+// AccessPoints returns all cached access points for the given tenant.
+func (c *Cache) AccessPoints(ctx context.Context, tenantID butterflymx.TaggedID) ([]AccessPoint, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, tenant_id, name, open_duration, online
+		FROM access_points
+		WHERE tenant_id = ?
+		ORDER BY name ASC`,
+		tenantID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query access points: %w", err)
+	}
+	defer rows.Close()
+
+	var accessPoints []AccessPoint
+	for rows.Next() {
+		var ap AccessPoint
+		var id, tenant string
+		if err := rows.Scan(&id, &tenant, &ap.Name, &ap.OpenDuration, &ap.Online); err != nil {
+			return nil, fmt.Errorf("failed to scan access point: %w", err)
+		}
+		if err := errors.Join(
+			ap.ID.UnmarshalText([]byte(id)),
+			ap.TenantID.UnmarshalText([]byte(tenant)),
+		); err != nil {
+			return nil, fmt.Errorf("access point %q: %w", id, err)
+		}
+		accessPoints = append(accessPoints, ap)
+	}
+
+	return accessPoints, rows.Err()
+}
+
+// Keychain is a keychain as stored in the cache.
+type Keychain struct {
+	ID       butterflymx.ID
+	TenantID butterflymx.TaggedID
+	Name     string
+	Kind     butterflymx.KeychainKind
+	StartsAt time.Time
+	EndsAt   time.Time
+	// Active is false if the keychain was not returned as active during the
+	// last sync.
+	Active bool
+}
+
+// Keychains returns all cached keychains, including ones that are no longer
+// active.
+func (c *Cache) Keychains(ctx context.Context) ([]Keychain, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, tenant_id, name, kind, starts_at, ends_at, active
+		FROM keychains
+		ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keychains: %w", err)
+	}
+	defer rows.Close()
+
+	var keychains []Keychain
+	for rows.Next() {
+		var k Keychain
+		var tenant string
+		var startsAt, endsAt int64
+		if err := rows.Scan(&k.ID, &tenant, &k.Name, &k.Kind, &startsAt, &endsAt, &k.Active); err != nil {
+			return nil, fmt.Errorf("failed to scan keychain: %w", err)
+		}
+		if err := k.TenantID.UnmarshalText([]byte(tenant)); err != nil {
+			return nil, fmt.Errorf("keychain %v: %w", k.ID, err)
+		}
+		k.StartsAt = time.Unix(startsAt, 0)
+		k.EndsAt = time.Unix(endsAt, 0)
+		keychains = append(keychains, k)
+	}
+
+	return keychains, rows.Err()
+}
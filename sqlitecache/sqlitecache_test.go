@@ -0,0 +1,204 @@
+package sqlitecache
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+
+	_ "modernc.org/sqlite"
+)
+
+var testNow = time.Now().Truncate(time.Second)
+
+func testData() *bmxtest.Data {
+	return &bmxtest.Data{
+		Tenants: []bmxtest.Tenant{{
+			Tenant: butterflymx.Tenant{
+				ID:       butterflymx.NewTaggedID("tenant", 100),
+				Name:     "Tenant",
+				Unit:     butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"},
+				Building: butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"},
+			},
+			AccessPoints: []butterflymx.AccessPoint{
+				{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door", OpenDuration: 5, Online: true},
+				{ID: butterflymx.NewTaggedID("access_point", 401), Name: "Garage", OpenDuration: 10, Online: true},
+			},
+			Keychains: []bmxtest.Keychain{{
+				ID:             500,
+				Name:           "Guest",
+				Kind:           butterflymx.CustomKeychain,
+				StartsAt:       testNow.Add(-time.Hour),
+				EndsAt:         testNow.Add(time.Hour),
+				AccessPointIDs: []butterflymx.ID{400},
+				VirtualKeys: []bmxtest.VirtualKey{{
+					ID:   600,
+					Name: "guest",
+					DoorReleases: []bmxtest.DoorRelease{{
+						ID:            700,
+						AccessPointID: 400,
+						Name:          "guest",
+						ReleaseMethod: "pin",
+						LoggedAt:      testNow.Add(-time.Minute),
+					}},
+				}},
+			}},
+		}},
+	}
+}
+
+func newTestCache(t *testing.T, client butterflymx.Client) *Cache {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// Each connection to :memory: is its own database.
+	db.SetMaxOpenConns(1)
+
+	cache, err := New(t.Context(), db, client)
+	assert.NoError(t, err)
+	return cache
+}
+
+func TestCache_Sync(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		cache := newTestCache(t, fakebmx.New(&bmxtest.Data{}))
+
+		stats, err := cache.Sync(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, SyncStats{}, stats)
+
+		releases, err := cache.DoorReleases(t.Context(), time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(releases))
+
+		keychains, err := cache.Keychains(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(keychains))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		client := fakebmx.New(testData())
+		cache := newTestCache(t, client)
+		tenantID := butterflymx.NewTaggedID("tenant", 100)
+
+		stats, err := cache.Sync(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, SyncStats{Tenants: 1, AccessPoints: 2, Keychains: 1, NewDoorReleases: 1}, stats)
+
+		releases, err := cache.DoorReleases(t.Context(), time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, []DoorRelease{{
+			ID:            700,
+			KeychainID:    500,
+			VirtualKeyID:  600,
+			PanelID:       400,
+			PanelName:     "Front Door",
+			Name:          "guest",
+			ReleaseMethod: "pin",
+			LoggedAt:      testNow.Add(-time.Minute),
+		}}, releases)
+
+		client.Update(func(d *bmxtest.Data) {
+			tenant := &d.Tenants[0]
+			tenant.AccessPoints[1].Name = "Side Gate"
+			tenant.AccessPoints[1].Online = false
+
+			vk := &tenant.Keychains[0].VirtualKeys[0]
+			vk.DoorReleases = append(vk.DoorReleases, bmxtest.DoorRelease{
+				ID:            701,
+				AccessPointID: 400,
+				Name:          "guest",
+				ReleaseMethod: "pin",
+				LoggedAt:      testNow,
+			})
+		})
+
+		stats, err = cache.Sync(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, SyncStats{Tenants: 1, AccessPoints: 2, Keychains: 1, NewDoorReleases: 1}, stats)
+
+		releases, err = cache.DoorReleases(t.Context(), testNow)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(releases))
+		assert.Equal(t, 701, releases[0].ID)
+
+		accessPoints, err := cache.AccessPoints(t.Context(), tenantID)
+		assert.NoError(t, err)
+		assert.Equal(t, []AccessPoint{
+			{ID: butterflymx.NewTaggedID("access_point", 400), TenantID: tenantID, Name: "Front Door", OpenDuration: 5, Online: true},
+			{ID: butterflymx.NewTaggedID("access_point", 401), TenantID: tenantID, Name: "Side Gate", OpenDuration: 10, Online: false},
+		}, accessPoints)
+	})
+
+	t.Run("deletion", func(t *testing.T) {
+		client := fakebmx.New(testData())
+		cache := newTestCache(t, client)
+		tenantID := butterflymx.NewTaggedID("tenant", 100)
+
+		_, err := cache.Sync(t.Context())
+		assert.NoError(t, err)
+
+		client.Update(func(d *bmxtest.Data) {
+			tenant := &d.Tenants[0]
+			tenant.AccessPoints = tenant.AccessPoints[:1]
+			tenant.Keychains[0].EndsAt = testNow.Add(-time.Minute)
+		})
+
+		stats, err := cache.Sync(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, SyncStats{Tenants: 1, AccessPoints: 1}, stats)
+
+		accessPoints, err := cache.AccessPoints(t.Context(), tenantID)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(accessPoints))
+		assert.Equal(t, "Front Door", accessPoints[0].Name)
+
+		keychains, err := cache.Keychains(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(keychains))
+		assert.False(t, keychains[0].Active)
+
+		// Door releases of inactive keychains are kept as history.
+		releases, err := cache.DoorReleases(t.Context(), time.Time{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(releases))
+
+		client.Update(func(d *bmxtest.Data) { d.Tenants = nil })
+
+		_, err = cache.Sync(t.Context())
+		assert.NoError(t, err)
+
+		accessPoints, err = cache.AccessPoints(t.Context(), tenantID)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(accessPoints))
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		client := fakebmx.New(testData())
+		cache := newTestCache(t, client)
+
+		_, err := cache.Sync(t.Context())
+		assert.NoError(t, err)
+
+		client.Update(func(d *bmxtest.Data) { d.Tenants[0].AccessPoints = nil })
+
+		fetchErr := errors.New("keychains unavailable")
+		client.SetError("Keychains", fetchErr)
+
+		_, err = cache.Sync(t.Context())
+		assert.IsError(t, err, fetchErr)
+
+		// Nothing is written if any fetch fails.
+		accessPoints, err := cache.AccessPoints(t.Context(), butterflymx.NewTaggedID("tenant", 100))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(accessPoints))
+	})
+}
@@ -2,10 +2,15 @@ package butterflymx
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
 	"time"
 )
 
+// ErrInvalidWeekday is returned when a Weekday is not one of the known
+// weekdays.
+var ErrInvalidWeekday = errors.New("invalid weekday")
+
 // Weekday represents a day of the week.
 type Weekday string
 
@@ -19,7 +24,39 @@ const (
 	Sunday    Weekday = "sun"
 )
 
-// ToTimeWeekday converts the Weekday to [time.Weekday].
+var (
+	_ encoding.TextMarshaler   = (*Weekday)(nil)
+	_ encoding.TextUnmarshaler = (*Weekday)(nil)
+)
+
+// IsValid reports whether w is one of the known weekdays, such as [Monday].
+func (w Weekday) IsValid() bool {
+	return w.ToTimeWeekday() != -1
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler]. Unknown weekdays are
+// rejected with [ErrInvalidWeekday] rather than passed through, since they
+// would never match any day in schedule logic.
+func (w *Weekday) UnmarshalText(text []byte) error {
+	newWeekday := Weekday(text)
+	if !newWeekday.IsValid() {
+		return fmt.Errorf("%w %q", ErrInvalidWeekday, text)
+	}
+	*w = newWeekday
+	return nil
+}
+
+// MarshalText implements [encoding.TextMarshaler]. Like
+// [Weekday.UnmarshalText], it rejects unknown weekdays.
+func (w Weekday) MarshalText() ([]byte, error) {
+	if !w.IsValid() {
+		return nil, fmt.Errorf("%w %q", ErrInvalidWeekday, string(w))
+	}
+	return []byte(w), nil
+}
+
+// ToTimeWeekday converts the Weekday to [time.Weekday]. It returns -1 if the
+// Weekday is not valid.
 func (w Weekday) ToTimeWeekday() time.Weekday {
 	switch w {
 	case Monday:
@@ -74,13 +111,22 @@ func (d Datestamp) MarshalText() ([]byte, error) {
 
 // String returns the string representation of the Datestamp.
 func (d Datestamp) String() string {
-	return fmt.Sprintf("%d-%02d-%02d", d.Year, d.Month, d.Day)
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
 }
 
 // ToTime converts the Datestamp to a time.Time in the given timezone at
-// midnight.
+// midnight. If midnight doesn't exist on that date because of a DST gap, the
+// first instant of the day is returned instead.
 func (d Datestamp) ToTime(tz *time.Location) time.Time {
-	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, tz)
+	t := time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, tz)
+	if t.Day() != d.Day {
+		// time.Date picked a time on the previous day. Move forward by the
+		// size of the gap to get to the end of it.
+		_, before := t.Zone()
+		_, after := time.Date(d.Year, d.Month, d.Day, 12, 0, 0, 0, tz).Zone()
+		t = t.Add(time.Duration(after-before) * time.Second)
+	}
+	return t
 }
 
 // Timestamp represents a time of day in the format [TimestampLayout].
@@ -112,16 +158,16 @@ func (wt Timestamp) MarshalText() ([]byte, error) {
 	return []byte(wt.String()), nil
 }
 
-// String returns the string representation of the WatchTime.
+// String returns the string representation of the Timestamp.
 func (wt Timestamp) String() string {
 	return fmt.Sprintf("%02d:%02d", wt.Hour, wt.Minute)
 }
 
-// ToTime converts the WatchTime to a time.Time on the given date using that
-// date's timezone.
+// ToTime converts the Timestamp to a time.Time on the given date using that
+// date's timezone. The time of day is interpreted as wall clock time, so it is
+// unaffected by DST transitions earlier on the same day. If the time of day
+// doesn't exist on that date because of a DST gap, it is normalized the same
+// way as [time.Date] does.
 func (wt Timestamp) ToTime(date time.Time) time.Time {
-	date = date.Truncate(24 * time.Hour)
-	date = date.Add(time.Duration(wt.Hour) * time.Hour)
-	date = date.Add(time.Duration(wt.Minute) * time.Minute)
-	return date
+	return time.Date(date.Year(), date.Month(), date.Day(), wt.Hour, wt.Minute, 0, 0, date.Location())
 }
@@ -0,0 +1,141 @@
+package butterflymx
+
+import (
+	"encoding/json/v2"
+	"math/rand/v2"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+// propertyTimezones are timezones with interesting DST rules: none at all,
+// the usual one hour shifts in both hemispheres, DST starting at midnight,
+// half hour offsets and a half hour DST shift.
+var propertyTimezones = []string{
+	"UTC",
+	"America/New_York",
+	"Europe/London",
+	"America/Sao_Paulo",
+	"America/Havana",
+	"Asia/Kolkata",
+	"Australia/Lord_Howe",
+	"Pacific/Chatham",
+}
+
+// forRandomTimes calls fn with random times in every timezone in
+// propertyTimezones, plus times around every DST transition of those
+// timezones in a few years known to have transitions at midnight.
+func forRandomTimes(t *testing.T, fn func(t *testing.T, tm time.Time)) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	end := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	for _, name := range propertyTimezones {
+		tz, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			for range 500 {
+				fn(t, time.Unix(start+rng.Int64N(end-start), 0).In(tz))
+			}
+
+			for _, year := range []int{2000, 2018, 2024} {
+				tm := time.Date(year, 1, 1, 0, 0, 0, 0, tz)
+				for tm.Year() == year {
+					next := tm.Add(time.Hour)
+					if offsetOf(tm) != offsetOf(next) {
+						for d := -2 * time.Hour; d <= 2*time.Hour; d += 15 * time.Minute {
+							fn(t, tm.Add(d))
+						}
+					}
+					tm = next
+				}
+			}
+		})
+	}
+}
+
+func TestTimestamp_ToTime(t *testing.T) {
+	forRandomTimes(t, func(t *testing.T, date time.Time) {
+		for _, ts := range []Timestamp{{0, 0}, {8, 0}, {12, 30}, {23, 59}, {date.Hour(), date.Minute()}} {
+			got := ts.ToTime(date)
+
+			if got.Location() != date.Location() {
+				t.Fatalf("%v on %v: got location %v", ts, date, got.Location())
+			}
+			if got.Hour() != ts.Hour || got.Minute() != ts.Minute {
+				// The wall clock time doesn't exist on this day because of a
+				// DST gap, so time.Date moved it by the size of the gap,
+				// which is at most an hour in all tested timezones.
+				want := time.Date(date.Year(), date.Month(), date.Day(), ts.Hour, ts.Minute, 0, 0, time.UTC)
+				wallClock := got.Add(time.Duration(offsetOf(got)) * time.Second).UTC()
+				if d := wallClock.Sub(want).Abs(); d > time.Hour {
+					t.Fatalf("%v on %v: got %v, which is %v off", ts, date, got, d)
+				}
+				continue
+			}
+			if got.YearDay() != date.YearDay() {
+				t.Fatalf("%v on %v: got %v, which is on a different day", ts, date, got)
+			}
+		}
+	})
+}
+
+func TestDatestamp_ToTime(t *testing.T) {
+	forRandomTimes(t, func(t *testing.T, tm time.Time) {
+		ds := Datestamp{Year: tm.Year(), Month: tm.Month(), Day: tm.Day()}
+		got := ds.ToTime(tm.Location())
+
+		if got.Location() != tm.Location() {
+			t.Fatalf("%v: got location %v", ds, got.Location())
+		}
+		if gotDS := (Datestamp{Year: got.Year(), Month: got.Month(), Day: got.Day()}); gotDS != ds {
+			t.Fatalf("%v in %v: got %v, which is on %v", ds, tm.Location(), got, gotDS)
+		}
+		if got.After(tm) {
+			t.Fatalf("%v in %v: got %v, which is after %v", ds, tm.Location(), got, tm)
+		}
+		if before := got.Add(-time.Nanosecond); before.Day() == ds.Day {
+			t.Fatalf("%v in %v: got %v, which is not the start of the day", ds, tm.Location(), got)
+		}
+
+		text, err := ds.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var again Datestamp
+		if err := again.UnmarshalText(text); err != nil || again != ds {
+			t.Fatalf("%v: round-trip through %q gave %v, %v", ds, text, again, err)
+		}
+	})
+}
+
+func offsetOf(tm time.Time) int {
+	_, offset := tm.Zone()
+	return offset
+}
+
+func TestWeekday_JSON(t *testing.T) {
+	var weekdays []Weekday
+	err := json.Unmarshal([]byte(`["mon","thu","sun"]`), &weekdays)
+	assert.NoError(t, err)
+	assert.Equal(t, []Weekday{Monday, Thursday, Sunday}, weekdays)
+
+	b, err := json.Marshal(weekdays)
+	assert.NoError(t, err)
+	assert.Equal(t, `["mon","thu","sun"]`, string(b))
+
+	err = json.Unmarshal([]byte(`["mon","thurs"]`), &weekdays)
+	assert.IsError(t, err, ErrInvalidWeekday)
+
+	_, err = json.Marshal([]Weekday{"thurs"})
+	assert.IsError(t, err, ErrInvalidWeekday)
+
+	assert.True(t, Friday.IsValid())
+	assert.False(t, Weekday("Fri").IsValid())
+	assert.Equal(t, time.Weekday(-1), Weekday("thurs").ToTimeWeekday())
+}
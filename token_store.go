@@ -0,0 +1,82 @@
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTokenNotFound is returned by [TokenStore.Load] if no token is stored
+// under the given key.
+var ErrTokenNotFound = errors.New("butterflymx: token not found")
+
+// Keys under which tokens are kept in a [TokenStore].
+const (
+	// APITokenStoreKey is the key of the API (Rails) token, stored as is.
+	APITokenStoreKey = "api-token"
+	// OAuth2TokenStoreKey is the key of the OAuth2 token, stored as JSON.
+	OAuth2TokenStoreKey = "oauth2-token"
+	// AccessPointAliasesStoreKey is the key of the [AccessPointAliases] of
+	// the account, stored as JSON.
+	AccessPointAliasesStoreKey = "access-point-aliases"
+)
+
+// TokenStore persists tokens between runs, so that long-running processes
+// and CLIs don't have to log in or exchange tokens every time they start. A
+// TokenStore holds the tokens of a single account; use one store per account.
+// [MemoryTokenStore] is an in-memory implementation, and package keyring
+// provides one backed by the OS keyring.
+//
+// Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Load returns the token stored under key, or [ErrTokenNotFound] if there
+	// is none.
+	Load(ctx context.Context, key string) ([]byte, error)
+	// Save stores token under key, replacing any existing token.
+	Save(ctx context.Context, key string, token []byte) error
+	// Delete removes the token stored under key. Deleting a token that
+	// doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryTokenStore is a [TokenStore] that keeps tokens in memory. The zero
+// value is ready to use.
+type MemoryTokenStore struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+var _ TokenStore = (*MemoryTokenStore)(nil)
+
+// Load implements [TokenStore].
+func (s *MemoryTokenStore) Load(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.m[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return append([]byte(nil), token...), nil
+}
+
+// Save implements [TokenStore].
+func (s *MemoryTokenStore) Save(ctx context.Context, key string, token []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.m == nil {
+		s.m = make(map[string][]byte)
+	}
+	s.m[key] = append([]byte(nil), token...)
+	return nil
+}
+
+// Delete implements [TokenStore].
+func (s *MemoryTokenStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, key)
+	return nil
+}
@@ -0,0 +1,91 @@
+package butterflymx
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"golang.org/x/oauth2"
+)
+
+func TestReuseStoredAPITokenSource(t *testing.T) {
+	t.Run("stored token is reused", func(t *testing.T) {
+		store := &MemoryTokenStore{}
+		assert.NoError(t, store.Save(t.Context(), APITokenStoreKey, []byte("stored")))
+
+		script := NewTokenSourceScript(t, TokenSourceStep{Token: "fresh"})
+		src := ReuseStoredAPITokenSource(script, store)
+
+		token, err := src.APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "stored", token)
+
+		token, err = src.APIToken(t.Context(), true)
+		assert.NoError(t, err)
+		assert.Equal(t, "fresh", token)
+
+		stored, err := store.Load(t.Context(), APITokenStoreKey)
+		assert.NoError(t, err)
+		assert.Equal(t, "fresh", string(stored))
+	})
+
+	t.Run("new token is saved", func(t *testing.T) {
+		store := &MemoryTokenStore{}
+		script := NewTokenSourceScript(t, TokenSourceStep{Token: "first"})
+
+		token, err := ReuseStoredAPITokenSource(script, store).APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", token)
+
+		// A new process picks up the saved token without an exchange.
+		token, err = ReuseStoredAPITokenSource(NewTokenSourceScript(t), store).APIToken(t.Context(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", token)
+	})
+
+	t.Run("auth error deletes stored token", func(t *testing.T) {
+		store := &MemoryTokenStore{}
+		assert.NoError(t, store.Save(t.Context(), APITokenStoreKey, []byte("stored")))
+
+		authErr := &AuthError{Failure: AuthFailureRefreshTokenExpired}
+		script := NewTokenSourceScript(t, TokenSourceStep{Err: authErr})
+
+		_, err := ReuseStoredAPITokenSource(script, store).APIToken(t.Context(), true)
+		assert.IsError(t, err, authErr)
+
+		_, err = store.Load(t.Context(), APITokenStoreKey)
+		assert.IsError(t, err, ErrTokenNotFound)
+	})
+}
+
+func TestStoreOAuth2TokenSource(t *testing.T) {
+	store := &MemoryTokenStore{}
+
+	_, err := LoadOAuth2Token(t.Context(), store)
+	assert.IsError(t, err, ErrTokenNotFound)
+
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", Expiry: expiry}
+
+	src := StoreOAuth2TokenSource(oauth2.StaticTokenSource(want), store)
+	_, err = src.Token()
+	assert.NoError(t, err)
+
+	got, err := LoadOAuth2Token(t.Context(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, want.AccessToken, got.AccessToken)
+	assert.Equal(t, want.RefreshToken, got.RefreshToken)
+	assert.True(t, want.Expiry.Equal(got.Expiry))
+
+	errStore := errors.New("keyring locked")
+	_, err = StoreOAuth2TokenSource(oauth2.StaticTokenSource(want), failingTokenStore{errStore}).Token()
+	assert.IsError(t, err, errStore)
+}
+
+type failingTokenStore struct{ err error }
+
+func (s failingTokenStore) Load(ctx context.Context, key string) ([]byte, error)     { return nil, s.err }
+func (s failingTokenStore) Save(ctx context.Context, key string, token []byte) error { return s.err }
+func (s failingTokenStore) Delete(ctx context.Context, key string) error             { return s.err }
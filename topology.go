@@ -0,0 +1,262 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Topology is an in-memory map of the buildings visible to an account, the
+// panels in them and the access points that can be unlocked in them. It is
+// built from several endpoints, since no single endpoint describes the whole
+// picture. Use [BuildTopology] to create one.
+//
+// The API doesn't expose which panel belongs to which access point, and their
+// IDs differ, so panels and access points are only related through their
+// building.
+//
+// A Topology must not be modified while it is being read from other
+// goroutines.
+type Topology struct {
+	buildings    map[ID]*TopologyBuilding
+	panels       map[ID]TopologyPanel
+	accessPoints map[ID]TopologyAccessPoint
+}
+
+// TopologyBuilding is a building in a [Topology].
+type TopologyBuilding struct {
+	// Building is the building. If the building was only seen through a
+	// panel, only its ID is known.
+	Building Building
+	// PanelIDs are the IDs of the panels in the building, sorted.
+	PanelIDs []ID
+	// AccessPointIDs are the numeric IDs of the access points in the
+	// building, sorted.
+	AccessPointIDs []ID
+}
+
+func (b *TopologyBuilding) clone() TopologyBuilding {
+	return TopologyBuilding{
+		Building:       b.Building,
+		PanelIDs:       slices.Clone(b.PanelIDs),
+		AccessPointIDs: slices.Clone(b.AccessPointIDs),
+	}
+}
+
+// TopologyPanel is a panel in a [Topology].
+type TopologyPanel struct {
+	ID   ID
+	Name string
+	// BuildingID is the numeric ID of the building the panel is in, or 0 if
+	// unknown.
+	BuildingID ID
+}
+
+// TopologyAccessPoint is an access point in a [Topology].
+type TopologyAccessPoint struct {
+	AccessPoint AccessPoint
+	// BuildingID is the numeric ID of the building the access point is in.
+	BuildingID ID
+}
+
+// NewTopology creates an empty [Topology]. Use [Topology.AddTenant] and
+// [Topology.AddKeychains] to fill it in.
+func NewTopology() *Topology {
+	return &Topology{
+		buildings:    make(map[ID]*TopologyBuilding),
+		panels:       make(map[ID]TopologyPanel),
+		accessPoints: make(map[ID]TopologyAccessPoint),
+	}
+}
+
+// BuildTopology builds a [Topology] of everything visible to the client. It
+// fetches all tenants along with their access points, and the active
+// keychains of every tenant to discover the panels. The options are passed to
+// every API call made.
+func BuildTopology(ctx context.Context, client Client, opts ...CallOption) (*Topology, error) {
+	topo := NewTopology()
+
+	tenants, err := CollectResults(client.Tenants(ctx, opts...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tenants: %w", err)
+	}
+
+	for _, tenant := range tenants {
+		accessPoints, err := CollectResults(client.TenantAccessPoints(ctx, tenant.ID, opts...))
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch access points of tenant %s: %w", tenant.ID, err)
+		}
+		topo.AddTenant(tenant, accessPoints)
+
+		keychains, err := client.Keychains(ctx, tenant.ID.Number, ActiveAccessCode, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch keychains of tenant %s: %w", tenant.ID, err)
+		}
+		if err := topo.AddKeychains(keychains); err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tenant.ID, err)
+		}
+	}
+
+	return topo, nil
+}
+
+// AddTenant adds the building of the tenant and the given access points of
+// the tenant, which are assumed to be in that building.
+func (t *Topology) AddTenant(tenant Tenant, accessPoints []AccessPoint) {
+	building := t.building(tenant.Building.ID.Number)
+	building.Building = tenant.Building
+
+	for _, ap := range accessPoints {
+		t.accessPoints[ap.ID.Number] = TopologyAccessPoint{
+			AccessPoint: ap,
+			BuildingID:  tenant.Building.ID.Number,
+		}
+		building.AccessPointIDs = insertSorted(building.AccessPointIDs, ap.ID.Number)
+	}
+}
+
+// AddKeychains adds every panel found in the references of the keychains,
+// such as the devices of the keychains and the panels of their door releases.
+func (t *Topology) AddKeychains(keychains *ResultsWithReferences[Keychain]) error {
+	for id, ref := range keychains.Refs {
+		if ref.Type != TypePanel {
+			continue
+		}
+
+		panel, err := (&TypedReference[Panel]{ID: id, Type: TypePanel}).Resolve(keychains.Refs)
+		if err != nil {
+			return fmt.Errorf("panel %d: %w", id, err)
+		}
+		t.addPanel(panel)
+	}
+	return nil
+}
+
+func (t *Topology) addPanel(panel *Panel) {
+	p := TopologyPanel{ID: panel.ID, Name: panel.Attributes.Name}
+	if ref := panel.Relationships.Building.Data; ref != nil {
+		p.BuildingID = ref.ID
+		building := t.building(ref.ID)
+		building.PanelIDs = insertSorted(building.PanelIDs, panel.ID)
+	}
+	t.panels[panel.ID] = p
+}
+
+// building returns the building with the given numeric ID, adding it if it
+// doesn't exist yet.
+func (t *Topology) building(id ID) *TopologyBuilding {
+	building, ok := t.buildings[id]
+	if !ok {
+		building = &TopologyBuilding{Building: Building{ID: BuildingTaggedID(id)}}
+		t.buildings[id] = building
+	}
+	return building
+}
+
+// Buildings returns all buildings, sorted by name and then by ID.
+func (t *Topology) Buildings() []TopologyBuilding {
+	buildings := make([]TopologyBuilding, 0, len(t.buildings))
+	for _, building := range t.buildings {
+		buildings = append(buildings, building.clone())
+	}
+	slices.SortFunc(buildings, func(a, b TopologyBuilding) int {
+		return cmp.Or(
+			cmp.Compare(a.Building.Name, b.Building.Name),
+			cmp.Compare(a.Building.ID.Number, b.Building.ID.Number))
+	})
+	return buildings
+}
+
+// Building returns the building with the given numeric ID.
+func (t *Topology) Building(id ID) (TopologyBuilding, bool) {
+	building, ok := t.buildings[id]
+	if !ok {
+		return TopologyBuilding{}, false
+	}
+	return building.clone(), true
+}
+
+// Panel returns the panel with the given ID.
+func (t *Topology) Panel(id ID) (TopologyPanel, bool) {
+	panel, ok := t.panels[id]
+	return panel, ok
+}
+
+// Panels returns all panels, sorted by ID.
+func (t *Topology) Panels() []TopologyPanel {
+	return slices.SortedFunc(maps.Values(t.panels), func(a, b TopologyPanel) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// AccessPoint returns the access point with the given numeric ID.
+func (t *Topology) AccessPoint(id ID) (TopologyAccessPoint, bool) {
+	ap, ok := t.accessPoints[id]
+	return ap, ok
+}
+
+// BuildingOfAccessPoint returns the building that the access point with the
+// given numeric ID is in.
+func (t *Topology) BuildingOfAccessPoint(id ID) (TopologyBuilding, bool) {
+	ap, ok := t.accessPoints[id]
+	if !ok {
+		return TopologyBuilding{}, false
+	}
+	return t.Building(ap.BuildingID)
+}
+
+// BuildingOfPanel returns the building that the panel with the given ID is
+// in.
+func (t *Topology) BuildingOfPanel(id ID) (TopologyBuilding, bool) {
+	panel, ok := t.panels[id]
+	if !ok || panel.BuildingID == 0 {
+		return TopologyBuilding{}, false
+	}
+	return t.Building(panel.BuildingID)
+}
+
+// OpenDurationOfPanel returns how long a door released at the panel with the
+// given ID stays unlocked, taken as the longest [AccessPoint.OpenDuration], in
+// seconds, of the access points in the panel's building. Since panels aren't
+// related to access points directly, this is an upper bound. It returns false
+// if the building of the panel or its access points are unknown.
+func (t *Topology) OpenDurationOfPanel(id ID) (time.Duration, bool) {
+	building, ok := t.BuildingOfPanel(id)
+	if !ok {
+		return 0, false
+	}
+
+	var longest int
+	for _, apID := range building.AccessPointIDs {
+		longest = max(longest, t.accessPoints[apID].AccessPoint.OpenDuration)
+	}
+	if longest == 0 {
+		return 0, false
+	}
+	return time.Duration(longest) * time.Second, true
+}
+
+// PanelOfDoorRelease returns the panel that the door release was made at.
+func (t *Topology) PanelOfDoorRelease(release *DoorRelease) (TopologyPanel, bool) {
+	ref := release.Relationships.Panel.Data
+	if ref == nil {
+		ref = release.Relationships.Device.Data
+	}
+	if ref == nil {
+		return TopologyPanel{}, false
+	}
+	return t.Panel(ref.ID)
+}
+
+func insertSorted(ids []ID, id ID) []ID {
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
@@ -0,0 +1,87 @@
+package butterflymx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestBuildTopology(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	hunter := butterflymx.Building{ID: butterflymx.BuildingTaggedID(300), Name: "Hunter Capital"}
+	annex := butterflymx.Building{ID: butterflymx.BuildingTaggedID(301), Name: "Annex"}
+	frontDoor := butterflymx.AccessPoint{ID: butterflymx.AccessPointTaggedID(400), Name: "Front Door"}
+	garage := butterflymx.AccessPoint{ID: butterflymx.AccessPointTaggedID(401), Name: "Garage"}
+	lobby := butterflymx.AccessPoint{ID: butterflymx.AccessPointTaggedID(402), Name: "Lobby"}
+
+	fake := fakebmx.New(&bmxtest.Data{
+		Tenants: []bmxtest.Tenant{
+			{
+				Tenant: butterflymx.Tenant{
+					ID:       butterflymx.TenantTaggedID(100),
+					Name:     "Jane",
+					Unit:     butterflymx.Unit{ID: butterflymx.UnitTaggedID(200), Label: "Apt 1"},
+					Building: hunter,
+				},
+				AccessPoints: []butterflymx.AccessPoint{garage, frontDoor},
+				Keychains: []bmxtest.Keychain{{
+					ID:             1000,
+					Name:           "Cleaner",
+					Kind:           butterflymx.CustomKeychain,
+					EndsAt:         now.Add(24 * time.Hour),
+					AccessPointIDs: []butterflymx.ID{400},
+					VirtualKeys: []bmxtest.VirtualKey{{
+						ID:           1001,
+						Name:         "cleaner@example.com",
+						PINCode:      "5678",
+						DoorReleases: []bmxtest.DoorRelease{{ID: 1002, AccessPointID: 400, Name: "Cleaner", LoggedAt: now}},
+					}},
+				}},
+			},
+			{
+				Tenant: butterflymx.Tenant{
+					ID:       butterflymx.TenantTaggedID(101),
+					Name:     "John",
+					Unit:     butterflymx.Unit{ID: butterflymx.UnitTaggedID(201), Label: "Apt 2"},
+					Building: annex,
+				},
+				AccessPoints: []butterflymx.AccessPoint{lobby},
+			},
+		},
+	})
+	fake.Now = func() time.Time { return now }
+
+	topo, err := butterflymx.BuildTopology(t.Context(), fake)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []butterflymx.TopologyBuilding{
+		{Building: annex, AccessPointIDs: []butterflymx.ID{402}},
+		{Building: hunter, PanelIDs: []butterflymx.ID{400}, AccessPointIDs: []butterflymx.ID{400, 401}},
+	}, topo.Buildings())
+
+	building, ok := topo.BuildingOfAccessPoint(401)
+	assert.True(t, ok)
+	assert.Equal(t, hunter, building.Building)
+
+	building, ok = topo.BuildingOfPanel(400)
+	assert.True(t, ok)
+	assert.Equal(t, hunter, building.Building)
+
+	_, ok = topo.BuildingOfAccessPoint(999)
+	assert.False(t, ok)
+
+	keychain, err := fake.Keychain(t.Context(), 1000)
+	assert.NoError(t, err)
+	virtualKey, err := keychain.Data.Relationships.VirtualKeys[0].Resolve(keychain.Refs)
+	assert.NoError(t, err)
+	release, err := virtualKey.Relationships.DoorReleases[0].Resolve(keychain.Refs)
+	assert.NoError(t, err)
+
+	panel, ok := topo.PanelOfDoorRelease(release)
+	assert.True(t, ok)
+	assert.Equal(t, butterflymx.TopologyPanel{ID: 400, Name: "Front Door", BuildingID: 300}, panel)
+}
@@ -0,0 +1,44 @@
+package butterflymx
+
+import (
+	"net/http"
+	"time"
+)
+
+// TransportOpts tunes the connection pool of the HTTP client created by
+// [NewHTTPClient].
+type TransportOpts struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each
+	// API host. The default of [http.DefaultTransport] is only 2, which makes
+	// high-frequency watchers open and close connections constantly and can
+	// exhaust ephemeral ports. Defaults to 16.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept open. Defaults
+	// to 90s.
+	IdleConnTimeout time.Duration
+	// ForceHTTP2 disables HTTP/1.1, so that all requests to a host share a
+	// single multiplexed connection. Requests fail if the server doesn't
+	// support HTTP/2.
+	ForceHTTP2 bool
+}
+
+// NewHTTPClient creates an HTTP client suitable for [APIClientOpts.HTTPClient]
+// with a connection pool tuned by opts. It is based on
+// [http.DefaultTransport], so proxy settings from the environment are still
+// honored.
+func NewHTTPClient(opts *TransportOpts) *http.Client {
+	opts = use(opts, &TransportOpts{})
+	opts.MaxIdleConnsPerHost = use(opts.MaxIdleConnsPerHost, 16)
+	opts.IdleConnTimeout = use(opts.IdleConnTimeout, 90*time.Second)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, opts.MaxIdleConnsPerHost)
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	if opts.ForceHTTP2 {
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+	}
+
+	return &http.Client{Transport: transport}
+}
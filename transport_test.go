@@ -0,0 +1,38 @@
+package butterflymx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		transport := NewHTTPClient(nil).Transport.(*http.Transport)
+		assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, 90*time.Second, transport.IdleConnTimeout)
+		assert.True(t, transport.Protocols == nil)
+	})
+
+	t.Run("force HTTP/2", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}))
+		server.EnableHTTP2 = true
+		server.StartTLS()
+		defer server.Close()
+
+		client := NewHTTPClient(&TransportOpts{ForceHTTP2: true, MaxIdleConnsPerHost: 4})
+		transport := client.Transport.(*http.Transport)
+		transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+		assert.Equal(t, 4, transport.MaxIdleConnsPerHost)
+
+		resp, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, 2, resp.ProtoMajor)
+	})
+}
@@ -0,0 +1,111 @@
+//go:build goexperiment.jsonv2
+
+package butterflymx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// UnitAccessSummary describes who can get into a unit: the tenants living in
+// it, the PIN codes and active keychains they hold, and the door releases
+// made with those keychains recently.
+type UnitAccessSummary struct {
+	Unit     Unit
+	Building Building
+	Tenants  []TenantAccessSummary
+}
+
+// TenantAccessSummary is the part of a [UnitAccessSummary] for one tenant.
+type TenantAccessSummary struct {
+	// Tenant is the tenant. Its PINCode is the tenant's own PIN code.
+	Tenant Tenant
+	// Keychains are the tenant's active keychains.
+	Keychains []Keychain
+	// DoorReleases are the door releases made using the virtual keys of
+	// Keychains since the requested time, newest first.
+	DoorReleases []DoorRelease
+	// Refs holds the references of Keychains and DoorReleases, which can be
+	// used to resolve their relationships.
+	Refs map[ID]RawReference
+}
+
+// SummarizeUnitAccess builds a [UnitAccessSummary] of the unit with the given
+// numeric ID, including door releases logged at or after since. It returns an
+// error if none of the client's tenants live in the unit.
+//
+// The keychains of the tenants are fetched concurrently. The options are
+// passed to every API call made.
+func SummarizeUnitAccess(ctx context.Context, client Client, unitID ID, since time.Time, opts ...CallOption) (*UnitAccessSummary, error) {
+	var summary UnitAccessSummary
+	for tenant, err := range client.Tenants(ctx, opts...) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch tenants: %w", err)
+		}
+		if tenant.Unit.ID.Number != unitID {
+			continue
+		}
+		summary.Unit = tenant.Unit
+		summary.Building = tenant.Building
+		summary.Tenants = append(summary.Tenants, TenantAccessSummary{Tenant: tenant})
+	}
+	if len(summary.Tenants) == 0 {
+		return nil, fmt.Errorf("no tenant found in unit %d", unitID)
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+
+	for i := range summary.Tenants {
+		tenant := &summary.Tenants[i]
+		g.Go(func() error {
+			keychains, err := client.Keychains(ctx, tenant.Tenant.ID.Number, ActiveAccessCode, opts...)
+			if err != nil {
+				return fmt.Errorf("failed to fetch keychains of tenant %s: %w", tenant.Tenant.ID, err)
+			}
+			tenant.Keychains = keychains.Data
+			tenant.Refs = keychains.Refs
+
+			tenant.DoorReleases, err = keychainDoorReleases(keychains, since)
+			if err != nil {
+				return fmt.Errorf("tenant %s: %w", tenant.Tenant.ID, err)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// keychainDoorReleases returns the door releases of all virtual keys of the
+// given keychains logged at or after since, newest first.
+func keychainDoorReleases(keychains *ResultsWithReferences[Keychain], since time.Time) ([]DoorRelease, error) {
+	var releases []DoorRelease
+	for _, keychain := range keychains.Data {
+		for virtualKey, err := range keychain.Relationships.VirtualKeys.Resolve(keychains.Refs) {
+			if err != nil {
+				return nil, fmt.Errorf("keychain %d: %w", keychain.ID, err)
+			}
+			for release, err := range virtualKey.Relationships.DoorReleases.Resolve(keychains.Refs) {
+				if err != nil {
+					return nil, fmt.Errorf("virtual key %d: %w", virtualKey.ID, err)
+				}
+				if !release.Attributes.LoggedAt.Before(since) {
+					releases = append(releases, *release)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(releases, func(a, b DoorRelease) int {
+		return b.Attributes.LoggedAt.Compare(a.Attributes.LoggedAt)
+	})
+	return releases, nil
+}
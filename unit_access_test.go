@@ -0,0 +1,92 @@
+package butterflymx_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+	"libdb.so/go-butterflymx"
+	"libdb.so/go-butterflymx/bmxtest"
+	"libdb.so/go-butterflymx/fakebmx"
+)
+
+func TestSummarizeUnitAccess(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	unit := butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 200), Label: "Apt 1"}
+	building := butterflymx.Building{ID: butterflymx.NewTaggedID("building", 300), Name: "Building"}
+	frontDoor := butterflymx.AccessPoint{ID: butterflymx.NewTaggedID("access_point", 400), Name: "Front Door"}
+
+	newTenant := func(id butterflymx.ID, name string, unit butterflymx.Unit, keychains ...bmxtest.Keychain) bmxtest.Tenant {
+		return bmxtest.Tenant{
+			Tenant: butterflymx.Tenant{
+				ID:       butterflymx.NewTaggedID("tenant", id),
+				Name:     name,
+				PINCode:  "1234",
+				Unit:     unit,
+				Building: building,
+			},
+			AccessPoints: []butterflymx.AccessPoint{frontDoor},
+			Keychains:    keychains,
+		}
+	}
+
+	fake := fakebmx.New(&bmxtest.Data{
+		Tenants: []bmxtest.Tenant{
+			newTenant(100, "Jane", unit,
+				bmxtest.Keychain{
+					ID:             1000,
+					Name:           "Cleaner",
+					Kind:           butterflymx.CustomKeychain,
+					EndsAt:         now.Add(24 * time.Hour),
+					AccessPointIDs: []butterflymx.ID{400},
+					VirtualKeys: []bmxtest.VirtualKey{{
+						ID:      1001,
+						Name:    "cleaner@example.com",
+						PINCode: "5678",
+						DoorReleases: []bmxtest.DoorRelease{
+							{ID: 1002, AccessPointID: 400, Name: "Cleaner", LoggedAt: now.Add(-48 * time.Hour)},
+							{ID: 1003, AccessPointID: 400, Name: "Cleaner", LoggedAt: now.Add(-2 * time.Hour)},
+							{ID: 1004, AccessPointID: 400, Name: "Cleaner", LoggedAt: now.Add(-1 * time.Hour)},
+						},
+					}},
+				},
+				bmxtest.Keychain{
+					ID:     2000,
+					Name:   "Expired",
+					Kind:   butterflymx.CustomKeychain,
+					EndsAt: now.Add(-time.Hour),
+				},
+			),
+			newTenant(101, "John", unit),
+			newTenant(102, "Neighbor", butterflymx.Unit{ID: butterflymx.NewTaggedID("unit", 201), Label: "Apt 2"}),
+		},
+	})
+	fake.Now = func() time.Time { return now }
+
+	summary, err := butterflymx.SummarizeUnitAccess(t.Context(), fake, 200, now.Add(-24*time.Hour))
+	assert.NoError(t, err)
+
+	assert.Equal(t, unit, summary.Unit)
+	assert.Equal(t, building, summary.Building)
+	assert.Equal(t, 2, len(summary.Tenants))
+
+	jane := summary.Tenants[0]
+	assert.Equal(t, "Jane", jane.Tenant.Name)
+	assert.Equal(t, butterflymx.PINCode("1234"), jane.Tenant.PINCode)
+	assert.Equal(t, 1, len(jane.Keychains))
+	assert.Equal(t, butterflymx.ID(1000), jane.Keychains[0].ID)
+
+	var releaseIDs []butterflymx.ID
+	for _, release := range jane.DoorReleases {
+		releaseIDs = append(releaseIDs, release.ID)
+	}
+	assert.Equal(t, []butterflymx.ID{1004, 1003}, releaseIDs, "recent releases should be newest first")
+
+	john := summary.Tenants[1]
+	assert.Equal(t, "John", john.Tenant.Name)
+	assert.Zero(t, john.Keychains)
+	assert.Zero(t, john.DoorReleases)
+
+	_, err = butterflymx.SummarizeUnitAccess(t.Context(), fake, 999, now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+// Package webhook signs and verifies webhook requests carrying door releases
+// and other events, so that notifiers forwarding polled events and the
+// receivers consuming them share the same security primitives.
+//
+// A request is signed using HMAC-SHA256 over its timestamp and body with a
+// secret shared by both ends. The signature is sent in the [SignatureHeader]
+// header in the form "t=<unix seconds>,v1=<hex signature>". Receivers reject
+// requests whose timestamp is too old, as well as requests that were already
+// seen, to protect against replays.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the HTTP header holding the signature of a request.
+const SignatureHeader = "X-Butterflymx-Signature"
+
+// DefaultTolerance is the default [Verifier.Tolerance].
+const DefaultTolerance = 5 * time.Minute
+
+// DefaultMaxBodySize is the default [Verifier.MaxBodySize].
+const DefaultMaxBodySize = 1 << 20 // 1 MiB
+
+var (
+	// ErrInvalidSignature is returned if a signature is missing, malformed or
+	// doesn't match the body.
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	// ErrExpired is returned if the timestamp of a signature is outside of
+	// [Verifier.Tolerance].
+	ErrExpired = errors.New("webhook: signature expired")
+	// ErrReplayed is returned if a signature was already verified before.
+	ErrReplayed = errors.New("webhook: request replayed")
+	// ErrBodyTooLarge is returned if the body of a request is larger than
+	// [Verifier.MaxBodySize].
+	ErrBodyTooLarge = errors.New("webhook: body too large")
+)
+
+// Sign returns the value of [SignatureHeader] for body sent at time t.
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := t.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac(secret, ts, body)))
+}
+
+// SignRequest signs req, whose body is body, by setting its
+// [SignatureHeader] using the current time.
+func SignRequest(req *http.Request, secret []byte, body []byte) {
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+}
+
+func mac(secret []byte, ts int64, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(strconv.FormatInt(ts, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verifier verifies signed webhook requests. A Verifier remembers the
+// signatures it has verified within the tolerance, so a single Verifier should
+// be used for all requests of a receiver.
+//
+// A Verifier is safe for concurrent use, but its fields must not be modified
+// once it is in use.
+type Verifier struct {
+	// Secret is the secret shared with the sender.
+	Secret []byte
+	// Tolerance is how far the timestamp of a signature may be from the
+	// current time. It defaults to [DefaultTolerance].
+	Tolerance time.Duration
+	// Now returns the current time. It defaults to [time.Now].
+	Now func() time.Time
+	// MaxBodySize is the maximum size of a request body read by
+	// [Verifier.VerifyRequest]. Since the body is read before its signature
+	// is checked, this keeps unauthenticated clients from making the receiver
+	// buffer arbitrarily large bodies. It defaults to [DefaultMaxBodySize],
+	// negative for no limit.
+	MaxBodySize int64
+
+	mu   sync.Mutex
+	seen map[string]time.Time // signature -> timestamp
+}
+
+// Verify checks that header, the value of [SignatureHeader], is a valid
+// signature of body that is recent and wasn't verified before. The error
+// wraps [ErrInvalidSignature], [ErrExpired] or [ErrReplayed].
+func (v *Verifier) Verify(header string, body []byte) error {
+	ts, sig, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, mac(v.Secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+
+	t := time.Unix(ts, 0)
+	if t.Before(now.Add(-tolerance)) || t.After(now.Add(tolerance)) {
+		return fmt.Errorf("%w: signed at %v", ErrExpired, t)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// Signatures older than the tolerance are rejected anyway, so they don't
+	// need to be remembered.
+	for seen, seenAt := range v.seen {
+		if seenAt.Before(now.Add(-tolerance)) {
+			delete(v.seen, seen)
+		}
+	}
+
+	key := string(sig)
+	if _, ok := v.seen[key]; ok {
+		return ErrReplayed
+	}
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	v.seen[key] = t
+
+	return nil
+}
+
+// VerifyRequest reads the body of req and verifies it using
+// [Verifier.Verify]. The body is returned and also restored in req, so that
+// it can be read again. Bodies larger than [Verifier.MaxBodySize] are rejected
+// with [ErrBodyTooLarge].
+func (v *Verifier) VerifyRequest(req *http.Request) ([]byte, error) {
+	maxBodySize := v.MaxBodySize
+	if maxBodySize == 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	r := req.Body
+	if maxBodySize > 0 {
+		r = http.MaxBytesReader(nil, req.Body, maxBodySize)
+	}
+	body, err := io.ReadAll(r)
+	req.Body.Close()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrBodyTooLarge, maxBodySize)
+		}
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := v.Verify(req.Header.Get(SignatureHeader), body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Handler returns a handler that only passes requests to next if they are
+// signed correctly. Other requests are rejected with 401 Unauthorized, or 413
+// Request Entity Too Large if their body is too large.
+func (v *Verifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.VerifyRequest(r); err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrBodyTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func parseHeader(header string) (int64, []byte, error) {
+	var ts int64
+	var sig []byte
+	for part := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		var err error
+		switch key {
+		case "t":
+			ts, err = strconv.ParseInt(value, 10, 64)
+		case "v1":
+			sig, err = hex.DecodeString(value)
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("%w: malformed %q: %v", ErrInvalidSignature, key, err)
+		}
+	}
+	if ts == 0 || sig == nil {
+		return 0, nil, fmt.Errorf("%w: missing timestamp or signature", ErrInvalidSignature)
+	}
+	return ts, sig, nil
+}
@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestVerifier(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"event":"door_release"}`)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newVerifier := func() *Verifier {
+		return &Verifier{Secret: secret, Now: func() time.Time { return now }}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		v := newVerifier()
+		assert.NoError(t, v.Verify(Sign(secret, now.Add(-time.Minute), body), body))
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		v := newVerifier()
+		header := Sign(secret, now, body)
+		assert.NoError(t, v.Verify(header, body))
+		assert.IsError(t, v.Verify(header, body), ErrReplayed)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		v := newVerifier()
+		assert.IsError(t, v.Verify(Sign(secret, now.Add(-DefaultTolerance-time.Second), body), body), ErrExpired)
+		assert.IsError(t, v.Verify(Sign(secret, now.Add(DefaultTolerance+time.Second), body), body), ErrExpired)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		v := newVerifier()
+		tests := []string{
+			"",
+			"t=1735689600",
+			"t=abc,v1=00",
+			"t=1735689600,v1=zz",
+			Sign([]byte("other"), now, body),
+			strings.Replace(Sign(secret, now, body), "t=1735689600", "t=1735689601", 1),
+		}
+		for _, header := range tests {
+			assert.IsError(t, v.Verify(header, body), ErrInvalidSignature, "header %q", header)
+		}
+		assert.IsError(t, v.Verify(Sign(secret, now, body), []byte("{}")), ErrInvalidSignature)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		v := newVerifier()
+		v.Now = nil
+
+		var got string
+		handler := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := io.ReadAll(r.Body)
+			assert.NoError(t, err)
+			got = string(b)
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		SignRequest(req, secret, body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, string(body), got, "body should be readable by the next handler")
+
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		v := newVerifier()
+		v.MaxBodySize = int64(len(body)) - 1
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		req.Header.Set(SignatureHeader, Sign(secret, now, body))
+		_, err := v.VerifyRequest(req)
+		assert.IsError(t, err, ErrBodyTooLarge)
+
+		handler := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("next handler should not be called")
+		}))
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		req.Header.Set(SignatureHeader, Sign(secret, now, body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+}